@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/logging"
+	"github.com/samcm/ts-discord-status/internal/logsample"
+	"github.com/samcm/ts-discord-status/internal/pipeline"
+)
+
+var (
+	auditConfigPath string
+	auditFix        bool
+)
+
+// errAuditMismatch is returned when the status message does not match the
+// live state and was not repaired, so scripts can tell from the exit code.
+var errAuditMismatch = errors.New("status message does not match the live state")
+
+func init() {
+	auditCmd.Flags().StringVarP(&auditConfigPath, "config", "c", "", "Path to configuration file (required)")
+	auditCmd.Flags().BoolVar(&auditFix, "fix", false, "Rewrite the status message from the live state when it differs (stop the bridge first)")
+	_ = auditCmd.MarkFlagRequired("config")
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Compare the Discord status message with the live TeamSpeak state",
+	Long: "Fetches the current status message, re-renders it from the live TeamSpeak state and prints the " +
+		"differences. Useful after a bug or a manual edit; --fix repairs the message the way the running bridge would. " +
+		"Only the main status channel is audited. Auditing is safe while the bridge runs, but stop it before --fix: " +
+		"a running bridge takes the repair for a second instance editing its message and stops updating it for a while.",
+	RunE: runAudit,
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(auditConfigPath, false)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	log.SetLevel(logrus.WarnLevel)
+
+	loggers, err := logging.New(log, cfg.Logging.Levels)
+	if err != nil {
+		return fmt.Errorf("invalid logging.levels: %w", err)
+	}
+
+	stages, err := displayStages(cfg)
+	if err != nil {
+		return err
+	}
+
+	display, err := displayConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	ts := teamSpeakService(loggers, cfg, logsample.New(cfg.Logging.SampleInterval, cfg.Logging.SampleBurst))
+
+	if err := ts.Start(ctx); err != nil {
+		return fmt.Errorf("failed to connect to TeamSpeak: %w", err)
+	}
+
+	defer ts.Stop()
+
+	state, err := ts.GetState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get TeamSpeak state: %w", err)
+	}
+
+	state, err = pipeline.Apply(ctx, state, stages...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare state: %w", err)
+	}
+
+	report, err := discord.Audit(ctx, loggers.For("discord"), discord.Config{
+		Token:      cfg.Discord.Token,
+		ChannelID:  cfg.Discord.StatusChannelID(),
+		WebhookURL: cfg.Discord.WebhookURL,
+		Forum: discord.Forum{
+			Title:      cfg.Discord.Forum.Title,
+			OnlineTag:  cfg.Discord.Forum.OnlineTag,
+			OfflineTag: cfg.Discord.Forum.OfflineTag,
+		},
+	}, display, state, auditFix)
+	if err != nil {
+		return err
+	}
+
+	printAudit(report)
+
+	if !report.Consistent() && !report.Fixed {
+		return errAuditMismatch
+	}
+
+	return nil
+}
+
+// printAudit prints the audit findings.
+func printAudit(report discord.AuditReport) {
+	if report.MessageID != "" {
+		fmt.Printf("Status message %s in channel %s\n", report.MessageID, report.ChannelID)
+	}
+
+	if report.Consistent() {
+		fmt.Println("✅ The status message matches the live state")
+
+		return
+	}
+
+	for _, p := range report.Problems {
+		fmt.Println("⚠️  " + p)
+	}
+
+	if report.Diff != "" {
+		fmt.Println("\nDifferences from the message to the live state:")
+		fmt.Print(report.Diff)
+	}
+
+	if report.Fixed {
+		fmt.Println("\n🔧 Rewrote the status message from the live state")
+	}
+}
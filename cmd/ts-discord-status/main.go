@@ -2,30 +2,50 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 	_ "time/tzdata" // embed the timezone database for the recap in distroless
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/samcm/ts-discord-status/internal/a2s"
+	"github.com/samcm/ts-discord-status/internal/api"
 	"github.com/samcm/ts-discord-status/internal/bridge"
 	"github.com/samcm/ts-discord-status/internal/config"
+	"github.com/samcm/ts-discord-status/internal/contentfilter"
 	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/errreport"
+	"github.com/samcm/ts-discord-status/internal/hosted"
+	"github.com/samcm/ts-discord-status/internal/jsonsource"
+	"github.com/samcm/ts-discord-status/internal/logging"
+	"github.com/samcm/ts-discord-status/internal/logsample"
+	"github.com/samcm/ts-discord-status/internal/minecraft"
+	"github.com/samcm/ts-discord-status/internal/mumble"
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/pipeline"
+	"github.com/samcm/ts-discord-status/internal/render"
 	"github.com/samcm/ts-discord-status/internal/store"
 	"github.com/samcm/ts-discord-status/internal/teamspeak"
 )
 
 var (
-	configPath string
-	dryRun     bool
+	configPath   string
+	dryRun       bool
+	strictConfig bool
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -42,13 +62,14 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (required)")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Fetch TeamSpeak state and print what would be posted, without connecting to Discord")
+	rootCmd.Flags().BoolVar(&strictConfig, "strict-config", false, "Fail on unknown configuration keys instead of warning")
 
 	rootCmd.MarkFlagRequired("config")
 }
 
 func run(cmd *cobra.Command, args []string) error {
 	// Load configuration
-	cfg, err := config.Load(configPath)
+	cfg, err := config.Load(configPath, strictConfig)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -66,45 +87,145 @@ func run(cmd *cobra.Command, args []string) error {
 		FullTimestamp: true,
 	})
 
+	for _, w := range cfg.Warnings {
+		log.Warn(w)
+	}
+
+	loggers, err := logging.New(log, cfg.Logging.Levels)
+	if err != nil {
+		return fmt.Errorf("invalid logging.levels: %w", err)
+	}
+
+	logStartup(cmd.Context(), log, cfg)
+
+	if err := errreport.Init(log, errreport.Config{
+		DSN:             cfg.Sentry.DSN,
+		Environment:     cfg.Sentry.Environment,
+		Release:         version,
+		Tags:            reportTags(cfg),
+		RepeatThreshold: cfg.Sentry.RepeatThreshold,
+		RepeatWindow:    cfg.Sentry.RepeatWindow,
+	}); err != nil {
+		return err
+	}
+
+	defer errreport.Flush()
+	defer errreport.Recover()
+
+	sampler := logsample.New(cfg.Logging.SampleInterval, cfg.Logging.SampleBurst)
+
 	// Create TeamSpeak service
-	tsService := teamspeak.NewService(log, teamspeak.Config{
-		Host:      cfg.TeamSpeak.Host,
-		QueryPort: cfg.TeamSpeak.QueryPort,
-		Username:  cfg.TeamSpeak.Username,
-		Password:  cfg.TeamSpeak.Password,
-		ServerID:  cfg.TeamSpeak.ServerID,
-	})
+	tsService := teamSpeakService(loggers, cfg, sampler)
+
+	stages, err := displayStages(cfg)
+	if err != nil {
+		return err
+	}
+
+	display, err := displayConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	targets, err := discordTargets(cfg)
+	if err != nil {
+		return err
+	}
 
 	if dryRun {
-		return runDryRun(cmd.Context(), log, tsService, cfg)
+		return runDryRun(cmd.Context(), log, tsService, stages, cfg)
 	}
 
 	// Create Discord service
-	dcService := discord.NewService(log, discord.Config{
+	dcService := discord.NewService(loggers.For("discord"), discord.Config{
 		Token:     cfg.Discord.Token,
-		ChannelID: cfg.Discord.ChannelID,
-	}, discord.DisplayConfig{
-		ShowEmptyChannels: cfg.Display.ShowEmptyChannels,
-		ServerAddress:     cfg.Display.ServerInfo.Address,
-		ServerPassword:    cfg.Display.ServerInfo.Password,
-		CustomFooter:      cfg.Display.CustomFooter,
-		ChannelNameFormat: cfg.Display.ChannelNameFormat,
-		ThumbnailURL:      cfg.Display.ThumbnailURL,
-	})
+		ChannelID: cfg.Discord.StatusChannelID(),
+
+		LogEmbedDiff:     cfg.Debug.EmbedDiff,
+		ShowStateVersion: cfg.Debug.StateVersion,
+		SlashCommands:    cfg.Features.SlashCommandsEnabled(),
+		Targets:          targets,
+		WebhookURL:       cfg.Discord.WebhookURL,
+		WebhookMessageID: cfg.Discord.WebhookMessageID,
+		DetailThread:     detailThread(cfg.Discord.DetailThread),
+		Forum: discord.Forum{
+			Title:      cfg.Discord.Forum.Title,
+			OnlineTag:  cfg.Discord.Forum.OnlineTag,
+			OfflineTag: cfg.Discord.Forum.OfflineTag,
+		},
+		PublishAlerts: cfg.Discord.PublishAlerts,
+	}, display)
 
 	// Create status recorder (optional)
 	var storeService store.Service
 	if cfg.Database.Enabled {
-		storeService = store.NewService(log, store.Config{
+		if !store.Available {
+			return errNoSQLite
+		}
+
+		storeService = store.NewService(loggers.For("store"), store.Config{
 			Path:          cfg.Database.Path,
 			RetentionDays: cfg.Database.RetentionDays,
 		})
 	}
 
 	// Create bridge service
-	bridgeService := bridge.NewService(log, bridge.Config{
-		UpdateInterval: cfg.Display.UpdateInterval,
-		RecordInterval: cfg.Database.RecordInterval,
+	bridgeService := bridge.NewService(loggers.For("bridge"), bridge.Config{
+		UpdateInterval:  cfg.Display.UpdateInterval,
+		RecordInterval:  cfg.Database.RecordInterval,
+		StaleAfter:      display.StaleAfter,
+		StateHistory:    cfg.Debug.StateHistory,
+		MetricsChannels: cfg.HTTP.MetricsChannels,
+		Failover: bridge.FailoverConfig{
+			After:     cfg.Discord.FailoverAfter,
+			ChannelID: cfg.Discord.FallbackChannelID,
+			OwnerIDs:  cfg.Discord.OwnerIDs,
+		},
+		OutageAlert: bridge.OutageAlertConfig{
+			RoleID:    cfg.Alerts.DowntimeRoleID,
+			ChannelID: cmp.Or(cfg.Alerts.DowntimeChannelID, cfg.Discord.StatusChannelID()),
+			After:     cfg.Alerts.DowntimeAfter,
+		},
+		Digest: bridge.DigestConfig{
+			Enabled:  cfg.Discord.DailyDigest.Enabled,
+			At:       clockMinutes(cfg.Discord.DailyDigest.At),
+			OwnerIDs: cfg.Discord.OwnerIDs,
+		},
+		Collage: bridge.CollageConfig{
+			Enabled:  cfg.Display.AvatarCollage.Enabled,
+			MaxUsers: cfg.Display.AvatarCollage.MaxUsers,
+			TileSize: cfg.Display.AvatarCollage.TileSize,
+			Columns:  cfg.Display.AvatarCollage.Columns,
+		},
+		Subscriptions:     cfg.Notifications.DMSubscriptions,
+		SubscriptionRoles: cfg.Notifications.SubscriptionRoles,
+		TrackChanges:      anyDisplay(cfg, func(d config.DisplayConfig) bool { return d.WhatChanged }),
+		Forecast: bridge.ForecastConfig{
+			Enabled: cfg.Display.BusyForecast.Enabled || anyDisplay(cfg, func(d config.DisplayConfig) bool { return d.EmptyState.Enabled }),
+			Hint:    cfg.Display.BusyForecast.Enabled,
+			Weeks:   cfg.Display.BusyForecast.Weeks,
+		},
+		UploadIconEmojis:      cfg.Display.ChannelIcons.Upload,
+		IconsForEmptyChannels: anyDisplay(cfg, func(d config.DisplayConfig) bool { return d.ShowEmptyChannels }),
+		Stages:                stages,
+		PublicFilter:          channelFilter(cfg.Display.ChannelFilter),
+		LogSampler:            sampler,
+		AFK: bridge.AFKConfig{
+			Enabled:     cfg.AFKAlerts.Enabled,
+			IdleAfter:   cfg.AFKAlerts.IdleAfter,
+			AFKChannels: cfg.AFKAlerts.AFKChannels,
+			Poke:        cfg.AFKAlerts.Poke,
+			PokeMessage: cfg.AFKAlerts.PokeMessage,
+		},
+		Notifications: bridge.NotificationsConfig{
+			Routes: notificationRoutes(cfg),
+			Queue: notify.Config{
+				Size:        cfg.Notifications.QueueSize,
+				BatchWindow: cfg.Notifications.BatchWindow,
+			},
+			OfflineAfter:    cfg.Notifications.OfflineAfter,
+			CapacityPercent: cfg.Notifications.CapacityPercent,
+		},
 	}, tsService, dcService, storeService)
 
 	// Setup context with signal handling
@@ -118,6 +239,10 @@ func run(cmd *cobra.Command, args []string) error {
 		<-sigCh
 		log.Info("Received shutdown signal")
 		cancel()
+
+		<-sigCh
+		log.Warn("Received second shutdown signal, exiting immediately")
+		os.Exit(1)
 	}()
 
 	// Start bridge
@@ -125,12 +250,62 @@ func run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to start bridge: %w", err)
 	}
 
-	// Wait for context cancellation
+	// Start HTTP API (optional)
+	var apiService api.Service
+	if cfg.HTTP.Listen != "" {
+		apiService = api.NewService(loggers.For("api"), api.Config{
+			Listen:  cfg.HTTP.Listen,
+			Token:   cfg.HTTP.Token,
+			Metrics: cfg.HTTP.Metrics,
+			Pprof:   cfg.HTTP.Pprof,
+
+			JoinQR:      cfg.Display.Connect.QRCode,
+			JoinAddress: cfg.Display.Connect.Address,
+		}, bridgeService)
+
+		if err := apiService.Start(ctx); err != nil {
+			_ = bridgeService.Stop()
+			return fmt.Errorf("failed to start HTTP API: %w", err)
+		}
+	}
+
+	// Wait for context cancellation; in-flight updates see it and abort
 	<-ctx.Done()
 
-	// Stop bridge
-	if err := bridgeService.Stop(); err != nil {
-		log.WithError(err).Warn("Error stopping bridge")
+	stopped := make(chan struct{})
+
+	// The step in progress, logged as abandoned when the deadline passes.
+	var step atomic.Value
+
+	go func() {
+		defer close(stopped)
+
+		// Stop HTTP API before the bridge it drives
+		if apiService != nil {
+			step.Store("http api")
+
+			if err := apiService.Stop(); err != nil {
+				log.WithError(err).Warn("Error stopping HTTP API")
+			}
+		}
+
+		// Stop bridge
+		step.Store("bridge")
+
+		if err := bridgeService.Stop(); err != nil {
+			log.WithError(err).Warn("Error stopping bridge")
+		}
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(cfg.ShutdownTimeout):
+		log.WithFields(logrus.Fields{
+			"timeout":   cfg.ShutdownTimeout,
+			"abandoned": step.Load(),
+		}).Error("Shutdown deadline exceeded, abandoning remaining operations")
+
+		return fmt.Errorf("shutdown did not finish within %s", cfg.ShutdownTimeout)
 	}
 
 	log.Info("Shutdown complete")
@@ -138,8 +313,174 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// teamSpeakService creates the TeamSpeak service, aggregating several servers
+// when teamspeak_servers or additional sources are configured.
+func teamSpeakService(loggers *logging.Loggers, cfg *config.Config, sampler *logsample.Sampler) teamspeak.Service {
+	log := loggers.For("teamspeak")
+
+	if !cfg.Aggregated() {
+		ts := cfg.TeamSpeak.VirtualServers()[0]
+
+		return teamspeak.NewService(log.WithField("server", serverLabel(ts)), tsConfig(ts, sampler))
+	}
+
+	servers := cfg.TeamSpeakServers
+	if cfg.TeamSpeak.Host != "" {
+		servers = cfg.TeamSpeak.VirtualServers()
+	}
+
+	members := make([]teamspeak.Source, 0, len(servers)+len(cfg.MumbleServers))
+	for _, ts := range servers {
+		members = append(members, teamspeak.NewService(log.WithFields(logrus.Fields{
+			"server":    serverLabel(ts),
+			"server_id": ts.ServerID,
+		}), tsConfig(ts, sampler)))
+	}
+
+	for _, m := range cfg.MumbleServers {
+		members = append(members, mumble.NewService(loggers.For("mumble").WithField("server", m.Host), mumble.Config{
+			Name: m.Name,
+			Host: m.Host,
+			Port: m.Port,
+		}))
+	}
+
+	for _, j := range cfg.JSONSources {
+		members = append(members, jsonsource.NewService(loggers.For("jsonsource").WithField("source", j.URL), jsonsource.Config{
+			Name:    j.Name,
+			URL:     j.URL,
+			Headers: j.Headers,
+			Timeout: j.Timeout,
+		}))
+	}
+
+	for _, h := range cfg.HostedServers {
+		members = append(members, hosted.NewService(loggers.For("hosted").WithField("source", h.URL), hosted.Config{
+			Name:     h.Name,
+			Provider: h.Provider,
+			URL:      h.URL,
+			APIKey:   h.APIKey,
+			ServerID: h.ServerID,
+			Headers:  h.Headers,
+			Fields:   hosted.Fields(h.Fields),
+			Timeout:  h.Timeout,
+		}))
+	}
+
+	for _, g := range cfg.GameServers {
+		members = append(members, a2s.NewService(loggers.For("a2s").WithField("server", g.Host), a2s.Config{
+			Name: g.Name,
+			Host: g.Host,
+			Port: g.Port,
+		}))
+	}
+
+	for _, m := range cfg.MinecraftServers {
+		members = append(members, minecraft.NewService(loggers.For("minecraft").WithField("server", m.Host), minecraft.Config{
+			Name: m.Name,
+			Host: m.Host,
+			Port: m.Port,
+		}))
+	}
+
+	return teamspeak.NewAggregate(log, cfg.Display.AggregateTitle, members...)
+}
+
+// notificationRoutes maps the configured routes, plus the join/leave and AFK
+// staff channel shorthands, to bridge routes.
+func notificationRoutes(cfg *config.Config) []bridge.Route {
+	var routes []bridge.Route
+
+	n := cfg.Notifications
+
+	var presence []string
+	if n.Joins {
+		presence = append(presence, notify.EventJoin)
+	}
+
+	if n.Leaves {
+		presence = append(presence, notify.EventLeave)
+	}
+
+	if len(presence) > 0 {
+		routes = append(routes, bridge.Route{Events: presence, Target: n.ChannelID})
+	}
+
+	if cfg.AFKAlerts.Enabled && cfg.AFKAlerts.StaffChannelID != "" {
+		routes = append(routes, bridge.Route{Events: []string{notify.EventModeration}, Target: cfg.AFKAlerts.StaffChannelID})
+	}
+
+	for _, r := range n.Routes {
+		target := r.ChannelID
+		if r.WebhookURL != "" {
+			target = r.WebhookURL
+		}
+
+		routes = append(routes, bridge.Route{Events: r.Events, Target: target, Format: r.Format})
+	}
+
+	return routes
+}
+
+// clockMinutes converts a validated "HH:MM" time of day to minutes after
+// midnight.
+func clockMinutes(s string) int {
+	t, _ := time.Parse("15:04", s)
+
+	return t.Hour()*60 + t.Minute()
+}
+
+// reportTags identifies the installation in error reports.
+func reportTags(cfg *config.Config) map[string]string {
+	servers := make([]string, 0, len(cfg.TeamSpeakServers)+1)
+	if cfg.TeamSpeak.Host != "" {
+		servers = append(servers, cfg.TeamSpeak.Host)
+	}
+
+	for _, ts := range cfg.TeamSpeakServers {
+		servers = append(servers, ts.Host)
+	}
+
+	return map[string]string{
+		"discord_channel":  cfg.Discord.StatusChannelID(),
+		"teamspeak_server": strings.Join(servers, ","),
+	}
+}
+
+// serverLabel names a TeamSpeak server in log lines: its label, else its
+// display name, else its host.
+func serverLabel(ts config.TeamSpeakConfig) string {
+	switch {
+	case ts.Label != "":
+		return ts.Label
+	case ts.Name != "":
+		return ts.Name
+	}
+
+	return ts.Host
+}
+
+// tsConfig converts a TeamSpeak config block to service settings.
+func tsConfig(ts config.TeamSpeakConfig, sampler *logsample.Sampler) teamspeak.Config {
+	return teamspeak.Config{
+		Name:      ts.Name,
+		Label:     ts.Label,
+		Host:      ts.Host,
+		QueryPort: ts.QueryPort,
+		SSH:       ts.Protocol == "ssh",
+		Username:  ts.Username,
+		Password:  ts.Password,
+		ServerID:  ts.ServerID,
+
+		HostKeyFingerprint: ts.HostKeyFingerprint,
+		FileCacheDir:       ts.FileCacheDir,
+		KeepaliveInterval:  ts.KeepaliveInterval,
+		LogSampler:         sampler,
+	}
+}
+
 // runDryRun fetches TeamSpeak state and prints what would be posted to Discord.
-func runDryRun(ctx context.Context, log logrus.FieldLogger, ts teamspeak.Service, cfg *config.Config) error {
+func runDryRun(ctx context.Context, log logrus.FieldLogger, ts teamspeak.Service, stages []pipeline.Middleware, cfg *config.Config) error {
 	log.Info("Running in dry-run mode")
 
 	// Connect to TeamSpeak
@@ -155,6 +496,11 @@ func runDryRun(ctx context.Context, log logrus.FieldLogger, ts teamspeak.Service
 		return fmt.Errorf("failed to get TeamSpeak state: %w", err)
 	}
 
+	state, err = pipeline.Apply(ctx, state, stages...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare state: %w", err)
+	}
+
 	// Print state
 	fmt.Println()
 	fmt.Println("╔══════════════════════════════════════════════════════════════╗")
@@ -163,49 +509,43 @@ func runDryRun(ctx context.Context, log logrus.FieldLogger, ts teamspeak.Service
 	fmt.Printf("║%s%s%s║\n", strings.Repeat(" ", padding), title, strings.Repeat(" ", 62-padding-len(title)))
 	fmt.Println("╠══════════════════════════════════════════════════════════════╣")
 
-	if cfg.Display.ServerInfo.Address != "" || cfg.Display.ServerInfo.Password != "" {
-		if cfg.Display.ServerInfo.Address != "" {
-			fmt.Printf("║  Address: %-52s ║\n", cfg.Display.ServerInfo.Address)
+	if cfg.Display.Connect.Address != "" || cfg.Display.Connect.Password != "" {
+		if cfg.Display.Connect.Address != "" {
+			fmt.Printf("║  Address: %-52s ║\n", cfg.Display.Connect.Address)
 		}
 
-		if cfg.Display.ServerInfo.Password != "" {
-			fmt.Printf("║  Password: %-51s ║\n", cfg.Display.ServerInfo.Password)
+		if cfg.Display.Connect.Password != "" {
+			fmt.Printf("║  Password: %-51s ║\n", cfg.Display.Connect.Password)
 		}
 
 		fmt.Println("╠══════════════════════════════════════════════════════════════╣")
 	}
 
-	hasUsers := false
+	doc := render.Build(state, renderOptions(cfg))
 
-	for _, ch := range state.Channels {
-		if !cfg.Display.ShowEmptyChannels && len(ch.Users) == 0 {
-			continue
-		}
+	for _, sec := range doc.Sections {
+		fmt.Printf("║  📁 %-55s (%d) ║\n", truncate(sec.Name, 50), len(sec.Lines))
 
-		if strings.Contains(strings.ToLower(ch.Name), "spacer") {
-			continue
+		if sec.Topic != "" {
+			fmt.Printf("║      %-57s ║\n", truncate(sec.Topic, 55))
 		}
 
-		hasUsers = true
-		fmt.Printf("║  📁 %-55s (%d) ║\n", truncate(ch.Name, 50), len(ch.Users))
-
-		for _, user := range ch.Users {
-			status := buildUserStatusCLI(user)
-			if status != "" {
-				display := fmt.Sprintf("%s %s", user.Nickname, status)
-				fmt.Printf("║      • %-55s ║\n", truncate(display, 50))
-			} else {
-				fmt.Printf("║      • %-55s ║\n", truncate(user.Nickname, 50))
+		for _, line := range sec.Lines {
+			display := line.Name()
+			if status := buildUserStatusCLI(line); status != "" {
+				display += " " + status
 			}
+
+			fmt.Printf("║      • %-55s ║\n", truncate(display, 50))
 		}
 	}
 
-	if !hasUsers {
+	if len(doc.Sections) == 0 {
 		fmt.Println("║  No users online                                             ║")
 	}
 
 	fmt.Println("╠══════════════════════════════════════════════════════════════╣")
-	fmt.Printf("║  %d/%d online • Uptime: %-38s ║\n", state.TotalUsers, state.MaxClients, formatDuration(state.Uptime))
+	fmt.Printf("║  %d/%d online • Uptime: %-38s ║\n", doc.Online, doc.MaxClients, formatDuration(doc.Uptime))
 
 	if cfg.Display.CustomFooter != "" {
 		fmt.Printf("║  %-60s ║\n", truncate(cfg.Display.CustomFooter, 60))
@@ -217,31 +557,381 @@ func runDryRun(ctx context.Context, log logrus.FieldLogger, ts teamspeak.Service
 	return nil
 }
 
-func buildUserStatusCLI(user teamspeak.User) string {
+// displayConfig converts the display section of the configuration into the
+// Discord renderer's settings.
+func displayConfig(cfg *config.Config) (discord.DisplayConfig, error) {
+	rules, err := colorRules(cfg)
+	if err != nil {
+		return discord.DisplayConfig{}, err
+	}
+
+	nameReset, err := channelNameReset(cfg)
+	if err != nil {
+		return discord.DisplayConfig{}, err
+	}
+
+	templates, err := embedTemplates(cfg.Display.Templates)
+	if err != nil {
+		return discord.DisplayConfig{}, err
+	}
+
+	return discord.DisplayConfig{
+		ShowEmptyChannels: cfg.Display.ShowEmptyChannels,
+		ChannelFilter:     channelFilter(cfg.Display.ChannelFilter),
+		ServerAddress:     cfg.Display.Connect.Address,
+		ServerPassword:    cfg.Display.Connect.Password,
+		Links:             displayLinks(cfg.Display.Links),
+		CustomFooter:      cfg.Display.CustomFooter,
+		ChannelNameFormat: cfg.Display.ChannelNameFormat,
+		ChannelNameDryRun: cfg.Display.ChannelNameDryRun,
+		ThumbnailURL:      cfg.Display.ThumbnailURL,
+		JoinQR:            cfg.Display.Connect.QRCode,
+		CompactLayout:     cfg.Display.Layout.Compact,
+		InlineStats:       cfg.Display.Layout.InlineStats,
+		StatsPerRow:       cfg.Display.Layout.StatsPerRow,
+		ChannelFields:     cfg.Display.Layout.Channels == "fields",
+		InlineChannels:    cfg.Display.Layout.InlineChannels,
+		Style:             cfg.Display.Style,
+		StaleAfter:        time.Duration(cfg.Display.StaleIntervals) * cfg.Display.UpdateInterval,
+		RelativeTime:      cfg.Display.RelativeTime,
+		ShowCountry:       cfg.Display.ShowCountry,
+		ShowChannelTopics: cfg.Display.ShowChannelTopics,
+		ShowConnectedTime: cfg.Display.ShowConnectedTime,
+		ShowTalkPower:     cfg.Display.ShowTalkPower,
+		ShowCodec:         cfg.Display.ShowCodec,
+		IconEmojis:        cfg.Display.ChannelIcons.Emojis,
+		GroupBadges:       cfg.Display.GroupBadges,
+		Emojis:            statusEmojis(cfg.Display.Emojis),
+		ColorRules:        rules,
+
+		ShowLongestSession: cfg.Display.ShowLongestSession,
+		ShowNetwork:        cfg.Display.ShowNetwork,
+		ChannelNameReset:   nameReset,
+		VoiceMirror:        voiceMirror(cfg.Display.VoiceMirror),
+		VoiceStatus:        voiceStatus(cfg.Display.VoiceStatus),
+		PresenceTemplates:  cfg.Display.Presence.Templates,
+		PresenceInterval:   cfg.Display.Presence.Interval,
+		NicknameFormat:     cfg.Display.Nickname.Format,
+		NicknameInterval:   cfg.Display.Nickname.Interval,
+		EmptyState: discord.EmptyState{
+			Enabled:  cfg.Display.EmptyState.Enabled,
+			Phrases:  cfg.Display.EmptyState.Phrases,
+			Fallback: cfg.Display.EmptyState.Fallback,
+		},
+		StatusEmoji: discord.StatusEmoji{
+			Online:       cfg.Display.StatusEmoji.Online,
+			Busy:         cfg.Display.StatusEmoji.Busy,
+			Offline:      cfg.Display.StatusEmoji.Offline,
+			BusyCapacity: cfg.Display.StatusEmoji.BusyCapacity / 100,
+		},
+		Templates:        templates,
+		QuietAfter:       cfg.Display.QuietAfter,
+		ViewButtons:      cfg.Display.ViewButtons.Enabled,
+		DefaultView:      cfg.Display.ViewButtons.Default,
+		ViewRevertAfter:  cfg.Display.ViewButtons.RevertAfter,
+		ChannelSelect:    cfg.Display.ChannelSelect,
+		WhatChanged:      cfg.Display.WhatChanged,
+		RefreshButton:    cfg.Display.RefreshButton.Enabled,
+		RefreshCooldown:  cfg.Display.RefreshButton.Cooldown,
+		MessagePerServer: cfg.Display.MessagePerServer,
+		Paginate:         cfg.Display.Paginate,
+	}, nil
+}
+
+// discordTargets converts discord.channels, each with its own display block.
+func discordTargets(cfg *config.Config) ([]discord.Target, error) {
+	targets := make([]discord.Target, 0, len(cfg.Discord.Channels))
+
+	for i, ch := range cfg.Discord.Channels {
+		c := *cfg
+		c.Display = ch.Display
+
+		display, err := displayConfig(&c)
+		if err != nil {
+			return nil, fmt.Errorf("discord.channels[%d]: %w", i, err)
+		}
+
+		targets = append(targets, discord.Target{ChannelID: ch.ChannelID, Display: display})
+	}
+
+	return targets, nil
+}
+
+// detailThread returns the detail thread name, or "" when it is disabled.
+func detailThread(t config.DetailThread) string {
+	if !t.Enabled {
+		return ""
+	}
+
+	return t.Name
+}
+
+// anyDisplay reports whether the display block or any of discord.channels
+// has an option on, for bridge work that only some channels need.
+func anyDisplay(cfg *config.Config, on func(config.DisplayConfig) bool) bool {
+	if on(cfg.Display) {
+		return true
+	}
+
+	for _, ch := range cfg.Discord.Channels {
+		if on(ch.Display) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// voiceMirror converts the voice channel mirror, or returns nil when it is
+// not configured.
+func voiceMirror(m config.VoiceMirror) *discord.VoiceMirror {
+	if m.ChannelID == "" {
+		return nil
+	}
+
+	return &discord.VoiceMirror{ChannelID: m.ChannelID, Format: m.Format}
+}
+
+// statusEmojis applies the configured status emojis over the defaults; an
+// empty emoji hides that state.
+func statusEmojis(m config.StateEmojis) render.Emojis {
+	e := render.DefaultEmojis
+
+	for state, emoji := range m {
+		switch state {
+		case "recording":
+			e.Recording = emoji
+		case "muted":
+			e.Muted = emoji
+		case "deafened":
+			e.Deafened = emoji
+		case "away":
+			e.Away = emoji
+		case "idle":
+			e.Idle = emoji
+		}
+	}
+
+	return e
+}
+
+// displayLinks converts the links field entries.
+func displayLinks(links []config.Link) []discord.Link {
+	out := make([]discord.Link, 0, len(links))
+	for _, l := range links {
+		out = append(out, discord.Link{Label: l.Label, URL: l.URL})
+	}
+
+	return out
+}
+
+// voiceStatus converts the voice channel status, or returns nil when it is
+// not configured.
+func voiceStatus(vs config.VoiceStatus) *discord.VoiceStatus {
+	if vs.ChannelID == "" {
+		return nil
+	}
+
+	return &discord.VoiceStatus{ChannelID: vs.ChannelID, Format: vs.Format}
+}
+
+// channelNameReset converts the overnight channel name reset, or returns nil
+// when it is not configured.
+func channelNameReset(cfg *config.Config) (*discord.ChannelNameReset, error) {
+	reset := cfg.Display.ChannelNameReset
+	if reset.Name == "" {
+		return nil, nil
+	}
+
+	window, err := discord.ParseClockRange(reset.Between)
+	if err != nil {
+		return nil, fmt.Errorf("display.channel_name_reset: %w", err)
+	}
+
+	return &discord.ChannelNameReset{Name: reset.Name, Window: window}, nil
+}
+
+// embedTemplates parses the embed templates.
+func embedTemplates(t config.EmbedTemplates) (discord.Templates, error) {
+	var (
+		out discord.Templates
+		err error
+	)
+
+	if out.Title, err = optionalTemplate("title", t.Title); err != nil {
+		return discord.Templates{}, err
+	}
+
+	if out.Channel, err = optionalTemplate("channel", t.Channel); err != nil {
+		return discord.Templates{}, err
+	}
+
+	if out.User, err = optionalTemplate("user", t.User); err != nil {
+		return discord.Templates{}, err
+	}
+
+	for i, f := range t.Fields {
+		if f.Value == "" {
+			return discord.Templates{}, fmt.Errorf("display.templates.fields[%d]: value is required", i)
+		}
+
+		field := discord.FieldTemplate{Inline: f.Inline}
+
+		if field.Name, err = optionalTemplate(fmt.Sprintf("fields[%d].name", i), f.Name); err != nil {
+			return discord.Templates{}, err
+		}
+
+		if field.Value, err = optionalTemplate(fmt.Sprintf("fields[%d].value", i), f.Value); err != nil {
+			return discord.Templates{}, err
+		}
+
+		out.Fields = append(out.Fields, field)
+	}
+
+	return out, nil
+}
+
+// optionalTemplate parses an embed template, returning nil when text is empty.
+func optionalTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := discord.ParseTemplate(name, text)
+	if err != nil {
+		return nil, fmt.Errorf("display.templates.%s: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+// colorRules converts the configured embed color rules.
+func colorRules(cfg *config.Config) ([]discord.ColorRule, error) {
+	rules := make([]discord.ColorRule, 0, len(cfg.Display.ColorRules))
+
+	for i, r := range cfg.Display.ColorRules {
+		color, err := discord.ParseColor(r.Color)
+		if err != nil {
+			return nil, fmt.Errorf("display.color_rules[%d]: %w", i, err)
+		}
+
+		rule := discord.ColorRule{
+			Color:        color,
+			Stale:        r.Stale,
+			Announcement: r.Announcement,
+			MinUsers:     r.MinUsers,
+			MaxUsers:     r.MaxUsers,
+			MinCapacity:  percentFraction(r.MinCapacity),
+			MaxCapacity:  percentFraction(r.MaxCapacity),
+		}
+
+		if r.Between != "" {
+			window, err := discord.ParseClockRange(r.Between)
+			if err != nil {
+				return nil, fmt.Errorf("display.color_rules[%d]: %w", i, err)
+			}
+
+			rule.Window = &window
+		}
+
+		for _, name := range r.Weekdays {
+			day, err := discord.ParseWeekday(name)
+			if err != nil {
+				return nil, fmt.Errorf("display.color_rules[%d]: %w", i, err)
+			}
+
+			rule.Weekdays = append(rule.Weekdays, day)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// percentFraction converts an optional percentage to a fraction.
+func percentFraction(p *float64) *float64 {
+	if p == nil {
+		return nil
+	}
+
+	f := *p / 100
+
+	return &f
+}
+
+// contentFilter compiles the configured content filter; it is nil when no
+// rules are configured.
+func contentFilter(cfg *config.Config) (*contentfilter.Filter, error) {
+	patterns := make([]contentfilter.Pattern, 0, len(cfg.Filter.Patterns))
+	for _, p := range cfg.Filter.Patterns {
+		patterns = append(patterns, contentfilter.Pattern{Pattern: p.Pattern, Replacement: p.Replacement})
+	}
+
+	filter, err := contentfilter.New(contentfilter.Config{
+		Words:       cfg.Filter.Words,
+		Patterns:    patterns,
+		Replacement: cfg.Filter.Replacement,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build content filter: %w", err)
+	}
+
+	return filter, nil
+}
+
+// displayStages builds the pipeline stages that prepare fetched states for
+// display: sanitize, then content and channel filtering.
+func displayStages(cfg *config.Config) ([]pipeline.Middleware, error) {
+	filter, err := contentFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return []pipeline.Middleware{
+		pipeline.Sanitize(),
+		pipeline.ContentFilter(filter),
+		pipeline.HideChannels(channelFilter(cfg.SharedChannelFilter())),
+	}, nil
+}
+
+// channelFilter converts a configured channel filter for the teamspeak package.
+func channelFilter(f config.ChannelFilter) teamspeak.ChannelFilter {
+	return teamspeak.ChannelFilter{
+		HideDefault:   f.HideDefault,
+		HideTemporary: f.HideTemporary,
+		HidePermanent: f.HidePermanent,
+		HideNames:     f.HideNames,
+	}
+}
+
+// buildUserStatusCLI spells out a line's status for the terminal, including
+// the away message the embed leaves out.
+func buildUserStatusCLI(line render.Line) string {
 	var parts []string
 
-	if user.IsRecording {
+	if line.Recording {
 		parts = append(parts, "🔴REC")
 	}
 
-	if user.OutputMuted {
+	switch {
+	case line.Deafened:
 		parts = append(parts, "🔇")
-	} else if user.InputMuted {
+	case line.Muted:
 		parts = append(parts, "🎙️")
 	}
 
-	if user.Away {
-		if user.AwayMessage != "" {
-			parts = append(parts, fmt.Sprintf("💤(%s)", user.AwayMessage))
+	if line.Away {
+		if line.AwayMessage != "" {
+			parts = append(parts, fmt.Sprintf("💤(%s)", line.AwayMessage))
 		} else {
 			parts = append(parts, "💤")
 		}
 	}
 
-	// Show idle time if > 5 minutes
-	if user.IdleTime > 5*time.Minute {
-		hours := int(user.IdleTime.Hours())
-		minutes := int(user.IdleTime.Minutes()) % 60
+	if line.Idle > 0 {
+		hours := int(line.Idle.Hours())
+		minutes := int(line.Idle.Minutes()) % 60
 		if hours > 0 {
 			parts = append(parts, fmt.Sprintf("idle %dh%dm", hours, minutes))
 		} else {
@@ -249,9 +939,26 @@ func buildUserStatusCLI(user teamspeak.User) string {
 		}
 	}
 
+	if !line.ConnectedAt.IsZero() {
+		parts = append(parts, "on "+formatDuration(time.Since(line.ConnectedAt)))
+	}
+
 	return strings.Join(parts, " ")
 }
 
+// renderOptions selects what the rendered document includes.
+func renderOptions(cfg *config.Config) render.Options {
+	return render.Options{
+		ShowEmptyChannels: cfg.Display.ShowEmptyChannels,
+		ShowCountry:       cfg.Display.ShowCountry,
+		ShowChannelTopics: cfg.Display.ShowChannelTopics,
+		ShowConnectedTime: cfg.Display.ShowConnectedTime,
+		ShowTalkPower:     cfg.Display.ShowTalkPower,
+		ShowCodec:         cfg.Display.ShowCodec,
+		GroupBadges:       cfg.Display.GroupBadges,
+	}
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
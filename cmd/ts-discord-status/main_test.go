@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+	"github.com/samcm/ts-discord-status/internal/render"
+)
+
+func TestStatusEmojis(t *testing.T) {
+	require.Equal(t, render.DefaultEmojis, statusEmojis(nil))
+
+	e := statusEmojis(config.StateEmojis{"muted": "<:micoff:123>", "away": ""})
+	require.Equal(t, "<:micoff:123>", e.Muted)
+	require.Empty(t, e.Away)
+	require.Equal(t, render.DefaultEmojis.Recording, e.Recording)
+
+	// Hiding every state keeps them all hidden.
+	all := config.StateEmojis{"recording": "", "muted": "", "deafened": "", "away": "", "idle": ""}
+	require.Equal(t, render.Emojis{}, statusEmojis(all))
+}
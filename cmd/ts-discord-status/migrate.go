@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+)
+
+var (
+	migrateConfigPath string
+	migrateFrom       string
+	migrateWrite      bool
+)
+
+func init() {
+	migrateCmd.Flags().StringVarP(&migrateConfigPath, "config", "c", "", "Path to configuration file (required)")
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Layout version the configuration was written for, e.g. v1 (required)")
+	migrateCmd.Flags().BoolVarP(&migrateWrite, "write", "w", false,
+		"Rewrite the file in place (the original is kept as <file>.bak) instead of printing to stdout")
+	_ = migrateCmd.MarkFlagRequired("config")
+	_ = migrateCmd.MarkFlagRequired("from")
+
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate-config",
+	Short: "Rewrite an older configuration file to the current layout",
+	Long: "Moves and renames options from an older configuration layout to the current one, keeping comments " +
+		"on the keys they belong to. Formatting such as blank lines and quoting may change.",
+	RunE: runMigrate,
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(migrateConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	out, changes, err := config.Migrate(data, migrateFrom)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintf(os.Stderr, "Nothing to migrate; %s already matches the %s layout\n", migrateConfigPath, config.CurrentVersion)
+
+		if !migrateWrite {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Fprintf(os.Stderr, "Migrated %s\n", c)
+	}
+
+	if !migrateWrite {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+
+	info, err := os.Stat(migrateConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	if err := os.WriteFile(migrateConfigPath+".bak", data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := os.WriteFile(migrateConfigPath, out, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s (backup: %s.bak)\n", migrateConfigPath, migrateConfigPath)
+
+	return nil
+}
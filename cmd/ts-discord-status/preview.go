@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/jsonsource"
+	"github.com/samcm/ts-discord-status/internal/pipeline"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+	"github.com/samcm/ts-discord-status/internal/teamspeak/teamspeaktest"
+)
+
+var (
+	previewConfigPath string
+	previewStatePath  string
+	previewListen     string
+)
+
+func init() {
+	previewCmd.Flags().StringVarP(&previewConfigPath, "config", "c", "", "Path to configuration file (required)")
+	previewCmd.Flags().StringVar(&previewStatePath, "state", "",
+		"State fixture in the JSON source document format (default: synthetic state)")
+	previewCmd.Flags().StringVar(&previewListen, "listen", "localhost:8090", "Address to serve the preview on")
+	_ = previewCmd.MarkFlagRequired("config")
+
+	devCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(devCmd)
+}
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Development tools",
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Serve a live-reloading preview of the status embed",
+	Long: "Renders the status embed from a state fixture as a local web page with approximate Discord styling. " +
+		"The page reloads whenever the configuration or fixture file changes.",
+	RunE: runPreview,
+}
+
+// previewWatchInterval is how often the configuration and fixture files are
+// checked for changes.
+const previewWatchInterval = 500 * time.Millisecond
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+
+	p := &previewer{log: log.WithField("component", "preview")}
+	p.render()
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go p.watch(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", p.handlePage)
+	mux.HandleFunc("GET /version", p.handleVersion)
+
+	server := &http.Server{
+		Addr:              previewListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.WithField("url", "http://"+previewListen).Info("Serving embed preview")
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve preview: %w", err)
+	}
+
+	return nil
+}
+
+// previewer keeps the latest rendered preview page.
+type previewer struct {
+	log logrus.FieldLogger
+
+	mu      sync.Mutex
+	page    []byte
+	version int
+}
+
+// render reloads the configuration and fixture and re-renders the page. Errors
+// are shown on the page so a broken edit is visible without checking logs.
+func (p *previewer) render() {
+	var buf strings.Builder
+
+	embed, err := previewEmbed()
+	if err != nil {
+		p.log.WithError(err).Warn("Failed to render preview")
+
+		if err := previewTemplate.Execute(&buf, previewPage{Error: err.Error()}); err != nil {
+			p.log.WithError(err).Error("Failed to write preview page")
+		}
+	} else if err := previewTemplate.Execute(&buf, newPreviewPage(embed)); err != nil {
+		p.log.WithError(err).Error("Failed to write preview page")
+	}
+
+	p.mu.Lock()
+	p.page = []byte(buf.String())
+	p.version++
+	p.mu.Unlock()
+}
+
+// watch re-renders whenever the configuration or fixture file changes.
+func (p *previewer) watch(ctx context.Context) {
+	ticker := time.NewTicker(previewWatchInterval)
+	defer ticker.Stop()
+
+	last := previewModTimes()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := previewModTimes()
+			if current == last {
+				continue
+			}
+
+			last = current
+
+			p.log.Info("Files changed, re-rendering preview")
+			p.render()
+		}
+	}
+}
+
+func (p *previewer) handlePage(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	page := p.page
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(page)
+}
+
+func (p *previewer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	version := p.version
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(strconv.Itoa(version)))
+}
+
+// previewModTimes returns a fingerprint of the watched files' modification
+// times; a missing file simply contributes nothing.
+func previewModTimes() string {
+	var parts []string
+
+	for _, path := range []string{previewConfigPath, previewStatePath} {
+		if path == "" {
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil {
+			parts = append(parts, info.ModTime().String())
+		}
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// previewEmbed builds the embed from the current configuration and fixture.
+func previewEmbed() (*discordgo.MessageEmbed, error) {
+	cfg, err := config.Load(previewConfigPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	display, err := displayConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := displayStages(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := previewState()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err = pipeline.Apply(context.Background(), state, stages...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare preview state: %w", err)
+	}
+
+	return discord.Preview(display, state), nil
+}
+
+// previewState loads the state fixture, falling back to a synthetic state.
+func previewState() (*teamspeak.State, error) {
+	if previewStatePath == "" {
+		return teamspeaktest.SyntheticState(8, 20), nil
+	}
+
+	data, err := os.ReadFile(previewStatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state fixture: %w", err)
+	}
+
+	var doc jsonsource.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse state fixture: %w", err)
+	}
+
+	state := doc.State()
+	state.FetchedAt = time.Now()
+
+	return state, nil
+}
+
+// previewPage is the template data for the preview page.
+type previewPage struct {
+	Error     string
+	Color     string
+	Author    string
+	AuthorURL string
+	Title     string
+	Desc      template.HTML
+	Thumbnail string
+	Image     string
+	Fields    []previewField
+	Footer    template.HTML
+}
+
+type previewField struct {
+	Name   template.HTML
+	Value  template.HTML
+	Inline bool
+}
+
+func newPreviewPage(embed *discordgo.MessageEmbed) previewPage {
+	page := previewPage{
+		Color: fmt.Sprintf("#%06X", embed.Color),
+		Title: embed.Title,
+		Desc:  discordMarkdown(embed.Description),
+	}
+
+	if embed.Author != nil {
+		page.Author = embed.Author.Name
+		page.AuthorURL = embed.Author.IconURL
+	}
+
+	if embed.Thumbnail != nil {
+		page.Thumbnail = embed.Thumbnail.URL
+	}
+
+	if embed.Image != nil {
+		page.Image = embed.Image.URL
+	}
+
+	for _, f := range embed.Fields {
+		page.Fields = append(page.Fields, previewField{
+			Name:   discordMarkdown(f.Name),
+			Value:  discordMarkdown(f.Value),
+			Inline: f.Inline,
+		})
+	}
+
+	var footer []string
+	if embed.Footer != nil && embed.Footer.Text != "" {
+		footer = append(footer, html.EscapeString(embed.Footer.Text))
+	}
+
+	if ts, err := time.Parse(time.RFC3339, embed.Timestamp); err == nil {
+		footer = append(footer, "Today at "+ts.Local().Format("15:04"))
+	}
+
+	page.Footer = template.HTML(strings.Join(footer, " • "))
+
+	return page
+}
+
+var (
+	mdInlineCode  = regexp.MustCompile("`([^`]+)`")
+	mdBold        = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	mdUnderline   = regexp.MustCompile(`__(.+?)__`)
+	mdItalic      = regexp.MustCompile(`\*(.+?)\*|\b_(.+?)_\b`)
+	mdStrike      = regexp.MustCompile(`~~(.+?)~~`)
+	mdLink        = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdTimestamp   = regexp.MustCompile(`&lt;t:(\d+)(?::([tTdDfFR]))?&gt;`)
+	mdCustomEmoji = regexp.MustCompile(`&lt;a?:(\w+):(\d+)&gt;`)
+)
+
+// discordMarkdown converts the subset of Discord markdown the embed uses into
+// HTML. Input is escaped first; code blocks are left unformatted.
+func discordMarkdown(s string) template.HTML {
+	var out strings.Builder
+
+	for i, part := range strings.Split(s, "```") {
+		if i%2 == 1 {
+			// Drop the language hint on the opening line.
+			if nl := strings.IndexByte(part, '\n'); nl >= 0 && !strings.ContainsAny(part[:nl], " \t") {
+				part = part[nl+1:]
+			}
+
+			out.WriteString("<pre>" + html.EscapeString(strings.TrimSuffix(part, "\n")) + "</pre>")
+
+			continue
+		}
+
+		out.WriteString(inlineMarkdown(part))
+	}
+
+	return template.HTML(out.String())
+}
+
+func inlineMarkdown(s string) string {
+	s = html.EscapeString(s)
+
+	var codes []string
+
+	s = mdInlineCode.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, "<code>"+mdInlineCode.FindStringSubmatch(m)[1]+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+
+	s = mdTimestamp.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdTimestamp.FindStringSubmatch(m)
+		unix, _ := strconv.ParseInt(sub[1], 10, 64)
+
+		return `<span class="ts">` + previewTimestamp(time.Unix(unix, 0), sub[2]) + "</span>"
+	})
+
+	s = mdCustomEmoji.ReplaceAllString(s, `<img class="emoji" alt=":$1:" src="https://cdn.discordapp.com/emojis/$2.png">`)
+	s = mdLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = mdBold.ReplaceAllString(s, "<strong>$1</strong>")
+	s = mdUnderline.ReplaceAllString(s, "<u>$1</u>")
+	s = mdItalic.ReplaceAllString(s, "<em>$1$2</em>")
+	s = mdStrike.ReplaceAllString(s, "<s>$1</s>")
+	s = strings.ReplaceAll(s, "\n", "<br>")
+
+	for i, code := range codes {
+		s = strings.Replace(s, fmt.Sprintf("\x00%d\x00", i), code, 1)
+	}
+
+	return s
+}
+
+// previewTimestamp formats Discord timestamp markup the way a viewer in the
+// local timezone would see it.
+func previewTimestamp(t time.Time, style string) string {
+	switch style {
+	case "t":
+		return t.Format("15:04")
+	case "T":
+		return t.Format("15:04:05")
+	case "d":
+		return t.Format("02/01/2006")
+	case "D":
+		return t.Format("2 January 2006")
+	case "F":
+		return t.Format("Monday, 2 January 2006 15:04")
+	case "R":
+		d := time.Since(t)
+		if d > -time.Minute && d < time.Minute {
+			return "just now"
+		}
+
+		if d < 0 {
+			return "in " + formatDuration(-d)
+		}
+
+		return formatDuration(d) + " ago"
+	default:
+		return t.Format("2 January 2006 15:04")
+	}
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Embed preview</title>
+<style>
+  body { background: #313338; color: #dbdee1; font: 15px/1.375 "gg sans", "Noto Sans", Helvetica, Arial, sans-serif; padding: 24px; }
+  .error { background: #4a1c1f; border-left: 4px solid #f23f43; padding: 12px 16px; border-radius: 4px; white-space: pre-wrap; max-width: 520px; }
+  .embed { display: grid; grid-template-columns: auto min-content; max-width: 520px; background: #2b2d31; border-left: 4px solid; border-radius: 4px; padding: 8px 16px 16px 12px; }
+  .main { min-width: 0; }
+  .author { display: flex; align-items: center; gap: 8px; margin-top: 8px; font-size: 14px; font-weight: 600; color: #f2f3f5; }
+  .author img { width: 24px; height: 24px; border-radius: 50%; }
+  .title { margin-top: 8px; font-weight: 600; color: #f2f3f5; }
+  .desc { margin-top: 8px; font-size: 14px; }
+  .fields { display: grid; grid-template-columns: repeat(3, 1fr); gap: 8px; margin-top: 8px; }
+  .field { font-size: 14px; min-width: 0; }
+  .field.block { grid-column: 1 / -1; }
+  .field .name { font-weight: 600; color: #f2f3f5; margin-bottom: 2px; }
+  .thumb img { max-width: 80px; max-height: 80px; border-radius: 4px; margin: 8px 0 0 16px; }
+  .image img { max-width: 100%; border-radius: 4px; margin-top: 16px; }
+  .footer { margin-top: 8px; font-size: 12px; color: #b5bac1; }
+  pre { background: #1e1f22; border: 1px solid #1e1f22; border-radius: 4px; padding: 7px; margin: 4px 0; white-space: pre-wrap; font: 14px/1.125 Consolas, "Andale Mono", monospace; }
+  code { background: #1e1f22; border-radius: 3px; padding: 0 2px; font: 85% Consolas, "Andale Mono", monospace; }
+  .ts { background: #3c3f45; border-radius: 3px; padding: 0 2px; }
+  .emoji { width: 1.375em; height: 1.375em; vertical-align: bottom; }
+  a { color: #00a8fc; text-decoration: none; }
+</style>
+</head>
+<body>
+{{if .Error}}
+<div class="error">{{.Error}}</div>
+{{else}}
+<div class="embed" style="border-color: {{.Color}}">
+  <div class="main">
+    {{if .Author}}<div class="author">{{if .AuthorURL}}<img src="{{.AuthorURL}}" alt="">{{end}}{{.Author}}</div>{{end}}
+    {{if .Title}}<div class="title">{{.Title}}</div>{{end}}
+    {{if .Desc}}<div class="desc">{{.Desc}}</div>{{end}}
+    {{if .Fields}}<div class="fields">
+      {{range .Fields}}<div class="field{{if not .Inline}} block{{end}}"><div class="name">{{.Name}}</div><div class="value">{{.Value}}</div></div>
+      {{end}}
+    </div>{{end}}
+    {{if .Image}}<div class="image"><img src="{{.Image}}" alt=""></div>{{end}}
+    {{if .Footer}}<div class="footer">{{.Footer}}</div>{{end}}
+  </div>
+  {{if .Thumbnail}}<div class="thumb"><img src="{{.Thumbnail}}" alt=""></div>{{end}}
+</div>
+{{end}}
+<script>
+  let version = null;
+  setInterval(async () => {
+    try {
+      const v = await (await fetch("/version")).text();
+      if (version !== null && v !== version) location.reload();
+      version = v;
+    } catch (e) {}
+  }, 1000);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscordMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"**Lobby** `3`", "<strong>Lobby</strong> <code>3</code>"},
+		{"*away* and _idle_", "<em>away</em> and <em>idle</em>"},
+		{"__under__ ~~gone~~", "<u>under</u> <s>gone</s>"},
+		{"a\nb", "a<br>b"},
+		{"[Site](https://example.com)", `<a href="https://example.com">Site</a>`},
+		{"<:micoff:123>", `<img class="emoji" alt=":micoff:" src="https://cdn.discordapp.com/emojis/123.png">`},
+		// Input is escaped, and markup inside inline code is left alone.
+		{"<script>", "&lt;script&gt;"},
+		{"`**not bold**`", "<code>**not bold**</code>"},
+		// Code blocks drop the language hint and are not formatted.
+		{"```ansi\n**raw**\n```", "<pre>**raw**</pre>"},
+		{"before ```a b\nc```", "before <pre>a b\nc</pre>"},
+	} {
+		require.Equal(t, template.HTML(tc.want), discordMarkdown(tc.in), tc.in)
+	}
+
+	ts := time.Date(2026, 1, 2, 15, 4, 0, 0, time.Local)
+	require.Equal(t, template.HTML(`at <span class="ts">15:04</span>`),
+		discordMarkdown("at <t:"+strconv.FormatInt(ts.Unix(), 10)+":t>"))
+}
+
+func TestPreviewTimestamp(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local)
+
+	require.Equal(t, "15:04", previewTimestamp(ts, "t"))
+	require.Equal(t, "15:04:05", previewTimestamp(ts, "T"))
+	require.Equal(t, "02/01/2026", previewTimestamp(ts, "d"))
+	require.Equal(t, "2 January 2026", previewTimestamp(ts, "D"))
+	require.Equal(t, "Friday, 2 January 2026 15:04", previewTimestamp(ts, "F"))
+	require.Equal(t, "2 January 2026 15:04", previewTimestamp(ts, ""))
+
+	require.Equal(t, "just now", previewTimestamp(time.Now(), "R"))
+	require.Contains(t, previewTimestamp(time.Now().Add(-2*time.Hour), "R"), " ago")
+	require.Contains(t, previewTimestamp(time.Now().Add(2*time.Hour), "R"), "in ")
+}
+
+func TestNewPreviewPage(t *testing.T) {
+	page := newPreviewPage(&discordgo.MessageEmbed{
+		Color:       0x43B581,
+		Title:       "Game Night",
+		Description: "**2** online",
+		Author:      &discordgo.MessageEmbedAuthor{Name: "TeamSpeak Server", IconURL: "https://example.com/icon.png"},
+		Image:       &discordgo.MessageEmbedImage{URL: "attachment://collage.png"},
+		Fields:      []*discordgo.MessageEmbedField{{Name: "Users", Value: "`2`", Inline: true}},
+		Footer:      &discordgo.MessageEmbedFooter{Text: "v7 <b>"},
+		Timestamp:   time.Date(2026, 1, 2, 15, 4, 0, 0, time.Local).Format(time.RFC3339),
+	})
+
+	require.Equal(t, "#43B581", page.Color)
+	require.Equal(t, "Game Night", page.Title)
+	require.Equal(t, template.HTML("<strong>2</strong> online"), page.Desc)
+	require.Equal(t, "TeamSpeak Server", page.Author)
+	require.Equal(t, "attachment://collage.png", page.Image)
+	require.Equal(t, []previewField{{Name: "Users", Value: "<code>2</code>", Inline: true}}, page.Fields)
+	require.Equal(t, template.HTML("v7 &lt;b&gt; • Today at 15:04"), page.Footer)
+}
+
+func TestPreviewer(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	statePath := filepath.Join(dir, "state.json")
+
+	previewConfigPath, previewStatePath = cfgPath, statePath
+	t.Cleanup(func() { previewConfigPath, previewStatePath = "", "" })
+
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+`), 0o600))
+	require.NoError(t, os.WriteFile(statePath, []byte(`{"name": "Fixture Server", "max_users": 32,
+  "channels": [{"name": "Lobby", "users": [{"name": "alice"}]}]}`), 0o600))
+
+	p := &previewer{log: logrus.New()}
+	p.render()
+
+	get := func(handler http.HandlerFunc) string {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		return rec.Body.String()
+	}
+
+	require.Equal(t, "1", get(p.handleVersion))
+	require.Contains(t, get(p.handlePage), "Fixture Server")
+	require.Contains(t, get(p.handlePage), "alice")
+
+	// A broken edit is shown on the page instead of the embed.
+	fingerprint := previewModTimes()
+	require.NotEmpty(t, fingerprint)
+
+	require.NoError(t, os.WriteFile(statePath, []byte(`{`), 0o600))
+	require.NoError(t, os.Chtimes(statePath, time.Now(), time.Now().Add(time.Minute)))
+	require.NotEqual(t, fingerprint, previewModTimes())
+
+	p.render()
+	require.Equal(t, "2", get(p.handleVersion))
+	require.Contains(t, get(p.handlePage), "failed to parse state fixture")
+
+	// Without a fixture the synthetic state is shown.
+	previewStatePath = ""
+
+	state, err := previewState()
+	require.NoError(t, err)
+	require.NotEmpty(t, state.Channels)
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"cmp"
 	"database/sql"
 	"fmt"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
+
+	"github.com/samcm/ts-discord-status/internal/chart"
+	"github.com/samcm/ts-discord-status/internal/store"
 )
 
 var (
-	recapDBPath string
-	recapYear   int
-	recapTZ     string
+	recapDBPath  string
+	recapYear    int
+	recapTZ      string
+	recapHeatmap string
 )
 
 func init() {
@@ -20,6 +26,8 @@ func init() {
 	recapCmd.Flags().IntVar(&recapYear, "year", 0, "Limit the recap to a calendar year (0 = all time)")
 	recapCmd.Flags().StringVar(&recapTZ, "tz", "Local",
 		"Timezone for day/hour grouping (IANA name, e.g. Australia/Sydney)")
+	recapCmd.Flags().StringVar(&recapHeatmap, "heatmap", "",
+		"Also write a PNG heatmap of average users by weekday and hour to this path")
 	_ = recapCmd.MarkFlagRequired("db")
 
 	rootCmd.AddCommand(recapCmd)
@@ -33,11 +41,17 @@ var recapCmd = &cobra.Command{
 }
 
 func runRecap(cmd *cobra.Command, args []string) error {
+	if !store.Available {
+		return errNoSQLite
+	}
+
 	loc, err := loadLocation(recapTZ)
 	if err != nil {
 		return err
 	}
 
+	renderer := chart.New(chart.Config{})
+
 	db, err := sql.Open("sqlite", "file:"+recapDBPath+"?mode=ro")
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -111,6 +125,18 @@ func runRecap(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := printDowntime(cmd, db, time.Unix(first.Int64, 0), time.Unix(last.Int64+sampleMinute, 0), loc); err != nil {
+		return err
+	}
+
+	if recapHeatmap != "" {
+		if err := writeHeatmap(cmd, renderer, db, where, args2, loc); err != nil {
+			return err
+		}
+
+		fmt.Printf("  Heatmap:          %s\n", recapHeatmap)
+	}
+
 	fmt.Println()
 
 	return nil
@@ -228,6 +254,136 @@ func printBusiest(db *sql.DB, where string, args []any, loc *time.Location) erro
 	return nil
 }
 
+// sampleMinute is the span of one snapshot, in seconds.
+const sampleMinute = 60
+
+// recapOutages is how many of the longest outages the recap lists.
+const recapOutages = 5
+
+// printDowntime sums the recorded outages of the period by link, telling
+// bridge restarts apart from TeamSpeak and Discord outages. Databases from
+// before connection tracking have nothing to report.
+func printDowntime(cmd *cobra.Command, db *sql.DB, since, until time.Time, loc *time.Location) error {
+	var tables int
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'connections'`).
+		Scan(&tables); err != nil {
+		return fmt.Errorf("failed to look up connection history: %w", err)
+	}
+
+	if tables == 0 {
+		return nil
+	}
+
+	outages, err := store.LoadOutages(cmd.Context(), db, since, until)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total             = make(map[string]time.Duration, 3)
+		count             = make(map[string]int, 3)
+		restarts, crashes int
+	)
+
+	for _, o := range outages {
+		total[o.Link] += o.Duration(until)
+		count[o.Link]++
+
+		if o.Crash {
+			crashes++
+		} else if o.Link == store.LinkBridge {
+			restarts++
+		}
+	}
+
+	fmt.Println("\n  Downtime:")
+	fmt.Printf("    %-24s %d (%d clean, %d crashed), %s down\n", "Bridge restarts",
+		restarts+crashes, restarts, crashes, humanMinutes(int64(total[store.LinkBridge].Minutes())))
+	fmt.Printf("    %-24s %d, %s down\n", "TeamSpeak outages",
+		count[store.LinkTeamSpeak], humanMinutes(int64(total[store.LinkTeamSpeak].Minutes())))
+	fmt.Printf("    %-24s %d, %s failing\n", "Discord update outages",
+		count[store.LinkDiscord], humanMinutes(int64(total[store.LinkDiscord].Minutes())))
+
+	slices.SortStableFunc(outages, func(a, b store.Outage) int { return cmp.Compare(b.Duration(until), a.Duration(until)) })
+
+	for _, o := range outages[:min(recapOutages, len(outages))] {
+		if o.Duration(until) < time.Minute {
+			break
+		}
+
+		kind := o.Link
+		if o.Crash {
+			kind += " crash"
+		}
+
+		fmt.Printf("      %s  %-16s %s\n", fmtTime(o.Start.Unix(), loc), kind, humanMinutes(int64(o.Duration(until).Minutes())))
+	}
+
+	return nil
+}
+
+// writeHeatmap renders the average user count per weekday and hour to
+// recapHeatmap.
+func writeHeatmap(cmd *cobra.Command, renderer chart.Renderer, db *sql.DB, where string, args []any, loc *time.Location) error {
+	rows, err := db.Query(`SELECT ts, total_users FROM samples `+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read samples: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sums, counts [7][24]float64
+
+	for rows.Next() {
+		var ts, users int64
+
+		if err := rows.Scan(&ts, &users); err != nil {
+			return fmt.Errorf("failed to scan sample: %w", err)
+		}
+
+		t := time.Unix(ts, 0).In(loc)
+		day := (int(t.Weekday()) + 6) % 7 // Monday first
+		sums[day][t.Hour()] += float64(users)
+		counts[day][t.Hour()]++
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	heatmap := chart.Heatmap{
+		Title: fmt.Sprintf("Average users online by hour (%s)", loc),
+		Rows:  []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"},
+	}
+
+	for h := range 24 {
+		heatmap.Columns = append(heatmap.Columns, fmt.Sprintf("%02d", h))
+	}
+
+	for day := range sums {
+		row := make([]float64, 24)
+
+		for h := range row {
+			if counts[day][h] > 0 {
+				row[h] = sums[day][h] / counts[day][h]
+			}
+		}
+
+		heatmap.Values = append(heatmap.Values, row)
+	}
+
+	img, err := renderer.Heatmap(cmd.Context(), heatmap)
+	if err != nil {
+		return fmt.Errorf("failed to render heatmap: %w", err)
+	}
+
+	if err := os.WriteFile(recapHeatmap, img, 0o644); err != nil {
+		return fmt.Errorf("failed to write heatmap: %w", err)
+	}
+
+	return nil
+}
+
 func loadLocation(name string) (*time.Location, error) {
 	if name == "" || name == "Local" {
 		return time.Local, nil
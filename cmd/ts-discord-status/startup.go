@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+// resolveTimeout bounds each DNS lookup of the startup log.
+const resolveTimeout = 2 * time.Second
+
+// logStartup logs what this run is made of in a few structured lines, so a
+// support request can be answered from the startup log alone. The Discord
+// side (channel, guild, permissions) is logged by the discord service once
+// it is connected. Secrets are never logged.
+func logStartup(ctx context.Context, log logrus.FieldLogger, cfg *config.Config) {
+	path, err := filepath.Abs(configPath)
+	if err != nil {
+		path = configPath
+	}
+
+	mode := "bot"
+	if cfg.Discord.WebhookURL != "" {
+		mode = "webhook"
+	}
+
+	log.WithFields(logrus.Fields{
+		"version":         version,
+		"go":              runtime.Version(),
+		"platform":        runtime.GOOS + "/" + runtime.GOARCH,
+		"sqlite":          store.Available,
+		"config":          path,
+		"features":        strings.Join(cfg.EnabledFeatures(), ","),
+		"discord_mode":    mode,
+		"discord_channel": cfg.Discord.StatusChannelID(),
+		"extra_channels":  len(cfg.Discord.Channels),
+		"update_interval": cfg.Display.UpdateInterval,
+	}).Info("Starting ts-discord-status")
+
+	servers := cfg.TeamSpeakServers
+	if cfg.TeamSpeak.Host != "" {
+		servers = cfg.TeamSpeak.VirtualServers()
+	}
+
+	for _, ts := range servers {
+		log.WithFields(logrus.Fields{
+			"server":    serverLabel(ts),
+			"endpoint":  net.JoinHostPort(ts.Host, strconv.Itoa(ts.QueryPort)),
+			"protocol":  ts.Protocol,
+			"server_id": ts.ServerID,
+			"resolved":  resolveHost(ctx, ts.Host),
+		}).Info("TeamSpeak endpoint")
+	}
+
+	if n := len(cfg.MumbleServers) + len(cfg.JSONSources) + len(cfg.HostedServers) + len(cfg.MinecraftServers) + len(cfg.GameServers); n > 0 {
+		log.WithFields(logrus.Fields{
+			"mumble":    len(cfg.MumbleServers),
+			"json":      len(cfg.JSONSources),
+			"hosted":    len(cfg.HostedServers),
+			"minecraft": len(cfg.MinecraftServers),
+			"game":      len(cfg.GameServers),
+		}).Info("Other sources")
+	}
+}
+
+// resolveHost returns the addresses host resolves to, or the lookup error,
+// as one log value.
+func resolveHost(ctx context.Context, host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return strings.Join(addrs, ",")
+}
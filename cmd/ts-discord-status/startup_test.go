@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/config"
+)
+
+func TestLogStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+teamspeak: {host: 127.0.0.1, password: secret, label: eu-1}
+discord: {webhook_url: "https://discord.com/api/webhooks/1/hook-secret"}
+json_sources: [{url: "https://example.com/state.json"}]
+`), 0o600))
+
+	cfg, err := config.Load(path, false)
+	require.NoError(t, err)
+
+	configPath = path
+	t.Cleanup(func() { configPath = "" })
+
+	log, hook := logtest.NewNullLogger()
+	logStartup(context.Background(), log, cfg)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 3)
+
+	require.Equal(t, "Starting ts-discord-status", entries[0].Message)
+	require.Equal(t, "webhook", entries[0].Data["discord_mode"])
+	require.Equal(t, path, entries[0].Data["config"])
+
+	require.Equal(t, "TeamSpeak endpoint", entries[1].Message)
+	require.Equal(t, "eu-1", entries[1].Data["server"])
+	require.Equal(t, "127.0.0.1:10011", entries[1].Data["endpoint"])
+	require.Equal(t, "127.0.0.1", entries[1].Data["resolved"])
+
+	require.Equal(t, "Other sources", entries[2].Message)
+	require.Equal(t, 1, entries[2].Data["json"])
+
+	// Secrets stay out of the log.
+	for _, e := range entries {
+		line, err := e.String()
+		require.NoError(t, err)
+		require.NotContains(t, line, "secret")
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	require.Equal(t, "10.0.0.1", resolveHost(context.Background(), "10.0.0.1"))
+	require.Contains(t, resolveHost(context.Background(), "nonexistent.invalid"), "error: ")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+// errNoSQLite is returned for features needing the database in builds without
+// SQLite support.
+var errNoSQLite = errors.New("this build has no SQLite support (built with -tags nosqlite); " +
+	"disable the database or use a build without the tag")
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version and the optional features compiled in",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sqlite := "no"
+		if store.Available {
+			sqlite = "yes"
+		}
+
+		fmt.Printf("ts-discord-status %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		fmt.Printf("sqlite: %s\n", sqlite)
+
+		return nil
+	},
+}
@@ -0,0 +1,363 @@
+// Package a2s reports game server status as a teamspeak.Source using Valve's
+// A2S query protocol, spoken by Source and many other engines (CS2, Valheim,
+// Rust, ARK, ...).
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// defaultTimeout bounds a whole query exchange.
+	defaultTimeout = 5 * time.Second
+
+	// maxPacketSize is the largest single A2S response packet.
+	maxPacketSize = 1400
+
+	headerInfo       = 'T'
+	headerPlayer     = 'U'
+	headerInfoResp   = 'I'
+	headerPlayerResp = 'D'
+	headerChallenge  = 'A'
+)
+
+// singlePacket prefixes every unsplit request and response.
+var singlePacket = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+// errSplit is returned for multi-packet responses, which only servers with
+// very large player lists send.
+var errSplit = errors.New("split responses are not supported")
+
+// Config holds game server settings.
+type Config struct {
+	Name    string // Display name (defaults to the server's own name)
+	Host    string
+	Port    int // Query port, often the game port or game port + 1
+	Timeout time.Duration
+}
+
+// info is the subset of an A2S_INFO response shown in the embed.
+type info struct {
+	Name       string
+	Map        string
+	Game       string
+	Players    int
+	MaxPlayers int
+}
+
+// player is an entry of an A2S_PLAYER response.
+type player struct {
+	Name     string
+	Duration time.Duration
+}
+
+type service struct {
+	log logrus.FieldLogger
+	cfg Config
+}
+
+// NewService creates an A2S source.
+func NewService(log logrus.FieldLogger, cfg Config) teamspeak.Source {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &service{
+		log: log.WithField("component", "a2s"),
+		cfg: cfg,
+	}
+}
+
+// Start checks the server answers queries.
+func (s *service) Start(ctx context.Context) error {
+	if _, err := s.GetState(ctx); err != nil {
+		return err
+	}
+
+	s.log.WithField("address", s.address()).Info("Game server reachable")
+
+	return nil
+}
+
+// Stop is a no-op; every fetch uses its own socket.
+func (s *service) Stop() error {
+	return nil
+}
+
+// GetState queries server info and the player list. Named players are shown
+// in a single "Players" channel; a failing player query still reports counts.
+func (s *service) GetState(ctx context.Context) (*teamspeak.State, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "udp", s.address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial game server: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if dl, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(dl); err != nil {
+			return nil, fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+
+	inf, err := queryInfo(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	name := s.cfg.Name
+	if name == "" {
+		name = inf.Name
+	}
+
+	state := &teamspeak.State{
+		ServerName: name,
+		TotalUsers: inf.Players,
+		MaxClients: inf.MaxPlayers,
+		FetchedAt:  now,
+	}
+
+	if inf.Map != "" {
+		state.Subtitle = "Map: " + inf.Map
+	}
+
+	players, err := queryPlayers(conn)
+	if err != nil {
+		s.log.WithError(err).Debug("Failed to query player list")
+
+		return state, nil
+	}
+
+	ch := teamspeak.Channel{ID: 1, Name: "Players", IsPermanent: true}
+
+	for _, p := range players {
+		// Connecting players and some bots have no name yet.
+		if p.Name == "" {
+			continue
+		}
+
+		ch.Users = append(ch.Users, teamspeak.User{
+			Nickname:    p.Name,
+			ChannelID:   ch.ID,
+			ConnectedAt: now.Add(-p.Duration),
+		})
+	}
+
+	if len(ch.Users) > 0 {
+		state.Channels = []teamspeak.Channel{ch}
+	}
+
+	return state, nil
+}
+
+func (s *service) address() string {
+	return net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+}
+
+// queryInfo sends A2S_INFO, answering a challenge if the server issues one.
+func queryInfo(conn net.Conn) (*info, error) {
+	req := append(append([]byte{}, singlePacket...), headerInfo)
+	req = append(req, "Source Engine Query\x00"...)
+
+	resp, err := exchange(conn, req, req, headerInfoResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query server info: %w", err)
+	}
+
+	return parseInfo(resp)
+}
+
+// queryPlayers sends A2S_PLAYER, which always needs a challenge first.
+func queryPlayers(conn net.Conn) ([]player, error) {
+	req := append(append([]byte{}, singlePacket...), headerPlayer)
+
+	resp, err := exchange(conn, append(req, singlePacket...), req, headerPlayerResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query players: %w", err)
+	}
+
+	return parsePlayers(resp)
+}
+
+// exchange sends first and, if the server answers with a challenge, resends
+// retry with the challenge appended. It returns the payload after the
+// expected response header.
+func exchange(conn net.Conn, first, retry []byte, want byte) ([]byte, error) {
+	resp, err := roundTrip(conn, first)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) == 5 && resp[0] == headerChallenge {
+		resp, err = roundTrip(conn, append(append([]byte{}, retry...), resp[1:5]...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(resp) == 0 || resp[0] != want {
+		return nil, fmt.Errorf("unexpected response type")
+	}
+
+	return resp[1:], nil
+}
+
+// roundTrip writes a request and returns the response without the
+// single-packet prefix.
+func roundTrip(conn net.Conn, req []byte) ([]byte, error) {
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxPacketSize)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 5 {
+		return nil, fmt.Errorf("short response (%d bytes)", n)
+	}
+
+	if !bytes.Equal(buf[:4], singlePacket) {
+		return nil, errSplit
+	}
+
+	return buf[4:n], nil
+}
+
+// parseInfo decodes an A2S_INFO payload.
+func parseInfo(b []byte) (*info, error) {
+	r := reader{b: b}
+
+	r.byte() // protocol version
+
+	inf := &info{
+		Name: r.string(),
+		Map:  r.string(),
+	}
+
+	r.string() // folder
+	inf.Game = r.string()
+	r.uint16() // app id
+	inf.Players = int(r.byte())
+	inf.MaxPlayers = int(r.byte())
+
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed server info: %w", r.err)
+	}
+
+	return inf, nil
+}
+
+// parsePlayers decodes an A2S_PLAYER payload.
+func parsePlayers(b []byte) ([]player, error) {
+	r := reader{b: b}
+
+	count := int(r.byte())
+	players := make([]player, 0, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		r.byte() // index
+
+		p := player{Name: r.string()}
+
+		r.uint32() // score
+
+		if secs := math.Float32frombits(r.uint32()); secs > 0 {
+			p.Duration = time.Duration(float64(secs) * float64(time.Second))
+		}
+
+		players = append(players, p)
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed player list: %w", r.err)
+	}
+
+	return players, nil
+}
+
+// reader decodes little-endian A2S payloads, recording the first error.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.b) < n {
+		r.err = errors.New("unexpected end of payload")
+
+		return nil
+	}
+
+	out := r.b[:n]
+	r.b = r.b[n:]
+
+	return out
+}
+
+func (r *reader) byte() byte {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.err = errors.New("unterminated string")
+
+		return ""
+	}
+
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+
+	return s
+}
@@ -0,0 +1,90 @@
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+var challenge = []byte{1, 2, 3, 4}
+
+// gameServer answers A2S_INFO directly and A2S_PLAYER after a challenge.
+func gameServer(t *testing.T) *net.UDPAddr {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			req := buf[4:n]
+
+			var resp bytes.Buffer
+
+			resp.Write(singlePacket)
+
+			switch {
+			case req[0] == headerInfo:
+				resp.WriteByte(headerInfoResp)
+				resp.WriteByte(17)
+				resp.WriteString("Test Server\x00de_dust2\x00csgo\x00Counter-Strike 2\x00")
+				_ = binary.Write(&resp, binary.LittleEndian, uint16(730))
+				resp.Write([]byte{2, 10, 0})
+			case req[0] == headerPlayer && !bytes.Equal(req[1:5], challenge):
+				resp.WriteByte(headerChallenge)
+				resp.Write(challenge)
+			case req[0] == headerPlayer:
+				resp.WriteByte(headerPlayerResp)
+				resp.WriteByte(2)
+
+				for i, name := range []string{"alice", ""} {
+					resp.WriteByte(byte(i))
+					resp.WriteString(name + "\x00")
+					_ = binary.Write(&resp, binary.LittleEndian, int32(10))
+					_ = binary.Write(&resp, binary.LittleEndian, math.Float32bits(90))
+				}
+			}
+
+			_, _ = conn.WriteToUDP(resp.Bytes(), addr)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestGetState(t *testing.T) {
+	addr := gameServer(t)
+
+	svc := NewService(logrus.New(), Config{Host: addr.IP.String(), Port: addr.Port, Timeout: time.Second})
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Test Server", state.ServerName)
+	require.Equal(t, "Map: de_dust2", state.Subtitle)
+	require.Equal(t, 2, state.TotalUsers)
+	require.Equal(t, 10, state.MaxClients)
+	require.Len(t, state.Channels, 1)
+	require.Len(t, state.Channels[0].Users, 1)
+	require.Equal(t, "alice", state.Channels[0].Users[0].Nickname)
+	require.WithinDuration(t, time.Now().Add(-90*time.Second), state.Channels[0].Users[0].ConnectedAt, 5*time.Second)
+}
+
+func TestParseInfoTruncated(t *testing.T) {
+	_, err := parseInfo([]byte{17, 'x'})
+	require.Error(t, err)
+}
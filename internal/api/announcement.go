@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+)
+
+// announcementRequest is the body of POST /api/v1/announcement.
+type announcementRequest struct {
+	Text     string `json:"text"`     // Line shown in the embed
+	Duration string `json:"duration"` // How long to show it, e.g. "45m" (default 30m)
+}
+
+// handleAnnounce sets the announcement line.
+func (s *service) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	var req announcementRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+
+		return
+	}
+
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+
+		return
+	}
+
+	s.announce(w, r, req.Text, req.Duration)
+}
+
+// handleClearAnnouncement removes the announcement line.
+func (s *service) handleClearAnnouncement(w http.ResponseWriter, r *http.Request) {
+	s.bridge.Announce(r.Context(), "", 0)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cleared"})
+}
+
+// announce validates and applies an announcement submitted through any
+// endpoint.
+func (s *service) announce(w http.ResponseWriter, r *http.Request, text, duration string) {
+	d, err := parseAnnouncement(text, duration)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	s.bridge.Announce(r.Context(), text, d)
+
+	s.log.WithField("duration", d).Info("Announcement received via HTTP")
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "announced"})
+}
+
+// parseAnnouncement checks the text length and parses the optional duration;
+// an empty duration yields 0, leaving the default to the bridge.
+func parseAnnouncement(text, duration string) (time.Duration, error) {
+	if utf8.RuneCountInString(text) > discord.MaxAnnouncementLength {
+		return 0, fmt.Errorf("announcement is longer than %d characters", discord.MaxAnnouncementLength)
+	}
+
+	if duration == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", duration)
+	}
+
+	return d, nil
+}
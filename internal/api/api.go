@@ -0,0 +1,228 @@
+// Package api serves the optional HTTP API used by external integrations.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// maxBodySize bounds request bodies; every endpoint takes small JSON.
+	maxBodySize = 64 * 1024
+
+	// shutdownTimeout bounds how long Stop waits for in-flight requests.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Config holds HTTP API settings.
+type Config struct {
+	Listen string // Address to listen on, e.g. ":8080"
+	Token  string // Bearer token required on /api routes
+	// Metrics serves Prometheus metrics on /metrics (unauthenticated).
+	Metrics bool
+	// Pprof serves Go runtime profiles on /debug/pprof/ (authenticated).
+	Pprof bool
+	// JoinQR serves a QR code of the join link on /join.png
+	// (unauthenticated), for JoinAddress or else the address the server
+	// reports. The link never carries the server password, since anyone
+	// reaching the port can decode it.
+	JoinQR      bool
+	JoinAddress string
+}
+
+// Bridge is the part of the bridge the API drives.
+type Bridge interface {
+	// Refresh triggers an immediate update instead of waiting for the next tick.
+	Refresh()
+	// Announce shows text in the embed for the given duration (0 uses the
+	// default); an empty text clears the announcement.
+	Announce(ctx context.Context, text string, duration time.Duration)
+	// History returns the most recent fetches, oldest first.
+	History() []bridge.HistoryEntry
+	// Current returns the state last shown in Discord, or nil before the
+	// first fetch.
+	Current() *teamspeak.State
+	// Silence suppresses notifications of an event type (or
+	// bridge.SilenceAll) for the given duration; 0 lifts the silence.
+	Silence(ctx context.Context, event string, duration time.Duration) error
+	// Silences returns the active silences and when each expires.
+	Silences() map[string]time.Time
+	// Outages returns the recorded outages since the given time, or
+	// bridge.ErrNoStore without the database.
+	Outages(ctx context.Context, since time.Time) ([]store.Outage, error)
+}
+
+// Service defines the HTTP API service interface.
+type Service interface {
+	Start(ctx context.Context) error
+	Stop() error
+}
+
+type service struct {
+	log     logrus.FieldLogger
+	cfg     Config
+	bridge  Bridge
+	handler http.Handler
+
+	lifecycle sync.Mutex   // Serializes Start and Stop
+	server    *http.Server // Nil while stopped
+	wg        sync.WaitGroup
+}
+
+// NewService creates a new HTTP API service.
+func NewService(log logrus.FieldLogger, cfg Config, bridge Bridge) Service {
+	s := &service{
+		log:    log.WithField("component", "api"),
+		cfg:    cfg,
+		bridge: bridge,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/webhook/refresh", s.authenticated(http.HandlerFunc(s.handleRefresh)))
+	mux.Handle("POST /api/v1/announcement", s.authenticated(http.HandlerFunc(s.handleAnnounce)))
+	mux.Handle("DELETE /api/v1/announcement", s.authenticated(http.HandlerFunc(s.handleClearAnnouncement)))
+	mux.Handle("GET /api/v1/state", s.authenticated(http.HandlerFunc(s.handleState)))
+	mux.Handle("GET /api/v1/silences", s.authenticated(http.HandlerFunc(s.handleSilences)))
+	mux.Handle("POST /api/v1/silences", s.authenticated(http.HandlerFunc(s.handleSilence)))
+	mux.Handle("DELETE /api/v1/silences", s.authenticated(http.HandlerFunc(s.handleUnsilence)))
+	mux.Handle("GET /api/v1/downtime", s.authenticated(http.HandlerFunc(s.handleDowntime)))
+	mux.Handle("GET /api/v1/downtime.png", s.authenticated(http.HandlerFunc(s.handleDowntimeChart)))
+	mux.Handle("GET /api/v1/debug/states", s.authenticated(http.HandlerFunc(s.handleStates)))
+
+	if cfg.Metrics {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	if cfg.JoinQR {
+		mux.HandleFunc("GET /join.png", s.handleJoinQR)
+	}
+
+	if cfg.Pprof {
+		mux.Handle("GET /debug/pprof/", s.authenticated(http.HandlerFunc(pprof.Index)))
+		mux.Handle("GET /debug/pprof/cmdline", s.authenticated(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("GET /debug/pprof/profile", s.authenticated(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("GET /debug/pprof/symbol", s.authenticated(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("GET /debug/pprof/trace", s.authenticated(http.HandlerFunc(pprof.Trace)))
+	}
+
+	s.handler = mux
+
+	return s
+}
+
+// Start begins listening. Binding happens synchronously so a busy port is
+// reported as a startup error. Starting a running server does nothing.
+func (s *service) Start(ctx context.Context) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.server != nil {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.cfg.Listen, err)
+	}
+
+	// A shut down http.Server cannot serve again, so each start gets its own.
+	server := &http.Server{
+		Handler:           s.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.server = server
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.WithError(err).Error("HTTP server failed")
+		}
+	}()
+
+	s.log.WithField("address", ln.Addr().String()).Info("HTTP API started")
+
+	return nil
+}
+
+// Stop gracefully shuts the server down. Stopping a stopped server does
+// nothing; it can be started again afterwards.
+func (s *service) Stop() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	s.server = nil
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+
+	return nil
+}
+
+// authenticated rejects requests without the configured bearer token.
+func (s *service) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// decodeBody decodes an optional JSON body into v. An empty body leaves v
+// untouched.
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+	"github.com/samcm/ts-discord-status/internal/qr"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestPprof(t *testing.T) {
+	get := func(cfg Config, token string) int {
+		svc := NewService(logrus.New(), cfg, &fakeBridge{}).(*service)
+
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNotFound, get(Config{Token: "secret"}, "secret"))
+	require.Equal(t, http.StatusUnauthorized, get(Config{Token: "secret", Pprof: true}, ""))
+	require.Equal(t, http.StatusOK, get(Config{Token: "secret", Pprof: true}, "secret"))
+}
+
+func TestDebugStates(t *testing.T) {
+	fake := &fakeBridge{history: []bridge.HistoryEntry{{Error: "a"}, {Error: "b"}, {Error: "c"}}}
+	svc := NewService(logrus.New(), Config{Token: "secret"}, fake).(*service)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/debug/states"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := get("?limit=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"states":[{"time":"0001-01-01T00:00:00Z","error":"b"},{"time":"0001-01-01T00:00:00Z","error":"c"}]}`, rec.Body.String())
+
+	require.Equal(t, http.StatusBadRequest, get("?limit=0").Code)
+}
+
+func TestStateETag(t *testing.T) {
+	fake := &fakeBridge{}
+	svc := NewService(logrus.New(), Config{Token: "secret"}, fake).(*service)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/state", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, get("").Code)
+
+	fake.current = &teamspeak.State{ServerName: "Game Night", Version: 7, Hash: "abc123"}
+
+	rec := get("")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `W/"abc123"`, rec.Header().Get("ETag"))
+	require.Equal(t, "7", rec.Header().Get("X-State-Version"))
+	require.Contains(t, rec.Body.String(), "Game Night")
+
+	require.Equal(t, http.StatusNotModified, get(`W/"old", "abc123"`).Code)
+	require.Equal(t, http.StatusNotModified, get(`W/"abc123"`).Code)
+	require.Equal(t, http.StatusOK, get(`"old"`).Code)
+}
+
+func TestSilences(t *testing.T) {
+	fake := &fakeBridge{}
+	svc := NewService(logrus.New(), Config{Token: "secret"}, fake).(*service)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/silences", `{"event":"offline","duration":"2h"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/silences", `{"duration":"30m"}`).Code)
+	require.Contains(t, fake.silences, "offline")
+	require.Contains(t, fake.silences, "all")
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/silences", `{"event":"offline"}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/silences", `{"event":"bogus","duration":"1h"}`).Code)
+
+	rec := do(http.MethodGet, "/api/v1/silences", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"offline":`)
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/silences", "").Code)
+	require.NotContains(t, fake.silences, "all")
+	require.Contains(t, fake.silences, "offline")
+}
+
+func TestRestart(t *testing.T) {
+	svc := NewService(logrus.New(), Config{Listen: "127.0.0.1:0"}, &fakeBridge{})
+	ctx := context.Background()
+
+	require.NoError(t, svc.Start(ctx))
+	require.NoError(t, svc.Start(ctx))
+	require.NoError(t, svc.Stop())
+	require.NoError(t, svc.Stop())
+
+	// A stopped server listens again on a new start.
+	require.NoError(t, svc.Start(ctx))
+	require.NoError(t, svc.Stop())
+}
+
+func TestDowntimeHeatmap(t *testing.T) {
+	now := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-24 * time.Hour)
+
+	heatmap := downtimeHeatmap([]store.Outage{
+		{Link: store.LinkTeamSpeak, Start: now.Add(-90 * time.Minute), End: now.Add(-75 * time.Minute)},
+		{Link: store.LinkBridge, Start: now.Add(-30 * time.Minute), End: now.Add(-25 * time.Minute), Crash: true},
+		{Link: store.LinkDiscord, Start: now.Add(-10 * time.Minute)}, // Ongoing
+	}, since, now)
+
+	require.Equal(t, []string{"Bridge", "TeamSpeak", "Discord"}, heatmap.Rows)
+	require.Len(t, heatmap.Columns, 24)
+	require.Equal(t, "12:00", heatmap.Columns[0])
+	require.InDelta(t, 5, heatmap.Values[0][23], 0.001)
+	require.InDelta(t, 15, heatmap.Values[1][22], 0.001)
+	require.InDelta(t, 10, heatmap.Values[2][23], 0.001)
+}
+
+func TestDowntimeNeedsStore(t *testing.T) {
+	svc := NewService(logrus.New(), Config{Token: "secret"}, &fakeBridge{}).(*service)
+
+	for query, want := range map[string]int{"": http.StatusNotFound, "?since=7d": http.StatusNotFound, "?since=soon": http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/downtime"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+		require.Equal(t, want, rec.Code, query)
+	}
+}
+
+func TestJoinQR(t *testing.T) {
+	svc := NewService(logrus.New(), Config{JoinQR: true, JoinAddress: "ts.example.com"}, &fakeBridge{}).(*service)
+
+	rec := httptest.NewRecorder()
+	svc.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/join.png", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The public code links to the server without its password.
+	code, err := qr.Encode("ts3server://ts.example.com")
+	require.NoError(t, err)
+
+	want, err := code.PNG(joinQRScale)
+	require.NoError(t, err)
+	require.Equal(t, want, rec.Body.Bytes())
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+)
+
+// statesResponse is the body of GET /api/v1/debug/states.
+type statesResponse struct {
+	States []bridge.HistoryEntry `json:"states"` // Oldest first
+}
+
+// handleStates dumps the bridge's recent fetches, optionally only the last
+// ?limit=N of them.
+func (s *service) handleStates(w http.ResponseWriter, r *http.Request) {
+	states := s.bridge.History()
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+
+			return
+		}
+
+		if limit < len(states) {
+			states = states[len(states)-limit:]
+		}
+	}
+
+	writeJSON(w, http.StatusOK, statesResponse{States: states})
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+	"github.com/samcm/ts-discord-status/internal/chart"
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+const (
+	// defaultDowntimeWindow is the window served without a since parameter.
+	defaultDowntimeWindow = 24 * time.Hour
+
+	// maxDowntimeWindow bounds the since parameter.
+	maxDowntimeWindow = 90 * 24 * time.Hour
+
+	// downtimeColumns is the number of time buckets in the downtime chart.
+	downtimeColumns = 24
+)
+
+// downtimeLinks are the chart rows, in display order.
+var downtimeLinks = []struct{ link, label string }{
+	{store.LinkBridge, "Bridge"},
+	{store.LinkTeamSpeak, "TeamSpeak"},
+	{store.LinkDiscord, "Discord"},
+}
+
+// outage is an outage in the downtime response.
+type outage struct {
+	Link    string     `json:"link"`
+	Start   time.Time  `json:"start"`
+	End     *time.Time `json:"end,omitempty"` // Absent while ongoing
+	Seconds int64      `json:"seconds"`
+	Restart bool       `json:"restart"` // A bridge stop or crash rather than a lost connection
+	Crashed bool       `json:"crashed"`
+}
+
+// handleDowntime lists the outages in the window given by the since query
+// parameter, a duration such as "7d" or "12h" (default 24h).
+func (s *service) handleDowntime(w http.ResponseWriter, r *http.Request) {
+	since, now, ok := s.downtimeWindow(w, r)
+	if !ok {
+		return
+	}
+
+	outages, ok := s.outages(w, r, since)
+	if !ok {
+		return
+	}
+
+	out := make([]outage, 0, len(outages))
+
+	for _, o := range outages {
+		entry := outage{
+			Link:    o.Link,
+			Start:   o.Start.UTC(),
+			Seconds: int64(o.Duration(now).Seconds()),
+			Restart: o.Link == store.LinkBridge,
+			Crashed: o.Crash,
+		}
+
+		if !o.End.IsZero() {
+			end := o.End.UTC()
+			entry.End = &end
+		}
+
+		out = append(out, entry)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"since": since.UTC(), "outages": out})
+}
+
+// handleDowntimeChart renders the minutes each link was down per time bucket
+// of the window as a heatmap, for dashboards to embed.
+func (s *service) handleDowntimeChart(w http.ResponseWriter, r *http.Request) {
+	since, now, ok := s.downtimeWindow(w, r)
+	if !ok {
+		return
+	}
+
+	outages, ok := s.outages(w, r, since)
+	if !ok {
+		return
+	}
+
+	img, err := chart.New(chart.Config{}).Heatmap(r.Context(), downtimeHeatmap(outages, since, now))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(img)
+}
+
+// downtimeWindow parses the since parameter, writing the error response when
+// it is invalid.
+func (s *service) downtimeWindow(w http.ResponseWriter, r *http.Request) (since, now time.Time, ok bool) {
+	window := defaultDowntimeWindow
+
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := parseWindow(v)
+		if err != nil || d <= 0 || d > maxDowntimeWindow {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid since %q", v))
+
+			return time.Time{}, time.Time{}, false
+		}
+
+		window = d
+	}
+
+	now = time.Now()
+
+	return now.Add(-window), now, true
+}
+
+// outages loads the outages since the given time, writing the error response
+// when they are unavailable.
+func (s *service) outages(w http.ResponseWriter, r *http.Request, since time.Time) ([]store.Outage, bool) {
+	outages, err := s.bridge.Outages(r.Context(), since)
+	if errors.Is(err, bridge.ErrNoStore) {
+		writeError(w, http.StatusNotFound, "downtime history needs the database to be enabled")
+
+		return nil, false
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+
+		return nil, false
+	}
+
+	return outages, true
+}
+
+// parseWindow parses a duration, also accepting whole days such as "7d".
+func parseWindow(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(v)
+}
+
+// downtimeHeatmap buckets the outages into downtimeColumns equal spans of
+// [since, now], one row per link, valued in minutes down.
+func downtimeHeatmap(outages []store.Outage, since, now time.Time) chart.Heatmap {
+	bucket := now.Sub(since) / downtimeColumns
+
+	layout := "15:04"
+	if bucket >= 24*time.Hour {
+		layout = "Jan 2"
+	}
+
+	heatmap := chart.Heatmap{Title: "Minutes down since " + since.Format("Jan 2 15:04")}
+
+	for i := range downtimeColumns {
+		heatmap.Columns = append(heatmap.Columns, since.Add(time.Duration(i)*bucket).Format(layout))
+	}
+
+	for _, l := range downtimeLinks {
+		row := make([]float64, downtimeColumns)
+
+		for _, o := range outages {
+			if o.Link != l.link {
+				continue
+			}
+
+			end := o.End
+			if end.IsZero() || end.After(now) {
+				end = now
+			}
+
+			for i := range row {
+				from := since.Add(time.Duration(i) * bucket)
+				to := from.Add(bucket)
+
+				if o.Start.After(from) {
+					from = o.Start
+				}
+
+				if end.Before(to) {
+					to = end
+				}
+
+				if to.After(from) {
+					row[i] += to.Sub(from).Minutes()
+				}
+			}
+		}
+
+		heatmap.Rows = append(heatmap.Rows, l.label)
+		heatmap.Values = append(heatmap.Values, row)
+	}
+
+	return heatmap
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/samcm/ts-discord-status/internal/qr"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// joinQRScale is the pixels per module of the served QR code.
+const joinQRScale = 8
+
+// handleJoinQR serves a QR code of the ts3server:// join link, for pages and
+// posters that link to it. The address is the configured one, else the one
+// the server reported.
+func (s *service) handleJoinQR(w http.ResponseWriter, _ *http.Request) {
+	address := s.cfg.JoinAddress
+	if address == "" {
+		if state := s.bridge.Current(); state != nil {
+			address = state.Address
+		}
+	}
+
+	uri := teamspeak.JoinURI(address, "")
+	if uri == "" {
+		writeError(w, http.StatusNotFound, "no connect address known")
+
+		return
+	}
+
+	code, err := qr.Encode(uri)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	img, err := code.PNG(joinQRScale)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(img)
+}
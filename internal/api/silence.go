@@ -0,0 +1,62 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+)
+
+// silenceRequest is the body of POST /api/v1/silences.
+type silenceRequest struct {
+	Event    string `json:"event"`    // Event type to suppress (default "all")
+	Duration string `json:"duration"` // How long to suppress it, e.g. "2h"
+}
+
+// handleSilences lists the active silences and when each expires.
+func (s *service) handleSilences(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"silences": s.bridge.Silences()})
+}
+
+// handleSilence suppresses notifications of one event type, or all of them.
+func (s *service) handleSilence(w http.ResponseWriter, r *http.Request) {
+	var req silenceRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+
+		return
+	}
+
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid duration %q", req.Duration))
+
+		return
+	}
+
+	event := cmp.Or(req.Event, bridge.SilenceAll)
+
+	if err := s.bridge.Silence(r.Context(), event, d); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"event": event, "expires": time.Now().Add(d).UTC()})
+}
+
+// handleUnsilence lifts the silence named by the event query parameter
+// (default "all").
+func (s *service) handleUnsilence(w http.ResponseWriter, r *http.Request) {
+	event := cmp.Or(r.URL.Query().Get("event"), bridge.SilenceAll)
+
+	if err := s.bridge.Silence(r.Context(), event, 0); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "lifted"})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleState serves the state last shown in Discord. The content hash is a
+// weak ETag, as it leaves out the fetch time and uptime the body carries, so
+// pollers sending If-None-Match get a bodiless 304 until something visible
+// changes; X-State-Version matches the version in the embed footer and the
+// debug history.
+func (s *service) handleState(w http.ResponseWriter, r *http.Request) {
+	state := s.bridge.Current()
+	if state == nil {
+		writeError(w, http.StatusServiceUnavailable, "no state fetched yet")
+
+		return
+	}
+
+	etag := `"` + state.Hash + `"`
+	w.Header().Set("ETag", "W/"+etag)
+	w.Header().Set("X-State-Version", strconv.FormatUint(state.Version, 10))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, weak or
+// strong, or is "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
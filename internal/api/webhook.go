@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+)
+
+// refreshRequest is the optional body of the refresh webhook.
+type refreshRequest struct {
+	Announcement string `json:"announcement"` // Line shown in the embed
+	Duration     string `json:"duration"`     // How long to show it, e.g. "45m"
+}
+
+// handleRefresh forces an immediate update, optionally showing an
+// announcement line in the embed for a while.
+func (s *service) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+
+		return
+	}
+
+	if req.Announcement == "" {
+		s.bridge.Refresh()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "refreshing"})
+
+		return
+	}
+
+	s.announce(w, r, req.Announcement, req.Duration)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/bridge"
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+type fakeBridge struct {
+	refreshes    int
+	announcement string
+	duration     time.Duration
+	history      []bridge.HistoryEntry
+	current      *teamspeak.State
+	silences     map[string]time.Time
+	outages      []store.Outage
+}
+
+func (b *fakeBridge) Refresh() { b.refreshes++ }
+
+func (b *fakeBridge) History() []bridge.HistoryEntry { return b.history }
+
+func (b *fakeBridge) Current() *teamspeak.State { return b.current }
+
+func (b *fakeBridge) Silences() map[string]time.Time { return b.silences }
+
+func (b *fakeBridge) Silence(_ context.Context, event string, d time.Duration) error {
+	if event == "bogus" {
+		return errors.New("unknown event")
+	}
+
+	if b.silences == nil {
+		b.silences = make(map[string]time.Time)
+	}
+
+	if d == 0 {
+		delete(b.silences, event)
+	} else {
+		b.silences[event] = time.Now().Add(d)
+	}
+
+	return nil
+}
+
+func (b *fakeBridge) Outages(context.Context, time.Time) ([]store.Outage, error) {
+	if b.outages == nil {
+		return nil, bridge.ErrNoStore
+	}
+
+	return b.outages, nil
+}
+
+func (b *fakeBridge) Announce(_ context.Context, text string, d time.Duration) {
+	b.announcement = text
+	b.duration = d
+}
+
+func TestWebhookRefresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+		wantBridge fakeBridge
+	}{
+		{name: "missing token", body: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "empty body refreshes", token: "secret", wantStatus: http.StatusAccepted, wantBridge: fakeBridge{refreshes: 1}},
+		{
+			name: "announcement with default duration", token: "secret", body: `{"announcement":"hi"}`,
+			wantStatus: http.StatusAccepted, wantBridge: fakeBridge{announcement: "hi"},
+		},
+		{
+			name: "announcement with duration", token: "secret", body: `{"announcement":"hi","duration":"5m"}`,
+			wantStatus: http.StatusAccepted, wantBridge: fakeBridge{announcement: "hi", duration: 5 * time.Minute},
+		},
+		{name: "bad duration", token: "secret", body: `{"announcement":"hi","duration":"-1m"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", token: "secret", body: `{"text":"hi"}`, wantStatus: http.StatusBadRequest},
+		{
+			name: "too long", token: "secret", body: `{"announcement":"` + strings.Repeat("x", discord.MaxAnnouncementLength+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bridge := &fakeBridge{}
+			svc := NewService(logrus.New(), Config{Token: "secret"}, bridge).(*service)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhook/refresh", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			svc.handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			require.Equal(t, tt.wantBridge, *bridge)
+		})
+	}
+}
+
+func TestAnnouncementEndpoints(t *testing.T) {
+	bridge := &fakeBridge{announcement: "old"}
+	svc := NewService(logrus.New(), Config{Token: "secret"}, bridge).(*service)
+
+	do := func(method, body string) int {
+		req := httptest.NewRequest(method, "/api/v1/announcement", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		svc.handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, `{}`))
+	require.Equal(t, http.StatusAccepted, do(http.MethodPost, `{"text":"Event at 20:00!","duration":"2h"}`))
+	require.Equal(t, fakeBridge{announcement: "Event at 20:00!", duration: 2 * time.Hour}, *bridge)
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, ""))
+	require.Empty(t, bridge.announcement)
+}
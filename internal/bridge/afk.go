@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// AFKConfig controls notifications about users idling outside AFK channels.
+type AFKConfig struct {
+	Enabled     bool
+	IdleAfter   time.Duration // Idle time that triggers a notification
+	AFKChannels []string      // Case-insensitive name substrings of channels where idling is fine
+	Poke        bool          // Also poke the user in TeamSpeak
+	PokeMessage string        // Poke text; {idle} is replaced with the idle time
+}
+
+// checkIdle notifies once per idle stretch about users idle beyond the
+// threshold in a non-AFK channel. A user becomes eligible again once they are
+// active, leave, or move to an AFK channel.
+func (s *service) checkIdle(ctx context.Context, state *teamspeak.State) {
+	idle := make(map[string]struct{}, len(s.idleNotified))
+
+	for _, ch := range state.Channels {
+		if s.isAFKChannel(ch.Name) {
+			continue
+		}
+
+		for _, u := range ch.Users {
+			if u.IdleTime < s.cfg.AFK.IdleAfter {
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%d", u.Server, u.ID)
+			idle[key] = struct{}{}
+
+			if _, ok := s.idleNotified[key]; ok {
+				continue
+			}
+
+			s.notifyIdle(ctx, state, ch, u)
+		}
+	}
+
+	s.idleNotified = idle
+}
+
+// notifyIdle sends the moderation notification and poke for one idle user.
+func (s *service) notifyIdle(ctx context.Context, state *teamspeak.State, ch teamspeak.Channel, u teamspeak.User) {
+	label, where := userServer(state, u)
+	log := s.log.WithFields(logrus.Fields{"nickname": u.Nickname, "server": label})
+
+	s.emit(ctx, alert{
+		event:   notify.EventModeration,
+		kind:    kindIdle,
+		subject: discord.EscapeMarkdown(u.Nickname),
+		text: fmt.Sprintf("💤 **%s** has been idle for %s in **#%s**%s",
+			discord.EscapeMarkdown(u.Nickname), shortDuration(u.IdleTime), discord.EscapeMarkdown(ch.Name), where),
+		summary: fmt.Sprintf("💤 {count} users have been idle for %s or more", shortDuration(s.cfg.AFK.IdleAfter)),
+	}, label)
+
+	if !s.cfg.AFK.Poke {
+		return
+	}
+
+	poker, ok := s.teamspeak.(teamspeak.Poker)
+	if !ok {
+		return
+	}
+
+	msg := strings.ReplaceAll(s.cfg.AFK.PokeMessage, "{idle}", shortDuration(u.IdleTime))
+	if err := poker.Poke(ctx, u, msg); err != nil {
+		log.WithError(err).Warn("Failed to poke idle user")
+	}
+}
+
+// isAFKChannel reports whether idling in the named channel is expected.
+func (s *service) isAFKChannel(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, afk := range s.cfg.AFK.AFKChannels {
+		if strings.Contains(name, strings.ToLower(afk)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shortDuration formats d to the minute, e.g. "1h30m".
+func shortDuration(d time.Duration) string {
+	out := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(out, "h0m") {
+		out = strings.TrimSuffix(out, "0m")
+	}
+
+	return out
+}
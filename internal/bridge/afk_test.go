@@ -0,0 +1,14 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortDuration(t *testing.T) {
+	require.Equal(t, "45m", shortDuration(45*time.Minute))
+	require.Equal(t, "1h30m", shortDuration(90*time.Minute))
+	require.Equal(t, "2h", shortDuration(2*time.Hour+10*time.Second))
+}
@@ -3,55 +3,241 @@ package bridge
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/logsample"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/pipeline"
+	"github.com/samcm/ts-discord-status/internal/scheduler"
 	"github.com/samcm/ts-discord-status/internal/store"
 	"github.com/samcm/ts-discord-status/internal/teamspeak"
 )
 
+// defaultAnnouncementDuration applies when an announcement has no duration.
+const defaultAnnouncementDuration = 30 * time.Minute
+
 // Config holds bridge configuration.
 type Config struct {
 	UpdateInterval time.Duration
 	RecordInterval time.Duration
+	StaleAfter     time.Duration // Age at which the last good state is re-rendered as stale (0 disables)
+	Collage        CollageConfig
+
+	UploadIconEmojis      bool // Upload channel icons as application emojis
+	IconsForEmptyChannels bool // Also upload icons of empty channels
+
+	// Stages prepare each fetched state for display (sanitize, filter), ahead
+	// of the bridge's own enrich stage and rendering. Recording always uses
+	// the state as fetched.
+	Stages []pipeline.Middleware
+
+	// PublicFilter hides channels from the API, notifications and
+	// subscriptions on top of Stages, which only hide what every status
+	// channel hides. It is the main display's filter, so a channel a single
+	// status channel shows is not named anywhere else.
+	PublicFilter teamspeak.ChannelFilter
+
+	// LogSampler limits repeated warnings during outages; nil logs all.
+	LogSampler *logsample.Sampler
+
+	AFK AFKConfig
+
+	// TrackChanges logs joins, leaves and moves for the "What changed?"
+	// button. Requires the store.
+	TrackChanges bool
+
+	// Subscriptions lets Discord users subscribe to a DM when a nickname
+	// comes online or the user count reaches a threshold. Requires the
+	// store.
+	Subscriptions bool
+
+	// SubscriptionRoles are the Discord roles allowed to subscribe to a
+	// nickname, so members cannot watch someone without the server's say;
+	// without any, only user count subscriptions are allowed.
+	SubscriptionRoles []string
+
+	Failover      FailoverConfig
+	OutageAlert   OutageAlertConfig
+	Digest        DigestConfig
+	Notifications NotificationsConfig
+	Forecast      ForecastConfig
+
+	// StateHistory is how many recent fetches to keep for diagnostics (0
+	// disables).
+	StateHistory int
+
+	// MetricsChannels are the channel names (case-insensitive) exported as
+	// per-channel user gauges; every name is a time series, so only listed
+	// channels are exported.
+	MetricsChannels []string
 }
 
 // Service defines the bridge service interface.
 type Service interface {
 	Start(ctx context.Context) error
 	Stop() error
+	// Refresh requests an immediate update without waiting for the next tick.
+	Refresh()
+	// Announce shows text in the embed for the given duration (0 uses the
+	// default) and refreshes. An empty text clears the announcement.
+	Announce(ctx context.Context, text string, duration time.Duration)
+	// History returns the most recent fetches, oldest first.
+	History() []HistoryEntry
+	// Current returns the state last handed to Discord, as the main status
+	// channel displays it, or nil before the first successful fetch.
+	Current() *teamspeak.State
+	// Silence suppresses notifications of an event type (or SilenceAll) for
+	// the given duration; 0 lifts the silence.
+	Silence(ctx context.Context, event string, duration time.Duration) error
+	// Silences returns the active silences and when each expires.
+	Silences() map[string]time.Time
+	// Outages returns the recorded bridge, TeamSpeak and Discord outages
+	// since the given time, or ErrNoStore without the database.
+	Outages(ctx context.Context, since time.Time) ([]store.Outage, error)
 }
 
 type service struct {
-	log        logrus.FieldLogger
-	cfg        Config
-	teamspeak  teamspeak.Service
-	discord    discord.Service
-	store      store.Service
-	lastRecord time.Time
-	done       chan struct{}
-	wg         sync.WaitGroup
+	log          logrus.FieldLogger
+	cfg          Config
+	teamspeak    teamspeak.Source
+	discord      discord.Service
+	store        store.Service
+	lastRecord   time.Time
+	lastState    *teamspeak.State                // Last successfully fetched state
+	avatars      map[string][]byte               // Avatar images by client unique id, owned by the collage task
+	builtRoster  string                          // Roster the shown collage was built for
+	collageBuilt bool                            // The shown collage is complete for builtRoster
+	collageImage []byte                          // The shown collage
+	iconsTried   map[uint32]struct{}             // Channel icons already offered for upload
+	idleNotified map[string]struct{}             // Idle users already notified about
+	history      *history                        // Recent fetches for diagnostics
+	changesPrev  *teamspeak.State                // Previous displayed state, for the change log
+	version      uint64                          // Version of the last fetched state
+	versionHash  string                          // Content hash of that state
+	current      atomic.Pointer[teamspeak.State] // Last displayed state, read by the API
+	queue        notify.Service                  // Batches channel notifications (nil sends directly)
+	presencePrev *teamspeak.State                // Previous displayed state, for join/leave notifications
+
+	fetchFailingSince time.Time // Start of the current run of failed fetches
+	offlineAlerted    bool      // The offline event was sent for the current run
+	capacityAlerted   bool      // The capacity event was sent and has not re-armed
+	metricsServers    []string  // Server labels the occupancy gauges were last set for
+
+	collageMu   sync.Mutex
+	roster      string           // Users the avatar collage should show
+	rosterUsers []teamspeak.User // Those users, in collage order
+	rosterSet   bool             // roster was set by an update
+
+	silenceMu sync.Mutex
+	silences  map[string]time.Time // Silenced event types (or SilenceAll) and when each expires
+
+	forecastAt time.Time // When the busy hours forecast was last computed
+
+	updateFailingSince time.Time // Start of the current run of failed status updates
+	failoverAlerted    bool      // A failover alert was sent for the current run
+
+	links map[string]bool // Connection state of each link last recorded this run
+
+	outageFailures int       // Consecutive failed fetches
+	outageSince    time.Time // When the current run of failed fetches began
+	outageAlerted  bool      // The outage alert was posted for the current run
+
+	subsMu   sync.Mutex
+	subs     []store.Subscription             // DM subscriptions, loaded from the store
+	subsSent map[store.Subscription]time.Time // When each subscription last sent a DM
+	subsPrev *teamspeak.State                 // Previous displayed state, for subscriptions
+	dms      []pendingDM                      // Subscription DMs waiting for the DM task
+
+	started        time.Time      // When the bridge started, for the digest uptime
+	nextDigest     time.Time      // When the next daily digest is due
+	digestSince    time.Time      // Start of the period the next digest covers
+	digestBaseline metrics.Totals // Counters at digestSince
+
+	recorder  store.Service     // The configured store; store is nil while it fails to start
+	scheduler scheduler.Service // Runs the update loop and the digest
+	lifecycle sync.Mutex        // Serializes Start and Stop
+	running   bool
 }
 
 // NewService creates a new bridge service. store may be nil to disable
-// status recording.
-func NewService(log logrus.FieldLogger, cfg Config, ts teamspeak.Service, dc discord.Service, st store.Service) Service {
-	return &service{
-		log:       log.WithField("component", "bridge"),
-		cfg:       cfg,
-		teamspeak: ts,
-		discord:   dc,
-		store:     st,
-		done:      make(chan struct{}),
+// status recording. Avatars and icons are only available when ts also
+// implements teamspeak.Files.
+func NewService(log logrus.FieldLogger, cfg Config, ts teamspeak.Source, dc discord.Service, st store.Service) Service {
+	s := &service{
+		log:        log.WithField("component", "bridge"),
+		cfg:        cfg,
+		teamspeak:  ts,
+		discord:    dc,
+		store:      st,
+		recorder:   st,
+		iconsTried: make(map[uint32]struct{}),
+		history:    newHistory(cfg.StateHistory),
+		silences:   make(map[string]time.Time),
+		subsSent:   make(map[store.Subscription]time.Time),
+	}
+
+	if cfg.Notifications.Queue.Size > 0 {
+		s.queue = notify.NewService(log, cfg.Notifications.Queue, s.send)
+	}
+
+	s.scheduler = scheduler.New(s.log)
+	s.scheduler.Add(scheduler.Task{Name: updateTask, Interval: cfg.UpdateInterval, Run: s.update})
+
+	if cfg.Subscriptions {
+		s.scheduler.Add(scheduler.Task{Name: dmTask, Interval: dmInterval, Run: s.sendDMs})
+	}
+
+	if cfg.Collage.Enabled {
+		s.scheduler.Add(scheduler.Task{Name: collageTask, Interval: cfg.UpdateInterval, Run: s.buildCollage})
+	}
+
+	if cfg.Digest.Enabled {
+		s.scheduler.Add(scheduler.Task{Name: "digest", Interval: digestCheckInterval, Run: func(ctx context.Context) error {
+			s.maybeSendDigest(ctx, time.Now())
+
+			return nil
+		}})
 	}
+
+	return s
 }
 
-// Start begins the sync loop.
-func (s *service) Start(ctx context.Context) error {
+// Start begins the sync loop. Starting a running bridge does nothing; a
+// stopped one reconnects and starts over, so it can be restarted in-process.
+func (s *service) Start(ctx context.Context) (err error) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	// A failing step stops whatever started before it, so a failed Start
+	// leaves nothing running. Stopping a service that never started does
+	// nothing.
+	defer func() {
+		if err != nil {
+			s.trackLink(context.Background(), store.LinkBridge, false)
+			s.stopServices()
+		}
+	}()
+
+	s.started = time.Now()
+	s.store = nil
+	// Like at startup, the first state has nothing to announce joins against.
+	s.presencePrev = nil
+	s.subsPrev = nil
+
 	// Start TeamSpeak connection
 	if err := s.teamspeak.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start TeamSpeak service: %w", err)
@@ -59,12 +245,12 @@ func (s *service) Start(ctx context.Context) error {
 
 	// Start Discord connection
 	if err := s.discord.Start(ctx); err != nil {
-		s.teamspeak.Stop()
 		return fmt.Errorf("failed to start Discord service: %w", err)
 	}
 
 	// Start status recorder. A recording failure must never take down the bot,
 	// so degrade to no recording rather than returning a fatal error.
+	s.store = s.recorder
 	if s.store != nil {
 		if err := s.store.Start(ctx); err != nil {
 			s.log.WithError(err).Warn("Failed to start status recorder; continuing without recording")
@@ -72,23 +258,71 @@ func (s *service) Start(ctx context.Context) error {
 		}
 	}
 
+	if s.store != nil {
+		s.links = make(map[string]bool, 3)
+		s.trackLink(ctx, store.LinkBridge, true)
+		s.restoreAnnouncement(ctx)
+		s.restoreSilences(ctx)
+
+		if s.cfg.Subscriptions {
+			s.restoreSubscriptions(ctx)
+		}
+	}
+
+	// Slash commands are routed back into the bridge
+	s.discord.SetCommands(s)
+
+	if s.queue != nil {
+		if err := s.queue.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start notification queue: %w", err)
+		}
+	}
+
 	// Do initial update
 	s.tick(ctx)
 
-	// Start sync loop
-	s.wg.Add(1)
+	if err := s.scheduler.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
 
-	go s.loop(ctx)
+	s.running = true
 
 	s.log.WithField("interval", s.cfg.UpdateInterval).Info("Bridge started")
 
 	return nil
 }
 
-// Stop stops the sync loop and disconnects services.
+// Stop stops the sync loop and disconnects services. Stopping a stopped
+// bridge does nothing.
 func (s *service) Stop() error {
-	close(s.done)
-	s.wg.Wait()
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+
+	if err := s.scheduler.Stop(); err != nil {
+		s.log.WithError(err).Warn("Failed to stop scheduler")
+	}
+
+	s.trackLink(context.Background(), store.LinkBridge, false)
+	s.stopServices()
+
+	s.log.Info("Bridge stopped")
+
+	return nil
+}
+
+// stopServices stops the queue, store, Discord and TeamSpeak services.
+func (s *service) stopServices() {
+	if s.queue != nil {
+		if err := s.queue.Stop(); err != nil {
+			s.log.WithError(err).Warn("Failed to stop notification queue")
+		}
+	}
 
 	if s.store != nil {
 		if err := s.store.Stop(); err != nil {
@@ -103,47 +337,113 @@ func (s *service) Stop() error {
 	if err := s.teamspeak.Stop(); err != nil {
 		s.log.WithError(err).Warn("Failed to stop TeamSpeak service")
 	}
+}
 
-	s.log.Info("Bridge stopped")
+// updateTask is the scheduler task name of the periodic update.
+const updateTask = "update"
+
+// update is the periodic update task.
+func (s *service) update(ctx context.Context) error {
+	s.tick(ctx)
 
 	return nil
 }
 
-// loop runs the periodic update loop.
-func (s *service) loop(ctx context.Context) {
-	defer s.wg.Done()
+// Refresh queues an immediate update. Requests arriving while one is already
+// pending are coalesced.
+func (s *service) Refresh() {
+	s.scheduler.Trigger(updateTask)
+}
+
+// History returns the most recent fetches, oldest first.
+func (s *service) History() []HistoryEntry {
+	return s.history.list()
+}
+
+// Current returns the last displayed state.
+func (s *service) Current() *teamspeak.State {
+	return s.current.Load()
+}
+
+// Announce shows text above the stats for the given duration and persists it
+// so it survives restarts.
+func (s *service) Announce(ctx context.Context, text string, duration time.Duration) {
+	if duration <= 0 {
+		duration = defaultAnnouncementDuration
+	}
 
-	ticker := time.NewTicker(s.cfg.UpdateInterval)
-	defer ticker.Stop()
+	expires := time.Now().Add(duration)
+	if text == "" {
+		expires = time.Time{}
+	}
 
-	for {
-		select {
-		case <-s.done:
-			return
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			s.tick(ctx)
+	s.discord.SetAnnouncement(text, expires)
+
+	if s.store != nil {
+		if err := s.store.SaveAnnouncement(ctx, text, expires); err != nil {
+			s.log.WithError(err).Warn("Failed to persist announcement")
 		}
 	}
+
+	s.Refresh()
+}
+
+// restoreAnnouncement shows an announcement persisted by a previous run if it
+// has not expired yet.
+func (s *service) restoreAnnouncement(ctx context.Context) {
+	text, expires, err := s.store.Announcement(ctx)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to load persisted announcement")
+
+		return
+	}
+
+	if text != "" && time.Now().Before(expires) {
+		s.discord.SetAnnouncement(text, expires)
+	}
 }
 
 // tick fetches the current TeamSpeak state once and fans it out to Discord and,
 // when due, the status recorder. A failure in one consumer does not block the
 // other.
 func (s *service) tick(ctx context.Context) {
+	start := time.Now()
+	defer func() { metrics.ObserveUpdate(metrics.PhaseCycle, time.Since(start)) }()
+
+	s.maybeRefreshForecast(ctx, start)
+
+	fetchStart := time.Now()
 	state, err := s.teamspeak.GetState(ctx)
+	metrics.ObserveUpdate(metrics.PhaseFetch, time.Since(fetchStart))
+
 	if err != nil {
-		s.log.WithError(err).Warn("Failed to get TeamSpeak state")
+		s.history.add(HistoryEntry{Time: time.Now(), Error: err.Error()})
+		s.cfg.LogSampler.Warn(s.log.WithError(err), "Failed to get TeamSpeak state")
+
+		var ban *teamspeak.BannedError
+		if errors.As(err, &ban) {
+			s.showBanned(ctx, ban.Until)
+		} else {
+			s.refreshStale(ctx)
+		}
+		s.trackFetch(ctx, err)
+		s.trackOutage(ctx, err)
+		s.trackLink(ctx, store.LinkTeamSpeak, false)
 
 		return
 	}
 
+	s.stamp(state)
+	s.lastState = state
+	s.trackFetch(ctx, nil)
+	s.trackOutage(ctx, nil)
+	s.trackLink(ctx, store.LinkTeamSpeak, true)
+	s.history.add(HistoryEntry{Time: time.Now(), State: state.Clone()})
+
 	s.log.WithField("users", state.TotalUsers).Debug("Fetched TeamSpeak state")
+	s.recordOccupancy(state)
 
-	if err := s.discord.UpdateStatus(ctx, state); err != nil {
-		s.log.WithError(err).Warn("Failed to update Discord status")
-	}
+	s.publish(ctx, state)
 
 	if s.store != nil && time.Since(s.lastRecord) >= s.cfg.RecordInterval {
 		if err := s.store.Record(ctx, state); err != nil {
@@ -153,3 +453,163 @@ func (s *service) tick(ctx context.Context) {
 		}
 	}
 }
+
+// recordOccupancy exports the fetched user counts of each server, labelled
+// with its label or name, and per channel for the allowlisted channels.
+// Same-named channels on one server are summed; listed channels that do not
+// exist count as empty.
+func (s *service) recordOccupancy(state *teamspeak.State) {
+	servers := state.Servers
+	if len(servers) == 0 {
+		servers = []*teamspeak.State{state}
+	}
+
+	labels := make([]string, len(servers))
+	for i, sv := range servers {
+		labels[i] = sv.LabelOrName()
+	}
+
+	// Drop the series of servers no longer shown, e.g. after a rename.
+	if !slices.Equal(labels, s.metricsServers) {
+		metrics.ResetOccupancy()
+		s.metricsServers = labels
+	}
+
+	for i, sv := range servers {
+		metrics.Occupancy(labels[i], sv.TotalUsers, sv.MaxClients)
+
+		if len(s.cfg.MetricsChannels) == 0 {
+			continue
+		}
+
+		users := make(map[string]int, len(s.cfg.MetricsChannels))
+
+		for _, ch := range sv.Channels {
+			users[strings.ToLower(ch.Name)] += len(ch.Users)
+		}
+
+		for _, name := range s.cfg.MetricsChannels {
+			metrics.ChannelUsers(labels[i], name, users[strings.ToLower(name)])
+		}
+	}
+}
+
+// stamp sets the state's content hash and version, moving to the next version
+// when the content changed since the previous fetch.
+func (s *service) stamp(state *teamspeak.State) {
+	hash := state.ContentHash()
+	if hash != s.versionHash {
+		s.version++
+		s.versionHash = hash
+	}
+
+	state.Version = s.version
+	state.Hash = hash
+}
+
+// publish runs the state through the display pipeline, renders it and hands
+// the displayed state to the alerting and change-log consumers.
+func (s *service) publish(ctx context.Context, state *teamspeak.State) {
+	display, err := pipeline.Apply(ctx, state, slices.Concat(s.cfg.Stages, []pipeline.Middleware{s.enrich})...)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to prepare state for display")
+
+		return
+	}
+
+	if display == nil {
+		return
+	}
+
+	public := s.cfg.PublicFilter.Apply(display)
+
+	updateStart := time.Now()
+	err = s.discord.UpdateStatus(ctx, display)
+	metrics.ObserveUpdate(metrics.PhaseDiscord, time.Since(updateStart))
+
+	// Current serves the state shown in Discord, so a failed update keeps the
+	// previous one.
+	if err != nil {
+		s.cfg.LogSampler.Warn(s.log.WithError(err), "Failed to update Discord status")
+	} else {
+		s.current.Store(public)
+	}
+
+	s.trackUpdate(ctx, err)
+
+	if s.cfg.AFK.Enabled {
+		s.checkIdle(ctx, public)
+	}
+
+	if s.cfg.TrackChanges && s.store != nil {
+		s.trackChanges(ctx, public)
+	}
+
+	if s.routed(notify.EventJoin, notify.EventLeave) {
+		s.notifyPresence(ctx, public)
+	}
+
+	if s.routed(notify.EventCapacity) {
+		s.notifyCapacity(ctx, public)
+	}
+
+	if s.cfg.Subscriptions && s.store != nil {
+		s.notifySubscribers(ctx, public)
+	}
+}
+
+// enrich is the bridge's pipeline stage: it refreshes the avatar collage and
+// channel icon emojis the rendered status refers to.
+func (s *service) enrich(next pipeline.Handler) pipeline.Handler {
+	return func(ctx context.Context, state *teamspeak.State) error {
+		if s.cfg.Collage.Enabled {
+			s.refreshCollage(ctx, state)
+		}
+
+		if s.cfg.UploadIconEmojis {
+			s.syncIconEmojis(ctx, state)
+		}
+
+		return next(ctx, state)
+	}
+}
+
+// refreshStale re-renders the last good state once it has aged past the stale
+// threshold, so the embed carries a visible warning instead of silently
+// showing old data.
+func (s *service) refreshStale(ctx context.Context) {
+	if s.lastState == nil || s.cfg.StaleAfter <= 0 || time.Since(s.lastState.FetchedAt) < s.cfg.StaleAfter {
+		return
+	}
+
+	s.rerender(ctx, s.lastState)
+}
+
+// showBanned re-renders the last good state (or an empty one) marked as
+// banned from ServerQuery until the given time.
+func (s *service) showBanned(ctx context.Context, until time.Time) {
+	state := &teamspeak.State{}
+	if s.lastState != nil {
+		state = s.lastState.Clone()
+	}
+
+	state.BannedUntil = until
+
+	s.rerender(ctx, state)
+}
+
+// rerender shows a state that was not just fetched, without the bridge's
+// enrich stage or the notification consumers.
+func (s *service) rerender(ctx context.Context, state *teamspeak.State) {
+	display, err := pipeline.Apply(ctx, state, s.cfg.Stages...)
+	if err != nil || display == nil {
+		return
+	}
+
+	err = s.discord.UpdateStatus(ctx, display)
+	if err != nil {
+		s.cfg.LogSampler.Warn(s.log.WithError(err), "Failed to update Discord status with stale data")
+	}
+
+	s.trackUpdate(ctx, err)
+}
@@ -0,0 +1,132 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// catchUpLimit bounds the changes listed in one "What changed?" reply.
+	catchUpLimit = 20
+
+	// catchUpFirstLookback is how far back a viewer's first click looks.
+	catchUpFirstLookback = time.Hour
+)
+
+// trackChanges logs who joined, left or moved since the previous state. The
+// states are the filtered ones shown in Discord, since the log is replayed
+// there.
+func (s *service) trackChanges(ctx context.Context, state *teamspeak.State) {
+	prev := s.changesPrev
+	s.changesPrev = state
+
+	if prev == nil {
+		return
+	}
+
+	if err := s.store.SaveChanges(ctx, diffUsers(prev, state, time.Now())); err != nil {
+		s.log.WithError(err).Warn("Failed to save channel changes")
+	}
+}
+
+// diffUsers lists the joins, leaves and moves between two states. Users are
+// matched by server, client id and nickname, so a reconnect or rename shows as
+// a leave and a join.
+func diffUsers(prev, cur *teamspeak.State, now time.Time) []store.Change {
+	key := func(u teamspeak.User) string {
+		return fmt.Sprintf("%d/%d/%s", u.Server, u.ID, u.Nickname)
+	}
+
+	channels := func(state *teamspeak.State) map[string]string {
+		where := make(map[string]string, state.TotalUsers)
+
+		for _, ch := range state.Channels {
+			for _, u := range ch.Users {
+				where[key(u)] = ch.Name
+			}
+		}
+
+		return where
+	}
+
+	before := channels(prev)
+	after := channels(cur)
+
+	var changes []store.Change
+
+	for _, ch := range cur.Channels {
+		for _, u := range ch.Users {
+			switch from, ok := before[key(u)]; {
+			case !ok:
+				changes = append(changes, store.Change{Time: now, Kind: store.ChangeJoin, Nickname: u.Nickname, To: ch.Name})
+			case from != ch.Name:
+				changes = append(changes, store.Change{Time: now, Kind: store.ChangeMove, Nickname: u.Nickname, From: from, To: ch.Name})
+			}
+		}
+	}
+
+	for _, ch := range prev.Channels {
+		for _, u := range ch.Users {
+			if _, ok := after[key(u)]; !ok {
+				changes = append(changes, store.Change{Time: now, Kind: store.ChangeLeave, Nickname: u.Nickname, From: ch.Name})
+			}
+		}
+	}
+
+	return changes
+}
+
+// WhatChanged describes the joins, leaves and moves since the viewer last
+// asked.
+func (s *service) WhatChanged(ctx context.Context, viewer string) string {
+	if s.store == nil {
+		return "Change history needs the database to be enabled."
+	}
+
+	now := time.Now()
+
+	catchUp, err := s.store.CatchUp(ctx, viewer, now.Add(-catchUpFirstLookback), now, catchUpLimit)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to load channel changes")
+
+		return "Could not load the change history, try again later."
+	}
+
+	return catchUpText(catchUp)
+}
+
+// catchUpText formats a catch-up reply.
+func catchUpText(c store.CatchUp) string {
+	since := fmt.Sprintf("<t:%d:R>", c.Since.Unix())
+	if len(c.Changes) == 0 {
+		return "Nothing changed since " + since + "."
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "🔄 **Changes since %s**\n", since)
+
+	if c.Total > len(c.Changes) {
+		fmt.Fprintf(&b, "*…%d earlier changes*\n", c.Total-len(c.Changes))
+	}
+
+	for _, ch := range c.Changes {
+		at := fmt.Sprintf("<t:%d:t>", ch.Time.Unix())
+
+		switch ch.Kind {
+		case store.ChangeJoin:
+			fmt.Fprintf(&b, "➡️ %s **%s** joined #%s\n", at, ch.Nickname, ch.To)
+		case store.ChangeLeave:
+			fmt.Fprintf(&b, "⬅️ %s **%s** left #%s\n", at, ch.Nickname, ch.From)
+		case store.ChangeMove:
+			fmt.Fprintf(&b, "🔀 %s **%s** moved #%s → #%s\n", at, ch.Nickname, ch.From, ch.To)
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestDiffUsers(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	prev := &teamspeak.State{Channels: []teamspeak.Channel{
+		{Name: "Lobby", Users: []teamspeak.User{{ID: 1, Nickname: "alice"}, {ID: 2, Nickname: "bob"}}},
+		{Name: "Games"},
+	}}
+	cur := &teamspeak.State{Channels: []teamspeak.Channel{
+		{Name: "Lobby", Users: []teamspeak.User{{ID: 3, Nickname: "carol"}}},
+		{Name: "Games", Users: []teamspeak.User{{ID: 1, Nickname: "alice"}}},
+	}}
+
+	require.Equal(t, []store.Change{
+		{Time: now, Kind: store.ChangeJoin, Nickname: "carol", To: "Lobby"},
+		{Time: now, Kind: store.ChangeMove, Nickname: "alice", From: "Lobby", To: "Games"},
+		{Time: now, Kind: store.ChangeLeave, Nickname: "bob", From: "Lobby"},
+	}, diffUsers(prev, cur, now))
+
+	require.Empty(t, diffUsers(cur, cur, now))
+}
+
+func TestCatchUpText(t *testing.T) {
+	since := time.Unix(1_700_000_000, 0)
+
+	require.Equal(t, "Nothing changed since <t:1700000000:R>.", catchUpText(store.CatchUp{Since: since}))
+
+	require.Equal(t, "🔄 **Changes since <t:1700000000:R>**\n"+
+		"*…2 earlier changes*\n"+
+		"🔀 <t:1700000060:t> **alice** moved #Lobby → #Games",
+		catchUpText(store.CatchUp{
+			Since: since,
+			Total: 3,
+			Changes: []store.Change{
+				{Time: since.Add(time.Minute), Kind: store.ChangeMove, Nickname: "alice", From: "Lobby", To: "Games"},
+			},
+		}))
+}
@@ -0,0 +1,197 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/collage"
+	"github.com/samcm/ts-discord-status/internal/filetransfer"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// CollageConfig controls the avatar collage attached to the embed.
+type CollageConfig struct {
+	Enabled  bool
+	MaxUsers int // Avatars beyond this many users are left out
+	TileSize int // Edge length of each avatar in pixels
+	Columns  int // Avatars per row
+}
+
+const (
+	// collageTask is the scheduler task name of the collage build.
+	collageTask = "collage"
+
+	// collageTimeout bounds the avatar downloads of one collage build.
+	collageTimeout = 10 * time.Second
+
+	// avatarWorkers is how many avatars are downloaded at once.
+	avatarWorkers = 4
+)
+
+// refreshCollage records the users the avatar collage shows and, when they
+// changed, has the collage task rebuild it. The build runs on its own, so slow
+// avatar downloads do not hold up the update; the new collage is attached to
+// the next one.
+func (s *service) refreshCollage(ctx context.Context, state *teamspeak.State) {
+	var users []teamspeak.User
+
+	for _, ch := range state.Channels {
+		for _, u := range ch.Users {
+			if len(users) < s.cfg.Collage.MaxUsers {
+				users = append(users, u)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(users))
+	for _, u := range users {
+		keys = append(keys, u.UniqueID+"/"+u.Nickname)
+	}
+
+	roster := strings.Join(keys, "|")
+
+	s.collageMu.Lock()
+	changed := !s.rosterSet || roster != s.roster
+	s.roster, s.rosterUsers, s.rosterSet = roster, users, true
+	s.collageMu.Unlock()
+
+	if changed {
+		s.scheduler.Trigger(collageTask)
+	}
+}
+
+// buildCollage is the collage task: it composes the collage for the latest
+// roster unless it is already shown. Avatars are looked up once per user and
+// kept for as long as that user stays online; the downloads themselves are
+// cached by the teamspeak service. A build whose downloads did not all
+// finish is retried on the next run.
+func (s *service) buildCollage(ctx context.Context) error {
+	s.collageMu.Lock()
+	roster, users, set := s.roster, s.rosterUsers, s.rosterSet
+	s.collageMu.Unlock()
+
+	if !set || (s.collageBuilt && roster == s.builtRoster) {
+		return nil
+	}
+
+	if len(users) == 0 {
+		s.avatars, s.collageImage = nil, nil
+		s.builtRoster, s.collageBuilt = roster, true
+		s.discord.SetImage(nil)
+
+		return nil
+	}
+
+	avatars, complete := s.fetchAvatars(ctx, users)
+	s.avatars = avatars
+
+	tiles := make([]collage.Tile, 0, len(users))
+	for _, u := range users {
+		tiles = append(tiles, collage.Tile{Key: u.Nickname, Image: avatars[u.UniqueID]})
+	}
+
+	img, err := collage.Compose(tiles, collage.Options{
+		TileSize: s.cfg.Collage.TileSize,
+		Columns:  s.cfg.Collage.Columns,
+		Gap:      2,
+	})
+	if err != nil {
+		metrics.Error(metrics.ErrorRender)
+		s.log.WithError(err).Warn("Failed to compose avatar collage")
+
+		return nil
+	}
+
+	s.builtRoster, s.collageBuilt = roster, complete
+
+	// Retries that fetched nothing new render the same image, which need not
+	// be uploaded again.
+	if !bytes.Equal(img, s.collageImage) {
+		s.collageImage = img
+		s.discord.SetImage(img)
+	}
+
+	return nil
+}
+
+// fetchAvatars returns the avatars of users by unique id, reusing those
+// already fetched and downloading the rest concurrently within
+// collageTimeout. Clients without an avatar map to nil, and fall back to a
+// placeholder colour. It reports false when a download failed or ran out of
+// time.
+func (s *service) fetchAvatars(ctx context.Context, users []teamspeak.User) (map[string][]byte, bool) {
+	ctx, cancel := context.WithTimeout(ctx, collageTimeout)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		complete = true
+		workers  = make(chan struct{}, avatarWorkers)
+	)
+
+	avatars := make(map[string][]byte, len(users))
+	missing := make(map[string]teamspeak.User)
+
+	for _, u := range users {
+		if data, ok := s.avatars[u.UniqueID]; ok {
+			avatars[u.UniqueID] = data
+		} else if u.UniqueID != "" {
+			missing[u.UniqueID] = u
+		}
+	}
+
+	for _, u := range missing {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+			case <-ctx.Done():
+				mu.Lock()
+				complete = false
+				mu.Unlock()
+
+				return
+			}
+
+			data, err := s.avatar(ctx, u)
+			if err != nil && !errors.Is(err, filetransfer.ErrNotFound) {
+				s.log.WithError(err).WithField("nickname", u.Nickname).Debug("Failed to fetch avatar")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			// A failed download is left out of the cache so the retry
+			// downloads it again.
+			if err == nil || errors.Is(err, filetransfer.ErrNotFound) {
+				avatars[u.UniqueID] = data
+			} else {
+				complete = false
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return avatars, complete
+}
+
+// avatar downloads a user's avatar if the source supports it.
+func (s *service) avatar(ctx context.Context, u teamspeak.User) ([]byte, error) {
+	files, ok := s.teamspeak.(teamspeak.Files)
+	if !ok {
+		return nil, filetransfer.ErrNotFound
+	}
+
+	return files.Avatar(ctx, u)
+}
@@ -0,0 +1,178 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/filetransfer"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// avatarSource serves avatars after delay; clients in block wait for the
+// context instead, and those in missing have none.
+type avatarSource struct {
+	fakeSource
+	delay   time.Duration
+	block   map[string]bool
+	missing map[string]bool
+
+	mu      sync.Mutex
+	fetched map[string]int
+}
+
+func (a *avatarSource) Avatar(ctx context.Context, u teamspeak.User) ([]byte, error) {
+	a.mu.Lock()
+	a.fetched[u.UniqueID]++
+	a.mu.Unlock()
+
+	if a.block[u.UniqueID] {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	time.Sleep(a.delay)
+
+	if a.missing[u.UniqueID] {
+		return nil, filetransfer.ErrNotFound
+	}
+
+	return avatarPNG(u.UniqueID), nil
+}
+
+// avatarPNG is a one-pixel avatar in a colour of its own for uid.
+func avatarPNG(uid string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: uid[0], G: uint8(len(uid)), B: 0x80, A: 0xFF})
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+
+	return buf.Bytes()
+}
+
+func (a *avatarSource) Icon(context.Context, uint32) ([]byte, error) {
+	return nil, filetransfer.ErrNotFound
+}
+
+func (a *avatarSource) fetches(uid string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.fetched[uid]
+}
+
+// imageRecorder records the collages set on the embed.
+type imageRecorder struct {
+	discord.Service
+	images [][]byte
+}
+
+func (r *imageRecorder) SetImage(data []byte) { r.images = append(r.images, data) }
+
+func collageState(uids ...string) *teamspeak.State {
+	ch := teamspeak.Channel{ID: 1, Name: "Lobby"}
+	for i, uid := range uids {
+		ch.Users = append(ch.Users, teamspeak.User{ID: i + 1, UniqueID: uid, Nickname: "user " + uid})
+	}
+
+	return &teamspeak.State{Channels: []teamspeak.Channel{ch}}
+}
+
+func newCollageService(ts *avatarSource, dc *imageRecorder) *service {
+	ts.fetched = make(map[string]int)
+
+	return NewService(logrus.New(), Config{
+		UpdateInterval: time.Hour,
+		Collage:        CollageConfig{Enabled: true, MaxUsers: 10, TileSize: 8, Columns: 4},
+	}, ts, dc, nil).(*service)
+}
+
+func TestCollage(t *testing.T) {
+	ts, dc := &avatarSource{delay: 100 * time.Millisecond, missing: map[string]bool{"d": true}}, &imageRecorder{}
+	s := newCollageService(ts, dc)
+	ctx := context.Background()
+
+	// The update only records the roster; the avatars download in the task.
+	s.refreshCollage(ctx, collageState("a", "b", "c", "d", "a"))
+	require.Empty(t, dc.images)
+	require.Zero(t, ts.fetches("a"))
+
+	// Downloads run side by side.
+	start := time.Now()
+	require.NoError(t, s.buildCollage(ctx))
+	require.Less(t, time.Since(start), 300*time.Millisecond)
+	require.Len(t, dc.images, 1)
+	require.NotEmpty(t, dc.images[0])
+	require.Equal(t, 1, ts.fetches("a"), "a client listed twice is fetched once")
+
+	// An unchanged roster is not rebuilt.
+	s.refreshCollage(ctx, collageState("a", "b", "c", "d", "a"))
+	require.NoError(t, s.buildCollage(ctx))
+	require.Len(t, dc.images, 1)
+
+	// Avatars are kept for the users still online, including the missing one.
+	s.refreshCollage(ctx, collageState("a", "d", "e"))
+	require.NoError(t, s.buildCollage(ctx))
+	require.Len(t, dc.images, 2)
+	require.Equal(t, 1, ts.fetches("a"))
+	require.Equal(t, 1, ts.fetches("d"))
+	require.Equal(t, 1, ts.fetches("e"))
+
+	// Nobody online clears the image.
+	s.refreshCollage(ctx, collageState())
+	require.NoError(t, s.buildCollage(ctx))
+	require.Nil(t, dc.images[2])
+}
+
+func TestCollageDeadline(t *testing.T) {
+	ts, dc := &avatarSource{block: map[string]bool{"slow": true}}, &imageRecorder{}
+	s := newCollageService(ts, dc)
+
+	s.refreshCollage(context.Background(), collageState("fast", "slow"))
+
+	// A download past the deadline leaves a placeholder instead of holding
+	// the collage back.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, s.buildCollage(ctx))
+	require.Len(t, dc.images, 1)
+
+	// The next run retries only the slow avatar.
+	ts.block = nil
+	require.NoError(t, s.buildCollage(context.Background()))
+	require.Len(t, dc.images, 2)
+	require.Equal(t, 1, ts.fetches("fast"))
+	require.Equal(t, 2, ts.fetches("slow"))
+
+	require.NoError(t, s.buildCollage(context.Background()))
+	require.Len(t, dc.images, 2)
+
+	// A retry rendering the same collage does not upload it again.
+	s.refreshCollage(context.Background(), collageState("other"))
+	ts.block = map[string]bool{"other": true}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, s.buildCollage(ctx))
+	require.Len(t, dc.images, 3)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, s.buildCollage(ctx))
+	require.Len(t, dc.images, 3)
+	require.Equal(t, 2, ts.fetches("other"))
+}
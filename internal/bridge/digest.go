@@ -0,0 +1,103 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/metrics"
+)
+
+// digestCheckInterval is how often the digest task checks whether the digest
+// is due.
+const digestCheckInterval = time.Minute
+
+// DigestConfig controls the daily operational summary DMed to the owners.
+type DigestConfig struct {
+	Enabled  bool
+	At       int // Local send time in minutes after midnight
+	OwnerIDs []string
+}
+
+// digestLabels names the error categories in the digest, in display order.
+var digestLabels = []struct{ category, label string }{
+	{metrics.ErrorTSConnect, "TeamSpeak connect"},
+	{metrics.ErrorTSQuery, "TeamSpeak query"},
+	{metrics.ErrorDiscordEdit, "Discord edit"},
+	{metrics.ErrorRender, "Render"},
+}
+
+// nextDigestTime returns the first send time strictly after now.
+func nextDigestTime(now time.Time, at int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at/60, at%60, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// maybeSendDigest sends the digest once its time has passed, covering
+// everything counted since the previous one.
+func (s *service) maybeSendDigest(ctx context.Context, now time.Time) {
+	if !s.cfg.Digest.Enabled || len(s.cfg.Digest.OwnerIDs) == 0 {
+		return
+	}
+
+	if s.nextDigest.IsZero() {
+		s.digestSince = now
+		s.digestBaseline = metrics.Snapshot()
+		s.nextDigest = nextDigestTime(now, s.cfg.Digest.At)
+
+		return
+	}
+
+	if now.Before(s.nextDigest) {
+		return
+	}
+
+	totals := metrics.Snapshot()
+	content := digestText(s.digestSince, now, s.started, s.digestBaseline, totals)
+
+	for _, id := range s.cfg.Digest.OwnerIDs {
+		if err := s.discord.DirectMessage(ctx, id, content); err != nil {
+			s.log.WithError(err).WithField("user_id", id).Warn("Failed to send daily digest")
+		}
+	}
+
+	s.digestSince = now
+	s.digestBaseline = totals
+	s.nextDigest = nextDigestTime(now, s.cfg.Digest.At)
+}
+
+// digestText summarises the counters that changed between two snapshots.
+func digestText(since, now, started time.Time, prev, cur metrics.Totals) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "📋 **ts-discord-status digest** (last %s)\n", shortDuration(now.Sub(since)))
+	fmt.Fprintf(&b, "Bridge uptime: %s\n", shortDuration(now.Sub(started)))
+
+	var errs []string
+
+	for _, l := range digestLabels {
+		if n := cur.Errors[l.category] - prev.Errors[l.category]; n > 0 {
+			errs = append(errs, fmt.Sprintf("%s %d", l.label, n))
+		}
+	}
+
+	if len(errs) == 0 {
+		b.WriteString("Errors: none\n")
+	} else {
+		fmt.Fprintf(&b, "Errors: %s\n", strings.Join(errs, ", "))
+	}
+
+	rateLimits := cur.Errors[metrics.ErrorDiscordRateLimit] - prev.Errors[metrics.ErrorDiscordRateLimit]
+	fmt.Fprintf(&b, "Rate-limit hits: %d\n", rateLimits)
+
+	fmt.Fprintf(&b, "Reconnects: Discord %d, TeamSpeak %d",
+		cur.Reconnects[metrics.ReconnectDiscord]-prev.Reconnects[metrics.ReconnectDiscord],
+		cur.Reconnects[metrics.ReconnectTeamSpeak]-prev.Reconnects[metrics.ReconnectTeamSpeak])
+
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/metrics"
+)
+
+func TestNextDigestTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	require.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), nextDigestTime(now, 9*60))
+	require.Equal(t, time.Date(2024, 1, 2, 7, 30, 0, 0, time.UTC), nextDigestTime(now, 7*60+30))
+	require.Equal(t, time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC), nextDigestTime(now, 8*60))
+}
+
+func TestDigestText(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	prev := metrics.Totals{
+		Errors:     map[string]uint64{metrics.ErrorTSQuery: 5},
+		Reconnects: map[string]uint64{},
+	}
+	cur := metrics.Totals{
+		Errors:     map[string]uint64{metrics.ErrorTSQuery: 8, metrics.ErrorDiscordRateLimit: 2},
+		Reconnects: map[string]uint64{metrics.ReconnectTeamSpeak: 1},
+	}
+
+	require.Equal(t, "📋 **ts-discord-status digest** (last 24h)\n"+
+		"Bridge uptime: 50h\n"+
+		"Errors: TeamSpeak query 3\n"+
+		"Rate-limit hits: 2\n"+
+		"Reconnects: Discord 0, TeamSpeak 1",
+		digestText(now.Add(-24*time.Hour), now, now.Add(-50*time.Hour), prev, cur))
+
+	require.Contains(t, digestText(now, now, now, cur, cur), "Errors: none")
+}
@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+// ErrNoStore is returned by features that read the database when it is not
+// enabled.
+var ErrNoStore = errors.New("the database is not enabled")
+
+// trackLink records a link going up or down when its state differs from the
+// last one recorded this run. The first observation of a run is always
+// recorded, so an outage that spanned a restart is closed.
+func (s *service) trackLink(ctx context.Context, link string, up bool) {
+	if s.store == nil {
+		return
+	}
+
+	if prev, ok := s.links[link]; ok && prev == up {
+		return
+	}
+
+	if err := s.store.SaveConnection(ctx, link, up, time.Now()); err != nil {
+		s.log.WithError(err).WithField("link", link).Warn("Failed to record connection state")
+
+		return
+	}
+
+	s.links[link] = up
+}
+
+// Outages returns the recorded outages since the given time.
+func (s *service) Outages(ctx context.Context, since time.Time) ([]store.Outage, error) {
+	st := s.store
+	if st == nil {
+		return nil, ErrNoStore
+	}
+
+	return st.Outages(ctx, since, time.Now())
+}
@@ -0,0 +1,80 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+// maxAlertError bounds the error text quoted in failover alerts.
+const maxAlertError = 1000
+
+// FailoverConfig controls the alert sent when the status message cannot be
+// updated for a while, e.g. after permissions were revoked.
+type FailoverConfig struct {
+	After     time.Duration // How long updates must fail before alerting
+	ChannelID string        // Fallback channel to alert (empty skips it)
+	OwnerIDs  []string      // Users to alert by DM
+}
+
+func (c FailoverConfig) enabled() bool {
+	return c.After > 0 && (c.ChannelID != "" || len(c.OwnerIDs) > 0)
+}
+
+// trackUpdate records the outcome of a status update. Once updates have failed
+// for Failover.After it alerts once, and again when they recover.
+func (s *service) trackUpdate(ctx context.Context, err error) {
+	s.trackLink(ctx, store.LinkDiscord, err == nil)
+
+	if !s.cfg.Failover.enabled() {
+		return
+	}
+
+	now := time.Now()
+
+	if err == nil {
+		if s.failoverAlerted {
+			s.sendFailoverAlert(ctx, fmt.Sprintf("✅ Status updates recovered after %s.", shortDuration(now.Sub(s.updateFailingSince))))
+		}
+
+		s.updateFailingSince = time.Time{}
+		s.failoverAlerted = false
+
+		return
+	}
+
+	if s.updateFailingSince.IsZero() {
+		s.updateFailingSince = now
+	}
+
+	if s.failoverAlerted || now.Sub(s.updateFailingSince) < s.cfg.Failover.After {
+		return
+	}
+
+	msg := err.Error()
+	if r := []rune(msg); len(r) > maxAlertError {
+		msg = string(r[:maxAlertError]) + "…"
+	}
+
+	s.sendFailoverAlert(ctx, fmt.Sprintf("⚠️ The TeamSpeak status message has not updated for %s:\n```\n%s\n```\n"+
+		"Check that the bot can still see and post in the status channel.", shortDuration(now.Sub(s.updateFailingSince)), msg))
+
+	s.failoverAlerted = true
+}
+
+// sendFailoverAlert posts to the fallback channel and DMs the owners.
+func (s *service) sendFailoverAlert(ctx context.Context, content string) {
+	if id := s.cfg.Failover.ChannelID; id != "" {
+		if err := s.discord.Notify(ctx, id, content); err != nil {
+			s.log.WithError(err).WithField("channel_id", id).Warn("Failed to send failover alert")
+		}
+	}
+
+	for _, id := range s.cfg.Failover.OwnerIDs {
+		if err := s.discord.DirectMessage(ctx, id, content); err != nil {
+			s.log.WithError(err).WithField("user_id", id).Warn("Failed to send failover alert DM")
+		}
+	}
+}
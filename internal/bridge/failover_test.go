@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+type alertRecorder struct {
+	discord.Service
+	sent []string
+}
+
+func (a *alertRecorder) Notify(_ context.Context, channelID, content string, _ ...string) error {
+	a.sent = append(a.sent, channelID+": "+content)
+	return nil
+}
+
+func (a *alertRecorder) DirectMessage(_ context.Context, userID, content string) error {
+	a.sent = append(a.sent, "dm "+userID+": "+content)
+	return nil
+}
+
+func TestFailoverAlertsOnce(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Failover: FailoverConfig{After: time.Nanosecond, ChannelID: "fallback", OwnerIDs: []string{"owner"}},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	err := errors.New("HTTP 403 Forbidden")
+
+	s.trackUpdate(ctx, err)
+	require.Empty(t, dc.sent)
+
+	time.Sleep(time.Millisecond)
+	s.trackUpdate(ctx, err)
+	s.trackUpdate(ctx, err)
+	require.Len(t, dc.sent, 2)
+	require.Contains(t, dc.sent[0], "fallback: ⚠️")
+	require.Contains(t, dc.sent[1], "dm owner: ⚠️")
+	require.Contains(t, dc.sent[1], "HTTP 403 Forbidden")
+
+	s.trackUpdate(ctx, nil)
+	require.Len(t, dc.sent, 4)
+	require.Contains(t, dc.sent[2], "recovered")
+
+	s.trackUpdate(ctx, nil)
+	require.Len(t, dc.sent, 4)
+}
+
+func TestOutageAlert(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		OutageAlert: OutageAlertConfig{RoleID: "42", ChannelID: "status", After: 2},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	err := errors.New("connection refused")
+
+	s.trackOutage(ctx, err)
+	require.Empty(t, dc.sent)
+
+	s.trackOutage(ctx, err)
+	s.trackOutage(ctx, err)
+	require.Len(t, dc.sent, 1)
+	require.Contains(t, dc.sent[0], "status: <@&42> 🔴")
+	require.Contains(t, dc.sent[0], "2 fetches in a row failed.")
+	require.NotContains(t, dc.sent[0], "connection refused")
+
+	s.trackOutage(ctx, nil)
+	require.Len(t, dc.sent, 2)
+	require.Contains(t, dc.sent[1], "is back up")
+	require.NotContains(t, dc.sent[1], "<@&42>")
+
+	// A single failure after recovering does not ping again.
+	s.trackOutage(ctx, err)
+	s.trackOutage(ctx, nil)
+	require.Len(t, dc.sent, 2)
+}
+
+func TestOutageReason(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}
+
+	require.Equal(t, "connection failed", outageReason(fmt.Errorf("failed to connect: %w", dial)))
+	require.Equal(t, "timed out", outageReason(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	require.Equal(t, "the bot is banned from the server", outageReason(&teamspeak.BannedError{}))
+	require.Empty(t, outageReason(errors.New("error id=1024 msg=invalid serverID")))
+}
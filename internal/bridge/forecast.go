@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+// ForecastConfig controls the busy hours forecast behind the "Usually busy
+// around 20:00–23:00" footer hint and the empty state.
+type ForecastConfig struct {
+	Enabled bool
+	Hint    bool // Show the footer hint
+	Weeks   int  // History the hourly averages cover
+}
+
+const (
+	// forecastRefresh is how often the hint is recomputed; hourly averages
+	// over weeks barely move within an hour.
+	forecastRefresh = time.Hour
+	// forecastMinHours is how many recorded hours each hour of day needs
+	// before a hint is shown, so a bot running for two days makes no claims.
+	forecastMinHours = 7
+	// forecastBusyShare is the share of the busiest hour's average from
+	// which neighbouring hours count as busy too.
+	forecastBusyShare = 0.75
+	// forecastMaxSpan is the longest busy window still worth a hint; a
+	// server busy all day has no peak to point out.
+	forecastMaxSpan = 8
+)
+
+// maybeRefreshForecast recomputes the hint once forecastRefresh has passed.
+func (s *service) maybeRefreshForecast(ctx context.Context, now time.Time) {
+	if !s.cfg.Forecast.Enabled || s.store == nil || now.Sub(s.forecastAt) < forecastRefresh {
+		return
+	}
+
+	s.forecastAt = now
+
+	usage, err := s.store.HourlyUsage(ctx, now.AddDate(0, 0, -7*s.cfg.Forecast.Weeks), now.Location())
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to compute busy hours forecast")
+
+		return
+	}
+
+	f := discord.Forecast{}
+	f.Start, f.End, f.Known = busyWindow(usage)
+
+	if s.cfg.Forecast.Hint {
+		f.Hint = forecastText(usage)
+	}
+
+	s.discord.SetForecast(f)
+}
+
+// forecastText describes the busy window, or returns "" when there is none.
+func forecastText(usage store.HourlyUsage) string {
+	start, end, ok := busyWindow(usage)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("Usually busy around %02d:00–%02d:00", start, end)
+}
+
+// busyWindow returns the hours around the busiest hour of day whose average
+// is within forecastBusyShare of it, as a start hour and an exclusive end
+// hour that may wrap past midnight.
+func busyWindow(usage store.HourlyUsage) (int, int, bool) {
+	peak := -1
+
+	for h := range 24 {
+		if usage.Hours[h] < forecastMinHours {
+			return 0, 0, false
+		}
+
+		if peak < 0 || usage.Average[h] > usage.Average[peak] {
+			peak = h
+		}
+	}
+
+	// Fewer than one user at the busiest hour is not busy.
+	if usage.Average[peak] < 1 {
+		return 0, 0, false
+	}
+
+	busy := func(h int) bool {
+		return usage.Average[(h+24)%24] >= forecastBusyShare*usage.Average[peak]
+	}
+
+	start, end := peak, peak+1
+	for end-start < 24 && busy(start-1) {
+		start--
+	}
+
+	for end-start < 24 && busy(end) {
+		end++
+	}
+
+	if end-start > forecastMaxSpan {
+		return 0, 0, false
+	}
+
+	return (start + 24) % 24, end % 24, true
+}
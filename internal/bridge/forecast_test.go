@@ -0,0 +1,38 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/store"
+)
+
+func TestForecastText(t *testing.T) {
+	usage := func(avg map[int]float64, hours int) store.HourlyUsage {
+		var u store.HourlyUsage
+		for h := range 24 {
+			u.Hours[h] = hours
+			u.Average[h] = 0.5
+		}
+
+		for h, a := range avg {
+			u.Average[h] = a
+		}
+
+		return u
+	}
+
+	evening := map[int]float64{19: 4, 20: 9, 21: 12, 22: 10, 23: 3}
+	require.Equal(t, "Usually busy around 20:00–23:00", forecastText(usage(evening, 28)))
+
+	// Not enough history yet.
+	require.Empty(t, forecastText(usage(evening, 3)))
+
+	// A window spanning midnight wraps.
+	late := map[int]float64{22: 8, 23: 10, 0: 9, 1: 2}
+	require.Equal(t, "Usually busy around 22:00–01:00", forecastText(usage(late, 28)))
+
+	// An empty server has no busy hours.
+	require.Empty(t, forecastText(usage(nil, 28)))
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// HistoryEntry is one fetch attempt kept for diagnostics: the state exactly as
+// fetched (before content filtering), or the error if the fetch failed.
+type HistoryEntry struct {
+	Time  time.Time        `json:"time"`
+	State *teamspeak.State `json:"state,omitempty"`
+	Error string           `json:"error,omitempty"`
+}
+
+// history is a fixed-size ring of recent fetches. A nil history keeps
+// nothing.
+type history struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		return nil
+	}
+
+	return &history{entries: make([]HistoryEntry, size)}
+}
+
+func (h *history) add(e HistoryEntry) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the entries oldest first.
+func (h *history) list() []HistoryEntry {
+	if h == nil {
+		return []HistoryEntry{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]HistoryEntry{}, h.entries[:h.next]...)
+	}
+
+	out := make([]HistoryEntry, 0, len(h.entries))
+	out = append(out, h.entries[h.next:]...)
+
+	return append(out, h.entries[:h.next]...)
+}
@@ -0,0 +1,27 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRing(t *testing.T) {
+	h := newHistory(3)
+
+	for _, e := range []string{"a", "b"} {
+		h.add(HistoryEntry{Error: e})
+	}
+
+	require.Equal(t, []HistoryEntry{{Error: "a"}, {Error: "b"}}, h.list())
+
+	for _, e := range []string{"c", "d", "e"} {
+		h.add(HistoryEntry{Error: e})
+	}
+
+	require.Equal(t, []HistoryEntry{{Error: "c"}, {Error: "d"}, {Error: "e"}}, h.list())
+
+	var disabled *history
+	disabled.add(HistoryEntry{Error: "x"})
+	require.Empty(t, disabled.list())
+}
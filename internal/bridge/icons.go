@@ -0,0 +1,36 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// syncIconEmojis uploads the icons of channels in the state as application
+// emojis. Each icon is attempted once per run so a failing upload (missing
+// permission, full emoji slots) is not retried every tick.
+func (s *service) syncIconEmojis(ctx context.Context, state *teamspeak.State) {
+	files, ok := s.teamspeak.(teamspeak.Files)
+	if !ok {
+		return
+	}
+
+	for _, ch := range state.Channels {
+		if ch.IconID == 0 || (len(ch.Users) == 0 && !s.cfg.IconsForEmptyChannels) {
+			continue
+		}
+
+		if _, ok := s.iconsTried[ch.IconID]; ok {
+			continue
+		}
+
+		s.iconsTried[ch.IconID] = struct{}{}
+
+		id := ch.IconID
+		fetch := func() ([]byte, error) { return files.Icon(ctx, id) }
+
+		if err := s.discord.UploadIconEmoji(ctx, id, fetch); err != nil {
+			s.log.WithError(err).WithField("icon_id", id).Warn("Failed to upload channel icon emoji")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/scheduler"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// lifecycle counts starts, stops and updates of the services it fakes.
+type lifecycle struct {
+	mu                     sync.Mutex
+	starts, stops, updates int
+}
+
+func (l *lifecycle) count(n *int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	*n++
+}
+
+func (l *lifecycle) counts() (starts, stops, updates int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.starts, l.stops, l.updates
+}
+
+type fakeSource struct{ lifecycle }
+
+func (f *fakeSource) Start(context.Context) error {
+	f.count(&f.starts)
+	return nil
+}
+
+func (f *fakeSource) Stop() error {
+	f.count(&f.stops)
+	return nil
+}
+
+func (f *fakeSource) GetState(context.Context) (*teamspeak.State, error) {
+	return &teamspeak.State{ServerName: "Game Night", FetchedAt: time.Now()}, nil
+}
+
+type fakeDiscord struct {
+	discord.Service
+	lifecycle
+	startErr error
+}
+
+func (f *fakeDiscord) Start(context.Context) error {
+	f.count(&f.starts)
+	return f.startErr
+}
+
+func (f *fakeDiscord) Stop() error {
+	f.count(&f.stops)
+	return nil
+}
+
+func (f *fakeDiscord) SetCommands(discord.Commands) {}
+
+func (f *fakeDiscord) UpdateStatus(context.Context, *teamspeak.State) error {
+	f.count(&f.updates)
+	return nil
+}
+
+func TestLifecycle(t *testing.T) {
+	ts, dc := &fakeSource{}, &fakeDiscord{}
+	s := NewService(logrus.New(), Config{UpdateInterval: time.Hour}, ts, dc, nil)
+	ctx := context.Background()
+
+	// Stopping a bridge that never started does nothing.
+	require.NoError(t, s.Stop())
+
+	require.NoError(t, s.Start(ctx))
+	require.NoError(t, s.Start(ctx))
+
+	starts, _, updates := dc.counts()
+	require.Equal(t, 1, starts)
+	require.Equal(t, 1, updates)
+
+	require.NoError(t, s.Stop())
+	require.NoError(t, s.Stop())
+
+	_, stops, _ := ts.counts()
+	require.Equal(t, 1, stops)
+
+	// A stopped bridge starts over: services reconnect and updates resume.
+	require.NoError(t, s.Start(ctx))
+
+	s.Refresh()
+	require.Eventually(t, func() bool {
+		_, _, updates := dc.counts()
+		return updates == 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, s.Stop())
+
+	starts, stops, _ = ts.counts()
+	require.Equal(t, 2, starts)
+	require.Equal(t, 2, stops)
+}
+
+func TestFailedStartStopsServices(t *testing.T) {
+	ctx := context.Background()
+
+	// Discord failing stops the TeamSpeak connection again.
+	ts, dc := &fakeSource{}, &fakeDiscord{startErr: errors.New("invalid token")}
+	s := NewService(logrus.New(), Config{UpdateInterval: time.Hour}, ts, dc, nil)
+	require.Error(t, s.Start(ctx))
+
+	_, stops, _ := ts.counts()
+	require.Equal(t, 1, stops)
+
+	// A later step failing stops everything started before it.
+	ts, dc = &fakeSource{}, &fakeDiscord{}
+	s = NewService(logrus.New(), Config{UpdateInterval: time.Hour}, ts, dc, nil)
+	s.(*service).scheduler.Add(scheduler.Task{Name: "broken"})
+	require.Error(t, s.Start(ctx))
+
+	_, stops, _ = ts.counts()
+	require.Equal(t, 1, stops)
+
+	_, stops, _ = dc.counts()
+	require.Equal(t, 1, stops)
+
+	// The failed bridge is not running, so stopping it does nothing.
+	require.NoError(t, s.Stop())
+
+	_, stops, _ = dc.counts()
+	require.Equal(t, 1, stops)
+}
+
+func TestRecordOccupancy(t *testing.T) {
+	s := NewService(logrus.New(), Config{MetricsChannels: []string{"lobby", "Games"}}, nil, &fakeDiscord{}, nil).(*service)
+	t.Cleanup(metrics.ResetOccupancy)
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		return rec.Body.String()
+	}
+
+	eu := &teamspeak.State{Label: "eu-1", TotalUsers: 2, MaxClients: 32, Channels: []teamspeak.Channel{
+		{Name: "Lobby", Users: []teamspeak.User{{Nickname: "alice"}, {Nickname: "bob"}}},
+	}}
+	us := &teamspeak.State{ServerName: "US", MaxClients: 64}
+
+	s.recordOccupancy(&teamspeak.State{Servers: []*teamspeak.State{eu, us}})
+
+	out := scrape()
+	require.Contains(t, out, `ts_discord_status_users_online{server="eu-1"} 2`)
+	require.Contains(t, out, `ts_discord_status_max_clients{server="US"} 64`)
+	require.Contains(t, out, `ts_discord_status_channel_users{channel="lobby",server="eu-1"} 2`)
+	require.Contains(t, out, `ts_discord_status_channel_users{channel="Games",server="US"} 0`)
+
+	// A server no longer shown stops being exported.
+	s.recordOccupancy(eu)
+
+	out = scrape()
+	require.Contains(t, out, `ts_discord_status_users_online{server="eu-1"} 2`)
+	require.NotContains(t, out, `server="US"`)
+}
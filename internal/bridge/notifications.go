@@ -0,0 +1,280 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// capacityRearm is how many percentage points occupancy must drop below the
+// capacity threshold before the next capacity notification, so a server
+// hovering at the threshold does not notify on every join.
+const capacityRearm = 10
+
+// NotificationsConfig routes channel notifications and sets the queue they
+// pass through.
+type NotificationsConfig struct {
+	Routes []Route
+	// Queue batches bursts of notifications; a zero size sends each one
+	// directly.
+	Queue notify.Config
+
+	OfflineAfter    time.Duration // How long fetches must fail before the offline event (0 disables it)
+	CapacityPercent float64       // Percent of slots used that triggers the capacity event (0 disables it)
+}
+
+// Route sends the events it lists to one Discord channel or webhook.
+type Route struct {
+	Events []string // Event types, e.g. notify.EventJoin
+	// Target is a Discord channel ID, or a webhook URL to post to instead.
+	Target string
+	// Format wraps each message; {text} is replaced with it, {event} with
+	// the event type and {server} with the server name. Empty sends the
+	// message as is.
+	Format string
+}
+
+// notification kinds, batched separately.
+const (
+	kindJoin     = "join"
+	kindLeave    = "leave"
+	kindIdle     = "idle"
+	kindOffline  = "offline"
+	kindOnline   = "online"
+	kindCapacity = "capacity"
+)
+
+// alert is one event before routing.
+type alert struct {
+	event   string // Event type routes match on
+	kind    string // Batching kind
+	subject string
+	text    string
+	summary string
+}
+
+// routed reports whether any route subscribes to one of events.
+func (s *service) routed(events ...string) bool {
+	for _, r := range s.cfg.Notifications.Routes {
+		for _, e := range events {
+			if slices.Contains(r.Events, e) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// emit sends a to every route subscribed to its event type, unless the type
+// is silenced, and reports whether it did.
+func (s *service) emit(ctx context.Context, a alert, server string) bool {
+	if s.silenced(a.event, time.Now()) {
+		s.log.WithField("event", a.event).Debug("Notification silenced")
+
+		return false
+	}
+
+	for _, r := range s.cfg.Notifications.Routes {
+		if !slices.Contains(r.Events, a.event) {
+			continue
+		}
+
+		s.notify(ctx, notify.Event{
+			Target:  r.Target,
+			Kind:    a.kind,
+			Subject: a.subject,
+			Text:    a.text,
+			Summary: a.summary,
+			Format:  strings.NewReplacer("{event}", a.event, "{server}", server).Replace(r.Format),
+			Roles:   mentionedRoles(r.Format),
+		})
+	}
+
+	return true
+}
+
+// notify sends e through the queue, or directly without one.
+func (s *service) notify(ctx context.Context, e notify.Event) {
+	if s.queue != nil {
+		if !s.queue.Push(e) {
+			s.cfg.LogSampler.Warn(s.log.WithField("kind", e.Kind), "Notification queue full, dropping notification")
+		}
+
+		return
+	}
+
+	if err := s.send(ctx, e.Target, notify.Format(e.Format, e.Text), e.Roles); err != nil {
+		s.log.WithError(err).WithField("kind", e.Kind).Warn("Failed to send notification")
+	}
+}
+
+// send delivers a notification to a channel or webhook.
+func (s *service) send(ctx context.Context, target, content string, roles []string) error {
+	if notify.IsWebhook(target) {
+		return notify.PostWebhook(ctx, target, content, roles...)
+	}
+
+	return s.discord.Notify(ctx, target, content, roles...)
+}
+
+// roleMention matches a role mention such as <@&345678901234567890>.
+var roleMention = regexp.MustCompile(`<@&(\d+)>`)
+
+// mentionedRoles returns the roles a route's format mentions, which its
+// messages may ping.
+func mentionedRoles(format string) []string {
+	var roles []string
+	for _, m := range roleMention.FindAllStringSubmatch(format, -1) {
+		roles = append(roles, m[1])
+	}
+
+	return roles
+}
+
+// notifyPresence announces joins and leaves since the previous displayed
+// state. The first state after startup has nothing to compare to, so a bot
+// restart does not announce everyone online.
+func (s *service) notifyPresence(ctx context.Context, state *teamspeak.State) {
+	prev := s.presencePrev
+	s.presencePrev = state
+
+	if prev == nil {
+		return
+	}
+
+	for _, sec := range presenceSections(prev, state) {
+		label, where := sec[1].LabelOrName(), ""
+		if len(state.Servers) > 1 {
+			where = " on **" + discord.EscapeMarkdown(label) + "**"
+		}
+
+		for _, c := range diffUsers(sec[0], sec[1], state.FetchedAt) {
+			nick := discord.EscapeMarkdown(c.Nickname)
+
+			switch c.Kind {
+			case store.ChangeJoin:
+				s.emit(ctx, alert{
+					event:   notify.EventJoin,
+					kind:    kindJoin,
+					subject: nick,
+					text:    "👋 **" + nick + "** joined **#" + discord.EscapeMarkdown(c.To) + "**" + where,
+					summary: "👋 {count} users joined",
+				}, label)
+			case store.ChangeLeave:
+				s.emit(ctx, alert{
+					event:   notify.EventLeave,
+					kind:    kindLeave,
+					subject: nick,
+					text:    "🚪 **" + nick + "** left" + where,
+					summary: "🚪 {count} users left",
+				}, label)
+			}
+		}
+	}
+}
+
+// presenceSections pairs the previous and current state of each aggregated
+// server, so joins and leaves are attributed to their server. A single
+// server, or servers that changed since prev, form one section.
+func presenceSections(prev, cur *teamspeak.State) [][2]*teamspeak.State {
+	if len(cur.Servers) < 2 || len(prev.Servers) != len(cur.Servers) {
+		return [][2]*teamspeak.State{{prev, cur}}
+	}
+
+	sections := make([][2]*teamspeak.State, len(cur.Servers))
+	for i := range cur.Servers {
+		sections[i] = [2]*teamspeak.State{prev.Servers[i], cur.Servers[i]}
+	}
+
+	return sections
+}
+
+// userServer returns the label of the server u is on, and the " on **label**"
+// alert texts add when several servers are aggregated.
+func userServer(state *teamspeak.State, u teamspeak.User) (string, string) {
+	if len(state.Servers) < 2 || u.Server >= len(state.Servers) {
+		return state.LabelOrName(), ""
+	}
+
+	label := state.Servers[u.Server].LabelOrName()
+
+	return label, " on **" + discord.EscapeMarkdown(label) + "**"
+}
+
+// notifyCapacity announces the server filling up, once until occupancy drops
+// capacityRearm points below the threshold again.
+func (s *service) notifyCapacity(ctx context.Context, state *teamspeak.State) {
+	threshold := s.cfg.Notifications.CapacityPercent
+	if threshold <= 0 || state.MaxClients <= 0 {
+		return
+	}
+
+	used := float64(state.TotalUsers) * 100 / float64(state.MaxClients)
+
+	switch {
+	case !s.capacityAlerted && used >= threshold:
+		s.capacityAlerted = true
+		s.emit(ctx, alert{
+			event: notify.EventCapacity,
+			kind:  kindCapacity,
+			text: fmt.Sprintf("📈 **%s** is nearly full: %d/%d slots in use",
+				discord.EscapeMarkdown(state.LabelOrName()), state.TotalUsers, state.MaxClients),
+		}, state.LabelOrName())
+	case s.capacityAlerted && used < threshold-capacityRearm:
+		s.capacityAlerted = false
+	}
+}
+
+// trackFetch announces the server going offline once fetches have failed for
+// OfflineAfter, and coming back after such an announcement. A silenced
+// announcement is retried on later failures and gets no recovery message.
+// err is the result of the latest fetch.
+func (s *service) trackFetch(ctx context.Context, err error) {
+	after := s.cfg.Notifications.OfflineAfter
+	if after <= 0 {
+		return
+	}
+
+	server, name := "", "The TeamSpeak server"
+	if s.lastState != nil && s.lastState.LabelOrName() != "" {
+		server, name = s.lastState.LabelOrName(), discord.EscapeMarkdown(s.lastState.LabelOrName())
+	}
+
+	if err == nil {
+		if s.offlineAlerted {
+			s.emit(ctx, alert{
+				event: notify.EventOffline,
+				kind:  kindOnline,
+				text:  fmt.Sprintf("🟢 **%s** is back online after %s", name, shortDuration(time.Since(s.fetchFailingSince))),
+			}, server)
+		}
+
+		s.fetchFailingSince = time.Time{}
+		s.offlineAlerted = false
+
+		return
+	}
+
+	if s.fetchFailingSince.IsZero() {
+		s.fetchFailingSince = time.Now()
+	}
+
+	if s.offlineAlerted || time.Since(s.fetchFailingSince) < after {
+		return
+	}
+
+	s.offlineAlerted = s.emit(ctx, alert{
+		event: notify.EventOffline,
+		kind:  kindOffline,
+		text:  fmt.Sprintf("🔴 **%s** is not responding", name),
+	}, server)
+}
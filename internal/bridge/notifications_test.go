@@ -0,0 +1,347 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestNotificationRouting(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{
+			Routes: []Route{
+				{Events: []string{notify.EventJoin}, Target: "public"},
+				{Events: []string{notify.EventJoin, notify.EventCapacity}, Target: "staff", Format: "[{server}/{event}] {text}"},
+				{Events: []string{notify.EventOffline}, Target: "oncall"},
+			},
+			CapacityPercent: 50,
+		},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	state := func(users ...string) *teamspeak.State {
+		ch := teamspeak.Channel{ID: 1, Name: "Lobby"}
+		for i, name := range users {
+			ch.Users = append(ch.Users, teamspeak.User{ID: i + 1, Nickname: name})
+		}
+
+		return &teamspeak.State{ServerName: "Game Night", MaxClients: 4, TotalUsers: len(users), Channels: []teamspeak.Channel{ch}}
+	}
+
+	s.notifyPresence(ctx, state("alice"))
+	s.notifyPresence(ctx, state("alice", "bob"))
+	require.Equal(t, []string{
+		"public: 👋 **bob** joined **#Lobby**",
+		"staff: [Game Night/join] 👋 **bob** joined **#Lobby**",
+	}, dc.sent)
+
+	dc.sent = nil
+
+	s.notifyCapacity(ctx, state("alice", "bob"))
+	s.notifyCapacity(ctx, state("alice", "bob", "carol"))
+	require.Equal(t, []string{"staff: [Game Night/capacity] 📈 **Game Night** is nearly full: 2/4 slots in use"}, dc.sent)
+
+	// Re-armed only once occupancy drops well below the threshold.
+	s.notifyCapacity(ctx, state("alice"))
+	s.notifyCapacity(ctx, state("alice", "bob"))
+	require.Len(t, dc.sent, 2)
+}
+
+func TestPresenceEscapesNames(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{Routes: []Route{{Events: []string{notify.EventJoin}, Target: "public"}}},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	state := func(users ...teamspeak.User) *teamspeak.State {
+		ch := teamspeak.Channel{ID: 1, Name: "**Lobby** <@&1>", Users: users}
+
+		return &teamspeak.State{ServerName: "Game Night", TotalUsers: len(users), Channels: []teamspeak.Channel{ch}}
+	}
+
+	s.notifyPresence(ctx, state())
+	s.notifyPresence(ctx, state(teamspeak.User{ID: 1, Nickname: "@everyone_"}))
+	require.Equal(t, []string{`public: 👋 **@everyone\_** joined **#\*\*Lobby\*\* \<@&1\>**`}, dc.sent)
+}
+
+func TestMentionedRoles(t *testing.T) {
+	require.Equal(t, []string{"345", "678"}, mentionedRoles("<@&345> <@&678> [{event}] {text}"))
+	require.Empty(t, mentionedRoles("<@123> @everyone {text}"))
+}
+
+func TestOfflineNotification(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{
+			Routes:       []Route{{Events: []string{notify.EventOffline}, Target: "oncall"}},
+			OfflineAfter: time.Nanosecond,
+		},
+	}, nil, dc, nil).(*service)
+	s.lastState = &teamspeak.State{ServerName: "Game Night"}
+
+	ctx := context.Background()
+	err := errors.New("connection refused")
+
+	s.trackFetch(ctx, err)
+	time.Sleep(time.Millisecond)
+	s.trackFetch(ctx, err)
+	s.trackFetch(ctx, err)
+	require.Equal(t, []string{"oncall: 🔴 **Game Night** is not responding"}, dc.sent)
+
+	s.trackFetch(ctx, nil)
+	require.Len(t, dc.sent, 2)
+	require.Contains(t, dc.sent[1], "oncall: 🟢 **Game Night** is back online")
+
+	s.trackFetch(ctx, nil)
+	require.Len(t, dc.sent, 2)
+}
+
+func TestSilence(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{
+			Routes: []Route{{Events: []string{notify.EventJoin, notify.EventCapacity}, Target: "staff"}},
+		},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	join := alert{event: notify.EventJoin, kind: kindJoin, text: "joined"}
+	full := alert{event: notify.EventCapacity, kind: kindCapacity, text: "full"}
+
+	require.NoError(t, s.Silence(ctx, notify.EventJoin, time.Hour))
+	require.Error(t, s.Silence(ctx, "bogus", time.Hour))
+
+	s.emit(ctx, join, "")
+	s.emit(ctx, full, "")
+	require.Equal(t, []string{"staff: full"}, dc.sent)
+
+	require.NoError(t, s.Silence(ctx, SilenceAll, time.Hour))
+	s.emit(ctx, full, "")
+	require.Len(t, dc.sent, 1)
+	require.Len(t, s.Silences(), 2)
+
+	require.NoError(t, s.Silence(ctx, SilenceAll, 0))
+	require.NoError(t, s.Silence(ctx, notify.EventJoin, 0))
+	s.emit(ctx, join, "")
+	require.Equal(t, []string{"staff: full", "staff: joined"}, dc.sent)
+	require.Empty(t, s.Silences())
+}
+
+func TestSilencedOfflineHasNoRecovery(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{
+			Routes:       []Route{{Events: []string{notify.EventOffline}, Target: "staff"}},
+			OfflineAfter: time.Nanosecond,
+		},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	down := errors.New("connection refused")
+
+	// Coming back after a silenced announcement says nothing either.
+	require.NoError(t, s.Silence(ctx, notify.EventOffline, time.Hour))
+	s.trackFetch(ctx, down)
+	s.trackFetch(ctx, down)
+	s.trackFetch(ctx, nil)
+	require.Empty(t, dc.sent)
+
+	// A silence lifted during the outage lets the announcement through, and
+	// then the recovery too.
+	s.trackFetch(ctx, down)
+	require.NoError(t, s.Silence(ctx, notify.EventOffline, 0))
+	s.trackFetch(ctx, down)
+	s.trackFetch(ctx, nil)
+	require.Len(t, dc.sent, 2)
+	require.Contains(t, dc.sent[0], "is not responding")
+	require.Contains(t, dc.sent[1], "is back online")
+}
+
+func TestNotificationServerLabels(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{
+			Routes: []Route{{Events: []string{notify.EventJoin}, Target: "staff", Format: "[{server}] {text}"}},
+		},
+	}, nil, dc, nil).(*service)
+
+	state := func(eu, us []string) *teamspeak.State {
+		member := func(name, label string, server int, users []string) *teamspeak.State {
+			ch := teamspeak.Channel{ID: 1, Name: "Lobby"}
+			for i, nick := range users {
+				ch.Users = append(ch.Users, teamspeak.User{ID: i + 1, Nickname: nick, Server: server})
+			}
+
+			return &teamspeak.State{ServerName: name, Label: label, Channels: []teamspeak.Channel{ch}}
+		}
+
+		servers := []*teamspeak.State{member("Game Night", "eu-1", 0, eu), member("Game Night", "", 1, us)}
+
+		return &teamspeak.State{
+			ServerName: "All servers",
+			Channels:   append(append([]teamspeak.Channel{}, servers[0].Channels...), servers[1].Channels...),
+			Servers:    servers,
+		}
+	}
+
+	ctx := context.Background()
+	s.notifyPresence(ctx, state(nil, nil))
+	s.notifyPresence(ctx, state([]string{"alice"}, []string{"bob"}))
+
+	// Servers without a label fall back to their name.
+	require.Equal(t, []string{
+		"staff: [eu-1] 👋 **alice** joined **#Lobby** on **eu-1**",
+		"staff: [Game Night] 👋 **bob** joined **#Lobby** on **Game Night**",
+	}, dc.sent)
+}
+
+func TestNotifySubscribers(t *testing.T) {
+	dc := &alertRecorder{}
+	s := NewService(logrus.New(), Config{Subscriptions: true}, nil, dc, nil).(*service)
+	s.subs = []store.Subscription{{UserID: "1", Nickname: "alice"}, {UserID: "2", Users: 2}}
+
+	ctx := context.Background()
+	state := func(users ...string) *teamspeak.State {
+		ch := teamspeak.Channel{ID: 1, Name: "Lobby"}
+		for i, name := range users {
+			ch.Users = append(ch.Users, teamspeak.User{ID: i + 1, Nickname: name})
+		}
+
+		return &teamspeak.State{ServerName: "Game Night", TotalUsers: len(users), Channels: []teamspeak.Channel{ch}}
+	}
+
+	// The first state is the baseline.
+	s.notifySubscribers(ctx, state("Alice"))
+	require.Empty(t, dc.sent)
+
+	s.notifySubscribers(ctx, state())
+	s.notifySubscribers(ctx, state("Alice", "bob"))
+	require.Empty(t, dc.sent, "DMs are sent by the DM task, not the update")
+
+	require.NoError(t, s.sendDMs(ctx))
+	require.ElementsMatch(t, []string{
+		"dm 1: 🟢 **Alice** is now online on **Game Night**.\n-# Stop these with `/ts unsubscribe`.",
+		"dm 2: 📈 **2** users are now online on **Game Night**.\n-# Stop these with `/ts unsubscribe`.",
+	}, dc.sent)
+
+	// Within the cooldown, reconnecting does not DM again.
+	s.notifySubscribers(ctx, state())
+	s.notifySubscribers(ctx, state("Alice", "bob"))
+	require.NoError(t, s.sendDMs(ctx))
+	require.Len(t, dc.sent, 2)
+
+	// A full queue drops DMs without starting their cooldown.
+	s.subsSent = make(map[store.Subscription]time.Time)
+	s.dms = make([]pendingDM, maxPendingDMs)
+	s.notifySubscribers(ctx, state())
+	s.notifySubscribers(ctx, state("Alice", "bob"))
+	require.Len(t, s.dms, maxPendingDMs)
+	require.Empty(t, s.subsSent)
+}
+
+// subscriptionStore keeps subscriptions in memory, enforcing the limit like
+// the database does.
+type subscriptionStore struct {
+	store.Service
+	subs []store.Subscription
+}
+
+func (f *subscriptionStore) Subscribe(_ context.Context, sub store.Subscription, limit int) error {
+	count := 0
+
+	for _, existing := range f.subs {
+		if existing == sub {
+			return nil
+		}
+
+		if existing.UserID == sub.UserID {
+			count++
+		}
+	}
+
+	if count >= limit {
+		return store.ErrSubscriptionLimit
+	}
+
+	f.subs = append(f.subs, sub)
+
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	st := &subscriptionStore{}
+	s := NewService(logrus.New(), Config{Subscriptions: true, SubscriptionRoles: []string{"trusted"}}, nil, &alertRecorder{}, st).(*service)
+	ctx := context.Background()
+
+	// Watching a nickname needs a subscription role; a user count does not.
+	require.Contains(t, s.Subscribe(ctx, "1", []string{"other"}, "Alice", 0), "do not have a role")
+	require.Contains(t, s.Subscribe(ctx, "1", nil, "", 5), "You will get a DM")
+	require.Contains(t, s.Subscribe(ctx, "1", []string{"other", "trusted"}, "Alice", 0), "You will get a DM")
+	require.Contains(t, s.Subscribe(ctx, "1", []string{"trusted"}, "alice", 0), "already subscribed")
+	require.Equal(t, []store.Subscription{{UserID: "1", Users: 5}, {UserID: "1", Nickname: "alice"}}, st.subs)
+
+	// The limit is enforced by the store.
+	for users := 6; len(st.subs) < maxSubscriptions; users++ {
+		require.Contains(t, s.Subscribe(ctx, "1", nil, "", users), "You will get a DM")
+	}
+
+	require.Contains(t, s.Subscribe(ctx, "1", nil, "", 100), "remove one with")
+	require.Len(t, s.subs, maxSubscriptions)
+}
+
+// statusRecorder records alerts and fails status updates with err.
+type statusRecorder struct {
+	alertRecorder
+	err error
+}
+
+func (r *statusRecorder) UpdateStatus(context.Context, *teamspeak.State) error { return r.err }
+
+func TestPublicFilter(t *testing.T) {
+	dc := &statusRecorder{}
+	s := NewService(logrus.New(), Config{
+		Notifications: NotificationsConfig{Routes: []Route{{Events: []string{notify.EventJoin}, Target: "public"}}},
+		PublicFilter:  teamspeak.ChannelFilter{HideNames: []string{"staff"}},
+	}, nil, dc, nil).(*service)
+
+	ctx := context.Background()
+	state := func(staff ...string) *teamspeak.State {
+		ch := teamspeak.Channel{ID: 2, Name: "Staff"}
+		for i, name := range staff {
+			ch.Users = append(ch.Users, teamspeak.User{ID: i + 1, Nickname: name})
+		}
+
+		return &teamspeak.State{ServerName: "Game Night", TotalUsers: len(staff), Channels: []teamspeak.Channel{{ID: 1, Name: "Lobby"}, ch}}
+	}
+
+	// A channel some status channel shows reaches the bridge, but neither the
+	// API nor the alerts name it.
+	s.publish(ctx, state())
+	s.publish(ctx, state("alice"))
+	require.Empty(t, dc.sent)
+	require.Equal(t, []teamspeak.Channel{{ID: 1, Name: "Lobby"}}, s.Current().Channels)
+}
+
+func TestCurrentNeedsUpdate(t *testing.T) {
+	dc := &statusRecorder{}
+	s := NewService(logrus.New(), Config{}, nil, dc, nil).(*service)
+	ctx := context.Background()
+
+	s.publish(ctx, &teamspeak.State{ServerName: "Game Night"})
+	require.Equal(t, "Game Night", s.Current().ServerName)
+
+	// A state that never reached Discord is not served.
+	dc.err = errors.New("discord down")
+	s.publish(ctx, &teamspeak.State{ServerName: "Renamed"})
+	require.Equal(t, "Game Night", s.Current().ServerName)
+}
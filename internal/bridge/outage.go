@@ -0,0 +1,106 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// OutageAlertConfig pings a role once the TeamSpeak server has failed to
+// answer several fetches in a row, and follows up when it answers again.
+type OutageAlertConfig struct {
+	RoleID    string // Role to ping (empty disables the alert)
+	ChannelID string // Channel to post in
+	After     int    // Consecutive failed fetches before the ping
+}
+
+func (c OutageAlertConfig) enabled() bool {
+	return c.RoleID != "" && c.ChannelID != "" && c.After > 0
+}
+
+// trackOutage counts consecutive failed fetches and posts the role ping when
+// they reach OutageAlert.After, or the follow-up on the first success after
+// it. A silence of the offline event holds back the ping.
+func (s *service) trackOutage(ctx context.Context, err error) {
+	cfg := s.cfg.OutageAlert
+	if !cfg.enabled() {
+		return
+	}
+
+	name := "The TeamSpeak server"
+	if s.lastState != nil && s.lastState.LabelOrName() != "" {
+		name = discord.EscapeMarkdown(s.lastState.LabelOrName())
+	}
+
+	if err == nil {
+		if s.outageAlerted {
+			s.postOutage(ctx, fmt.Sprintf("🟢 **%s** is back up after %s.", name, shortDuration(time.Since(s.outageSince))))
+		}
+
+		s.outageFailures = 0
+		s.outageAlerted = false
+
+		return
+	}
+
+	if s.outageFailures == 0 {
+		s.outageSince = time.Now()
+	}
+
+	s.outageFailures++
+
+	if s.outageAlerted || s.outageFailures < cfg.After {
+		return
+	}
+
+	if s.silenced(notify.EventOffline, time.Now()) {
+		s.log.WithField("event", notify.EventOffline).Debug("Outage alert silenced")
+
+		return
+	}
+
+	reason := ""
+	if r := outageReason(err); r != "" {
+		reason = " (" + r + ")"
+	}
+
+	s.postOutage(ctx, fmt.Sprintf("<@&%s> 🔴 **%s** is down: %d fetches in a row failed%s.",
+		cfg.RoleID, name, s.outageFailures, reason))
+
+	s.outageAlerted = true
+}
+
+// outageReason names the kind of failure for the alert, or returns "". The
+// error itself is not quoted: the alert channel may be public, and errors
+// name the query host and port.
+func outageReason(err error) string {
+	var (
+		ban    *teamspeak.BannedError
+		netErr net.Error
+	)
+
+	switch {
+	case errors.As(err, &ban):
+		return "the bot is banned from the server"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	case errors.As(err, &netErr):
+		return "connection failed"
+	default:
+		return ""
+	}
+}
+
+// postOutage posts an outage alert or its follow-up, which may ping the
+// alert role.
+func (s *service) postOutage(ctx context.Context, content string) {
+	if err := s.discord.Notify(ctx, s.cfg.OutageAlert.ChannelID, content, s.cfg.OutageAlert.RoleID); err != nil {
+		s.log.WithError(err).WithField("channel_id", s.cfg.OutageAlert.ChannelID).Warn("Failed to send outage alert")
+	}
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/notify"
+)
+
+// SilenceAll silences every event type at once.
+const SilenceAll = discord.SilenceAll
+
+// Silence suppresses notifications of event, or of every event with
+// SilenceAll, for duration; a zero duration lifts the silence. Silences are
+// persisted when the store is enabled, so they survive restarts.
+func (s *service) Silence(ctx context.Context, event string, duration time.Duration) error {
+	if event != SilenceAll && !slices.Contains(notify.Events, event) {
+		return fmt.Errorf("unknown event %q (want %s or %s)", event, strings.Join(notify.Events, ", "), SilenceAll)
+	}
+
+	var expires time.Time
+	if duration > 0 {
+		expires = time.Now().Add(duration)
+	}
+
+	s.silenceMu.Lock()
+	if expires.IsZero() {
+		delete(s.silences, event)
+	} else {
+		s.silences[event] = expires
+	}
+	s.silenceMu.Unlock()
+
+	s.log.WithFields(logrus.Fields{"event": event, "duration": duration}).Info("Notification silence changed")
+
+	if s.store != nil {
+		if err := s.store.SaveSilence(ctx, event, expires); err != nil {
+			s.log.WithError(err).Warn("Failed to persist silence")
+		}
+	}
+
+	return nil
+}
+
+// Silences returns the active silences and when each expires.
+func (s *service) Silences() map[string]time.Time {
+	s.silenceMu.Lock()
+	defer s.silenceMu.Unlock()
+
+	now := time.Now()
+	maps.DeleteFunc(s.silences, func(_ string, expires time.Time) bool { return !now.Before(expires) })
+
+	return maps.Clone(s.silences)
+}
+
+// silenced reports whether notifications of event are suppressed at now.
+func (s *service) silenced(event string, now time.Time) bool {
+	s.silenceMu.Lock()
+	defer s.silenceMu.Unlock()
+
+	for _, key := range []string{event, SilenceAll} {
+		if expires, ok := s.silences[key]; ok && now.Before(expires) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// restoreSilences loads the silences persisted by a previous run.
+func (s *service) restoreSilences(ctx context.Context) {
+	silences, err := s.store.Silences(ctx)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to load persisted silences")
+
+		return
+	}
+
+	s.silenceMu.Lock()
+	maps.Copy(s.silences, silences)
+	s.silenceMu.Unlock()
+}
@@ -0,0 +1,279 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/discord"
+	"github.com/samcm/ts-discord-status/internal/store"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// maxSubscriptions bounds the subscriptions of one Discord user.
+	maxSubscriptions = 10
+
+	// subscriptionCooldown is how long a subscription stays quiet after a DM,
+	// so a user reconnecting or a count hovering at the threshold does not
+	// flood the subscriber.
+	subscriptionCooldown = 15 * time.Minute
+
+	// dmTask is the scheduler task name of the subscription DM delivery.
+	dmTask = "subscription-dms"
+
+	// dmInterval is how often the DM task runs without being triggered.
+	dmInterval = time.Minute
+
+	// maxPendingDMs bounds the DMs waiting for delivery; further DMs are
+	// dropped until the task catches up.
+	maxPendingDMs = 100
+)
+
+// pendingDM is a subscription DM waiting for delivery.
+type pendingDM struct {
+	userID string
+	text   string
+}
+
+// Subscribe stores a DM subscription for a nickname coming online, or for
+// the user count reaching users, and returns the reply for the subscriber.
+// Nickname subscriptions need one of the subscription roles in roles.
+func (s *service) Subscribe(ctx context.Context, userID string, roles []string, nickname string, users int) string {
+	if reply := s.subscriptionsReady(); reply != "" {
+		return reply
+	}
+
+	sub := store.Subscription{UserID: userID, Nickname: strings.ToLower(strings.TrimSpace(nickname)), Users: users}
+
+	switch {
+	case (sub.Nickname == "") == (sub.Users == 0):
+		return "Give either a nickname or a user count."
+	case sub.Users < 0:
+		return "The user count must be positive."
+	case sub.Nickname != "" && !slices.ContainsFunc(roles, func(r string) bool { return slices.Contains(s.cfg.SubscriptionRoles, r) }):
+		return "You do not have a role allowed to subscribe to nicknames, subscribe to a user count instead."
+	}
+
+	s.subsMu.Lock()
+	subscribed := slices.Contains(s.subs, sub)
+	s.subsMu.Unlock()
+
+	if subscribed {
+		return "You are already subscribed to that."
+	}
+
+	err := s.store.Subscribe(ctx, sub, maxSubscriptions)
+	switch {
+	case errors.Is(err, store.ErrSubscriptionLimit):
+		return fmt.Sprintf("You already have %d subscriptions, remove one with `/ts unsubscribe` first.", maxSubscriptions)
+	case err != nil:
+		s.log.WithError(err).Warn("Failed to save subscription")
+
+		return "Could not save the subscription, try again later."
+	}
+
+	s.subsMu.Lock()
+	if !slices.Contains(s.subs, sub) {
+		s.subs = append(s.subs, sub)
+	}
+	s.subsMu.Unlock()
+
+	return "🔔 You will get a DM when " + subscriptionText(sub) + ". Discord only delivers it if you allow DMs from server members."
+}
+
+// Unsubscribe removes the user's subscription for a nickname or user count,
+// or all of them when neither is given, and returns the reply.
+func (s *service) Unsubscribe(ctx context.Context, userID, nickname string, users int) string {
+	if reply := s.subscriptionsReady(); reply != "" {
+		return reply
+	}
+
+	sub := store.Subscription{UserID: userID, Nickname: strings.ToLower(strings.TrimSpace(nickname)), Users: users}
+
+	n, err := s.store.Unsubscribe(ctx, sub)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to delete subscription")
+
+		return "Could not remove the subscription, try again later."
+	}
+
+	s.subsMu.Lock()
+	kept := s.subs[:0]
+
+	for _, existing := range s.subs {
+		if existing.UserID != userID || (sub.Nickname != "" || sub.Users != 0) && existing != sub {
+			kept = append(kept, existing)
+		}
+	}
+
+	s.subs = kept
+	s.subsMu.Unlock()
+
+	switch {
+	case n == 0:
+		return "You had no matching subscription."
+	case n == 1:
+		return "🔕 Removed 1 subscription."
+	default:
+		return fmt.Sprintf("🔕 Removed %d subscriptions.", n)
+	}
+}
+
+// Subscriptions lists the user's subscriptions.
+func (s *service) Subscriptions(_ context.Context, userID string) string {
+	if reply := s.subscriptionsReady(); reply != "" {
+		return reply
+	}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	var lines []string
+
+	for _, sub := range s.subs {
+		if sub.UserID == userID {
+			lines = append(lines, "• "+subscriptionText(sub))
+		}
+	}
+
+	if len(lines) == 0 {
+		return "You have no subscriptions. Add one with `/ts subscribe`."
+	}
+
+	return "You get a DM when:\n" + strings.Join(lines, "\n")
+}
+
+// subscriptionsReady returns why subscriptions cannot be changed, or "".
+func (s *service) subscriptionsReady() string {
+	switch {
+	case !s.cfg.Subscriptions:
+		return "DM subscriptions are not enabled (notifications.dm_subscriptions)."
+	case s.store == nil:
+		return "DM subscriptions need the database to be enabled."
+	}
+
+	return ""
+}
+
+// subscriptionText describes what a subscription waits for.
+func subscriptionText(sub store.Subscription) string {
+	if sub.Nickname != "" {
+		return "**" + discord.EscapeMarkdown(sub.Nickname) + "** comes online"
+	}
+
+	return fmt.Sprintf("**%d** or more users are online", sub.Users)
+}
+
+// restoreSubscriptions loads the stored subscriptions.
+func (s *service) restoreSubscriptions(ctx context.Context) {
+	subs, err := s.store.Subscriptions(ctx)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to load subscriptions")
+
+		return
+	}
+
+	s.subsMu.Lock()
+	s.subs = subs
+	s.subsMu.Unlock()
+}
+
+// notifySubscribers queues DMs for the subscribers of nicknames that came
+// online and of user counts that were reached since the previous displayed
+// state, and triggers their delivery so slow or rate limited DMs do not hold
+// up the update. Like join notifications, the first state after startup only
+// sets the baseline.
+func (s *service) notifySubscribers(ctx context.Context, state *teamspeak.State) {
+	prev := s.subsPrev
+	s.subsPrev = state
+
+	if prev == nil {
+		return
+	}
+
+	before := onlineNicknames(prev)
+	after := onlineNicknames(state)
+	now := time.Now()
+
+	s.subsMu.Lock()
+	due := make(map[store.Subscription]string)
+
+	for _, sub := range s.subs {
+		if now.Sub(s.subsSent[sub]) < subscriptionCooldown {
+			continue
+		}
+
+		switch {
+		case sub.Nickname != "":
+			if nick, ok := after[sub.Nickname]; ok {
+				if _, was := before[sub.Nickname]; !was {
+					due[sub] = fmt.Sprintf("🟢 **%s** is now online on **%s**.",
+						discord.EscapeMarkdown(nick), discord.EscapeMarkdown(state.LabelOrName()))
+				}
+			}
+		case prev.TotalUsers < sub.Users && state.TotalUsers >= sub.Users:
+			due[sub] = fmt.Sprintf("📈 **%d** users are now online on **%s**.",
+				state.TotalUsers, discord.EscapeMarkdown(state.LabelOrName()))
+		}
+	}
+
+	dropped := 0
+
+	for sub, text := range due {
+		if len(s.dms) >= maxPendingDMs {
+			dropped++
+
+			continue
+		}
+
+		s.subsSent[sub] = now
+		s.dms = append(s.dms, pendingDM{userID: sub.UserID, text: text + "\n-# Stop these with `/ts unsubscribe`."})
+	}
+	s.subsMu.Unlock()
+
+	if dropped > 0 {
+		s.cfg.LogSampler.Warn(s.log.WithField("dropped", dropped), "Subscription DM queue full, dropping DMs")
+	}
+
+	if len(due) > dropped {
+		s.scheduler.Trigger(dmTask)
+	}
+}
+
+// sendDMs is the DM task: it delivers the queued subscription DMs.
+func (s *service) sendDMs(ctx context.Context) error {
+	s.subsMu.Lock()
+	dms := s.dms
+	s.dms = nil
+	s.subsMu.Unlock()
+
+	for _, dm := range dms {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := s.discord.DirectMessage(ctx, dm.userID, dm.text); err != nil {
+			s.log.WithError(err).WithField("user_id", dm.userID).Warn("Failed to send subscription DM")
+		}
+	}
+
+	return nil
+}
+
+// onlineNicknames maps the lowercased nicknames in state to how they are
+// written.
+func onlineNicknames(state *teamspeak.State) map[string]string {
+	nicks := make(map[string]string, state.TotalUsers)
+
+	for _, ch := range state.Channels {
+		for _, u := range ch.Users {
+			nicks[strings.ToLower(u.Nickname)] = u.Nickname
+		}
+	}
+
+	return nicks
+}
@@ -0,0 +1,299 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+// Colours match Discord's dark theme so charts sit naturally in embeds.
+var (
+	colorBackground = color.RGBA{0x2B, 0x2D, 0x31, 0xFF}
+	colorGrid       = color.RGBA{0x3F, 0x41, 0x47, 0xFF}
+	colorText       = color.RGBA{0xB5, 0xBA, 0xC1, 0xFF}
+	colorAccent     = color.RGBA{0x58, 0x65, 0xF2, 0xFF}
+	colorEmptyCell  = color.RGBA{0x35, 0x37, 0x3C, 0xFF}
+)
+
+const (
+	titleScale = 2
+	margin     = 12
+	labelGap   = 6
+)
+
+// builtin draws charts with the standard library only.
+type builtin struct {
+	width, height int
+}
+
+// Line plots the series with horizontal gridlines and a labelled Y axis.
+func (b *builtin) Line(_ context.Context, c Line) ([]byte, error) {
+	if len(c.Values) == 0 {
+		return nil, fmt.Errorf("no values to plot")
+	}
+
+	img := b.canvas(c.Title)
+
+	top := b.plotTop(c.Title)
+	peak := niceMax(c.Values)
+
+	left := margin + textWidth(formatValue(peak), 1) + labelGap
+	plot := image.Rect(left, top, b.width-margin, b.height-margin-glyphHeight-labelGap)
+
+	if plot.Dx() <= 0 || plot.Dy() <= 0 {
+		return nil, fmt.Errorf("chart size %dx%d is too small", b.width, b.height)
+	}
+
+	const gridLines = 4
+
+	for i := 0; i <= gridLines; i++ {
+		y := plot.Max.Y - plot.Dy()*i/gridLines
+		fill(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), colorGrid)
+
+		label := formatValue(peak * float64(i) / gridLines)
+		drawText(img, left-labelGap-textWidth(label, 1), y-glyphHeight/2, label, colorText, 1)
+	}
+
+	point := func(i int) image.Point {
+		x := plot.Min.X + plot.Dx()/2
+		if len(c.Values) > 1 {
+			x = plot.Min.X + plot.Dx()*i/(len(c.Values)-1)
+		}
+
+		return image.Pt(x, plot.Max.Y-int(math.Round(float64(plot.Dy())*max(c.Values[i], 0)/peak)))
+	}
+
+	for i := range c.Values {
+		p := point(i)
+
+		if i > 0 {
+			prev := point(i - 1)
+			drawLine(img, prev.X, prev.Y, p.X, p.Y, colorAccent)
+		}
+
+		if len(c.Values) <= plot.Dx()/8 {
+			fill(img, image.Rect(p.X-2, p.Y-2, p.X+3, p.Y+3), colorAccent)
+		}
+	}
+
+	// Label as many points as fit without overlapping, evenly spaced.
+	labels := min(len(c.Labels), len(c.Values))
+	every := labelStep(c.Labels[:labels], plot.Dx(), labels)
+
+	for i := 0; i < labels; i += every {
+		p := point(i)
+		w := textWidth(c.Labels[i], 1)
+		x := min(max(p.X-w/2, 0), b.width-w)
+		drawText(img, x, plot.Max.Y+labelGap, c.Labels[i], colorText, 1)
+	}
+
+	return encode(img)
+}
+
+// Heatmap shades each cell by its share of the highest value.
+func (b *builtin) Heatmap(_ context.Context, c Heatmap) ([]byte, error) {
+	if len(c.Rows) == 0 || len(c.Columns) == 0 {
+		return nil, fmt.Errorf("no cells to plot")
+	}
+
+	img := b.canvas(c.Title)
+
+	rowLabel := 0
+	for _, r := range c.Rows {
+		rowLabel = max(rowLabel, textWidth(r, 1))
+	}
+
+	left := margin + rowLabel + labelGap
+	plot := image.Rect(left, b.plotTop(c.Title), b.width-margin, b.height-margin-glyphHeight-labelGap)
+
+	cellW := plot.Dx() / len(c.Columns)
+	cellH := plot.Dy() / len(c.Rows)
+
+	if cellW < 2 || cellH < 2 {
+		return nil, fmt.Errorf("chart size %dx%d is too small for %dx%d cells", b.width, b.height, len(c.Columns), len(c.Rows))
+	}
+
+	var peak float64
+
+	for _, row := range c.Values {
+		for _, v := range row {
+			peak = max(peak, v)
+		}
+	}
+
+	for r, name := range c.Rows {
+		y := plot.Min.Y + r*cellH
+		drawText(img, left-labelGap-textWidth(name, 1), y+(cellH-glyphHeight)/2, name, colorText, 1)
+
+		for col := range c.Columns {
+			var v float64
+			if r < len(c.Values) && col < len(c.Values[r]) {
+				v = c.Values[r][col]
+			}
+
+			shade := colorEmptyCell
+			if v > 0 && peak > 0 {
+				shade = blend(colorEmptyCell, colorAccent, v/peak)
+			}
+
+			x := plot.Min.X + col*cellW
+			fill(img, image.Rect(x+1, y+1, x+cellW, y+cellH), shade)
+		}
+	}
+
+	every := labelStep(c.Columns, plot.Dx(), len(c.Columns))
+
+	for col := 0; col < len(c.Columns); col += every {
+		w := textWidth(c.Columns[col], 1)
+		x := plot.Min.X + col*cellW + (cellW-w)/2
+		drawText(img, x, plot.Min.Y+len(c.Rows)*cellH+labelGap, c.Columns[col], colorText, 1)
+	}
+
+	if peak > 0 {
+		legend := "max " + formatValue(peak)
+		drawText(img, b.width-margin-textWidth(legend, 1), margin, legend, colorText, 1)
+	}
+
+	return encode(img)
+}
+
+// canvas returns a background-filled image with the title drawn.
+func (b *builtin) canvas(title string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, b.width, b.height))
+	fill(img, img.Bounds(), colorBackground)
+
+	if title != "" {
+		drawText(img, margin, margin, title, colorText, titleScale)
+	}
+
+	return img
+}
+
+// plotTop returns the first row below the title.
+func (b *builtin) plotTop(title string) int {
+	if title == "" {
+		return margin
+	}
+
+	return margin + glyphHeight*titleScale + margin
+}
+
+// labelStep returns how many labels to advance between drawn ones so the
+// widest still fits in its share of width.
+func labelStep(labels []string, width, n int) int {
+	widest := 0
+	for _, l := range labels {
+		widest = max(widest, textWidth(l, 1))
+	}
+
+	if widest == 0 || n == 0 {
+		return 1
+	}
+
+	fit := max(width/(widest+labelGap), 1)
+
+	return max((n+fit-1)/fit, 1)
+}
+
+// niceMax rounds the largest value up to 1, 2 or 5 times a power of ten so
+// gridline labels stay readable. It is at least 1.
+func niceMax(values []float64) float64 {
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	if peak <= 1 {
+		return 1
+	}
+
+	pow := math.Pow(10, math.Floor(math.Log10(peak)))
+
+	for _, m := range []float64{1, 2, 5, 10} {
+		if peak <= m*pow {
+			return m * pow
+		}
+	}
+
+	return 10 * pow
+}
+
+// formatValue prints v with at most one decimal.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawLine draws a two pixel wide line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+
+	for {
+		fill(img, image.Rect(x0, y0, x0+2, y0+2), c)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// blend mixes a into b by t in [0, 1].
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+
+	return 0
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
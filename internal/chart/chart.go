@@ -0,0 +1,55 @@
+// Package chart renders small PNG charts for graph and heatmap features.
+//
+// The builtin backend is pure Go with an embedded bitmap font, so it needs no
+// cgo, external binaries or system fonts and works on minimal container
+// images.
+package chart
+
+import (
+	"context"
+)
+
+const (
+	defaultWidth  = 800
+	defaultHeight = 300
+)
+
+// Line is a single series plotted over labelled points.
+type Line struct {
+	Title  string
+	Labels []string // X axis label per point
+	Values []float64
+}
+
+// Heatmap is a grid of values, shaded from empty to the highest value.
+type Heatmap struct {
+	Title   string
+	Rows    []string
+	Columns []string
+	Values  [][]float64 // Indexed [row][column]
+}
+
+// Renderer draws charts into PNG images.
+type Renderer interface {
+	Line(ctx context.Context, c Line) ([]byte, error)
+	Heatmap(ctx context.Context, c Heatmap) ([]byte, error)
+}
+
+// Config sizes the renderer.
+type Config struct {
+	Width  int // Image width in pixels (default 800)
+	Height int // Image height in pixels (default 300)
+}
+
+// New returns a renderer for cfg.
+func New(cfg Config) Renderer {
+	if cfg.Width <= 0 {
+		cfg.Width = defaultWidth
+	}
+
+	if cfg.Height <= 0 {
+		cfg.Height = defaultHeight
+	}
+
+	return &builtin{width: cfg.Width, height: cfg.Height}
+}
@@ -0,0 +1,46 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinRenders(t *testing.T) {
+	r := New(Config{Width: 400, Height: 200})
+
+	line, err := r.Line(context.Background(), Line{
+		Title:  "Users online",
+		Labels: []string{"00:00", "06:00", "12:00", "18:00"},
+		Values: []float64{0, 3, 12, 7},
+	})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(line))
+	require.NoError(t, err)
+	require.Equal(t, 400, img.Bounds().Dx())
+	require.Equal(t, 200, img.Bounds().Dy())
+
+	heat, err := r.Heatmap(context.Background(), Heatmap{
+		Rows:    []string{"Mon", "Tue"},
+		Columns: []string{"00", "01", "02"},
+		Values:  [][]float64{{0, 1, 2}, {3}},
+	})
+	require.NoError(t, err)
+
+	_, err = png.Decode(bytes.NewReader(heat))
+	require.NoError(t, err)
+
+	_, err = r.Line(context.Background(), Line{})
+	require.Error(t, err)
+}
+
+func TestNiceMax(t *testing.T) {
+	require.Equal(t, 1.0, niceMax(nil))
+	require.Equal(t, 2.0, niceMax([]float64{1.5}))
+	require.Equal(t, 50.0, niceMax([]float64{12, 31}))
+	require.Equal(t, 100.0, niceMax([]float64{100}))
+}
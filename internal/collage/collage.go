@@ -0,0 +1,134 @@
+// Package collage composes client avatars into a single grid image.
+package collage
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register decoders for TeamSpeak avatar formats
+	_ "image/jpeg"
+	"image/png"
+)
+
+// maxTilePixels caps the decoded size of a single avatar. The byte size is
+// bounded by the download limit, but a small PNG can still declare huge
+// dimensions, so larger images get a placeholder instead.
+const maxTilePixels = 2048 * 2048
+
+// Tile is one cell of the collage. Image holds the encoded avatar; when it is
+// empty, cannot be decoded or is too large, a placeholder colour derived from Key is drawn.
+type Tile struct {
+	Key   string
+	Image []byte
+}
+
+// Options controls the collage geometry.
+type Options struct {
+	TileSize int // Edge length of each square tile in pixels
+	Columns  int // Maximum tiles per row
+	Gap      int // Pixels between tiles
+}
+
+// Compose renders the tiles into a PNG grid.
+func Compose(tiles []Tile, opts Options) ([]byte, error) {
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("no tiles to compose")
+	}
+
+	if opts.TileSize <= 0 || opts.Columns <= 0 {
+		return nil, fmt.Errorf("invalid collage options: tile size %d, columns %d", opts.TileSize, opts.Columns)
+	}
+
+	cols := opts.Columns
+	if len(tiles) < cols {
+		cols = len(tiles)
+	}
+
+	rows := (len(tiles) + cols - 1) / cols
+	step := opts.TileSize + opts.Gap
+
+	canvas := image.NewRGBA(image.Rect(0, 0, cols*step-opts.Gap, rows*step-opts.Gap))
+
+	for i, tile := range tiles {
+		x := (i % cols) * step
+		y := (i / cols) * step
+		dst := image.Rect(x, y, x+opts.TileSize, y+opts.TileSize)
+
+		src, err := decode(tile.Image)
+		if err != nil {
+			draw.Draw(canvas, dst, image.NewUniform(placeholder(tile.Key)), image.Point{}, draw.Src)
+			continue
+		}
+
+		scale(canvas, dst, src)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode collage: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode decodes an avatar after checking its declared dimensions against
+// maxTilePixels.
+func decode(data []byte) (image.Image, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image config: %w", err)
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxTilePixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return src, nil
+}
+
+// scale draws src into dst using nearest-neighbour sampling of its centred
+// square crop, which is plenty for small avatar thumbnails.
+func scale(canvas *image.RGBA, dst image.Rectangle, src image.Image) {
+	b := src.Bounds()
+
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	ox := b.Min.X + (b.Dx()-side)/2
+	oy := b.Min.Y + (b.Dy()-side)/2
+	size := dst.Dx()
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			canvas.Set(dst.Min.X+x, dst.Min.Y+y, src.At(ox+x*side/size, oy+y*side/size))
+		}
+	}
+}
+
+// placeholder picks a stable muted colour for a tile without an avatar.
+func placeholder(key string) color.Color {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	v := h.Sum32()
+
+	return color.RGBA{
+		R: uint8(64 + v%128),
+		G: uint8(64 + (v>>8)%128),
+		B: uint8(64 + (v>>16)%128),
+		A: 0xFF,
+	}
+}
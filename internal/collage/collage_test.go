@@ -0,0 +1,144 @@
+package collage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	return buf.Bytes()
+}
+
+func solid(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	return encode(t, img)
+}
+
+// bomb returns a tiny PNG whose header declares w x h pixels.
+func bomb(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+
+	data := solid(t, 1, 1, color.White)
+
+	// The IHDR chunk follows the 8-byte signature: length, type, then width
+	// and height, with the chunk CRC after its 13 data bytes.
+	binary.BigEndian.PutUint32(data[16:], w)
+	binary.BigEndian.PutUint32(data[20:], h)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	return data
+}
+
+func compose(t *testing.T, tiles []Tile, opts Options) image.Image {
+	t.Helper()
+
+	data, err := Compose(tiles, opts)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	return img
+}
+
+func rgba(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func TestComposeGrid(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	tiles := make([]Tile, 5)
+
+	for i := range tiles {
+		tiles[i] = Tile{Key: "u", Image: solid(t, 8, 8, red)}
+	}
+
+	img := compose(t, tiles, Options{TileSize: 10, Columns: 3, Gap: 2})
+	require.Equal(t, image.Rect(0, 0, 3*12-2, 2*12-2), img.Bounds())
+
+	// The first tile of the second row starts one step down.
+	require.Equal(t, red, rgba(img.At(0, 12)))
+	// Gaps and the missing sixth cell stay transparent.
+	require.Zero(t, rgba(img.At(10, 0)).A)
+	require.Zero(t, rgba(img.At(30, 20)).A)
+
+	// Fewer tiles than columns shrinks the grid.
+	img = compose(t, tiles[:2], Options{TileSize: 10, Columns: 3, Gap: 2})
+	require.Equal(t, image.Rect(0, 0, 22, 10), img.Bounds())
+}
+
+func TestComposeScalesCentredCrop(t *testing.T) {
+	// A 30x10 image: blue on the left and right thirds, green in the middle.
+	src := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	green := color.RGBA{G: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 30; x++ {
+			c := blue
+			if x >= 10 && x < 20 {
+				c = green
+			}
+
+			src.Set(x, y, c)
+		}
+	}
+
+	img := compose(t, []Tile{{Key: "u", Image: encode(t, src)}}, Options{TileSize: 4, Columns: 1})
+	require.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			require.Equal(t, green, rgba(img.At(x, y)), "pixel %d,%d", x, y)
+		}
+	}
+}
+
+func TestComposePlaceholder(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":       nil,
+		"undecodable": []byte("not an image"),
+		"oversized":   bomb(t, 100000, 100000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			img := compose(t, []Tile{{Key: "user", Image: data}}, Options{TileSize: 4, Columns: 1})
+			require.Equal(t, rgba(placeholder("user")), rgba(img.At(2, 2)))
+		})
+	}
+}
+
+func TestDecodeRejectsOversized(t *testing.T) {
+	_, err := decode(bomb(t, 100000, 100000))
+	require.ErrorContains(t, err, "too large")
+
+	_, err = decode(solid(t, 2, 2, color.White))
+	require.NoError(t, err)
+}
+
+func TestComposeInvalidOptions(t *testing.T) {
+	_, err := Compose(nil, Options{TileSize: 4, Columns: 1})
+	require.Error(t, err)
+
+	_, err = Compose([]Tile{{Key: "u"}}, Options{TileSize: 0, Columns: 1})
+	require.Error(t, err)
+}
@@ -3,19 +3,67 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template/parse"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/samcm/ts-discord-status/internal/notify"
 )
 
 // Config represents the complete application configuration.
 type Config struct {
+	// Sources and Sinks are the structured layout: typed entries replacing
+	// the flat teamspeak, *_servers, json_sources and discord keys.
+	Sources []SourceConfig `yaml:"sources"`
+	Sinks   []SinkConfig   `yaml:"sinks"`
+
 	TeamSpeak TeamSpeakConfig `yaml:"teamspeak"`
-	Discord   DiscordConfig   `yaml:"discord"`
-	Display   DisplayConfig   `yaml:"display"`
-	Database  DatabaseConfig  `yaml:"database"`
-	Logging   LoggingConfig   `yaml:"logging"`
+	// TeamSpeakServers aggregates several servers into one embed, replacing
+	// the single teamspeak block.
+	TeamSpeakServers []TeamSpeakConfig `yaml:"teamspeak_servers"`
+	// MumbleServers are shown as extra sections next to the TeamSpeak
+	// server(s).
+	MumbleServers []MumbleConfig `yaml:"mumble_servers"`
+	// JSONSources are HTTP endpoints serving presence state as JSON, shown as
+	// extra sections.
+	JSONSources []JSONSourceConfig `yaml:"json_sources"`
+	// HostedServers are TeamSpeak servers read through a hosting provider's
+	// REST API, for owners without ServerQuery access.
+	HostedServers []HostedConfig `yaml:"hosted_servers"`
+	// MinecraftServers are shown as extra sections with their player counts.
+	MinecraftServers []MinecraftConfig `yaml:"minecraft_servers"`
+	// GameServers are A2S-queryable game servers (CS2, Valheim, ...) shown as
+	// extra sections with player counts and the current map.
+	GameServers   []GameServerConfig  `yaml:"game_servers"`
+	Discord       DiscordConfig       `yaml:"discord"`
+	Display       DisplayConfig       `yaml:"display"`
+	Filter        FilterConfig        `yaml:"content_filter"`
+	Database      DatabaseConfig      `yaml:"database"`
+	HTTP          HTTPConfig          `yaml:"http"`
+	AFKAlerts     AFKAlertsConfig     `yaml:"afk_alerts"`
+	Alerts        AlertsConfig        `yaml:"alerts"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	Sentry        SentryConfig        `yaml:"sentry"`
+	Debug         DebugConfig         `yaml:"debug"`
+	Features      FeaturesConfig      `yaml:"features"`
+
+	// ShutdownTimeout bounds how long shutdown waits for in-flight updates
+	// before abandoning them and exiting anyway.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// Warnings lists unknown and deprecated keys found while loading, for the
+	// caller to log once logging is configured.
+	Warnings []string `yaml:"-"`
 }
 
 // DatabaseConfig holds settings for recording status snapshots to a local
@@ -27,44 +75,786 @@ type DatabaseConfig struct {
 	RetentionDays  int           `yaml:"retention_days"`
 }
 
+// AFKAlertsConfig notifies staff (or pokes the user) when someone idles
+// outside AFK channels.
+type AFKAlertsConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	IdleAfter      time.Duration `yaml:"idle_after"`       // Idle time that triggers an alert (default: 30m)
+	AFKChannels    []string      `yaml:"afk_channels"`     // Channel name substrings where idling is fine (default: ["afk"])
+	StaffChannelID string        `yaml:"staff_channel_id"` // Discord channel to notify
+	Poke           bool          `yaml:"poke"`             // Poke the user in TeamSpeak
+	PokeMessage    string        `yaml:"poke_message"`     // {idle} is replaced with the idle time
+}
+
+// AlertsConfig pings a role while the TeamSpeak server is down.
+type AlertsConfig struct {
+	DowntimeRoleID    string `yaml:"downtime_role_id"`    // Role pinged once fetches keep failing (empty disables it)
+	DowntimeChannelID string `yaml:"downtime_channel_id"` // Channel to post in (default: the status channel)
+	DowntimeAfter     int    `yaml:"downtime_after"`      // Consecutive failed fetches before the ping (default: 3)
+}
+
+// NotificationsConfig routes event notifications to channels and webhooks and
+// sets how they are queued and batched.
+type NotificationsConfig struct {
+	// ChannelID, Joins and Leaves are shorthand for a single route sending
+	// joins and/or leaves to one channel.
+	ChannelID string `yaml:"channel_id"`
+	Joins     bool   `yaml:"joins"`
+	Leaves    bool   `yaml:"leaves"`
+
+	Routes []NotificationRoute `yaml:"routes"`
+
+	// DMSubscriptions lets users subscribe with /ts subscribe to a DM when a
+	// nickname comes online or the user count reaches a threshold.
+	DMSubscriptions bool `yaml:"dm_subscriptions"`
+	// SubscriptionRoles are the Discord role IDs allowed to subscribe to a
+	// nickname; without any, only user count subscriptions are allowed.
+	SubscriptionRoles []string `yaml:"subscription_roles"`
+
+	QueueSize       int           `yaml:"queue_size"`       // Notifications held at most before dropping; 0 sends each one directly (default: 100)
+	BatchWindow     time.Duration `yaml:"batch_window"`     // How long to gather similar notifications into one message (default: 10s)
+	OfflineAfter    time.Duration `yaml:"offline_after"`    // How long fetches must fail before the offline event (default: 2m)
+	CapacityPercent float64       `yaml:"capacity_percent"` // Percent of slots used that triggers the capacity event; 0 disables it (default: 90)
+}
+
+// NotificationRoute sends the listed events to one channel or webhook.
+type NotificationRoute struct {
+	Events     []string `yaml:"events"`      // join, leave, offline, capacity, moderation
+	ChannelID  string   `yaml:"channel_id"`  // Discord channel to post to
+	WebhookURL string   `yaml:"webhook_url"` // Or a Discord webhook URL
+	Format     string   `yaml:"format"`      // {text}, {event} and {server} are replaced (default: the message as is)
+}
+
+// HTTPConfig holds settings for the optional HTTP API.
+type HTTPConfig struct {
+	Listen string `yaml:"listen"` // Address to listen on, e.g. ":8080" (empty disables the API)
+	Token  string `yaml:"token"`  // Bearer token required by the /api endpoints
+	// Metrics serves Prometheus metrics on /metrics without authentication.
+	Metrics bool `yaml:"metrics"`
+	// MetricsChannels lists the channel names exported as per-channel user
+	// gauges. Only listed channels are exported, so hundreds of temporary
+	// channels cannot blow up the label count.
+	MetricsChannels []string `yaml:"metrics_channels"`
+	// Pprof serves Go runtime profiles on /debug/pprof/ behind the token.
+	Pprof bool `yaml:"pprof"`
+}
+
+// FilterConfig lists words and patterns replaced in nicknames, away messages,
+// and channel names before they are shown in Discord.
+type FilterConfig struct {
+	Words       []string        `yaml:"words"`       // Whole words, case-insensitive
+	Patterns    []FilterPattern `yaml:"patterns"`    // Regular expressions
+	Replacement string          `yaml:"replacement"` // Default replacement (empty masks each character with ✱)
+}
+
+// FilterPattern is a regular expression with an optional replacement.
+type FilterPattern struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
 // TeamSpeakConfig holds TeamSpeak ServerQuery connection settings.
 type TeamSpeakConfig struct {
+	Name      string `yaml:"name"`  // Optional display name overriding the server's own name
+	Label     string `yaml:"label"` // Optional short name for logs, metrics, alerts and the embed footer
 	Host      string `yaml:"host"`
-	QueryPort int    `yaml:"query_port"`
+	Protocol  string `yaml:"protocol"`   // "raw" (telnet-style) or "ssh" (default: "raw")
+	QueryPort int    `yaml:"query_port"` // Default: 10011 for raw, 10022 for ssh
 	Username  string `yaml:"username"`
 	Password  string `yaml:"password"`
+	APIKey    string `yaml:"api_key"` // Rejected: API keys authenticate WebQuery, see hosted_servers
 	ServerID  int    `yaml:"server_id"`
+	ServerIDs []int  `yaml:"server_ids"` // Several virtual servers on this instance; overrides server_id
+
+	// HostKeyFingerprint pins the SSH host key, e.g. "SHA256:...". It is
+	// required with protocol ssh: the password is sent over the connection,
+	// and trusting whichever key answers first would not catch a server that
+	// was impersonated from the start.
+	HostKeyFingerprint string `yaml:"host_key_fingerprint"`
+
+	FileCacheDir string `yaml:"file_cache_dir"` // Optional directory for downloaded avatars and icons
+
+	// KeepaliveInterval sends a no-op command when the connection has been
+	// idle this long, to keep it open and notice a dead one before the next
+	// update (0 disables).
+	KeepaliveInterval time.Duration `yaml:"keepalive_interval"`
+}
+
+// MumbleConfig holds settings for a Mumble server shown alongside TeamSpeak.
+type MumbleConfig struct {
+	Name string `yaml:"name"` // Display name (default: the host)
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // Voice port, which also answers status pings (default: 64738)
+}
+
+// JSONSourceConfig holds settings for an HTTP JSON presence endpoint.
+type JSONSourceConfig struct {
+	Name    string            `yaml:"name"`    // Display name overriding the document's name
+	URL     string            `yaml:"url"`     // Endpoint returning the state document
+	Headers map[string]string `yaml:"headers"` // Extra request headers, e.g. Authorization
+	Timeout time.Duration     `yaml:"timeout"` // Request timeout (default: 10s)
+}
+
+// HostedConfig holds settings for a TeamSpeak server read through a hosting
+// provider's REST API.
+type HostedConfig struct {
+	Name     string            `yaml:"name"`     // Display name overriding the server's own
+	Provider string            `yaml:"provider"` // "webquery" or "panel"
+	URL      string            `yaml:"url"`      // WebQuery base URL, or the panel's status endpoint
+	APIKey   string            `yaml:"api_key"`  // WebQuery API key
+	ServerID int               `yaml:"server_id"`
+	Headers  map[string]string `yaml:"headers"` // Extra request headers, e.g. a panel's Authorization
+	Fields   HostedFields      `yaml:"fields"`  // Where the panel's response holds each value
+	Timeout  time.Duration     `yaml:"timeout"` // Request timeout (default: 10s)
+}
+
+// HostedFields are dotted paths into a panel response, e.g.
+// "data.server.slots". User fields are relative to each entry of users.
+type HostedFields struct {
+	ServerName    string `yaml:"server_name"`
+	MaxUsers      string `yaml:"max_users"`
+	TotalUsers    string `yaml:"total_users"`
+	UptimeSeconds string `yaml:"uptime_seconds"`
+	Users         string `yaml:"users"`
+	UserName      string `yaml:"user_name"`
+	UserChannel   string `yaml:"user_channel"`
+	UserAway      string `yaml:"user_away"`
+	UserMuted     string `yaml:"user_muted"`
+}
+
+// MinecraftConfig holds settings for a Minecraft server shown alongside
+// TeamSpeak.
+type MinecraftConfig struct {
+	Name string `yaml:"name"` // Display name (default: the server description)
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // Default: SRV record, then 25565
+}
+
+// GameServerConfig holds settings for an A2S-queryable game server.
+type GameServerConfig struct {
+	Name string `yaml:"name"` // Display name (default: the server's own name)
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // Query port
 }
 
 // DiscordConfig holds Discord bot settings.
 type DiscordConfig struct {
-	Token     string `yaml:"token"`
+	Token     string   `yaml:"token"`
+	ChannelID string   `yaml:"channel_id"`
+	OwnerIDs  []string `yaml:"owner_ids"` // Users DMed about operational problems
+	// FallbackChannelID receives an alert when the status message cannot be
+	// updated for FailoverAfter.
+	FallbackChannelID string        `yaml:"fallback_channel_id"`
+	FailoverAfter     time.Duration `yaml:"failover_after"`
+	DailyDigest       DailyDigest   `yaml:"daily_digest"`
+	// Channels are further status channels, possibly in other guilds, each
+	// with a status message of its own. Without channel_id the first entry
+	// is the main channel.
+	Channels []DiscordChannel `yaml:"channels"`
+	// WebhookURL publishes the status through a webhook instead of a bot,
+	// for guilds where no bot can be added. Replaces token and channel_id.
+	WebhookURL string `yaml:"webhook_url"`
+	// WebhookMessageID is the webhook's status message to keep editing, as
+	// logged when it was posted. Empty posts a new one on every start.
+	WebhookMessageID string `yaml:"webhook_message_id"`
+	// ThreadID posts the status message in an existing thread instead of
+	// channel_id's feed. Archived threads are reopened.
+	ThreadID string `yaml:"thread_id"`
+	// DetailThread moves the detailed view into a thread started under the
+	// status message, which then shows the summary.
+	DetailThread DetailThread `yaml:"detail_thread"`
+	// Forum configures the post the status lives in when channel_id is a
+	// forum channel.
+	Forum ForumConfig `yaml:"forum"`
+	// PublishAlerts publishes alerts the bot posts in announcement channels
+	// to the servers following them. The status message is never published.
+	PublishAlerts bool `yaml:"publish_alerts"`
+}
+
+// ForumConfig titles and tags the bot's post in a forum status channel.
+type ForumConfig struct {
+	Title      string `yaml:"title"`       // Post title; channel name placeholders (default: "{server}")
+	OnlineTag  string `yaml:"online_tag"`  // Forum tag applied while the server is up, e.g. "Online"
+	OfflineTag string `yaml:"offline_tag"` // Forum tag applied while the data is stale, e.g. "Offline"
+}
+
+// DetailThread starts a thread under the status message holding the full
+// per-channel breakdown.
+type DetailThread struct {
+	Enabled bool   `yaml:"enabled"`
+	Name    string `yaml:"name"` // Thread name (default: "Channel details")
+}
+
+// StatusChannelID is where the status message is posted: thread_id when set,
+// otherwise channel_id.
+func (d DiscordConfig) StatusChannelID() string {
+	if d.ThreadID != "" {
+		return d.ThreadID
+	}
+
+	return d.ChannelID
+}
+
+// validateCredentials checks that the status is published through exactly
+// one of a bot and a webhook.
+func (d DiscordConfig) validateCredentials() error {
+	if d.WebhookURL == "" {
+		switch {
+		case d.Token == "":
+			return fmt.Errorf("discord.token is required")
+		case d.StatusChannelID() == "":
+			return fmt.Errorf("discord.channel_id is required")
+		case d.WebhookMessageID != "":
+			return fmt.Errorf("discord.webhook_message_id requires discord.webhook_url")
+		case d.ThreadID != "" && d.DetailThread.Enabled:
+			return fmt.Errorf("discord.detail_thread cannot be used with discord.thread_id: threads cannot hold threads")
+		case d.DetailThread.Enabled && d.DetailThread.Name == "":
+			return fmt.Errorf("discord.detail_thread.name is required")
+		}
+
+		return nil
+	}
+
+	switch {
+	case d.Token != "":
+		return fmt.Errorf("use either discord.token or discord.webhook_url, not both")
+	case !strings.HasPrefix(d.WebhookURL, "https://") || !strings.Contains(d.WebhookURL, "/webhooks/"):
+		return fmt.Errorf("discord.webhook_url must be an https://.../webhooks/<id>/<token> URL")
+	case d.ChannelID != "" || len(d.Channels) > 0:
+		return fmt.Errorf("discord.channel_id and discord.channels need a bot and cannot be used with discord.webhook_url")
+	case d.ThreadID != "" || d.DetailThread.Enabled:
+		return fmt.Errorf("discord.thread_id and discord.detail_thread need a bot and cannot be used with discord.webhook_url")
+	}
+
+	return nil
+}
+
+// DiscordChannel is one entry of discord.channels: a channel ID, or a mapping
+// with the ID and display options overriding the display block.
+type DiscordChannel struct {
 	ChannelID string `yaml:"channel_id"`
+	// Display is the display block with the entry's overrides applied,
+	// resolved by Load.
+	Display DisplayConfig `yaml:"display"`
+
+	overrides *yaml.Node
+}
+
+// UnmarshalYAML accepts a bare channel ID as well as a mapping, and keeps the
+// display overrides to apply over the display block once it is known.
+func (c *DiscordChannel) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.ChannelID = node.Value
+
+		return nil
+	}
+
+	var raw struct {
+		ChannelID string    `yaml:"channel_id"`
+		Display   yaml.Node `yaml:"display"`
+	}
+
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	c.ChannelID = raw.ChannelID
+	if raw.Display.Kind != 0 {
+		c.overrides = &raw.Display
+	}
+
+	return nil
+}
+
+// resolveChannels applies each channel's overrides over display. Maps are
+// copied first so an override does not leak into display or other channels.
+func (d *DiscordConfig) resolveChannels(display DisplayConfig) error {
+	for i := range d.Channels {
+		ch := &d.Channels[i]
+
+		ch.Display = display
+		ch.Display.GroupBadges = maps.Clone(display.GroupBadges)
+		ch.Display.ChannelIcons.Emojis = maps.Clone(display.ChannelIcons.Emojis)
+		ch.Display.Emojis = maps.Clone(display.Emojis)
+
+		if ch.overrides == nil {
+			continue
+		}
+
+		if err := ch.overrides.Decode(&ch.Display); err != nil {
+			return fmt.Errorf("discord.channels[%d].display: %w", i, err)
+		}
+
+		if key := sharedDisplayKey(display, ch.Display); key != "" {
+			return fmt.Errorf("discord.channels[%d].display.%s applies to every channel and cannot be overridden", i, key)
+		}
+	}
+
+	return nil
+}
+
+// sharedDisplayKey names the first option that differs between display and
+// a channel's display but is applied before the state reaches the channels
+// or only by the main channel, or returns "".
+func sharedDisplayKey(display, channel DisplayConfig) string {
+	switch {
+	case channel.UpdateInterval != display.UpdateInterval:
+		return "update_interval"
+	case channel.AggregateTitle != display.AggregateTitle:
+		return "aggregate_title"
+	case channel.AvatarCollage != display.AvatarCollage:
+		return "avatar_collage"
+	case channel.BusyForecast != display.BusyForecast:
+		return "busy_forecast"
+	case channel.ChannelIcons.Upload != display.ChannelIcons.Upload:
+		return "channel_icons.upload"
+	case channel.VoiceMirror != display.VoiceMirror:
+		return "voice_mirror"
+	case channel.VoiceStatus != display.VoiceStatus:
+		return "voice_status"
+	}
+
+	return ""
+}
+
+// SharedChannelFilter is the part of the channel filter every status channel
+// applies: what the display block and all of discord.channels hide. It is
+// applied before the state reaches the channels, the API and recordings, and
+// each channel hides the rest of its own filter.
+func (c *Config) SharedChannelFilter() ChannelFilter {
+	shared := c.Display.ChannelFilter
+
+	for _, ch := range c.Discord.Channels {
+		f := ch.Display.ChannelFilter
+
+		shared.HideDefault = shared.HideDefault && f.HideDefault
+		shared.HideTemporary = shared.HideTemporary && f.HideTemporary
+		shared.HidePermanent = shared.HidePermanent && f.HidePermanent
+		shared.HideNames = slices.DeleteFunc(slices.Clone(shared.HideNames), func(name string) bool {
+			return !slices.ContainsFunc(f.HideNames, func(n string) bool { return strings.EqualFold(n, name) })
+		})
+	}
+
+	return shared
+}
+
+// DailyDigest DMs the owners a daily summary of errors, reconnects and
+// rate-limit hits.
+type DailyDigest struct {
+	Enabled bool   `yaml:"enabled"`
+	At      string `yaml:"at"` // Local time of day, "HH:MM" (default: "09:00")
 }
 
 // DisplayConfig holds display and formatting options.
 type DisplayConfig struct {
-	ShowEmptyChannels bool          `yaml:"show_empty_channels"`
-	UpdateInterval    time.Duration `yaml:"update_interval"`
-	ServerInfo        ServerInfo    `yaml:"server_info"`
-	CustomFooter      string        `yaml:"custom_footer"`
-	ChannelNameFormat string        `yaml:"channel_name_format"` // e.g., "TS: {online}/{max}" - updates channel name
-	ThumbnailURL      string        `yaml:"thumbnail_url"`       // Optional image URL for embed thumbnail
+	ShowEmptyChannels  bool             `yaml:"show_empty_channels"`
+	UpdateInterval     time.Duration    `yaml:"update_interval"`
+	Connect            ServerInfo       `yaml:"connect"`
+	Links              []Link           `yaml:"links"`       // Shown as a "Links" stats field
+	ServerInfo         ServerInfo       `yaml:"server_info"` // Deprecated: moved to connect
+	CustomFooter       string           `yaml:"custom_footer"`
+	ChannelNameFormat  string           `yaml:"channel_name_format"`  // e.g., "TS: {online}/{max}" - updates channel name
+	ChannelNameDryRun  bool             `yaml:"channel_name_dry_run"` // Log the names channel_name_format produces instead of renaming
+	ThumbnailURL       string           `yaml:"thumbnail_url"`        // Optional image URL for embed thumbnail
+	Layout             LayoutConfig     `yaml:"layout"`
+	Style              string           `yaml:"style"`           // "default" or "mobile"
+	StaleIntervals     int              `yaml:"stale_intervals"` // Warn once data is this many update intervals old (0 disables)
+	RelativeTime       bool             `yaml:"relative_time"`   // Use live Discord timestamps instead of static durations
+	ShowConnectedTime  bool             `yaml:"show_connected_time"`
+	ShowCountry        bool             `yaml:"show_country"`        // Country flag before each nickname
+	ShowChannelTopics  bool             `yaml:"show_channel_topics"` // Italic topic under each channel header
+	ShowTalkPower      bool             `yaml:"show_talk_power"`     // ⭐ priority speakers, 🎤 talkers in moderated channels
+	ShowCodec          bool             `yaml:"show_codec"`          // Codec after channel names, e.g. "(Opus Music)"
+	ChannelFilter      ChannelFilter    `yaml:"channel_filter"`
+	AvatarCollage      AvatarCollage    `yaml:"avatar_collage"`
+	ChannelIcons       ChannelIcons     `yaml:"channel_icons"`
+	GroupBadges        map[int]string   `yaml:"group_badges"`         // Server group id -> emoji or label after member nicknames
+	Emojis             StateEmojis      `yaml:"emojis"`               // Status emojis in user lines
+	AggregateTitle     string           `yaml:"aggregate_title"`      // Embed title when teamspeak_servers is used
+	MessagePerServer   bool             `yaml:"message_per_server"`   // One message per aggregated server instead of a combined embed
+	Paginate           bool             `yaml:"paginate"`             // Continue a channel list too long for one embed in further messages
+	ColorRules         []ColorRule      `yaml:"color_rules"`          // Ordered embed color rules (first match wins)
+	ShowLongestSession bool             `yaml:"show_longest_session"` // Stats field naming who has been connected the longest
+	ShowNetwork        bool             `yaml:"show_network"`         // Stats field with bandwidth in/out and packet loss
+	ChannelNameReset   ChannelNameReset `yaml:"channel_name_reset"`
+	VoiceMirror        VoiceMirror      `yaml:"voice_mirror"`
+	VoiceStatus        VoiceStatus      `yaml:"voice_status"`
+	Presence           PresenceConfig   `yaml:"presence"`
+	Nickname           NicknameConfig   `yaml:"nickname"`
+	StatusEmoji        StatusEmoji      `yaml:"status_emoji"` // Emojis for the {status_emoji} channel name placeholder
+	QuietAfter         time.Duration    `yaml:"quiet_after"`  // Show "quiet since" once empty this long (0 disables)
+	EmptyState         EmptyState       `yaml:"empty_state"`
+	Templates          EmbedTemplates   `yaml:"templates"`
+	ViewButtons        ViewButtons      `yaml:"view_buttons"`
+	ChannelSelect      bool             `yaml:"channel_select"` // Menu of occupied channels replying with full user detail
+	WhatChanged        bool             `yaml:"what_changed"`   // Button replying with joins, leaves and moves since the viewer's last click
+	RefreshButton      RefreshButton    `yaml:"refresh_button"`
+	BusyForecast       BusyForecast     `yaml:"busy_forecast"`
+}
+
+// EmptyState replaces "No active channels" while nobody is online with a
+// phrase pointing at the usual busy hours, from the recorded history.
+type EmptyState struct {
+	Enabled bool `yaml:"enabled"`
+	// Phrases rotate daily; {start} and {end} are replaced with the busy
+	// hours and {greeting} with e.g. "Good night".
+	Phrases  []string `yaml:"phrases"`
+	Fallback string   `yaml:"fallback"` // Shown when no busy hours are known, or during them
+}
+
+// EmbedTemplates customize the embed with Go text/template templates
+// executed with .State, and in the channel list also .Channel and .User.
+// Unset templates keep the built-in layout.
+type EmbedTemplates struct {
+	Title   string          `yaml:"title"`
+	Fields  []FieldTemplate `yaml:"fields"`  // Replace the stats fields
+	Channel string          `yaml:"channel"` // Channel header line
+	User    string          `yaml:"user"`    // User line
+}
+
+// FieldTemplate is a templated embed field; fields whose value renders empty
+// are left out.
+type FieldTemplate struct {
+	Name   string `yaml:"name"`
+	Value  string `yaml:"value"`
+	Inline bool   `yaml:"inline"`
+}
+
+// BusyForecast shows "Usually busy around 20:00–23:00" in the footer, from
+// the recorded history.
+type BusyForecast struct {
+	Enabled bool `yaml:"enabled"`
+	Weeks   int  `yaml:"weeks"` // History the hourly averages cover (default: 4)
+}
+
+// ViewButtons lets viewers switch the embed between a summary and the full
+// user list.
+type ViewButtons struct {
+	Enabled     bool          `yaml:"enabled"`
+	Default     string        `yaml:"default"`      // "summary" or "detailed" (default: "detailed")
+	RevertAfter time.Duration `yaml:"revert_after"` // How long a picked view lasts (default: 5m)
+}
+
+// RefreshButton adds a button polling TeamSpeak and re-rendering the embed
+// right away, instead of waiting for the next update interval.
+type RefreshButton struct {
+	Enabled  bool          `yaml:"enabled"`
+	Cooldown time.Duration `yaml:"cooldown"` // How long each user waits between refreshes (default: 1m)
+}
+
+// StatusEmoji are the health indicators used for {status_emoji}.
+type StatusEmoji struct {
+	Online       string  `yaml:"online"`
+	Busy         string  `yaml:"busy"`          // Nearly full, or one of several servers not responding
+	Offline      string  `yaml:"offline"`       // Data is stale
+	BusyCapacity float64 `yaml:"busy_capacity"` // Percent of slots used from which the server counts as busy
+}
+
+// PresenceConfig rotates the bot's custom status through templates.
+type PresenceConfig struct {
+	Templates []string      `yaml:"templates"` // Placeholders: {online}, {max}, {server}, {uptime}, {peak_today}
+	Interval  time.Duration `yaml:"interval"`  // Time each template is shown (default: 1m)
+}
+
+// VoiceMirror renames a voice channel, ideally empty and locked, to show the
+// occupancy in the channel list, independently of channel_name_format.
+type VoiceMirror struct {
+	ChannelID string `yaml:"channel_id"` // Empty disables the mirror
+	Format    string `yaml:"format"`     // Placeholders as for channel_name_format (default: "🔊 TS: {online} online")
+}
+
+// VoiceStatus keeps a one-line status in a voice channel's status field,
+// which, unlike a name, can change often.
+type VoiceStatus struct {
+	ChannelID string `yaml:"channel_id"` // Empty disables the status
+	Format    string `yaml:"format"`     // Placeholders as for channel_name_format (default: "{online} in TeamSpeak")
+}
+
+// ChannelNameReset renames the status channel to a base name while the server
+// is empty overnight, instead of updating counts in the name.
+type ChannelNameReset struct {
+	Name    string `yaml:"name"`    // Base channel name; empty disables the reset
+	Between string `yaml:"between"` // Daily local time range (default: "01:00-08:00")
+}
+
+// ColorRule sets the embed color when all of its conditions hold; omitted
+// conditions match anything.
+type ColorRule struct {
+	Color        string   `yaml:"color"`        // "#RRGGBB"
+	Stale        *bool    `yaml:"stale"`        // Data is stale (TeamSpeak unreachable)
+	Announcement *bool    `yaml:"announcement"` // An announcement is showing
+	MinUsers     *int     `yaml:"min_users"`
+	MaxUsers     *int     `yaml:"max_users"`
+	MinCapacity  *float64 `yaml:"min_capacity"` // Percent of slots used, 0-100
+	MaxCapacity  *float64 `yaml:"max_capacity"` // Percent of slots used, 0-100
+	Between      string   `yaml:"between"`      // Daily local time range, e.g. "20:00-23:30"
+	Weekdays     []string `yaml:"weekdays"`     // e.g. ["sat", "sun"]
+}
+
+// ChannelIcons maps TeamSpeak channel icons to Discord emojis shown in front of
+// channel names.
+type ChannelIcons struct {
+	Emojis map[uint32]string `yaml:"emojis"` // Icon id -> emoji, e.g. "🎮" or "<:game:1234>"
+	Upload bool              `yaml:"upload"` // Upload unmapped icons as application emojis
+}
+
+// AvatarCollage configures the grid of online users' avatars shown as the
+// embed image.
+type AvatarCollage struct {
+	Enabled  bool `yaml:"enabled"`
+	MaxUsers int  `yaml:"max_users"`
+	TileSize int  `yaml:"tile_size"`
+	Columns  int  `yaml:"columns"`
+}
+
+// ChannelFilter hides channels by flag or name.
+type ChannelFilter struct {
+	HideDefault   bool     `yaml:"hide_default"`   // Hide the server's default channel
+	HideTemporary bool     `yaml:"hide_temporary"` // Hide temporary channels
+	HidePermanent bool     `yaml:"hide_permanent"` // Hide permanent and semi-permanent channels
+	HideNames     []string `yaml:"hide_names"`     // Case-insensitive name substrings to hide
+}
+
+// LayoutConfig controls how the embed's stats fields are arranged.
+type LayoutConfig struct {
+	Compact     bool `yaml:"compact"`       // Single-column, mobile-friendly embed
+	InlineStats bool `yaml:"inline_stats"`  // Render stats fields side by side rather than stacked
+	StatsPerRow int  `yaml:"stats_per_row"` // Inline stats fields per row (1-3)
+	// Channels is "list" for a single "Channels" field, or "fields" for one
+	// field per occupied channel (default: "list").
+	Channels       string `yaml:"channels"`
+	InlineChannels bool   `yaml:"inline_channels"` // Render channel fields side by side
+}
+
+// UnmarshalYAML accepts a bare channels mode, as in "layout: fields", as well
+// as a mapping.
+func (l *LayoutConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		l.Channels = node.Value
+
+		return nil
+	}
+
+	type plain LayoutConfig
+
+	return node.Decode((*plain)(l))
+}
+
+// StateEmojis replace the status emojis of user lines, by state: recording,
+// muted, deafened, away and idle. Custom emojis such as
+// "<:micoff:123456789012345678>" work; an empty emoji hides the state.
+type StateEmojis map[string]string
+
+// statusEmojiStates are the keys of display.emojis.
+var statusEmojiStates = []string{"recording", "muted", "deafened", "away", "idle"}
+
+func (e StateEmojis) validate() error {
+	for state := range e {
+		if !slices.Contains(statusEmojiStates, state) {
+			return fmt.Errorf("unknown state %q (use %s)", state, strings.Join(statusEmojiStates, ", "))
+		}
+	}
+
+	return nil
+}
+
+const (
+	// maxLinkLabel bounds the label of one link.
+	maxLinkLabel = 100
+
+	// maxLinksLength is Discord's field value limit, which all links share.
+	maxLinksLength = 1024
+)
+
+// validLinkURL reports whether raw is an absolute http or https URL with a
+// host. Whitespace would end the masked link early, so it is rejected too.
+func validLinkURL(raw string) bool {
+	if strings.ContainsFunc(raw, unicode.IsSpace) {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Link is an entry of the embed's links field, e.g. the community website.
+type Link struct {
+	Label string `yaml:"label"`
+	URL   string `yaml:"url"`
 }
 
 // ServerInfo holds optional server connection info to display.
 type ServerInfo struct {
 	Address  string `yaml:"address"`
 	Password string `yaml:"password"`
+	// QRCode attaches a QR code of the ts3server:// join link as the embed
+	// thumbnail, and serves it without the password on /join.png with
+	// http.listen set.
+	QRCode bool `yaml:"qr_code"`
+}
+
+// DebugConfig holds diagnostics settings.
+type DebugConfig struct {
+	StateHistory int  `yaml:"state_history"` // Recent fetches kept for GET /api/v1/debug/states (0 disables)
+	EmbedDiff    bool `yaml:"embed_diff"`    // Log a unified diff of the embed text before each edit
+	StateVersion bool `yaml:"state_version"` // Show the state version and hash in the embed footer
+}
+
+// NicknameConfig sets the bot's nickname in the status channel's guild to a
+// short status, as an alternative to renaming the channel.
+type NicknameConfig struct {
+	Format   string        `yaml:"format"`   // Placeholders as for channel_name_format, e.g. "TS {online}/{max}"
+	Interval time.Duration `yaml:"interval"` // Minimum time between changes (default: 1m; at least 30s)
+}
+
+// FeaturesConfig switches whole subsystems off regardless of their own
+// settings. An unset switch leaves the feature to its own config; with Minimal
+// set, only switches explicitly set to true stay on.
+type FeaturesConfig struct {
+	Minimal        bool  `yaml:"minimal"`
+	ChannelRename  *bool `yaml:"channel_rename"`  // display.channel_name_format, voice_mirror and voice_status
+	Presence       *bool `yaml:"presence"`        // display.presence
+	Nickname       *bool `yaml:"nickname"`        // display.nickname
+	SlashCommands  *bool `yaml:"slash_commands"`  // The /ts command
+	Alerts         *bool `yaml:"alerts"`          // AFK alerts, downtime pings, failover alerts and the daily digest
+	History        *bool `yaml:"history"`         // Database recording and display.what_changed
+	API            *bool `yaml:"api"`             // The HTTP API, including metrics and pprof
+	Metrics        *bool `yaml:"metrics"`         // http.metrics
+	ErrorReporting *bool `yaml:"error_reporting"` // Sentry
+}
+
+// enabled resolves one switch.
+func (f FeaturesConfig) enabled(flag *bool) bool {
+	if flag != nil {
+		return *flag
+	}
+
+	return !f.Minimal
+}
+
+// SlashCommandsEnabled reports whether the /ts command is registered.
+func (f FeaturesConfig) SlashCommandsEnabled() bool {
+	return f.enabled(f.SlashCommands)
+}
+
+// applyFeatures clears the settings of switched-off features so the rest of
+// the program sees them as unconfigured.
+func (c *Config) applyFeatures() {
+	f := c.Features
+
+	displays := []*DisplayConfig{&c.Display}
+	for i := range c.Discord.Channels {
+		displays = append(displays, &c.Discord.Channels[i].Display)
+	}
+
+	for _, d := range displays {
+		if !f.enabled(f.ChannelRename) {
+			d.ChannelNameFormat = ""
+			d.ChannelNameReset.Name = ""
+			d.VoiceMirror.ChannelID = ""
+			d.VoiceStatus.ChannelID = ""
+		}
+
+		if !f.enabled(f.Presence) {
+			d.Presence.Templates = nil
+		}
+
+		if !f.enabled(f.Nickname) {
+			d.Nickname.Format = ""
+		}
+
+		if !f.enabled(f.History) {
+			d.WhatChanged = false
+			d.BusyForecast.Enabled = false
+		}
+	}
+
+	if !f.enabled(f.Alerts) {
+		c.AFKAlerts.Enabled = false
+		c.Notifications.Joins = false
+		c.Notifications.Leaves = false
+		c.Notifications.Routes = nil
+		c.Discord.FailoverAfter = 0
+		c.Discord.DailyDigest.Enabled = false
+		c.Alerts.DowntimeRoleID = ""
+		c.Notifications.DMSubscriptions = false
+	}
+
+	if !f.enabled(f.History) || !f.enabled(f.SlashCommands) {
+		c.Notifications.DMSubscriptions = false
+	}
+
+	if !f.enabled(f.History) {
+		c.Database.Enabled = false
+	}
+
+	if !f.enabled(f.API) {
+		c.HTTP.Listen = ""
+	}
+
+	if !f.enabled(f.Metrics) {
+		c.HTTP.Metrics = false
+		c.HTTP.MetricsChannels = nil
+	}
+
+	if !f.enabled(f.ErrorReporting) {
+		c.Sentry.DSN = ""
+	}
+}
+
+// EnabledFeatures names the feature switches whose subsystems are configured
+// and on, for the startup log.
+func (c *Config) EnabledFeatures() []string {
+	features := []struct {
+		name string
+		on   bool
+	}{
+		{"channel_rename", c.Display.ChannelNameFormat != "" || c.Display.ChannelNameReset.Name != "" || c.Display.VoiceMirror.ChannelID != "" || c.Display.VoiceStatus.ChannelID != ""},
+		{"presence", len(c.Display.Presence.Templates) > 0},
+		{"nickname", c.Display.Nickname.Format != ""},
+		{"slash_commands", c.Features.SlashCommandsEnabled() && c.Discord.WebhookURL == ""},
+		{"alerts", c.AFKAlerts.Enabled || c.Discord.FailoverAfter > 0 || c.Discord.DailyDigest.Enabled || len(c.Notifications.Routes) > 0 || c.Alerts.DowntimeRoleID != "" || c.Notifications.DMSubscriptions},
+		{"history", c.Database.Enabled},
+		{"api", c.HTTP.Listen != ""},
+		{"metrics", c.HTTP.Listen != "" && c.HTTP.Metrics},
+		{"error_reporting", c.Sentry.DSN != ""},
+	}
+
+	var names []string
+
+	for _, f := range features {
+		if f.on {
+			names = append(names, f.name)
+		}
+	}
+
+	return names
+}
+
+// SentryConfig holds error reporting settings.
+type SentryConfig struct {
+	DSN             string        `yaml:"dsn"` // Empty disables reporting
+	Environment     string        `yaml:"environment"`
+	RepeatThreshold int           `yaml:"repeat_threshold"` // Report a warning once it repeats this often
+	RepeatWindow    time.Duration `yaml:"repeat_window"`    // within this window
 }
 
 // LoggingConfig holds logging settings.
 type LoggingConfig struct {
 	Level string `yaml:"level"`
+
+	// Repeated warnings (e.g. during an outage) are logged at most
+	// SampleBurst times per SampleInterval; 0 disables sampling.
+	SampleInterval time.Duration     `yaml:"sample_interval"`
+	SampleBurst    int               `yaml:"sample_burst"`
+	Levels         map[string]string `yaml:"levels"` // Per-component overrides, e.g. {discord: debug}
 }
 
-// Load reads and parses the configuration from the given file path.
-func Load(path string) (*Config, error) {
+// Load reads and parses the configuration from the given file path. Unknown
+// keys are reported in Warnings, or fail loading when strict is set; deprecated
+// keys are always reported in Warnings.
+func Load(path string, strict bool) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -73,27 +863,157 @@ func Load(path string) (*Config, error) {
 	cfg := &Config{
 		// Set defaults
 		TeamSpeak: TeamSpeakConfig{
-			QueryPort: 10011,
-			Username:  "serveradmin",
-			ServerID:  1,
+			Username: "serveradmin",
+			ServerID: 1,
+		},
+		Discord: DiscordConfig{
+			FailoverAfter: 10 * time.Minute,
+			DailyDigest:   DailyDigest{At: "09:00"},
+			DetailThread:  DetailThread{Name: "Channel details"},
+			Forum:         ForumConfig{Title: "{server}"},
 		},
 		Display: DisplayConfig{
 			ShowEmptyChannels: false,
 			UpdateInterval:    30 * time.Second,
+			Style:             "default",
+			StaleIntervals:    3,
+			RelativeTime:      true,
+			AggregateTitle:    "TeamSpeak Servers",
+			ChannelNameReset:  ChannelNameReset{Between: "01:00-08:00"},
+			VoiceMirror:       VoiceMirror{Format: "🔊 TS: {online} online"},
+			VoiceStatus:       VoiceStatus{Format: "{online} in TeamSpeak"},
+			Presence:          PresenceConfig{Interval: time.Minute},
+			Nickname:          NicknameConfig{Interval: time.Minute},
+			StatusEmoji:       StatusEmoji{Online: "🟢", Busy: "🟡", Offline: "🔴", BusyCapacity: 80},
+			ViewButtons:       ViewButtons{Default: "detailed", RevertAfter: 5 * time.Minute},
+			RefreshButton:     RefreshButton{Cooldown: time.Minute},
+			BusyForecast:      BusyForecast{Weeks: 4},
+			EmptyState: EmptyState{
+				Phrases: []string{
+					"Nobody online — usually picks up around {start}",
+					"{greeting}! Nobody's on right now, it usually picks up around {start}",
+				},
+				Fallback: "Nobody online right now",
+			},
+			AvatarCollage: AvatarCollage{
+				MaxUsers: 24,
+				TileSize: 64,
+				Columns:  8,
+			},
+			Layout: LayoutConfig{
+				InlineStats: true,
+				StatsPerRow: 3,
+				Channels:    "list",
+			},
 		},
 		Database: DatabaseConfig{
 			RecordInterval: 60 * time.Second,
 			RetentionDays:  400,
 		},
+		Alerts: AlertsConfig{
+			DowntimeAfter: 3,
+		},
+		AFKAlerts: AFKAlertsConfig{
+			IdleAfter:   30 * time.Minute,
+			AFKChannels: []string{"afk"},
+			PokeMessage: "You have been idle for {idle}, please move to the AFK channel.",
+		},
+		Notifications: NotificationsConfig{
+			QueueSize:       100,
+			BatchWindow:     10 * time.Second,
+			OfflineAfter:    2 * time.Minute,
+			CapacityPercent: 90,
+		},
 		Logging: LoggingConfig{
-			Level: "info",
+			Level:          "info",
+			SampleInterval: 10 * time.Minute,
+			SampleBurst:    3,
 		},
+		Debug: DebugConfig{
+			StateHistory: 60,
+		},
+		Sentry: SentryConfig{
+			RepeatThreshold: 3,
+			RepeatWindow:    10 * time.Minute,
+		},
+		ShutdownTimeout: 15 * time.Second,
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	unknown, deprecated := checkKeys(&doc)
+	if strict && len(unknown) > 0 {
+		lines := make([]string, 0, len(unknown))
+		for _, k := range unknown {
+			lines = append(lines, "  "+k.String())
+		}
+
+		return nil, fmt.Errorf("unknown config keys:\n%s", strings.Join(lines, "\n"))
+	}
+
+	for _, k := range unknown {
+		cfg.Warnings = append(cfg.Warnings, "unknown config key, ignored: "+k.String())
+	}
+
+	for _, k := range deprecated {
+		cfg.Warnings = append(cfg.Warnings, "deprecated config key: "+k.String())
+	}
+
+	if len(doc.Content) > 0 {
+		defaults := *cfg
+
+		if err := doc.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+
+		if err := cfg.applyLayout(doc.Content[0], &defaults); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	if cfg.Display.Connect == (ServerInfo{}) {
+		cfg.Display.Connect = cfg.Display.ServerInfo
+	}
+
+	if err := cfg.Discord.resolveChannels(cfg.Display); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Without channel_id the first listed channel is the main one, with its
+	// overrides as the display block.
+	if cfg.Discord.StatusChannelID() == "" && len(cfg.Discord.Channels) > 0 {
+		cfg.Discord.ChannelID = cfg.Discord.Channels[0].ChannelID
+		cfg.Display = cfg.Discord.Channels[0].Display
+		cfg.Discord.Channels = cfg.Discord.Channels[1:]
+	}
+
+	cfg.applyFeatures()
+
+	// The query port depends on the protocol, so it is defaulted after parsing.
+	if cfg.TeamSpeak.QueryPort == 0 {
+		cfg.TeamSpeak.QueryPort = defaultQueryPort(cfg.TeamSpeak.Protocol)
+	}
+
+	// List entries are decoded into zero values, so defaults are applied after
+	// parsing.
+	for i := range cfg.TeamSpeakServers {
+		ts := &cfg.TeamSpeakServers[i]
+		if ts.QueryPort == 0 {
+			ts.QueryPort = defaultQueryPort(ts.Protocol)
+		}
+
+		if ts.Username == "" {
+			ts.Username = "serveradmin"
+		}
+
+		if ts.ServerID == 0 {
+			ts.ServerID = 1
+		}
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -101,28 +1021,113 @@ func Load(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// VirtualServers returns one config per server_ids entry, or the config itself
+// when server_ids is not set.
+func (t TeamSpeakConfig) VirtualServers() []TeamSpeakConfig {
+	if len(t.ServerIDs) == 0 {
+		return []TeamSpeakConfig{t}
+	}
+
+	out := make([]TeamSpeakConfig, 0, len(t.ServerIDs))
+	for _, id := range t.ServerIDs {
+		v := t
+		v.ServerID = id
+		v.ServerIDs = nil
+
+		if t.Label != "" {
+			v.Label = fmt.Sprintf("%s #%d", t.Label, id)
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
+// defaultQueryPort is the ServerQuery port TeamSpeak listens on for protocol.
+func defaultQueryPort(protocol string) int {
+	if protocol == "ssh" {
+		return 10022
+	}
+
+	return 10011
+}
+
+// validProtocol reports whether protocol is a supported ServerQuery transport.
+func validProtocol(protocol string) bool {
+	return protocol == "" || protocol == "raw" || protocol == "ssh"
+}
+
 // Validate checks that all required configuration fields are set.
 func (c *Config) Validate() error {
-	if c.TeamSpeak.Host == "" {
-		return fmt.Errorf("teamspeak.host is required")
+	if c.Aggregated() {
+		if err := c.validateServers(); err != nil {
+			return err
+		}
+	} else {
+		if c.TeamSpeak.Host == "" {
+			return fmt.Errorf("teamspeak.host is required")
+		}
+
+		if err := c.TeamSpeak.validateLogin("teamspeak"); err != nil {
+			return err
+		}
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("shutdown_timeout must be positive")
 	}
 
-	if c.TeamSpeak.Password == "" {
-		return fmt.Errorf("teamspeak.password is required")
+	if !validProtocol(c.TeamSpeak.Protocol) {
+		return fmt.Errorf("teamspeak.protocol must be \"raw\" or \"ssh\"")
 	}
 
-	if c.Discord.Token == "" {
-		return fmt.Errorf("discord.token is required")
+	if err := c.TeamSpeak.validateKeepalive("teamspeak"); err != nil {
+		return err
 	}
 
-	if c.Discord.ChannelID == "" {
-		return fmt.Errorf("discord.channel_id is required")
+	if err := c.Discord.validateCredentials(); err != nil {
+		return err
+	}
+
+	channels := map[string]bool{c.Discord.StatusChannelID(): true}
+
+	for i, ch := range c.Discord.Channels {
+		switch {
+		case ch.ChannelID == "":
+			return fmt.Errorf("discord.channels[%d]: channel_id is required", i)
+		case channels[ch.ChannelID]:
+			return fmt.Errorf("discord.channels[%d]: channel %s is listed twice", i, ch.ChannelID)
+		}
+
+		if err := ch.Display.validate(fmt.Sprintf("discord.channels[%d].display", i)); err != nil {
+			return err
+		}
+
+		channels[ch.ChannelID] = true
 	}
 
 	if c.Display.UpdateInterval < 5*time.Second {
 		return fmt.Errorf("display.update_interval must be at least 5s")
 	}
 
+	if err := c.Display.validate("display"); err != nil {
+		return err
+	}
+
+	if c.Display.AvatarCollage.Enabled {
+		ac := c.Display.AvatarCollage
+		if ac.MaxUsers < 1 || ac.TileSize < 8 || ac.TileSize > 256 || ac.Columns < 1 {
+			return fmt.Errorf("display.avatar_collage needs max_users >= 1, tile_size 8-256 and columns >= 1")
+		}
+	}
+
+	for _, p := range c.Filter.Patterns {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("content_filter.patterns: invalid pattern %q: %w", p.Pattern, err)
+		}
+	}
+
 	if c.Database.Enabled {
 		if c.Database.Path == "" {
 			return fmt.Errorf("database.path is required when database.enabled is true")
@@ -133,5 +1138,507 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.AFKAlerts.Enabled {
+		if c.AFKAlerts.IdleAfter < time.Minute {
+			return fmt.Errorf("afk_alerts.idle_after must be at least 1m")
+		}
+
+		if c.AFKAlerts.StaffChannelID == "" && !c.AFKAlerts.Poke && !c.Notifications.routes("moderation") {
+			return fmt.Errorf("afk_alerts needs staff_channel_id, a moderation notification route, or poke")
+		}
+	}
+
+	if c.Alerts.DowntimeRoleID != "" {
+		if c.Alerts.DowntimeAfter < 1 {
+			return fmt.Errorf("alerts.downtime_after must be at least 1")
+		}
+	}
+
+	if c.Notifications.DMSubscriptions && !c.Database.Enabled {
+		return fmt.Errorf("notifications.dm_subscriptions requires database.enabled")
+	}
+
+	if (c.Notifications.Joins || c.Notifications.Leaves) && c.Notifications.ChannelID == "" {
+		return fmt.Errorf("notifications.joins and notifications.leaves require notifications.channel_id")
+	}
+
+	if c.Notifications.QueueSize < 0 {
+		return fmt.Errorf("notifications.queue_size must not be negative")
+	}
+
+	if c.Notifications.BatchWindow < 0 {
+		return fmt.Errorf("notifications.batch_window must not be negative")
+	}
+
+	if c.Notifications.OfflineAfter < 0 {
+		return fmt.Errorf("notifications.offline_after must not be negative")
+	}
+
+	if p := c.Notifications.CapacityPercent; p < 0 || p > 100 {
+		return fmt.Errorf("notifications.capacity_percent must be between 0 and 100")
+	}
+
+	for i, r := range c.Notifications.Routes {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("notifications.routes[%d]: %w", i, err)
+		}
+	}
+
+	if len(c.Display.Presence.Templates) > 0 && c.Display.Presence.Interval < 15*time.Second {
+		return fmt.Errorf("display.presence.interval must be at least 15s")
+	}
+
+	if c.Display.Nickname.Format != "" && c.Display.Nickname.Interval < 30*time.Second {
+		return fmt.Errorf("display.nickname.interval must be at least 30s")
+	}
+
+	if c.Display.WhatChanged && !c.Database.Enabled {
+		return fmt.Errorf("display.what_changed requires database.enabled")
+	}
+
+	if c.Display.BusyForecast.Enabled {
+		if !c.Database.Enabled {
+			return fmt.Errorf("display.busy_forecast requires database.enabled")
+		}
+
+		if c.Display.BusyForecast.Weeks < 1 {
+			return fmt.Errorf("display.busy_forecast.weeks must be at least 1")
+		}
+	}
+
+	if c.Display.ChannelNameReset.Name != "" && c.Display.ChannelNameFormat == "" {
+		return fmt.Errorf("display.channel_name_reset requires display.channel_name_format")
+	}
+
+	if m := c.Display.VoiceMirror; m.ChannelID != "" {
+		switch {
+		case m.Format == "":
+			return fmt.Errorf("display.voice_mirror.format is required")
+		case m.ChannelID == c.Discord.StatusChannelID():
+			return fmt.Errorf("display.voice_mirror.channel_id must be a voice channel, not the status channel")
+		}
+	}
+
+	if vs := c.Display.VoiceStatus; vs.ChannelID != "" {
+		switch {
+		case vs.Format == "":
+			return fmt.Errorf("display.voice_status.format is required")
+		case vs.ChannelID == c.Discord.StatusChannelID():
+			return fmt.Errorf("display.voice_status.channel_id must be a voice channel, not the status channel")
+		}
+	}
+
+	if err := c.validateWebhook(); err != nil {
+		return err
+	}
+
+	if c.Discord.DailyDigest.Enabled {
+		if len(c.Discord.OwnerIDs) == 0 {
+			return fmt.Errorf("discord.daily_digest requires discord.owner_ids")
+		}
+
+		if _, err := time.Parse("15:04", c.Discord.DailyDigest.At); err != nil {
+			return fmt.Errorf("discord.daily_digest.at must be HH:MM: %w", err)
+		}
+	}
+
+	if c.HTTP.Listen != "" && c.HTTP.Token == "" {
+		return fmt.Errorf("http.token is required when http.listen is set")
+	}
+
+	if n := len(c.HTTP.MetricsChannels); n > maxMetricsChannels {
+		return fmt.Errorf("http.metrics_channels lists %d channels, at most %d are allowed", n, maxMetricsChannels)
+	}
+
 	return nil
 }
+
+// validateWebhook rejects the options that need a bot when the status is
+// published through discord.webhook_url: a webhook has no gateway, so it
+// cannot rename channels, answer buttons or commands, or post anywhere but
+// its own channel.
+func (c *Config) validateWebhook() error {
+	if c.Discord.WebhookURL == "" {
+		return nil
+	}
+
+	botOnly := []struct {
+		name string
+		set  bool
+	}{
+		{"display.channel_name_format", c.Display.ChannelNameFormat != ""},
+		{"display.view_buttons", c.Display.ViewButtons.Enabled},
+		{"display.refresh_button", c.Display.RefreshButton.Enabled},
+		{"display.channel_select", c.Display.ChannelSelect},
+		{"display.what_changed", c.Display.WhatChanged},
+		{"display.presence", len(c.Display.Presence.Templates) > 0},
+		{"display.nickname", c.Display.Nickname.Format != ""},
+		{"display.voice_mirror", c.Display.VoiceMirror.ChannelID != ""},
+		{"display.voice_status", c.Display.VoiceStatus.ChannelID != ""},
+		{"display.paginate", c.Display.Paginate},
+		{"display.channel_icons.upload", c.Display.ChannelIcons.Upload},
+		{"discord.fallback_channel_id", c.Discord.FallbackChannelID != ""},
+		{"discord.publish_alerts", c.Discord.PublishAlerts},
+		{"discord.daily_digest", c.Discord.DailyDigest.Enabled},
+		{"alerts.downtime_role_id", c.Alerts.DowntimeRoleID != ""},
+		{"afk_alerts.staff_channel_id", c.AFKAlerts.StaffChannelID != ""},
+		{"notifications.channel_id", c.Notifications.ChannelID != ""},
+		{"notifications.dm_subscriptions", c.Notifications.DMSubscriptions},
+	}
+
+	for _, o := range botOnly {
+		if o.set {
+			return fmt.Errorf("%s needs a bot and cannot be used with discord.webhook_url", o.name)
+		}
+	}
+
+	for i, r := range c.Notifications.Routes {
+		if r.ChannelID != "" {
+			return fmt.Errorf("notifications.routes[%d].channel_id needs a bot and cannot be used with discord.webhook_url; use webhook_url", i)
+		}
+	}
+
+	return nil
+}
+
+// maxMetricsChannels bounds the per-channel gauges, one time series each.
+const maxMetricsChannels = 100
+
+// hexColor matches a "#RRGGBB" embed color.
+var hexColor = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// validate checks the options a display block can set per status channel;
+// prefix names the block in errors.
+func (d DisplayConfig) validate(prefix string) error {
+	switch {
+	case d.Layout.StatsPerRow < 1 || d.Layout.StatsPerRow > 3:
+		return fmt.Errorf("%s.layout.stats_per_row must be between 1 and 3", prefix)
+	case d.Layout.Channels != "list" && d.Layout.Channels != "fields":
+		return fmt.Errorf("%s.layout.channels must be \"list\" or \"fields\"", prefix)
+	case d.Style != "default" && d.Style != "mobile":
+		return fmt.Errorf("%s.style must be \"default\" or \"mobile\"", prefix)
+	case d.StaleIntervals < 0:
+		return fmt.Errorf("%s.stale_intervals must not be negative", prefix)
+	case d.StatusEmoji.BusyCapacity <= 0 || d.StatusEmoji.BusyCapacity > 100:
+		return fmt.Errorf("%s.status_emoji.busy_capacity must be between 0 and 100", prefix)
+	case d.ViewButtons.Default != "summary" && d.ViewButtons.Default != "detailed":
+		return fmt.Errorf("%s.view_buttons.default must be \"summary\" or \"detailed\"", prefix)
+	case d.ViewButtons.Enabled && d.ViewButtons.RevertAfter < time.Minute:
+		return fmt.Errorf("%s.view_buttons.revert_after must be at least 1m", prefix)
+	case d.RefreshButton.Enabled && d.RefreshButton.Cooldown < 10*time.Second:
+		return fmt.Errorf("%s.refresh_button.cooldown must be at least 10s", prefix)
+	case d.Paginate && d.MessagePerServer:
+		return fmt.Errorf("%s.paginate cannot be combined with %s.message_per_server", prefix, prefix)
+	}
+
+	if err := d.Emojis.validate(); err != nil {
+		return fmt.Errorf("%s.emojis: %w", prefix, err)
+	}
+
+	links := 0
+
+	for i, l := range d.Links {
+		switch {
+		case l.Label == "":
+			return fmt.Errorf("%s.links[%d].label is required", prefix, i)
+		case utf8.RuneCountInString(l.Label) > maxLinkLabel:
+			return fmt.Errorf("%s.links[%d].label must be at most %d characters", prefix, i, maxLinkLabel)
+		case !validLinkURL(l.URL):
+			return fmt.Errorf("%s.links[%d].url must be an http:// or https:// URL with a host and no spaces", prefix, i)
+		}
+
+		// Rendered as "[label](url)", joined by " · ", with parentheses in the
+		// URL percent-encoded.
+		links += utf8.RuneCountInString(l.Label) + utf8.RuneCountInString(l.URL) + 4 +
+			2*(strings.Count(l.URL, "(")+strings.Count(l.URL, ")"))
+		if i > 0 {
+			links += 3
+		}
+	}
+
+	if links > maxLinksLength {
+		return fmt.Errorf("%s.links take %d characters, more than the %d of one embed field", prefix, links, maxLinksLength)
+	}
+
+	for i, r := range d.ColorRules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%s.color_rules[%d]: %w", prefix, i, err)
+		}
+	}
+
+	if err := d.Templates.validate(); err != nil {
+		return fmt.Errorf("%s.templates.%w", prefix, err)
+	}
+
+	if es := d.EmptyState; es.Enabled {
+		switch {
+		case len(es.Phrases) == 0 && es.Fallback == "":
+			return fmt.Errorf("%s.empty_state needs phrases or a fallback", prefix)
+		case slices.Contains(es.Phrases, ""):
+			return fmt.Errorf("%s.empty_state.phrases must not contain empty phrases", prefix)
+		}
+	}
+
+	// An empty substring would match, and hide, every channel.
+	if slices.Contains(d.ChannelFilter.HideNames, "") {
+		return fmt.Errorf("%s.channel_filter.hide_names must not contain empty names", prefix)
+	}
+
+	return nil
+}
+
+// validate checks a color rule's color, time range and weekdays.
+func (r ColorRule) validate() error {
+	if !hexColor.MatchString(r.Color) {
+		return fmt.Errorf("invalid color %q, expected #RRGGBB", r.Color)
+	}
+
+	if r.Between != "" {
+		from, to, ok := strings.Cut(r.Between, "-")
+		if !ok {
+			return fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", r.Between)
+		}
+
+		for _, t := range []string{from, to} {
+			if _, err := time.Parse("15:04", strings.TrimSpace(t)); err != nil {
+				return fmt.Errorf("invalid time range %q: %w", r.Between, err)
+			}
+		}
+	}
+
+	for _, day := range r.Weekdays {
+		if !validWeekday(day) {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+
+	for _, p := range []*float64{r.MinCapacity, r.MaxCapacity} {
+		if p != nil && (*p < 0 || *p > 100) {
+			return fmt.Errorf("min_capacity and max_capacity must be between 0 and 100")
+		}
+	}
+
+	return nil
+}
+
+// validWeekday reports whether s names a weekday, in full or by at least its
+// first three letters.
+func validWeekday(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks the template syntax. Functions are resolved when the
+// discord package parses the templates, so unknown names are not reported
+// here. Errors are relative to the templates block.
+func (t EmbedTemplates) validate() error {
+	check := func(name, text string) error {
+		tree := parse.New(name)
+		tree.Mode = parse.SkipFuncCheck
+
+		if _, err := tree.Parse(text, "", "", map[string]*parse.Tree{}); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		return nil
+	}
+
+	if err := check("title", t.Title); err != nil {
+		return err
+	}
+
+	if err := check("channel", t.Channel); err != nil {
+		return err
+	}
+
+	if err := check("user", t.User); err != nil {
+		return err
+	}
+
+	for i, f := range t.Fields {
+		if f.Value == "" {
+			return fmt.Errorf("fields[%d]: value is required", i)
+		}
+
+		if err := check(fmt.Sprintf("fields[%d].name", i), f.Name); err != nil {
+			return err
+		}
+
+		if err := check(fmt.Sprintf("fields[%d].value", i), f.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// routes reports whether any route subscribes to event.
+func (n NotificationsConfig) routes(event string) bool {
+	for _, r := range n.Routes {
+		if slices.Contains(r.Events, event) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks a route's events and target.
+func (r NotificationRoute) validate() error {
+	if len(r.Events) == 0 {
+		return fmt.Errorf("events is required")
+	}
+
+	for _, e := range r.Events {
+		if !slices.Contains(notify.Events, e) {
+			return fmt.Errorf("unknown event %q (want one of %s)", e, strings.Join(notify.Events, ", "))
+		}
+	}
+
+	switch {
+	case r.ChannelID == "" && r.WebhookURL == "":
+		return fmt.Errorf("channel_id or webhook_url is required")
+	case r.ChannelID != "" && r.WebhookURL != "":
+		return fmt.Errorf("use either channel_id or webhook_url, not both")
+	case r.WebhookURL != "" && !strings.HasPrefix(r.WebhookURL, "https://"):
+		return fmt.Errorf("webhook_url must be an https URL")
+	}
+
+	return nil
+}
+
+// validateLogin checks that a ServerQuery password and, for SSH, the host key
+// fingerprint are configured. The query
+// login command only takes username and password: TeamSpeak API keys
+// authenticate WebQuery, which hosted_servers reads.
+func (ts TeamSpeakConfig) validateLogin(prefix string) error {
+	switch {
+	case ts.APIKey != "":
+		return fmt.Errorf("%s.api_key is not supported: API keys only work with WebQuery, "+
+			"configure the server under hosted_servers with provider: webquery instead", prefix)
+	case ts.Password == "":
+		return fmt.Errorf("%s.password is required", prefix)
+	case ts.Protocol == "ssh" && ts.HostKeyFingerprint == "":
+		return fmt.Errorf("%s.host_key_fingerprint is required with protocol ssh; "+
+			"get it with ssh-keyscan -p %d %s | ssh-keygen -lf -", prefix, ts.QueryPort, ts.Host)
+	}
+
+	return nil
+}
+
+// validateKeepalive checks the keepalive interval is off or not so short that
+// the pings themselves count towards the query flood limit.
+func (ts TeamSpeakConfig) validateKeepalive(prefix string) error {
+	if ts.KeepaliveInterval != 0 && ts.KeepaliveInterval < 10*time.Second {
+		return fmt.Errorf("%s.keepalive_interval must be at least 10s (or 0 to disable)", prefix)
+	}
+
+	return nil
+}
+
+// maxTeamSpeakServers bounds aggregation so every server keeps a readable
+// section within Discord's embed limits.
+const maxTeamSpeakServers = 10
+
+// validateServers checks the server lists used for aggregation.
+func (c *Config) validateServers() error {
+	if c.TeamSpeak.Host != "" && len(c.TeamSpeakServers) > 0 {
+		return fmt.Errorf("use either teamspeak or teamspeak_servers, not both")
+	}
+
+	if c.TeamSpeak.Host != "" {
+		if err := c.TeamSpeak.validateLogin("teamspeak"); err != nil {
+			return err
+		}
+	}
+
+	if len(c.TeamSpeak.ServerIDs) > 1 && c.TeamSpeak.Name != "" {
+		return fmt.Errorf("teamspeak.name cannot be used with several server_ids")
+	}
+
+	seen := make(map[int]bool, len(c.TeamSpeak.ServerIDs))
+	for _, id := range c.TeamSpeak.ServerIDs {
+		if id <= 0 || seen[id] {
+			return fmt.Errorf("teamspeak.server_ids must be distinct positive ids")
+		}
+
+		seen[id] = true
+	}
+
+	if n := len(c.TeamSpeakServers) + len(c.TeamSpeak.ServerIDs) + len(c.MumbleServers) + len(c.JSONSources) +
+		len(c.HostedServers) + len(c.MinecraftServers) + len(c.GameServers); n > maxTeamSpeakServers {
+		return fmt.Errorf("at most %d servers can be shown together", maxTeamSpeakServers)
+	}
+
+	for i, m := range c.MumbleServers {
+		if m.Host == "" {
+			return fmt.Errorf("mumble_servers[%d].host is required", i)
+		}
+	}
+
+	for i, j := range c.JSONSources {
+		if j.URL == "" {
+			return fmt.Errorf("json_sources[%d].url is required", i)
+		}
+	}
+
+	for i, h := range c.HostedServers {
+		switch {
+		case h.URL == "":
+			return fmt.Errorf("hosted_servers[%d].url is required", i)
+		case h.Provider == "webquery" && h.APIKey == "":
+			return fmt.Errorf("hosted_servers[%d].api_key is required for webquery", i)
+		case h.Provider == "panel" && h.Fields.Users == "" && h.Fields.TotalUsers == "":
+			return fmt.Errorf("hosted_servers[%d].fields needs users or total_users", i)
+		case h.Provider != "webquery" && h.Provider != "panel":
+			return fmt.Errorf("hosted_servers[%d].provider must be \"webquery\" or \"panel\"", i)
+		}
+	}
+
+	for i, m := range c.MinecraftServers {
+		if m.Host == "" {
+			return fmt.Errorf("minecraft_servers[%d].host is required", i)
+		}
+	}
+
+	for i, g := range c.GameServers {
+		if g.Host == "" || g.Port == 0 {
+			return fmt.Errorf("game_servers[%d] needs host and port", i)
+		}
+	}
+
+	for i, ts := range c.TeamSpeakServers {
+		if ts.Host == "" {
+			return fmt.Errorf("teamspeak_servers[%d].host is required", i)
+		}
+
+		if err := ts.validateLogin(fmt.Sprintf("teamspeak_servers[%d]", i)); err != nil {
+			return err
+		}
+
+		if !validProtocol(ts.Protocol) {
+			return fmt.Errorf("teamspeak_servers[%d].protocol must be \"raw\" or \"ssh\"", i)
+		}
+
+		if err := ts.validateKeepalive(fmt.Sprintf("teamspeak_servers[%d]", i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Aggregated reports whether several servers or sources are combined into one
+// embed.
+func (c *Config) Aggregated() bool {
+	return len(c.TeamSpeakServers) > 0 || len(c.TeamSpeak.ServerIDs) > 1 || len(c.MumbleServers) > 0 || len(c.JSONSources) > 0 ||
+		len(c.HostedServers) > 0 || len(c.MinecraftServers) > 0 || len(c.GameServers) > 0
+}
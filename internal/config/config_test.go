@@ -0,0 +1,222 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLogin(t *testing.T) {
+	_, err := loadString(t, `
+teamspeak: {host: ts.example.com, api_key: key}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "teamspeak.api_key is not supported")
+	require.ErrorContains(t, err, "hosted_servers")
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "teamspeak.password is required")
+
+	_, err = loadString(t, `
+teamspeak_servers:
+  - {host: a.example.com, password: secret}
+  - {host: b.example.com, password: secret, protocol: ssh}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "teamspeak_servers[1].host_key_fingerprint is required with protocol ssh")
+	require.ErrorContains(t, err, "ssh-keyscan -p 10022 b.example.com")
+}
+
+func TestQueueSize(t *testing.T) {
+	// 0 disables the queue, as it does for the bridge.
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+notifications: {queue_size: 0}
+`)
+	require.NoError(t, err)
+	require.Zero(t, cfg.Notifications.QueueSize)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+notifications: {queue_size: -1}
+`)
+	require.ErrorContains(t, err, "notifications.queue_size must not be negative")
+}
+
+func TestCapacityPercent(t *testing.T) {
+	// 0 disables the capacity event.
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+notifications: {capacity_percent: 0}
+`)
+	require.NoError(t, err)
+	require.Zero(t, cfg.Notifications.CapacityPercent)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+notifications: {capacity_percent: 101}
+`)
+	require.ErrorContains(t, err, "notifications.capacity_percent must be between 0 and 100")
+}
+
+func TestStateEmojis(t *testing.T) {
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels:
+    - channel_id: "2"
+      display: {emojis: {away: ""}}
+    - "3"
+display:
+  emojis: {muted: "<:micoff:123>"}
+`)
+	require.NoError(t, err)
+
+	// An override changes only its own channel.
+	require.Equal(t, StateEmojis{"muted": "<:micoff:123>"}, cfg.Display.Emojis)
+	require.Equal(t, StateEmojis{"muted": "<:micoff:123>", "away": ""}, cfg.Discord.Channels[0].Display.Emojis)
+	require.Equal(t, StateEmojis{"muted": "<:micoff:123>"}, cfg.Discord.Channels[1].Display.Emojis)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels: [{channel_id: "2", display: {emojis: {talking: "🗣️"}}}]
+`)
+	require.ErrorContains(t, err, `discord.channels[0].display.emojis: unknown state "talking"`)
+}
+
+func TestChannelDisplayValidated(t *testing.T) {
+	for _, tc := range []struct {
+		display string
+		wantErr string
+	}{
+		{`{color_rules: [{color: red}]}`, `discord.channels[0].display.color_rules[0]: invalid color "red"`},
+		{`{color_rules: [{color: "#FF0000", between: "20:00"}]}`, "discord.channels[0].display.color_rules[0]: invalid time range"},
+		{`{color_rules: [{color: "#FF0000", weekdays: [someday]}]}`, `discord.channels[0].display.color_rules[0]: invalid weekday "someday"`},
+		{`{templates: {title: "{{.State"}}`, "discord.channels[0].display.templates.title:"},
+		{`{templates: {fields: [{name: Users}]}}`, "discord.channels[0].display.templates.fields[0]: value is required"},
+		{`{links: [{label: Site, url: "ftp://example.com"}]}`, "discord.channels[0].display.links[0].url must be an http:// or https:// URL"},
+		{`{links: [{label: Site, url: "https://"}]}`, "discord.channels[0].display.links[0].url must be an http:// or https:// URL with a host"},
+		{`{links: [{label: Site, url: "https://example.com/a b"}]}`, "discord.channels[0].display.links[0].url must be an http:// or https:// URL"},
+		{`{links: [{label: Site, url: "https://example.com"}, {label: "` + strings.Repeat("x", 101) + `", url: "https://example.com"}]}`,
+			"discord.channels[0].display.links[1].label must be at most 100 characters"},
+		{`{links: [` + strings.Repeat(`{label: "`+strings.Repeat("x", 100)+`", url: "https://example.com"}, `, 10) + `]}`,
+			"discord.channels[0].display.links take 1257 characters, more than the 1024 of one embed field"},
+		{`{empty_state: {enabled: true, phrases: [], fallback: ""}}`, "discord.channels[0].display.empty_state needs phrases or a fallback"},
+		{`{channel_filter: {hide_names: [""]}}`, "discord.channels[0].display.channel_filter.hide_names must not contain empty names"},
+		{`{paginate: true, message_per_server: true}`, "discord.channels[0].display.paginate cannot be combined"},
+	} {
+		_, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels: [{channel_id: "2", display: `+tc.display+`}]
+`)
+		require.ErrorContains(t, err, tc.wantErr, tc.display)
+	}
+
+	// Template functions are only known to the discord package.
+	_, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels:
+    - channel_id: "2"
+      display:
+        color_rules: [{color: "#FF0000", between: "20:00-23:30", weekdays: [sat, Sunday], min_capacity: 50}]
+        templates: {title: "{{ .State.ServerName | upper }}", fields: [{name: Users, value: "{{ .State.TotalUsers }}"}]}
+`)
+	require.NoError(t, err)
+}
+
+func TestDefaultQueryPort(t *testing.T) {
+	for _, tc := range []struct {
+		teamspeak string
+		want      int
+	}{
+		{`{host: ts.example.com, password: secret}`, 10011},
+		{`{host: ts.example.com, password: secret, protocol: raw}`, 10011},
+		{`{host: ts.example.com, password: secret, protocol: ssh, host_key_fingerprint: "SHA256:abc"}`, 10022},
+		{`{host: ts.example.com, password: secret, protocol: ssh, host_key_fingerprint: "SHA256:abc", query_port: 2222}`, 2222},
+	} {
+		cfg, err := loadString(t, "teamspeak: "+tc.teamspeak+"\ndiscord: {token: tok, channel_id: \"1\"}\n")
+		require.NoError(t, err, tc.teamspeak)
+		require.Equal(t, tc.want, cfg.TeamSpeak.QueryPort, tc.teamspeak)
+	}
+
+	// Aggregated servers default their ports one by one.
+	cfg, err := loadString(t, `
+teamspeak_servers:
+  - {host: a.example.com, password: secret}
+  - {host: b.example.com, password: secret, protocol: ssh, host_key_fingerprint: "SHA256:abc"}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.NoError(t, err)
+	require.Equal(t, 10011, cfg.TeamSpeakServers[0].QueryPort)
+	require.Equal(t, 10022, cfg.TeamSpeakServers[1].QueryPort)
+}
+
+func TestValidateCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		discord string
+		wantErr string
+	}{
+		{`{channel_id: "1"}`, "discord.token is required"},
+		{`{token: tok}`, "discord.channel_id is required"},
+		{`{token: tok, channel_id: "1", webhook_message_id: "2"}`, "discord.webhook_message_id requires discord.webhook_url"},
+		{`{token: tok, channel_id: "1", thread_id: "2", detail_thread: {enabled: true}}`, "threads cannot hold threads"},
+		{`{token: tok, webhook_url: "https://discord.com/api/webhooks/1/t"}`, "use either discord.token or discord.webhook_url"},
+		{`{webhook_url: "http://discord.com/api/webhooks/1/t"}`, "discord.webhook_url must be an https://"},
+		{`{webhook_url: "https://discord.com/api/webhooks/1/t", channel_id: "1"}`, "discord.channel_id and discord.channels need a bot"},
+		{`{webhook_url: "https://discord.com/api/webhooks/1/t", thread_id: "1"}`, "discord.thread_id and discord.detail_thread need a bot"},
+	} {
+		_, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: `+tc.discord+`
+`)
+		require.ErrorContains(t, err, tc.wantErr, tc.discord)
+	}
+
+	_, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {webhook_url: "https://discord.com/api/webhooks/1/t", webhook_message_id: "2"}
+notifications: {routes: [{events: [join], webhook_url: "https://discord.com/api/webhooks/3/u"}]}
+`)
+	require.NoError(t, err)
+}
+
+func TestWebhookRejectsBotOptions(t *testing.T) {
+	for _, tc := range []struct {
+		extra   string
+		wantErr string
+	}{
+		{`display: {channel_name_format: "TS: {online}"}`, "display.channel_name_format needs a bot"},
+		{`display: {view_buttons: {enabled: true}}`, "display.view_buttons needs a bot"},
+		{`display: {refresh_button: {enabled: true}}`, "display.refresh_button needs a bot"},
+		{`display: {presence: {templates: ["{online} online"]}}`, "display.presence needs a bot"},
+		{`display: {nickname: {format: "TS {online}"}}`, "display.nickname needs a bot"},
+		{`display: {voice_mirror: {channel_id: "5"}}`, "display.voice_mirror needs a bot"},
+		{`notifications: {routes: [{events: [join], channel_id: "5"}]}`, "notifications.routes[0].channel_id needs a bot"},
+	} {
+		_, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {webhook_url: "https://discord.com/api/webhooks/1/t"}
+`+tc.extra+`
+`)
+		require.ErrorContains(t, err, tc.wantErr, tc.extra)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyFeatures(t *testing.T) {
+	on := true
+	off := false
+
+	newConfig := func(f FeaturesConfig) *Config {
+		c := &Config{Features: f}
+		c.Display.ChannelNameFormat = "TS: {online}"
+		c.Display.Presence.Templates = []string{"{online} online"}
+		c.Display.Nickname.Format = "TS {online}"
+		c.Database.Enabled = true
+		c.HTTP.Listen = ":8080"
+		c.Sentry.DSN = "https://key@example.com/1"
+
+		c.applyFeatures()
+
+		return c
+	}
+
+	c := newConfig(FeaturesConfig{})
+	require.Equal(t, "TS: {online}", c.Display.ChannelNameFormat)
+	require.True(t, c.Database.Enabled)
+	require.True(t, c.Features.SlashCommandsEnabled())
+
+	c = newConfig(FeaturesConfig{History: &off})
+	require.False(t, c.Database.Enabled)
+	require.Equal(t, ":8080", c.HTTP.Listen)
+
+	c = newConfig(FeaturesConfig{Minimal: true, API: &on})
+	require.Empty(t, c.Display.ChannelNameFormat)
+	require.Nil(t, c.Display.Presence.Templates)
+	require.Empty(t, c.Display.Nickname.Format)
+	require.False(t, c.Database.Enabled)
+	require.Empty(t, c.Sentry.DSN)
+	require.Equal(t, ":8080", c.HTTP.Listen)
+	require.False(t, c.Features.SlashCommandsEnabled())
+}
+
+func TestEnabledFeatures(t *testing.T) {
+	c := &Config{}
+	c.Display.Nickname.Format = "TS {online}"
+	c.HTTP.Listen = ":8080"
+	c.HTTP.Metrics = true
+
+	require.Equal(t, []string{"nickname", "slash_commands", "api", "metrics"}, c.EnabledFeatures())
+
+	c.Features.Minimal = true
+	c.Discord.FailoverAfter = time.Minute
+	c.applyFeatures()
+	require.Empty(t, c.EnabledFeatures())
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deprecatedKeys maps option paths that are still accepted but have been
+// renamed or moved to a migration hint. Entries are added when options change.
+var deprecatedKeys = map[string]string{
+	"display.server_info": "moved to display.connect; run migrate-config --from v1",
+}
+
+// keyIssue is a configuration key that is unknown or deprecated.
+type keyIssue struct {
+	Line int
+	Path string
+	Hint string
+}
+
+func (k keyIssue) String() string {
+	if k.Hint == "" {
+		return fmt.Sprintf("line %d: %s", k.Line, k.Path)
+	}
+
+	return fmt.Sprintf("line %d: %s (%s)", k.Line, k.Path, k.Hint)
+}
+
+// checkKeys walks a parsed document against the Config type and returns keys
+// no option matches, with a suggestion for likely typos, and deprecated keys
+// with their migration hint.
+func checkKeys(doc *yaml.Node) (unknown, deprecated []keyIssue) {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	var walk func(node *yaml.Node, t reflect.Type, path string)
+
+	walk = func(node *yaml.Node, t reflect.Type, path string) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch {
+		case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+			fields := yamlFields(t)
+
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				keyPath := joinPath(path, key.Value)
+
+				field, ok := fields[key.Value]
+				if !ok {
+					issue := keyIssue{Line: key.Line, Path: keyPath}
+					if s := suggest(key.Value, fields); s != "" {
+						issue.Hint = fmt.Sprintf("did you mean %s?", s)
+					}
+
+					unknown = append(unknown, issue)
+
+					continue
+				}
+
+				if hint, ok := deprecatedKeys[keyPath]; ok {
+					deprecated = append(deprecated, keyIssue{Line: key.Line, Path: keyPath, Hint: hint})
+				}
+
+				walk(value, field, keyPath)
+			}
+		case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+			}
+		case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+			}
+		}
+	}
+
+	walk(doc, reflect.TypeOf(Config{}), "")
+
+	return unknown, deprecated
+}
+
+// yamlFields maps the yaml keys of a struct to their field types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// suggest returns the known key closest to an unknown one, if it is close
+// enough to be a likely typo.
+func suggest(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", max(2, len(key)/4)+1
+
+	for name := range fields {
+		if d := levenshtein(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCheckKeys(t *testing.T) {
+	src := `
+teamspeak:
+  host: ts.example.com
+display:
+  show_empty_channel: true
+  color_rules:
+    - color: "#FFFFFF"
+      min_user: 3
+  channel_icons:
+    emojis:
+      123: "🎮"
+json_sources:
+  - url: https://example.com
+    headers:
+      X-Key: value
+frobnicate: 1
+`
+
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(src), &doc))
+
+	unknown, deprecated := checkKeys(&doc)
+	require.Empty(t, deprecated)
+	require.Equal(t, []keyIssue{
+		{Line: 5, Path: "display.show_empty_channel", Hint: "did you mean show_empty_channels?"},
+		{Line: 8, Path: "display.color_rules[0].min_user", Hint: "did you mean min_users?"},
+		{Line: 16, Path: "frobnicate"},
+	}, unknown)
+}
+
+func TestCheckKeysExampleConfig(t *testing.T) {
+	data, err := os.ReadFile("../../config.example.yaml")
+	require.NoError(t, err)
+
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+
+	unknown, _ := checkKeys(&doc)
+	require.Empty(t, unknown)
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceConfig is one entry of the sources list: where presence state comes
+// from. Exactly one typed block is set, e.g.
+//
+//	sources:
+//	  - teamspeak: {host: ts.example.com, password: secret}
+//	  - minecraft: {host: mc.example.com}
+type SourceConfig struct {
+	TeamSpeak  *TeamSpeakConfig  `yaml:"teamspeak"`
+	Mumble     *MumbleConfig     `yaml:"mumble"`
+	JSON       *JSONSourceConfig `yaml:"json"`
+	Hosted     *HostedConfig     `yaml:"hosted"`
+	Minecraft  *MinecraftConfig  `yaml:"minecraft"`
+	GameServer *GameServerConfig `yaml:"game_server"`
+}
+
+// SinkConfig is one entry of the sinks list: where the status is published.
+// Exactly one typed block is set.
+type SinkConfig struct {
+	Discord *DiscordConfig `yaml:"discord"`
+}
+
+// sourceTypes and sinkTypes name the typed blocks, for error messages.
+const (
+	sourceTypes = "teamspeak, mumble, json, hosted, minecraft or game_server"
+	sinkTypes   = "discord"
+)
+
+// flatSourceKeys and flatSinkKeys are the top-level keys of the flat layout
+// that sources and sinks replace.
+var (
+	flatSourceKeys = []string{"teamspeak", "teamspeak_servers", "mumble_servers", "json_sources", "hosted_servers", "minecraft_servers", "game_servers"}
+	flatSinkKeys   = []string{"discord"}
+)
+
+func (s SourceConfig) count() int {
+	return countSet(s.TeamSpeak != nil, s.Mumble != nil, s.JSON != nil, s.Hosted != nil, s.Minecraft != nil, s.GameServer != nil)
+}
+
+func (s SinkConfig) count() int {
+	return countSet(s.Discord != nil)
+}
+
+func countSet(set ...bool) int {
+	n := 0
+
+	for _, ok := range set {
+		if ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// applyLayout folds sources and sinks into the flat fields the rest of the
+// program reads, so both layouts load the same way. TeamSpeak and Discord
+// blocks are decoded again over defaults so entries get the same defaults as
+// the flat keys.
+func (c *Config) applyLayout(root *yaml.Node, defaults *Config) error {
+	if len(c.Sources) > 0 {
+		if key := setKeys(root, flatSourceKeys); key != "" {
+			return fmt.Errorf("use either sources or %s, not both", key)
+		}
+
+		nodes := mappingValue(root, "sources").Content
+
+		var teamspeak []TeamSpeakConfig
+
+		for i, src := range c.Sources {
+			if src.count() != 1 {
+				return fmt.Errorf("sources[%d] needs exactly one of %s", i, sourceTypes)
+			}
+
+			switch {
+			case src.TeamSpeak != nil:
+				ts := defaults.TeamSpeak
+				if err := mappingValue(nodes[i], "teamspeak").Decode(&ts); err != nil {
+					return fmt.Errorf("sources[%d].teamspeak: %w", i, err)
+				}
+
+				teamspeak = append(teamspeak, ts)
+			case src.Mumble != nil:
+				c.MumbleServers = append(c.MumbleServers, *src.Mumble)
+			case src.JSON != nil:
+				c.JSONSources = append(c.JSONSources, *src.JSON)
+			case src.Hosted != nil:
+				c.HostedServers = append(c.HostedServers, *src.Hosted)
+			case src.Minecraft != nil:
+				c.MinecraftServers = append(c.MinecraftServers, *src.Minecraft)
+			case src.GameServer != nil:
+				c.GameServers = append(c.GameServers, *src.GameServer)
+			}
+		}
+
+		// A single TeamSpeak source behaves like the teamspeak block, so
+		// server_ids and the non-aggregated embed keep working.
+		if len(teamspeak) == 1 {
+			c.TeamSpeak = teamspeak[0]
+		} else {
+			c.TeamSpeakServers = teamspeak
+		}
+	}
+
+	if len(c.Sinks) > 0 {
+		if key := setKeys(root, flatSinkKeys); key != "" {
+			return fmt.Errorf("use either sinks or %s, not both", key)
+		}
+
+		nodes := mappingValue(root, "sinks").Content
+		discord := 0
+
+		for i, sink := range c.Sinks {
+			if sink.count() != 1 {
+				return fmt.Errorf("sinks[%d] needs exactly one of %s", i, sinkTypes)
+			}
+
+			if discord++; discord > 1 {
+				return fmt.Errorf("sinks[%d]: only one discord sink is supported", i)
+			}
+
+			d := defaults.Discord
+			if err := mappingValue(nodes[i], "discord").Decode(&d); err != nil {
+				return fmt.Errorf("sinks[%d].discord: %w", i, err)
+			}
+
+			c.Discord = d
+		}
+	}
+
+	return nil
+}
+
+// setKeys lists which of keys are set at the top level of root.
+func setKeys(root *yaml.Node, keys []string) string {
+	var set []string
+
+	for _, k := range keys {
+		if mappingKey(root, k) != nil {
+			set = append(set, k)
+		}
+	}
+
+	return strings.Join(set, ", ")
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadString(t *testing.T, src string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o600))
+
+	return Load(path, true)
+}
+
+func TestLoadSourcesAndSinks(t *testing.T) {
+	flat, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+minecraft_servers:
+  - {host: mc.example.com}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.NoError(t, err)
+
+	structured, err := loadString(t, `
+sources:
+  - teamspeak: {host: ts.example.com, password: secret}
+  - minecraft: {host: mc.example.com}
+sinks:
+  - discord: {token: tok, channel_id: "1"}
+`)
+	require.NoError(t, err)
+
+	require.Equal(t, flat.TeamSpeak, structured.TeamSpeak)
+	require.Equal(t, "serveradmin", structured.TeamSpeak.Username)
+	require.Equal(t, 10011, structured.TeamSpeak.QueryPort)
+	require.Equal(t, flat.MinecraftServers, structured.MinecraftServers)
+	require.Equal(t, flat.Discord, structured.Discord)
+	require.Equal(t, 10*time.Minute, structured.Discord.FailoverAfter)
+
+	several, err := loadString(t, `
+sources:
+  - teamspeak: {host: a.example.com, password: secret}
+  - teamspeak: {host: b.example.com, password: secret, protocol: ssh, host_key_fingerprint: "SHA256:abc"}
+sinks:
+  - discord: {token: tok, channel_id: "1"}
+`)
+	require.NoError(t, err)
+	require.Len(t, several.TeamSpeakServers, 2)
+	require.Equal(t, 10022, several.TeamSpeakServers[1].QueryPort)
+	require.Equal(t, 1, several.TeamSpeakServers[1].ServerID)
+}
+
+func TestLoadLayoutErrors(t *testing.T) {
+	_, err := loadString(t, `
+sources:
+  - teamspeak: {host: ts.example.com, password: secret}
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "use either sources or teamspeak")
+
+	_, err = loadString(t, `
+sources:
+  - teamspeak: {host: ts.example.com, password: secret}
+    mumble: {host: mumble.example.com}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "sources[0] needs exactly one")
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+sinks:
+  - discord: {token: tok, channel_id: "1"}
+  - discord: {token: tok, channel_id: "2"}
+`)
+	require.ErrorContains(t, err, "only one discord sink")
+
+	_, err = loadString(t, `
+sources:
+  - teamspeak: {host: ts.example.com, pasword: secret}
+discord: {token: tok, channel_id: "1"}
+`)
+	require.ErrorContains(t, err, "sources[0].teamspeak.pasword")
+}
+
+func TestLoadDiscordChannels(t *testing.T) {
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channels:
+    - "1"
+    - "2"
+    - channel_id: "3"
+      display: {style: mobile, group_badges: {9: "🛡️"}}
+display:
+  custom_footer: Hello
+  group_badges: {6: "⭐"}
+`)
+	require.NoError(t, err)
+
+	// The first entry became the main channel.
+	require.Equal(t, "1", cfg.Discord.ChannelID)
+	require.Len(t, cfg.Discord.Channels, 2)
+
+	plain, custom := cfg.Discord.Channels[0].Display, cfg.Discord.Channels[1].Display
+	require.Equal(t, "default", plain.Style)
+	require.Equal(t, "Hello", plain.CustomFooter)
+
+	// Overrides apply on top of the display block without changing it.
+	require.Equal(t, "mobile", custom.Style)
+	require.Equal(t, "Hello", custom.CustomFooter)
+	require.Equal(t, map[int]string{6: "⭐", 9: "🛡️"}, custom.GroupBadges)
+	require.Equal(t, map[int]string{6: "⭐"}, cfg.Display.GroupBadges)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1", channels: ["1"]}
+`)
+	require.ErrorContains(t, err, "listed twice")
+}
+
+func TestSharedChannelFilter(t *testing.T) {
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels:
+    - channel_id: "2"
+      display: {channel_filter: {hide_temporary: false, hide_names: [STAFF]}}
+display:
+  channel_filter: {hide_temporary: true, hide_names: [staff, admin]}
+`)
+	require.NoError(t, err)
+
+	// Only what every channel hides is hidden before the channels; the public
+	// channel hides the rest itself.
+	require.Equal(t, ChannelFilter{HideNames: []string{"staff"}}, cfg.SharedChannelFilter())
+	require.Equal(t, []string{"staff", "admin"}, cfg.Display.ChannelFilter.HideNames)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels: [{channel_id: "2", display: {update_interval: 1m}}]
+`)
+	require.ErrorContains(t, err, "display.update_interval applies to every channel")
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels: [{channel_id: "2", display: {voice_mirror: {channel_id: "9"}}}]
+`)
+	require.ErrorContains(t, err, "display.voice_mirror applies to every channel")
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord:
+  token: tok
+  channel_id: "1"
+  channels: [{channel_id: "2", display: {voice_status: {channel_id: "9"}}}]
+`)
+	require.ErrorContains(t, err, "display.voice_status applies to every channel")
+}
+
+func TestLayoutChannels(t *testing.T) {
+	cfg, err := loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+display:
+  layout: fields
+`)
+	require.NoError(t, err)
+	require.Equal(t, "fields", cfg.Display.Layout.Channels)
+	require.Equal(t, 3, cfg.Display.Layout.StatsPerRow)
+
+	_, err = loadString(t, `
+teamspeak: {host: ts.example.com, password: secret}
+discord: {token: tok, channel_id: "1"}
+display:
+  layout: {channels: grid}
+`)
+	require.ErrorContains(t, err, "display.layout.channels")
+}
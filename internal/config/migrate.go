@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// migration rewrites a configuration document from one layout version to the
+// next.
+type migration struct {
+	from  string
+	to    string
+	apply func(m *migrator, root *yaml.Node) error
+}
+
+// migrator collects the changes made by migrations. Key renames are also
+// recorded as text edits so a file that only needs renames keeps its exact
+// formatting; any other change re-encodes the document.
+type migrator struct {
+	changes      []string
+	renames      []rename
+	restructured bool
+}
+
+type rename struct {
+	line, column int
+	from, to     string
+}
+
+// rename renames a mapping key in place.
+func (m *migrator) rename(key *yaml.Node, to, change string) {
+	m.renames = append(m.renames, rename{line: key.Line, column: key.Column, from: key.Value, to: to})
+	key.Value = to
+	m.changes = append(m.changes, change)
+}
+
+// migrations lists layout changes in order; a config is migrated by applying
+// every step from its version onwards.
+var migrations = []migration{
+	{from: "v1", to: "v2", apply: migrateV1},
+}
+
+// CurrentVersion is the configuration layout version Load expects.
+var CurrentVersion = migrations[len(migrations)-1].to
+
+// Migrate rewrites a configuration document written for an older layout
+// version to the current one. Comments are kept on the keys they belong to.
+func Migrate(data []byte, from string) ([]byte, []string, error) {
+	start := -1
+
+	for i, m := range migrations {
+		if m.from == from {
+			start = i
+			break
+		}
+	}
+
+	if start < 0 {
+		if from == CurrentVersion {
+			return data, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("unknown config version %q", from)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil, nil
+	}
+
+	m := &migrator{}
+
+	for _, step := range migrations[start:] {
+		if err := step.apply(m, doc.Content[0]); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate from %s to %s: %w", step.from, step.to, err)
+		}
+	}
+
+	if len(m.changes) == 0 {
+		return data, nil, nil
+	}
+
+	if !m.restructured {
+		if out, ok := applyRenames(data, m.renames); ok {
+			return out, m.changes, nil
+		}
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to write config: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return buf.Bytes(), m.changes, nil
+}
+
+// applyRenames rewrites renamed keys in the original text. It reports false
+// when a key is not found where the parser placed it (e.g. a quoted key), so
+// the caller falls back to re-encoding.
+func applyRenames(data []byte, renames []rename) ([]byte, bool) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	for _, r := range renames {
+		if r.line < 1 || r.line > len(lines) {
+			return nil, false
+		}
+
+		line := lines[r.line-1]
+		col := r.column - 1
+
+		if col < 0 || !bytes.HasPrefix(line[col:], []byte(r.from)) {
+			return nil, false
+		}
+
+		edited := append([]byte{}, line[:col]...)
+		edited = append(edited, r.to...)
+		lines[r.line-1] = append(edited, line[col+len(r.from):]...)
+	}
+
+	return bytes.Join(lines, nil), true
+}
+
+// migrateV1 moves display.server_info to display.connect.
+func migrateV1(m *migrator, root *yaml.Node) error {
+	display := mappingValue(root, "display")
+	if display == nil || display.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	key := mappingKey(display, "server_info")
+	if key == nil {
+		return nil
+	}
+
+	if mappingKey(display, "connect") != nil {
+		return fmt.Errorf("line %d: both display.server_info and display.connect are set", key.Line)
+	}
+
+	m.rename(key, "connect", "display.server_info -> display.connect")
+
+	return nil
+}
+
+// mappingKey returns the key node for name in a mapping node.
+func mappingKey(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i]
+		}
+	}
+
+	return nil
+}
+
+// mappingValue returns the value node for name in a mapping node.
+func mappingValue(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateV1(t *testing.T) {
+	src := `display:
+  # Shown in the Connect field
+
+  server_info:   # joins
+    address: "ts.example.com"
+`
+
+	out, changes, err := Migrate([]byte(src), "v1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"display.server_info -> display.connect"}, changes)
+	require.Equal(t, `display:
+  # Shown in the Connect field
+
+  connect:   # joins
+    address: "ts.example.com"
+`, string(out))
+
+	out, changes, err = Migrate(out, "v1")
+	require.NoError(t, err)
+	require.Empty(t, changes)
+	require.Contains(t, string(out), "connect:")
+}
+
+func TestMigrateErrors(t *testing.T) {
+	_, _, err := Migrate([]byte("display:\n  server_info: {}\n  connect: {}\n"), "v1")
+	require.ErrorContains(t, err, "line 2")
+
+	_, _, err = Migrate([]byte("display: {}\n"), "v0")
+	require.Error(t, err)
+
+	_, changes, err := Migrate([]byte("display: {}\n"), CurrentVersion)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
@@ -0,0 +1,176 @@
+// Package contentfilter rewrites user-controlled strings (nicknames, away
+// messages, channel names) before they are shown outside TeamSpeak.
+package contentfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Pattern is a regular expression with an optional replacement.
+type Pattern struct {
+	Pattern     string
+	Replacement string // Empty uses the filter's default replacement
+}
+
+// Config lists what to filter.
+type Config struct {
+	Words       []string  // Matched case-insensitively as whole words
+	Patterns    []Pattern // Regular expressions, replacement may use $1 etc.
+	Replacement string    // Default replacement; empty masks each match with maskRune
+}
+
+// maskRune masks filtered text. It looks like an asterisk, but unlike one it
+// is not Discord markdown, so masks cannot turn into bold or italics.
+const maskRune = "✱"
+
+type rule struct {
+	re          *regexp.Regexp
+	replacement string
+	word        bool // Matches only whole words
+}
+
+// Filter applies the configured rules. A nil *Filter passes strings through.
+type Filter struct {
+	rules []rule
+}
+
+// New compiles a filter. It returns nil when no rules are configured.
+func New(cfg Config) (*Filter, error) {
+	f := &Filter{}
+
+	for _, w := range cfg.Words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+
+		f.rules = append(f.rules, rule{
+			re:          regexp.MustCompile(`(?i)` + regexp.QuoteMeta(w)),
+			replacement: cfg.Replacement,
+			word:        true,
+		})
+	}
+
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter pattern %q: %w", p.Pattern, err)
+		}
+
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = cfg.Replacement
+		}
+
+		f.rules = append(f.rules, rule{re: re, replacement: replacement})
+	}
+
+	if len(f.rules) == 0 {
+		return nil, nil
+	}
+
+	return f, nil
+}
+
+// Apply returns s with every rule applied in order.
+func (f *Filter) Apply(s string) string {
+	if f == nil {
+		return s
+	}
+
+	for _, r := range f.rules {
+		switch {
+		case r.word:
+			s = replaceWords(s, r)
+		case r.replacement == "":
+			s = r.re.ReplaceAllStringFunc(s, mask)
+		default:
+			s = r.re.ReplaceAllString(s, r.replacement)
+		}
+	}
+
+	return s
+}
+
+// replaceWords replaces the matches of a word rule that are not part of a
+// longer word. Regexp's \b only knows ASCII, so the letters and digits around
+// a match are checked here instead, which keeps "café" from matching "caf".
+func replaceWords(s string, r rule) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+
+	for _, m := range r.re.FindAllStringIndex(s, -1) {
+		before, _ := utf8.DecodeLastRuneInString(s[:m[0]])
+		after, _ := utf8.DecodeRuneInString(s[m[1]:])
+
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+
+		b.WriteString(s[last:m[0]])
+
+		if r.replacement == "" {
+			b.WriteString(mask(s[m[0]:m[1]]))
+		} else {
+			b.WriteString(r.replacement)
+		}
+
+		last = m[1]
+	}
+
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// isWordRune reports whether r continues a word. utf8.RuneError, returned at
+// either end of the string, does not.
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_')
+}
+
+// State returns a copy of state with server, channel, and user strings
+// filtered. The input is not modified.
+func (f *Filter) State(state *teamspeak.State) *teamspeak.State {
+	if f == nil || state == nil {
+		return state
+	}
+
+	out := state.Clone()
+	f.apply(out)
+
+	for _, sv := range out.Servers {
+		f.apply(sv)
+	}
+
+	return out
+}
+
+// apply filters the strings of a single state in place.
+func (f *Filter) apply(out *teamspeak.State) {
+	out.ServerName = f.Apply(out.ServerName)
+
+	for i := range out.Channels {
+		ch := &out.Channels[i]
+		ch.Name = f.Apply(ch.Name)
+		ch.Topic = f.Apply(ch.Topic)
+
+		for j := range ch.Users {
+			ch.Users[j].Nickname = f.Apply(ch.Users[j].Nickname)
+			ch.Users[j].AwayMessage = f.Apply(ch.Users[j].AwayMessage)
+		}
+	}
+}
+
+// mask replaces a match with one maskRune per character.
+func mask(s string) string {
+	return strings.Repeat(maskRune, utf8.RuneCountInString(s))
+}
@@ -0,0 +1,72 @@
+package contentfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestApply(t *testing.T) {
+	f, err := New(Config{
+		Words: []string{"heck"},
+		Patterns: []Pattern{
+			{Pattern: `(?i)d[a4]rn`, Replacement: "dang"},
+			{Pattern: `spam+`},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "what the ✱✱✱✱ is this", f.Apply("what the HECK is this"))
+	require.Equal(t, "checkpoint", f.Apply("checkpoint"), "words only match whole words")
+	require.Equal(t, "dang it", f.Apply("D4rn it"))
+	require.Equal(t, "✱✱✱✱✱!", f.Apply("spamm!"))
+
+	// Adjacent masks must not read as Discord bold.
+	require.NotContains(t, f.Apply("heck heck"), "**")
+}
+
+func TestWordsUnicode(t *testing.T) {
+	f, err := New(Config{Words: []string{"caf", "straße", "хрен"}})
+	require.NoError(t, err)
+
+	// Letters outside ASCII still continue a word.
+	require.Equal(t, "café", f.Apply("café"))
+	require.Equal(t, "écaf", f.Apply("écaf"))
+	require.Equal(t, "Hauptstraßen", f.Apply("Hauptstraßen"))
+
+	// And words made of them are matched.
+	require.Equal(t, "die ✱✱✱✱✱✱ hier", f.Apply("die STRAßE hier"))
+	require.Equal(t, "✱✱✱✱, ✱✱✱✱!", f.Apply("хрен, Хрен!"))
+	require.Equal(t, "«✱✱✱»", f.Apply("«caf»"))
+}
+
+func TestNoRulesIsNil(t *testing.T) {
+	f, err := New(Config{Words: []string{" "}})
+	require.NoError(t, err)
+	require.Nil(t, f)
+	require.Equal(t, "untouched", f.Apply("untouched"))
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := New(Config{Patterns: []Pattern{{Pattern: "("}}})
+	require.Error(t, err)
+}
+
+func TestStateLeavesInputUntouched(t *testing.T) {
+	f, err := New(Config{Words: []string{"bad"}, Replacement: "good"})
+	require.NoError(t, err)
+
+	in := &teamspeak.State{Channels: []teamspeak.Channel{{
+		Name:  "bad room",
+		Users: []teamspeak.User{{Nickname: "bad guy", AwayMessage: "being bad"}},
+	}}}
+
+	out := f.State(in)
+
+	require.Equal(t, "good room", out.Channels[0].Name)
+	require.Equal(t, "good guy", out.Channels[0].Users[0].Nickname)
+	require.Equal(t, "being good", out.Channels[0].Users[0].AwayMessage)
+	require.Equal(t, "bad guy", in.Channels[0].Users[0].Nickname)
+}
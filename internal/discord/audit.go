@@ -0,0 +1,200 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// AuditReport is the outcome of comparing the status message in Discord with
+// the embed rendered from the live state.
+type AuditReport struct {
+	ChannelID string
+	MessageID string   // Empty when no status message was found
+	Problems  []string // What is wrong with the message besides its content
+	Diff      string   // Unified diff from the message to the fresh render, "" when they match
+	Fixed     bool     // The message was rewritten from the live state
+}
+
+// Consistent reports whether the message matches the live state.
+func (r AuditReport) Consistent() bool {
+	return r.MessageID != "" && len(r.Problems) == 0 && r.Diff == ""
+}
+
+// Audit fetches the status message of the main status channel over the REST
+// API, without opening a gateway connection, and compares it with the embed
+// rendered from state. With fix, an inconsistent message is repaired the way
+// the running bridge would: a missing or suppressed message is posted again
+// and a differing one edited. The bridge must be stopped for fix: it would
+// take the repair for a second instance editing its message and stand by.
+func Audit(ctx context.Context, log logrus.FieldLogger, cfg Config, display DisplayConfig, state *teamspeak.State, fix bool) (AuditReport, error) {
+	if cfg.WebhookURL != "" {
+		return AuditReport{}, errors.New("auditing needs a bot token; webhook messages cannot be looked up")
+	}
+
+	log = log.WithField("component", "discord")
+
+	session, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		return AuditReport{}, fmt.Errorf("failed to create Discord session: %w", err)
+	}
+
+	// Without a gateway connection the bot user is not filled in by READY.
+	user, err := session.User("@me", discordgo.WithContext(ctx))
+	if err != nil {
+		return AuditReport{}, fmt.Errorf("failed to look up the bot user: %w", err)
+	}
+
+	session.State.User = user
+
+	s := newService(log, cfg, display, newSkewClock(log))
+	s.session = session
+
+	return s.audit(ctx, state, fix)
+}
+
+// audit compares the status message with state, and repairs it with fix.
+func (s *service) audit(ctx context.Context, state *teamspeak.State, fix bool) (AuditReport, error) {
+	if state != nil {
+		state = s.display.ChannelFilter.Apply(state)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := AuditReport{ChannelID: s.cfg.ChannelID}
+
+	found, err := s.findMessage()
+	if err != nil {
+		return report, err
+	}
+
+	if found {
+		report.MessageID = s.messageID
+
+		if err := s.auditMessage(ctx, state, &report); err != nil {
+			return report, err
+		}
+	} else {
+		report.Problems = append(report.Problems, "no status message from the bot in the channel")
+	}
+
+	if !fix || report.Consistent() {
+		return report, nil
+	}
+
+	if !found {
+		if err := s.findOrCreateMessage(); err != nil {
+			return report, fmt.Errorf("failed to create status message: %w", err)
+		}
+	} else if err := s.verifyMessage(ctx); err != nil {
+		return report, err
+	}
+
+	s.lastState = state
+
+	if _, err := s.editMessage(ctx, state); err != nil {
+		return report, fmt.Errorf("failed to update status message: %w", err)
+	}
+
+	s.updateServerMessages(ctx, state)
+	s.updatePages(ctx, state)
+
+	report.MessageID = s.messageID
+	report.Fixed = true
+
+	return report, nil
+}
+
+// findMessage adopts the bot's existing status message like
+// findOrCreateMessage, but reports false instead of creating one. Must be
+// called with s.mu held.
+func (s *service) findMessage() (bool, error) {
+	ch, err := s.session.Channel(s.cfg.ChannelID)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up status channel: %w", err)
+	}
+
+	if err := checkStatusChannel(ch); err != nil {
+		return false, err
+	}
+
+	if ch.Type == discordgo.ChannelTypeGuildForum {
+		post := s.ownPost(ch)
+		if post == nil {
+			return false, nil
+		}
+
+		s.adoptPost(post)
+
+		return true, nil
+	}
+
+	messages, err := s.session.ChannelMessages(s.channel(), 50, "", "", "")
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch channel messages: %w", err)
+	}
+
+	return s.adoptOwnMessage(messages, s.session.State.User.ID), nil
+}
+
+// auditMessage fills the report in for the adopted message. Must be called
+// with s.mu held.
+func (s *service) auditMessage(ctx context.Context, state *teamspeak.State, report *AuditReport) error {
+	msg, err := s.session.ChannelMessage(s.channel(), s.messageID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to fetch status message: %w", err)
+	}
+
+	if msg.Flags&discordgo.MessageFlagsSuppressEmbeds != 0 {
+		report.Problems = append(report.Problems, "the message's embeds are suppressed")
+	}
+
+	if len(msg.Embeds) == 0 {
+		report.Problems = append(report.Problems, "the message has no embed")
+
+		return nil
+	}
+
+	pages := s.buildPages(s.mainState(state))
+
+	if want := s.wantExtraMessages(state, len(pages)-1); want != len(s.extraMessages) {
+		report.Problems = append(report.Problems,
+			fmt.Sprintf("%d follow-up messages expected, %d found", want, len(s.extraMessages)))
+	}
+
+	report.Diff = unifiedDiff(auditText(msg.Embeds[0]), auditText(pages[0]))
+
+	return nil
+}
+
+// wantExtraMessages is the number of messages expected after the main one.
+func (s *service) wantExtraMessages(state *teamspeak.State, pages int) int {
+	switch {
+	case s.display.MessagePerServer && state != nil && len(state.Servers) > 1:
+		return len(state.Servers) - 1
+	case s.display.Paginate:
+		return pages
+	default:
+		return len(s.extraMessages)
+	}
+}
+
+// discordTimestamp matches Discord timestamp markup such as <t:1700000000:R>.
+var discordTimestamp = regexp.MustCompile(`<t:-?\d+(:[tTdDfFR])?>`)
+
+// auditText flattens an embed for the audit diff. Attached images are left
+// out, since a sent embed points at the attachment by name and a fetched one
+// at its CDN URL, and timestamps are masked as they follow the fetch time.
+func auditText(e *discordgo.MessageEmbed) string {
+	c := *e
+	c.Image = nil
+
+	return discordTimestamp.ReplaceAllString(embedText(&c), "<t:…>")
+}
@@ -0,0 +1,147 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxPublishes is how many messages Discord lets a channel publish per
+	// publishWindow. Past it Discord answers with a retry after of up to an
+	// hour, so further alerts are not published rather than queued.
+	maxPublishes  = 10
+	publishWindow = time.Hour
+
+	// publishTimeout bounds publishing an alert, which runs after Notify
+	// has returned.
+	publishTimeout = 10 * time.Second
+)
+
+// channelTypeNames name the channel types that cannot hold the status
+// message, for the startup error.
+var channelTypeNames = map[discordgo.ChannelType]string{
+	discordgo.ChannelTypeDM:              "a DM",
+	discordgo.ChannelTypeGroupDM:         "a group DM",
+	discordgo.ChannelTypeGuildCategory:   "a category",
+	discordgo.ChannelTypeGuildStore:      "a store channel",
+	discordgo.ChannelTypeGuildStageVoice: "a stage channel",
+	discordgo.ChannelTypeGuildDirectory:  "a directory",
+	discordgo.ChannelTypeGuildMedia:      "a media channel",
+}
+
+// checkStatusChannel rejects channels that cannot hold the status message,
+// so a wrong channel_id fails at startup with a clear error rather than with
+// an opaque API error on the first post.
+func checkStatusChannel(ch *discordgo.Channel) error {
+	if name, ok := channelTypeNames[ch.Type]; ok {
+		return fmt.Errorf("channel %s (%s) is %s, which cannot host the status message; "+
+			"use a text, announcement, voice or forum channel, or a thread", ch.ID, ch.Name, name)
+	}
+
+	return nil
+}
+
+// publishAsync publishes msg in the background, so a slow or rate limited
+// crosspost never holds up the alert path.
+func (s *service) publishAsync(ctx context.Context, session *discordgo.Session, channelID string, msg *discordgo.Message) {
+	if !s.cfg.PublishAlerts {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		s.publish(ctx, session, channelID, msg, time.Now())
+	}()
+}
+
+// publish crossposts a message the bot posted in an announcement channel to
+// the servers following it. Discord allows 10 publishes per channel and hour,
+// so only alerts are published, never the status message, and once a
+// channel used them up its alerts stay unpublished until the hour has passed.
+func (s *service) publish(ctx context.Context, session *discordgo.Session, channelID string, msg *discordgo.Message, now time.Time) {
+	typ, err := s.alertChannelType(ctx, session, channelID)
+	if err != nil {
+		s.log.WithError(err).WithField("channel_id", channelID).Warn("Failed to look up alert channel")
+
+		return
+	}
+
+	if typ != discordgo.ChannelTypeGuildNews {
+		return
+	}
+
+	if !s.allowPublish(channelID, now) {
+		s.log.WithField("channel_id", channelID).Warn("Not publishing alert: the channel reached Discord's limit of 10 publishes per hour")
+
+		return
+	}
+
+	if _, err := session.ChannelMessageCrosspost(channelID, msg.ID,
+		discordgo.WithContext(ctx), discordgo.WithRetryOnRatelimit(false)); err != nil {
+		s.log.WithError(err).WithField("channel_id", channelID).Warn("Failed to publish alert to followers")
+	}
+}
+
+// alertChannelType returns the type of an alert channel, looked up once.
+func (s *service) alertChannelType(ctx context.Context, session *discordgo.Session, channelID string) (discordgo.ChannelType, error) {
+	s.mu.Lock()
+	typ, ok := s.channelTypes[channelID]
+	s.mu.Unlock()
+
+	if ok {
+		return typ, nil
+	}
+
+	ch, err := session.Channel(channelID, discordgo.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.channelTypes[channelID] = ch.Type
+	s.mu.Unlock()
+
+	return ch.Type, nil
+}
+
+// allowPublish counts a publish to channelID unless the channel already
+// published maxPublishes messages within publishWindow.
+func (s *service) allowPublish(channelID string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recent := slices.DeleteFunc(s.published[channelID], func(t time.Time) bool { return now.Sub(t) >= publishWindow })
+	if len(recent) >= maxPublishes {
+		s.published[channelID] = recent
+
+		return false
+	}
+
+	s.published[channelID] = append(recent, now)
+
+	return true
+}
+
+// markdownEscaper backslash-escapes the characters Discord reads as
+// formatting, and the angle bracket that starts mention markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`,
+	">", `\>`, "<", `\<`, "#", `\#`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+)
+
+// EscapeMarkdown escapes text from TeamSpeak, such as nicknames and channel
+// names, for use inside a Discord message.
+func EscapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
@@ -0,0 +1,123 @@
+package discord
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// skewThreshold is how far the host clock must be from Discord's before embed
+// times are corrected. Date headers have one-second resolution, so smaller
+// offsets are indistinguishable from network jitter.
+const skewThreshold = 10 * time.Second
+
+// skewClock estimates the offset between the host clock and Discord's from the
+// Date headers of API responses. Hosts without a real-time clock (e.g. a
+// Raspberry Pi that booted before NTP synced) can be minutes off, which makes
+// every "updated ... ago" on a fresh edit wrong.
+type skewClock struct {
+	log logrus.FieldLogger
+
+	mu      sync.Mutex
+	offset  time.Duration // Discord's clock minus the host's, smoothed
+	samples int
+	warned  bool // Whether the current skew has been logged
+}
+
+func newSkewClock(log logrus.FieldLogger) *skewClock {
+	return &skewClock{log: log}
+}
+
+// observe records one response: its Date header, and when the request was
+// sent and the response received on the host clock.
+func (c *skewClock) observe(date string, sent, received time.Time) {
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	// The header is truncated to the second; the midpoint of the round trip
+	// is the best local estimate of when it was written.
+	sample := server.Add(500 * time.Millisecond).Sub(sent.Add(received.Sub(sent) / 2))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.samples++
+
+	// A moving average damps jitter, but a clock stepped by NTP moves the
+	// offset for good, so a large jump is taken as is.
+	if c.samples == 1 || absDuration(sample-c.offset) > time.Minute {
+		c.offset = sample
+	} else {
+		c.offset += (sample - c.offset) / 5
+	}
+
+	skewed := absDuration(c.offset) >= skewThreshold
+
+	switch {
+	case skewed && !c.warned:
+		c.warned = true
+		c.log.WithField("offset", c.offset.Round(time.Second)).
+			Warn("Host clock differs from Discord's; correcting embed timestamps (check NTP)")
+	case !skewed && c.warned:
+		c.warned = false
+		c.log.Info("Host clock is back in sync with Discord")
+	}
+}
+
+// Offset returns the correction to add to host times, or zero while the skew
+// is below skewThreshold. A nil clock never corrects.
+func (c *skewClock) Offset() time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if absDuration(c.offset) < skewThreshold {
+		return 0
+	}
+
+	return c.offset
+}
+
+// correct converts a host time to Discord's clock.
+func (c *skewClock) correct(t time.Time) time.Time {
+	return t.Add(c.Offset())
+}
+
+// transport wraps next, measuring the skew on every response.
+func (c *skewClock) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent := time.Now()
+
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			c.observe(resp.Header.Get("Date"), sent, time.Now())
+		}
+
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
@@ -0,0 +1,39 @@
+package discord
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkewClock(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	c := newSkewClock(log)
+	sent := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(200 * time.Millisecond)
+
+	// Within the threshold nothing is corrected.
+	c.observe(sent.Add(3*time.Second).Format(http.TimeFormat), sent, received)
+	require.Zero(t, c.Offset())
+
+	// A host 45 minutes behind is corrected once measured.
+	c.observe(sent.Add(45*time.Minute).Format(http.TimeFormat), sent, received)
+	require.InDelta(t, float64(45*time.Minute), float64(c.Offset()), float64(time.Second))
+	require.WithinDuration(t, sent.Add(45*time.Minute), c.correct(sent), time.Second)
+
+	// Unparsable headers are ignored.
+	c.observe("", sent, received)
+	require.InDelta(t, float64(45*time.Minute), float64(c.Offset()), float64(time.Second))
+
+	// NTP stepping the clock back in sync is picked up straight away.
+	c.observe(sent.Format(http.TimeFormat), sent, received)
+	require.Zero(t, c.Offset())
+
+	var unset *skewClock
+	require.Equal(t, sent, unset.correct(sent))
+}
@@ -0,0 +1,159 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// ColorRule sets the embed color when all of its conditions hold. Unset
+// conditions match anything; the first matching rule wins.
+type ColorRule struct {
+	Color int
+
+	Stale        *bool    // Data is older than the staleness threshold (server unreachable)
+	Announcement *bool    // An announcement is showing
+	MinUsers     *int     // At least this many users online
+	MaxUsers     *int     // At most this many users online
+	MinCapacity  *float64 // At least this fraction of slots used (0-1)
+	MaxCapacity  *float64 // At most this fraction of slots used (0-1)
+
+	// Window limits the rule to a daily local time range, in minutes after
+	// midnight; From > To wraps past midnight. Nil applies all day.
+	Window   *ClockRange
+	Weekdays []time.Weekday // Empty applies every day
+}
+
+// ClockRange is a daily time range in minutes after midnight.
+type ClockRange struct {
+	From, To int
+}
+
+// colorInput is what color rules are evaluated against.
+type colorInput struct {
+	state        *teamspeak.State
+	stale        bool
+	announcement bool
+	now          time.Time
+}
+
+// matches reports whether all of the rule's conditions hold.
+func (r ColorRule) matches(in colorInput) bool {
+	capacity := capacityUsed(in.state)
+
+	switch {
+	case r.Stale != nil && *r.Stale != in.stale,
+		r.Announcement != nil && *r.Announcement != in.announcement,
+		r.MinUsers != nil && in.state.TotalUsers < *r.MinUsers,
+		r.MaxUsers != nil && in.state.TotalUsers > *r.MaxUsers,
+		r.MinCapacity != nil && capacity < *r.MinCapacity,
+		r.MaxCapacity != nil && capacity > *r.MaxCapacity:
+		return false
+	}
+
+	if len(r.Weekdays) > 0 && !containsWeekday(r.Weekdays, in.now.Weekday()) {
+		return false
+	}
+
+	return r.Window == nil || r.Window.contains(in.now)
+}
+
+func (c ClockRange) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+
+	if c.From <= c.To {
+		return m >= c.From && m < c.To
+	}
+
+	return m >= c.From || m < c.To
+}
+
+func containsWeekday(days []time.Weekday, d time.Weekday) bool {
+	for _, day := range days {
+		if day == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// embedColor picks the embed color from the configured rules, falling back to
+// the capacity-based default.
+func (s *service) embedColor(in colorInput) int {
+	for _, r := range s.display.ColorRules {
+		if r.matches(in) {
+			return r.Color
+		}
+	}
+
+	capacityPercent := capacityUsed(in.state)
+
+	switch {
+	case in.state.TotalUsers == 0:
+		return 0x95A5A6 // Gray - empty
+	case capacityPercent >= 0.8:
+		return 0xE74C3C // Red - almost full
+	case capacityPercent >= 0.5:
+		return 0xF39C12 // Orange - busy
+	default:
+		return 0x2ECC71 // Green - available
+	}
+}
+
+// capacityUsed returns the share of slots in use, or 0 for a server
+// reporting no slot limit.
+func capacityUsed(state *teamspeak.State) float64 {
+	if state.MaxClients <= 0 {
+		return 0
+	}
+
+	return float64(state.TotalUsers) / float64(state.MaxClients)
+}
+
+// ParseColor parses a "#RRGGBB" color.
+func ParseColor(s string) (int, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return 0, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+
+	return int(v), nil
+}
+
+// ParseClockRange parses a daily range such as "20:00-23:30".
+func ParseClockRange(s string) (ClockRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return ClockRange{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+
+	f, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return ClockRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+
+	t, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return ClockRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+
+	return ClockRange{From: f.Hour()*60 + f.Minute(), To: t.Hour()*60 + t.Minute()}, nil
+}
+
+// ParseWeekday parses a weekday name such as "sat" or "Saturday".
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
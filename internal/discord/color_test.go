@@ -0,0 +1,55 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestEmbedColorRules(t *testing.T) {
+	yes := true
+	minUsers := 10
+
+	svc := newTestService(DisplayConfig{ColorRules: []ColorRule{
+		{Color: 0xFF0000, Stale: &yes},
+		{Color: 0x800080, Window: &ClockRange{From: 20 * 60, To: 2 * 60}, Weekdays: []time.Weekday{time.Saturday}},
+		{Color: 0x00FFFF, MinUsers: &minUsers},
+	}})
+
+	saturdayEvening := time.Date(2026, 10, 17, 21, 0, 0, 0, time.Local)
+	sundayEvening := saturdayEvening.AddDate(0, 0, 1)
+	state := &teamspeak.State{TotalUsers: 2, MaxClients: 32}
+	busy := &teamspeak.State{TotalUsers: 12, MaxClients: 32}
+
+	require.Equal(t, 0xFF0000, svc.embedColor(colorInput{state: busy, stale: true, now: saturdayEvening}))
+	require.Equal(t, 0x800080, svc.embedColor(colorInput{state: busy, now: saturdayEvening}))
+	require.Equal(t, 0x00FFFF, svc.embedColor(colorInput{state: busy, now: sundayEvening}))
+	require.Equal(t, 0x2ECC71, svc.embedColor(colorInput{state: state, now: sundayEvening}))
+
+	// A server reporting no slot limit is never full.
+	unlimited := &teamspeak.State{TotalUsers: 2}
+	require.Equal(t, 0x2ECC71, newTestService(DisplayConfig{}).embedColor(colorInput{state: unlimited, now: sundayEvening}))
+	require.Zero(t, capacityUsed(unlimited))
+}
+
+func TestClockRangeWrapsMidnight(t *testing.T) {
+	r, err := ParseClockRange("22:00-02:00")
+	require.NoError(t, err)
+
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	require.True(t, r.contains(day.Add(23*time.Hour)))
+	require.True(t, r.contains(day.Add(time.Hour)))
+	require.False(t, r.contains(day.Add(12*time.Hour)))
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#9B59B6")
+	require.NoError(t, err)
+	require.Equal(t, 0x9B59B6, c)
+
+	_, err = ParseColor("purple")
+	require.Error(t, err)
+}
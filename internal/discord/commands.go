@@ -0,0 +1,451 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/notify"
+)
+
+// MaxAnnouncementLength keeps announcements to a single embed line.
+const MaxAnnouncementLength = 200
+
+// Commands is implemented by whatever acts on slash commands beyond the
+// Discord service itself (the bridge).
+type Commands interface {
+	// Announce shows text in the embed for the given duration (0 uses the
+	// default); an empty text clears the announcement.
+	Announce(ctx context.Context, text string, duration time.Duration)
+	// WhatChanged describes the joins, leaves and moves since the viewer
+	// last asked.
+	WhatChanged(ctx context.Context, viewer string) string
+	// Silence suppresses notifications of an event type ("all" for every
+	// type) for the given duration; 0 lifts the silence.
+	Silence(ctx context.Context, event string, duration time.Duration) error
+	// Refresh requests an immediate update without waiting for the next
+	// interval.
+	Refresh()
+	// Subscribe adds a DM subscription of the user, holding the given
+	// server roles, for a nickname coming online, or for the user count
+	// reaching users, and returns the reply.
+	Subscribe(ctx context.Context, userID string, roles []string, nickname string, users int) string
+	// Unsubscribe removes a subscription of the user, or all of them when
+	// neither nickname nor users is given, and returns the reply.
+	Unsubscribe(ctx context.Context, userID, nickname string, users int) string
+	// Subscriptions lists the user's subscriptions.
+	Subscriptions(ctx context.Context, userID string) string
+}
+
+// subscriptionOptions are the options of /ts subscribe and /ts unsubscribe.
+var subscriptionOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "nickname",
+		Description: "TeamSpeak nickname to watch for",
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionInteger,
+		Name:        "users",
+		Description: "Number of users online to watch for",
+		MinValue:    &minSubscriptionUsers,
+	},
+}
+
+var minSubscriptionUsers = 1.0
+
+// SilenceAll is the /ts silence choice covering every event type.
+const SilenceAll = "all"
+
+// silenceEvents are the event choices of /ts silence and /ts unsilence:
+// SilenceAll followed by every notify event type.
+var silenceEvents = func() []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{{Name: SilenceAll, Value: SilenceAll}}
+
+	for _, event := range notify.Events {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: event, Value: event})
+	}
+
+	return choices
+}()
+
+// tsCommand is the /ts command tree registered in the status channel's guild.
+var tsCommand = &discordgo.ApplicationCommand{
+	Name:        "ts",
+	Description: "TeamSpeak status",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "status",
+			Description: "Show the current status, visible only to you",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "who",
+			Description: "Show where a user is and whether they are muted or idle",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "nickname",
+					Description: "TeamSpeak nickname, or part of it",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "preview-name",
+			Description: "Show the channel name the configured format produces right now",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "announce",
+			Description: "Show an announcement in the status embed",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "text",
+					Description: "Announcement text, e.g. \"Event at 20:00!\"",
+					Required:    true,
+					MaxLength:   MaxAnnouncementLength,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "How long to show it, e.g. 45m or 2h (default 30m)",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "clear-announcement",
+			Description: "Remove the current announcement",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "silence",
+			Description: "Suppress notifications for a while, e.g. during maintenance",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "How long to stay silent, e.g. 45m or 2h",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "event",
+					Description: "Which notifications to suppress (default all)",
+					Choices:     silenceEvents,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "unsilence",
+			Description: "Lift a notification silence",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "event",
+					Description: "Which silence to lift (default all)",
+					Choices:     silenceEvents,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "subscribe",
+			Description: "Get a DM when someone comes online or the server fills up",
+			Options:     subscriptionOptions,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "unsubscribe",
+			Description: "Stop a DM subscription, or all of them without options",
+			Options:     subscriptionOptions,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "subscriptions",
+			Description: "List your DM subscriptions",
+		},
+	},
+}
+
+// SetCommands sets the handler for slash commands.
+func (s *service) SetCommands(c Commands) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = c
+
+	for _, t := range s.targets {
+		t.SetCommands(c)
+	}
+}
+
+// registerCommands registers the slash commands in the guild of the status
+// channel. Guild commands are available immediately, unlike global ones.
+// Discord keeps them across reconnects, so they are registered once.
+func (s *service) registerCommands() error {
+	if s.registered.Load() {
+		return nil
+	}
+
+	ch, err := s.session.Channel(s.cfg.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to look up status channel: %w", err)
+	}
+
+	if ch.GuildID == "" {
+		return fmt.Errorf("status channel is not in a guild")
+	}
+
+	if _, err := s.session.ApplicationCommandBulkOverwrite(applicationID(s.session), ch.GuildID,
+		[]*discordgo.ApplicationCommand{tsCommand}); err != nil {
+		return fmt.Errorf("failed to register slash commands: %w", err)
+	}
+
+	s.registered.Store(true)
+
+	return nil
+}
+
+// registerInteractionHandler routes slash commands to onCommand and the
+// message components to their handlers.
+func (s *service) registerInteractionHandler() {
+	s.session.AddHandler(func(sess *discordgo.Session, i *discordgo.InteractionCreate) {
+		// Interactions in a target's channel are that target's.
+		s := s.owner(i.ChannelID)
+
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			if err := sess.InteractionRespond(i.Interaction, s.onCommand(i)); err != nil {
+				s.log.WithError(err).Warn("Failed to respond to interaction")
+			}
+		case discordgo.InteractionMessageComponent:
+			id := i.MessageComponentData().CustomID
+
+			if view, ok := strings.CutPrefix(id, viewButtonPrefix); ok {
+				s.onViewButton(sess, i, view)
+			} else if id == channelSelectID {
+				if err := sess.InteractionRespond(i.Interaction, s.onChannelSelect(i)); err != nil {
+					s.log.WithError(err).Warn("Failed to respond to channel selection")
+				}
+			} else if id == whatChangedID {
+				s.onWhatChanged(sess, i)
+			} else if id == refreshID {
+				if err := sess.InteractionRespond(i.Interaction, s.onRefresh(i, time.Now())); err != nil {
+					s.log.WithError(err).Warn("Failed to respond to refresh button")
+				}
+			}
+		}
+	})
+}
+
+// onCommand handles a /ts invocation and returns the response to send.
+func (s *service) onCommand(i *discordgo.InteractionCreate) *discordgo.InteractionResponse {
+	data := i.ApplicationCommandData()
+	if data.Name != tsCommand.Name || len(data.Options) == 0 {
+		return ephemeral("Unknown command.")
+	}
+
+	// Lookups only read the last state and need no command handler.
+	switch sub := data.Options[0]; sub.Name {
+	case "status":
+		return s.onStatus()
+	case "who":
+		return s.onWho(sub)
+	case "preview-name":
+		return ephemeral(s.previewName(time.Now()))
+	}
+
+	s.mu.Lock()
+	commands := s.commands
+	s.mu.Unlock()
+
+	if commands == nil {
+		return ephemeral("The bot is still starting, try again in a moment.")
+	}
+
+	sub := data.Options[0]
+
+	switch sub.Name {
+	case "announce":
+		if !canManage(i) {
+			return ephemeral("You need the Manage Messages permission to post announcements.")
+		}
+
+		var text, duration string
+
+		for _, opt := range sub.Options {
+			switch opt.Name {
+			case "text":
+				text = opt.StringValue()
+			case "duration":
+				duration = opt.StringValue()
+			}
+		}
+
+		var d time.Duration
+
+		if duration != "" {
+			parsed, err := time.ParseDuration(duration)
+			if err != nil || parsed <= 0 {
+				return ephemeral(fmt.Sprintf("Invalid duration %q, use e.g. 45m or 2h.", duration))
+			}
+
+			d = parsed
+		}
+
+		commands.Announce(context.Background(), text, d)
+
+		return ephemeral("📢 Announcement posted.")
+	case "clear-announcement":
+		if !canManage(i) {
+			return ephemeral("You need the Manage Messages permission to clear announcements.")
+		}
+
+		commands.Announce(context.Background(), "", 0)
+
+		return ephemeral("Announcement cleared.")
+	case "silence", "unsilence":
+		if !canManage(i) {
+			return ephemeral("You need the Manage Messages permission to silence notifications.")
+		}
+
+		return s.onSilence(commands, sub)
+	case "subscribe", "unsubscribe", "subscriptions":
+		return s.onSubscription(commands, i, sub)
+	}
+
+	return ephemeral("Unknown command.")
+}
+
+// onSubscription handles /ts subscribe, /ts unsubscribe and
+// /ts subscriptions.
+func (s *service) onSubscription(commands Commands, i *discordgo.InteractionCreate, sub *discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponse {
+	user := interactionUser(i)
+	if user == "" {
+		return ephemeral("Unknown user.")
+	}
+
+	var (
+		nickname string
+		users    int
+		roles    []string
+	)
+
+	if i.Member != nil {
+		roles = i.Member.Roles
+	}
+
+	for _, opt := range sub.Options {
+		switch opt.Name {
+		case "nickname":
+			nickname = opt.StringValue()
+		case "users":
+			users = int(opt.IntValue())
+		}
+	}
+
+	switch sub.Name {
+	case "subscribe":
+		return ephemeral(commands.Subscribe(context.Background(), user, roles, nickname, users))
+	case "unsubscribe":
+		return ephemeral(commands.Unsubscribe(context.Background(), user, nickname, users))
+	default:
+		return ephemeral(truncateLines(commands.Subscriptions(context.Background(), user), maxReplyLength))
+	}
+}
+
+// onSilence handles /ts silence and /ts unsilence.
+func (s *service) onSilence(commands Commands, sub *discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponse {
+	event, duration := "all", ""
+
+	for _, opt := range sub.Options {
+		switch opt.Name {
+		case "event":
+			event = opt.StringValue()
+		case "duration":
+			duration = opt.StringValue()
+		}
+	}
+
+	var d time.Duration
+
+	if sub.Name == "silence" {
+		parsed, err := time.ParseDuration(duration)
+		if err != nil || parsed <= 0 {
+			return ephemeral(fmt.Sprintf("Invalid duration %q, use e.g. 45m or 2h.", duration))
+		}
+
+		d = parsed
+	}
+
+	if err := commands.Silence(context.Background(), event, d); err != nil {
+		return ephemeral("Could not change the silence: " + err.Error())
+	}
+
+	what := "All notifications are"
+	if event != "all" {
+		what = fmt.Sprintf("**%s** notifications are", event)
+	}
+
+	if d == 0 {
+		return ephemeral("🔔 " + what + " no longer silenced.")
+	}
+
+	return ephemeral(fmt.Sprintf("🔕 %s silenced until <t:%d:f>.", what, time.Now().Add(d).Unix()))
+}
+
+// previewName describes the channel name the format produces for the last
+// state, and when it would next be applied.
+func (s *service) previewName(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.display.ChannelNameFormat == "" {
+		return "No channel name format is configured (display.channel_name_format)."
+	}
+
+	if s.lastState == nil {
+		return "The bot is still starting, try again in a moment."
+	}
+
+	name := s.channelName(s.lastState, now)
+	reply := fmt.Sprintf("The channel name would be `%s`.", name)
+
+	switch {
+	case s.display.ChannelNameDryRun:
+		reply += "\nDry run is on, so the channel is not renamed."
+	case name == s.lastChannelName:
+		reply += "\nThe channel already has this name."
+	case now.Sub(s.lastChannelRename) < channelRenameInterval:
+		reply += fmt.Sprintf("\nThe next rename is allowed <t:%d:R>.", s.lastChannelRename.Add(channelRenameInterval).Unix())
+	default:
+		reply += "\nIt is applied with the next update."
+	}
+
+	return reply
+}
+
+// canManage reports whether the invoking member may change the embed.
+func canManage(i *discordgo.InteractionCreate) bool {
+	return i.Member != nil && i.Member.Permissions&discordgo.PermissionManageMessages != 0
+}
+
+// ephemeral builds a reply only the invoking user sees.
+func ephemeral(content string) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package discord
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ErrConflict is returned by UpdateStatus while another instance drives the
+// status message.
+var ErrConflict = errors.New("another instance is updating the status message")
+
+// conflictStandby is how long foreign edits must stop before a service that
+// backed off resumes driving the status message.
+const conflictStandby = 3 * time.Minute
+
+// registerConflictHandler watches edits to the status message. Only this bot
+// can edit its messages, so an edit that is not ours comes from another
+// instance running with the same token. Edits in a target's channel are
+// checked against that target's message.
+func (s *service) registerConflictHandler() {
+	s.session.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageUpdate) {
+		if m.Message == nil || m.EditedTimestamp == nil {
+			return
+		}
+
+		s := s.owner(m.ChannelID)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if m.ID != s.messageID {
+			return
+		}
+
+		if s.seenEdit.IsZero() || m.EditedTimestamp.After(s.seenEdit) {
+			s.seenEdit = *m.EditedTimestamp
+		}
+	})
+}
+
+// checkConflict reports whether another instance is driving the status
+// message. An edit newer than our last one means someone else edited it since;
+// our own edits have been recorded in lastEdited by the time the next update
+// runs. After backing off, editing resumes once no foreign edit has been seen
+// for conflictStandby. Must be called with s.mu held.
+func (s *service) checkConflict(now time.Time) bool {
+	foreign := !s.lastEdited.IsZero() && s.seenEdit.After(s.lastEdited)
+
+	switch {
+	case foreign && !s.conflict:
+		s.conflict = true
+		s.log.WithField("message_id", s.messageID).Error("Another instance is editing the status message; " +
+			"refusing to update it until it stops. Check for a second copy of the bot running with the same token")
+	case s.conflict && now.Sub(s.seenEdit) >= conflictStandby:
+		s.conflict = false
+		s.lastEdited = s.seenEdit
+		s.log.WithField("message_id", s.messageID).Warn("No edits from another instance seen recently; resuming status updates")
+	}
+
+	return s.conflict
+}
+
+// recordEdit remembers the edit time of a message this service just edited.
+// Must be called with s.mu held.
+func (s *service) recordEdit(msg *discordgo.Message) {
+	if msg.EditedTimestamp != nil {
+		s.lastEdited = *msg.EditedTimestamp
+	}
+}
@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+)
+
+// permission is a channel permission the bot uses, and whether the current
+// configuration needs it.
+type permission struct {
+	name   string
+	bit    int64
+	needed bool
+}
+
+// permissions lists the channel permissions the configuration relies on,
+// with post the forum post of the status message, if any.
+func (s *service) permissions(post string) []permission {
+	renames := s.display.ChannelNameFormat != "" || s.display.ChannelNameReset != nil || s.display.VoiceMirror != nil
+
+	return []permission{
+		{"ViewChannel", discordgo.PermissionViewChannel, true},
+		{"SendMessages", discordgo.PermissionSendMessages, true},
+		{"EmbedLinks", discordgo.PermissionEmbedLinks, true},
+		{"ReadMessageHistory", discordgo.PermissionReadMessageHistory, true},
+		{"AttachFiles", discordgo.PermissionAttachFiles, s.display.JoinQR},
+		{"UseExternalEmojis", discordgo.PermissionUseExternalEmojis, false},
+		{"ManageChannels", discordgo.PermissionManageChannels, renames},
+		{"ChangeNickname", discordgo.PermissionChangeNickname, s.display.NicknameFormat != ""},
+		{"CreatePublicThreads", discordgo.PermissionCreatePublicThreads, s.cfg.DetailThread != ""},
+		{"SendMessagesInThreads", discordgo.PermissionSendMessagesInThreads, s.cfg.DetailThread != "" || post != ""},
+		{"ManageThreads", discordgo.PermissionManageThreads, post != ""},
+	}
+}
+
+// diagnoseOnce logs the diagnostics on the first connection only, not on
+// every reconnect.
+func (s *service) diagnoseOnce() {
+	if s.diagnosed.Swap(true) {
+		return
+	}
+
+	// The REST lookups below must not hold up status updates.
+	s.mu.Lock()
+	session, messageID, post := s.session, s.messageID, s.post
+	s.mu.Unlock()
+
+	s.logDiagnostics(session, messageID, post)
+}
+
+// logDiagnostics logs the status channel, its guild, the gateway intents and
+// the bot's permissions in the channel, warning about missing ones. Lookup
+// failures are logged in place of the values. Call it without s.mu held,
+// after the status message was found or created.
+func (s *service) logDiagnostics(session *discordgo.Session, messageID, post string) {
+	fields := logrus.Fields{
+		"channel_id": s.cfg.ChannelID,
+		"message_id": messageID,
+		"intents":    fmt.Sprintf("%#x", session.Identify.Intents),
+	}
+
+	if session.State.User != nil {
+		fields["bot"] = session.State.User.Username
+		fields["bot_id"] = session.State.User.ID
+	}
+
+	if post != "" {
+		fields["forum_post"] = post
+	}
+
+	ch, err := session.Channel(s.cfg.ChannelID)
+	if err != nil {
+		fields["channel_error"] = err.Error()
+		s.log.WithFields(fields).Info("Discord diagnostics")
+
+		return
+	}
+
+	fields["channel"] = ch.Name
+	fields["channel_type"] = int(ch.Type)
+	fields["guild_id"] = ch.GuildID
+
+	if g, err := session.Guild(ch.GuildID); err == nil {
+		fields["guild"] = g.Name
+	}
+
+	var missing []string
+
+	if session.State.User != nil {
+		perms, err := session.UserChannelPermissions(session.State.User.ID, s.cfg.ChannelID)
+		if err != nil {
+			fields["permissions_error"] = err.Error()
+		} else {
+			var held []string
+
+			for _, p := range s.permissions(post) {
+				switch {
+				case perms&discordgo.PermissionAdministrator != 0 || perms&p.bit != 0:
+					held = append(held, p.name)
+				case p.needed:
+					missing = append(missing, p.name)
+				}
+			}
+
+			fields["permissions"] = strings.Join(held, ",")
+		}
+	}
+
+	if len(missing) > 0 {
+		fields["missing"] = strings.Join(missing, ",")
+		s.log.WithFields(fields).Warn("Discord diagnostics: the bot lacks permissions this configuration needs")
+
+		return
+	}
+
+	s.log.WithFields(fields).Info("Discord diagnostics")
+}
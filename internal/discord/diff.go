@@ -0,0 +1,166 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 2
+
+// embedText flattens the visible parts of an embed into lines for diffing.
+// The embed timestamp is left out since it changes on every edit.
+func embedText(e *discordgo.MessageEmbed) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "color: #%06X\n", e.Color)
+
+	if e.Author != nil {
+		fmt.Fprintf(&b, "author: %s\n", e.Author.Name)
+	}
+
+	fmt.Fprintf(&b, "title: %s\n", e.Title)
+
+	if e.Description != "" {
+		fmt.Fprintf(&b, "description:\n%s\n", e.Description)
+	}
+
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "field %q (inline=%t):\n%s\n", f.Name, f.Inline, f.Value)
+	}
+
+	if e.Image != nil {
+		fmt.Fprintf(&b, "image: %s\n", e.Image.URL)
+	}
+
+	if e.Footer != nil {
+		fmt.Fprintf(&b, "footer: %s\n", e.Footer.Text)
+	}
+
+	return b.String()
+}
+
+// unifiedDiff returns a unified diff of two texts line by line, or "" when
+// they are equal.
+func unifiedDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		text string
+		i, j int // Line indexes in x and y before this op
+	}
+
+	var ops []op
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, op{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', x[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+
+	out.WriteString("--- previous\n+++ current\n")
+
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are within
+		// twice the context of each other.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+
+		if first == len(ops) {
+			break
+		}
+
+		lo := max(first-diffContext, start)
+		hi := first
+
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				hi = k
+			} else if k-hi > 2*diffContext {
+				break
+			}
+		}
+
+		hi = min(hi+diffContext, len(ops)-1)
+
+		var oldLines, newLines int
+
+		for _, o := range ops[lo : hi+1] {
+			if o.kind != '+' {
+				oldLines++
+			}
+
+			if o.kind != '-' {
+				newLines++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[lo].i+1, oldLines, ops[lo].j+1, newLines)
+
+		for _, o := range ops[lo : hi+1] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			out.WriteByte('\n')
+		}
+
+		start = hi + 1
+	}
+
+	return out.String()
+}
+
+// logEmbedDiff logs how the embed differs from the last successful edit, and
+// returns its text for the caller to record in lastEmbedText once the edit
+// succeeds, so a failed edit is not diffed against.
+func (s *service) logEmbedDiff(embed *discordgo.MessageEmbed) string {
+	text := embedText(embed)
+
+	switch diff := unifiedDiff(s.lastEmbedText, text); {
+	case s.lastEmbedText == "":
+		s.log.Infof("Embed for first edit:\n%s", text)
+	case diff == "":
+		s.log.Info("Embed unchanged; editing anyway")
+	default:
+		s.log.Infof("Embed changed:\n%s", diff)
+	}
+
+	return text
+}
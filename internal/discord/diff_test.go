@@ -0,0 +1,174 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	require.Empty(t, unifiedDiff("a\nb\n", "a\nb\n"))
+
+	prev := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	curr := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n"
+
+	require.Equal(t, `--- previous
++++ current
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -9,2 +9,3 @@
+ 9
+ 10
++11
+`, unifiedDiff(prev, curr))
+}
+
+func TestEmbedTextSkipsTimestamp(t *testing.T) {
+	a := &discordgo.MessageEmbed{Title: "TS", Timestamp: "2024-01-01T00:00:00Z"}
+	b := &discordgo.MessageEmbed{Title: "TS", Timestamp: "2024-01-01T00:00:30Z"}
+
+	require.Equal(t, embedText(a), embedText(b))
+}
+
+func TestEmbedDiffAfterFailedEdit(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.ChannelID = "status"
+	svc.cfg.LogEmbedDiff = true
+	svc.messageID = "m"
+
+	state := &teamspeak.State{ServerName: "Game Night", MaxClients: 32, FetchedAt: time.Now()}
+
+	// A failed edit is not what the next diff compares against.
+	fake.handle("PATCH", "/channels/status/messages/m", func([]byte) (int, any) { return 500, nil })
+	_, err := svc.editMessage(t.Context(), state)
+	require.Error(t, err)
+	require.Empty(t, svc.lastEmbedText)
+
+	fake.handle("PATCH", "/channels/status/messages/m", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "m", "channel_id": "status"}
+	})
+	_, err = svc.editMessage(t.Context(), state)
+	require.NoError(t, err)
+	require.Contains(t, svc.lastEmbedText, "Game Night")
+}
+
+func TestAuditTextMasksVolatileParts(t *testing.T) {
+	sent := &discordgo.MessageEmbed{
+		Title:       "TS",
+		Description: "Updated <t:1700000000:R>",
+		Image:       &discordgo.MessageEmbedImage{URL: "attachment://chart.png"},
+	}
+	fetched := &discordgo.MessageEmbed{
+		Title:       "TS",
+		Description: "Updated <t:1700000042:R>",
+		Image:       &discordgo.MessageEmbedImage{URL: "https://cdn.discordapp.com/attachments/1/2/chart.png"},
+	}
+
+	require.Equal(t, auditText(sent), auditText(fetched))
+	require.NotNil(t, sent.Image)
+
+	fetched.Title = "Other"
+	require.NotEqual(t, auditText(sent), auditText(fetched))
+}
+
+func TestAudit(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.ChannelID = "status"
+
+	state := &teamspeak.State{
+		ServerName: "Game Night",
+		TotalUsers: 1,
+		MaxClients: 32,
+		FetchedAt:  time.Now(),
+		Channels:   []teamspeak.Channel{{ID: 1, Name: "Lobby", Users: []teamspeak.User{{ID: 1, Nickname: "alice"}}}},
+	}
+	embed := svc.buildPages(svc.mainState(state))[0]
+	message := func() map[string]any {
+		return map[string]any{"id": "m1", "channel_id": "status", "author": map[string]any{"id": "bot"}, "embeds": []any{embed}}
+	}
+
+	fake.handle("GET", "/channels/status", func([]byte) (int, any) { return 200, map[string]any{"id": "status", "type": 0} })
+	fake.handle("GET", "/channels/status/messages", func([]byte) (int, any) { return 200, []any{message()} })
+	fake.handle("GET", "/channels/status/messages/m1", func([]byte) (int, any) { return 200, message() })
+	fake.handle("PATCH", "/channels/status/messages/m1", func([]byte) (int, any) { return 200, message() })
+
+	ctx := t.Context()
+
+	report, err := svc.audit(ctx, state, false)
+	require.NoError(t, err)
+	require.Equal(t, "m1", report.MessageID)
+	require.True(t, report.Consistent(), report.Diff)
+
+	// A stale message is reported, and only rewritten with fix.
+	embed = &discordgo.MessageEmbed{
+		Title:       "Game Night",
+		Description: "Nobody online",
+		Author:      &discordgo.MessageEmbedAuthor{IconURL: statusIcon},
+	}
+
+	report, err = svc.audit(ctx, state, false)
+	require.NoError(t, err)
+	require.False(t, report.Consistent())
+	require.Contains(t, report.Diff, "-Nobody online")
+	require.Empty(t, fake.calls("PATCH", "/channels/status/messages/m1"))
+
+	report, err = svc.audit(ctx, state, true)
+	require.NoError(t, err)
+	require.True(t, report.Fixed)
+	require.Len(t, fake.calls("PATCH", "/channels/status/messages/m1"), 1)
+
+	// Without a message of the bot's, nothing is adopted.
+	svc.messageID = ""
+	fake.handle("GET", "/channels/status/messages", func([]byte) (int, any) {
+		return 200, []any{map[string]any{"id": "m2", "author": map[string]any{"id": "someone"}, "embeds": []any{embed}}}
+	})
+
+	report, err = svc.audit(ctx, state, false)
+	require.NoError(t, err)
+	require.Empty(t, report.MessageID)
+	require.Equal(t, []string{"no status message from the bot in the channel"}, report.Problems)
+	require.False(t, report.Consistent())
+}
+
+func TestWantExtraMessages(t *testing.T) {
+	servers := &teamspeak.State{Servers: []*teamspeak.State{{}, {}, {}}}
+
+	for _, tc := range []struct {
+		name    string
+		display DisplayConfig
+		state   *teamspeak.State
+		want    int
+	}{
+		{name: "one per further server", display: DisplayConfig{MessagePerServer: true}, state: servers, want: 2},
+		{name: "single server", display: DisplayConfig{MessagePerServer: true}, state: &teamspeak.State{}, want: 1},
+		{name: "pages", display: DisplayConfig{Paginate: true}, state: &teamspeak.State{}, want: 3},
+		{name: "neither", state: servers, want: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestService(tc.display)
+			svc.extraMessages = []string{"x"}
+
+			require.Equal(t, tc.want, svc.wantExtraMessages(tc.state, 3))
+		})
+	}
+}
+
+func TestAuditReportConsistent(t *testing.T) {
+	require.True(t, AuditReport{MessageID: "m1"}.Consistent())
+	require.False(t, AuditReport{}.Consistent())
+	require.False(t, AuditReport{MessageID: "m1", Problems: []string{"the message has no embed"}}.Consistent())
+	require.False(t, AuditReport{MessageID: "m1", Diff: "-a\n+b\n"}.Consistent())
+}
@@ -2,16 +2,23 @@
 package discord
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 
+	"github.com/samcm/ts-discord-status/internal/errreport"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/render"
 	"github.com/samcm/ts-discord-status/internal/teamspeak"
 )
 
@@ -23,22 +30,120 @@ const (
 	// maxOpensPerHour bounds gateway logins so a flapping network cannot exhaust
 	// Discord's daily IDENTIFY budget and trip its abuse protection.
 	maxOpensPerHour = 10
+
+	// maxSectionsLength is the share of Discord's 6000 character embed limit
+	// available to the channel lists of aggregated servers.
+	maxSectionsLength = 4500
+
+	// imageName is the attachment name of the embed image.
+	imageName = "online.png"
+)
+
+// Embed rendering styles.
+const (
+	// StyleDefault is the full desktop-oriented layout.
+	StyleDefault = "default"
+	// StyleMobile uses short lines, no code blocks, and fewer emojis so the
+	// embed does not wrap badly on phones.
+	StyleMobile = "mobile"
 )
 
 // Config holds Discord bot settings.
 type Config struct {
 	Token     string
 	ChannelID string
+	// LogEmbedDiff logs a unified diff of the embed text before each edit.
+	LogEmbedDiff bool
+	// ShowStateVersion puts the state version and hash in the footer, to
+	// match what the embed shows against the API and debug history.
+	ShowStateVersion bool
+	// SlashCommands registers the /ts command.
+	SlashCommands bool
+	// Targets are further channels, possibly in other guilds, that get a
+	// status message of their own.
+	Targets []Target
+	// WebhookURL publishes the status through a webhook instead of the bot;
+	// Token, ChannelID and Targets are then unused.
+	WebhookURL string
+	// WebhookMessageID is the webhook message to keep editing across
+	// restarts; empty posts a new one.
+	WebhookMessageID string
+	// DetailThread names a thread started under the status message that
+	// holds the detailed view, while the message defaults to the summary.
+	DetailThread string
+	// Forum sets up the status post when the status channel is a forum.
+	Forum Forum
+	// PublishAlerts publishes alerts posted in announcement channels to the
+	// servers following them.
+	PublishAlerts bool
 }
 
 // DisplayConfig holds display formatting options.
 type DisplayConfig struct {
-	ShowEmptyChannels bool
-	ServerAddress     string
-	ServerPassword    string
-	CustomFooter      string
-	ChannelNameFormat string // e.g., "TS: {online}/{max}"
-	ThumbnailURL      string // Optional thumbnail image URL
+	ShowEmptyChannels  bool
+	ChannelFilter      teamspeak.ChannelFilter // Channels left out of this message, on top of those hidden for every sink
+	ServerAddress      string
+	ServerPassword     string
+	Links              []Link // Shown as a "Links" stats field
+	CustomFooter       string
+	ChannelNameFormat  string            // e.g., "TS: {online}/{max}"
+	ChannelNameDryRun  bool              // Log the channel names ChannelNameFormat produces instead of renaming
+	ThumbnailURL       string            // Optional thumbnail image URL
+	JoinQR             bool              // Attach a QR code of the ts3server:// join link as the thumbnail, replacing ThumbnailURL
+	CompactLayout      bool              // Stack every field in a single column
+	InlineStats        bool              // Render stats fields side by side
+	StatsPerRow        int               // Inline stats fields per row (1-3)
+	Style              string            // StyleDefault or StyleMobile
+	StaleAfter         time.Duration     // Data age at which the embed shows a staleness warning (0 disables)
+	RelativeTime       bool              // Use live Discord timestamp markup instead of static durations
+	ShowConnectedTime  bool              // Append each user's session start to their line
+	ShowCountry        bool              // Show each user's country flag before their nickname
+	ShowChannelTopics  bool              // Show each channel's topic under its header
+	ShowTalkPower      bool              // ⭐ for priority speakers, 🎤 for users who may talk in moderated channels
+	ShowCodec          bool              // Append each channel's codec to its name, e.g. "(Opus Music)"
+	IconEmojis         map[uint32]string // TeamSpeak icon id -> emoji shown before the channel name
+	GroupBadges        map[int]string    // Server group id -> badge shown after member nicknames
+	Emojis             render.Emojis     // Status emojis for recording, deafened, muted, away and idle users; an empty one hides the state
+	ColorRules         []ColorRule       // Ordered embed color rules; the capacity default applies when none match
+	ShowLongestSession bool              // Add a stats field naming the user connected the longest
+	ShowNetwork        bool              // Add a stats field with bandwidth in/out and packet loss
+	ChannelNameReset   *ChannelNameReset // Base channel name while the server is empty overnight
+	VoiceMirror        *VoiceMirror      // Voice channel renamed to show occupancy, if any
+	VoiceStatus        *VoiceStatus      // Voice channel whose status shows occupancy, if any
+	PresenceTemplates  []string          // Bot status texts rotated every PresenceInterval, e.g. "{online} online"
+	PresenceInterval   time.Duration
+	StatusEmoji        StatusEmoji   // Emojis for the {status_emoji} channel name placeholder
+	EmptyState         EmptyState    // Shown instead of "No active channels" while nobody is online
+	Templates          Templates     // Replace the title, stats fields, channel headers and user lines
+	QuietAfter         time.Duration // Note "quiet since" once the server has been empty this long (0 disables)
+	ViewButtons        bool          // Buttons under the message switching between ViewSummary and ViewDetailed
+	DefaultView        string        // View shown when nobody picked one (default: ViewDetailed)
+	ViewRevertAfter    time.Duration // How long a picked view lasts
+	ChannelSelect      bool          // Select menu of occupied channels replying with their full user detail
+	MessagePerServer   bool          // Render each aggregated server into a message of its own
+	Paginate           bool          // Continue a channel list too long for one embed in further messages
+	ChannelFields      bool          // One field per occupied channel instead of a single "Channels" field
+	InlineChannels     bool          // Render channel fields side by side
+	WhatChanged        bool          // "What changed?" button replying with joins, leaves and moves since the viewer's last click
+	RefreshButton      bool          // "Refresh" button polling TeamSpeak and re-rendering the embed right away
+	RefreshCooldown    time.Duration // How long each user waits between refreshes
+	NicknameFormat     string        // Bot nickname in the status channel's guild, e.g. "TS {online}/{max}"
+	NicknameInterval   time.Duration // Minimum time between nickname changes
+}
+
+// StatusEmoji are the health indicators for the {status_emoji} placeholder.
+type StatusEmoji struct {
+	Online       string
+	Busy         string
+	Offline      string
+	BusyCapacity float64 // Fraction of slots used from which the server counts as busy
+}
+
+// ChannelNameReset renames the channel to a fixed base name while the server
+// is empty during a daily window, saving renames on quiet nights.
+type ChannelNameReset struct {
+	Name   string
+	Window ClockRange
 }
 
 // Service defines the Discord service interface.
@@ -46,6 +151,27 @@ type Service interface {
 	Start(ctx context.Context) error
 	Stop() error
 	UpdateStatus(ctx context.Context, state *teamspeak.State) error
+	// SetImage replaces the PNG shown as the embed image on the next update;
+	// nil removes it.
+	SetImage(data []byte)
+	// UploadIconEmoji makes a TeamSpeak channel icon available as an
+	// application emoji shown in front of the channels using it.
+	UploadIconEmoji(ctx context.Context, iconID uint32, fetch func() ([]byte, error)) error
+	// SetAnnouncement shows text at the top of the embed until the given
+	// time; an empty text clears it.
+	SetAnnouncement(text string, until time.Time)
+	// SetForecast sets the busy hours forecast: the footer hint (empty
+	// removes it) and the window the empty state refers to.
+	SetForecast(f Forecast)
+	// SetCommands sets the handler slash commands are routed to.
+	SetCommands(c Commands)
+	// Notify posts a plain message to a channel other than the status
+	// channel, e.g. a staff channel. Mentions in content only ping the
+	// given roles.
+	Notify(ctx context.Context, channelID, content string, roles ...string) error
+	// DirectMessage sends a plain message to a user's DMs. Mentions in
+	// content ping no one.
+	DirectMessage(ctx context.Context, userID, content string) error
 }
 
 type service struct {
@@ -55,29 +181,129 @@ type service struct {
 	session           *discordgo.Session
 	messageID         string
 	mu                sync.Mutex
-	lastUserCount     int       // Track to avoid unnecessary renames
-	lastChannelRename time.Time // Rate limit channel renames
-
+	lastChannelName   string                      // Track to avoid unnecessary renames
+	lastChannelRename time.Time                   // Rate limit channel renames
+	image             []byte                      // PNG attached as the embed image
+	imageDirty        bool                        // image or joinQR changed since the last successful edit
+	joinQR            []byte                      // PNG of the join link QR code, attached as the thumbnail
+	joinURI           string                      // Join link joinQR encodes
+	iconEmojis        map[uint32]string           // Uploaded emoji markup by TeamSpeak icon id
+	appEmojis         map[string]*discordgo.Emoji // Application emojis by name, loaded lazily
+	announcement      string                      // Line shown above the stats
+	announcementUntil time.Time                   // When the announcement expires
+	forecast          Forecast                    // Busy hours hint in the footer and window for the empty state
+	commands          Commands                    // Slash command handler, set by the bridge
+	lastHash          string                      // messageHash of the last successful edit
+	lastEmbedText     string                      // embedText of the last edit, for LogEmbedDiff
+	lastVerified      time.Time                   // When the message was last fetched back
+	presenceIndex     int                         // Current presence template
+	presenceRotated   time.Time                   // When presenceIndex last changed
+	lastPresence      string                      // Presence text last sent
+	lastPresenceSent  time.Time
+	peakDay           string                    // Local date peakToday belongs to
+	peakToday         int                       // Highest user count seen today
+	emptySince        time.Time                 // When the server was last seen becoming empty
+	lastState         *teamspeak.State          // Last rendered state, re-rendered on view changes
+	view              string                    // View picked with the buttons ("" is the default)
+	viewUntil         time.Time                 // When view reverts to the default
+	refreshedBy       map[string]time.Time      // When each user last clicked Refresh, within the cooldown
+	lastRefresh       time.Time                 // When any user last refreshed
+	lastEdited        time.Time                 // Edit time of our last edit of the status message
+	seenEdit          time.Time                 // Latest edit time of the status message seen on the gateway
+	conflict          bool                      // Another instance is driving the message
+	extraMessages     []string                  // Messages after the main one: later servers with MessagePerServer, or pages with Paginate
+	pages             []*discordgo.MessageEmbed // Continuation pages rendered with the last edit, for extraMessages
+	clock             *skewClock                // Host clock offset from Discord's, for embed times
+	guildID           string                    // Guild of the status channel, looked up lazily
+	guildLookupFailed bool                      // The last guild lookup failed
+	nicknames         map[string]sentNickname   // Bot nickname last set, by guild id
+	targets           []*service                // Further status channels sharing the session
+	detail            *service                  // Detailed view in a thread under the status message, if any
+	post              string                    // Forum post holding the status message, when the channel is a forum
+	forumTags         map[string]string         // Forum tag ids by lowercased name
+	lastLimitWarnings string                    // Trims of the last render, see fit
+	mirrorName        string                    // Current name of the voice mirror channel
+	mirrorRenamed     time.Time                 // When the voice mirror was last renamed
+	voiceStatus       string                    // Voice channel status last set
+	voiceStatusSet    time.Time                 // When the voice channel status last changed or failed to
+	voiceStatusWait   time.Duration             // Wait after voiceStatusSet, doubled on each failure
+	lastPostTitle     string
+	lastPostTags      string // Comma-separated applied tag ids
+	lastPostRename    time.Time
+	channelTypes      map[string]discordgo.ChannelType // Types of the alert channels, looked up on their first alert
+	published         map[string][]time.Time           // Publish times per alert channel within publishWindow
+
+	templateMu   sync.Mutex        // Guards templateErrs, as rendering may run without s.mu
+	templateErrs map[string]string // Last error warned about, by template name
+
+	lifecycle    sync.Mutex // Serializes Start and Stop
 	done         chan struct{}
 	wg           sync.WaitGroup
 	closing      atomic.Bool
+	diagnosed    atomic.Bool // Diagnostics were logged
+	registered   atomic.Bool // Slash commands were registered
 	reconnecting atomic.Bool
 	reconnectMu  sync.Mutex
 	openTimes    []time.Time
+	backoff      time.Duration // First reconnect delay, reconnectBaseDelay outside tests
+	maxBackoff   time.Duration // Longest reconnect delay, reconnectMaxDelay outside tests
 }
 
 // NewService creates a new Discord service.
 func NewService(log logrus.FieldLogger, cfg Config, display DisplayConfig) Service {
+	log = log.WithField("component", "discord")
+
+	s := newService(log, cfg, display, newSkewClock(log))
+	if cfg.WebhookURL != "" {
+		return newWebhookService(s)
+	}
+
+	s.targets = newTargets(s)
+
+	if s.detail = newDetailThread(s); s.detail != nil {
+		s.display.DefaultView = ViewSummary
+	}
+
+	return s
+}
+
+func newService(log logrus.FieldLogger, cfg Config, display DisplayConfig, clock *skewClock) *service {
 	return &service{
-		log:     log.WithField("component", "discord"),
-		cfg:     cfg,
-		display: display,
-		done:    make(chan struct{}),
+		log:          log,
+		cfg:          cfg,
+		display:      display,
+		done:         make(chan struct{}),
+		iconEmojis:   make(map[uint32]string),
+		nicknames:    make(map[string]sentNickname),
+		refreshedBy:  make(map[string]time.Time),
+		channelTypes: make(map[string]discordgo.ChannelType),
+		published:    make(map[string][]time.Time),
+		clock:        clock,
+		backoff:      reconnectBaseDelay,
+		maxBackoff:   reconnectMaxDelay,
 	}
 }
 
-// Start connects to Discord and finds or creates the status message.
+// Start connects to Discord and finds or creates the status message. Starting
+// a running service does nothing; a stopped one starts over.
 func (s *service) Start(ctx context.Context) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	s.mu.Lock()
+	running := s.session != nil
+	s.mu.Unlock()
+
+	if running {
+		return nil
+	}
+
+	// Stop closed done and ended every goroutine reading it.
+	if s.closing.Load() {
+		s.done = make(chan struct{})
+		s.closing.Store(false)
+	}
+
 	session, err := discordgo.New("Bot " + s.cfg.Token)
 	if err != nil {
 		return fmt.Errorf("failed to create Discord session: %w", err)
@@ -88,11 +314,19 @@ func (s *service) Start(ctx context.Context) error {
 	// disabled. Drive reconnects ourselves with bounded backoff instead.
 	session.ShouldReconnectOnError = false
 
+	// Every API response carries Discord's Date header; measure the host
+	// clock against it so embed times stay right on hosts without NTP.
+	session.Client.Transport = s.clock.transport(session.Client.Transport)
+
 	s.mu.Lock()
 	s.session = session
 	s.mu.Unlock()
 
+	s.setTargetSession(session)
+
 	s.registerReconnectHandler()
+	s.registerInteractionHandler()
+	s.registerConflictHandler()
 
 	// A Discord outage (or a disabled token) must never crash the process: the
 	// container would just hot-restart and turn each restart into a fresh login,
@@ -116,12 +350,28 @@ func (s *service) connect() error {
 
 	s.log.Info("Connected to Discord")
 
+	// A new gateway session starts without a presence.
+	s.mu.Lock()
+	s.lastPresence = ""
+	s.mu.Unlock()
+
 	if err := s.ensureMessage(); err != nil {
 		s.session.Close()
 
 		return fmt.Errorf("failed to find or create status message: %w", err)
 	}
 
+	s.diagnoseOnce()
+
+	// Commands are a convenience; the status embed works without them.
+	if s.cfg.SlashCommands {
+		if err := s.registerCommands(); err != nil {
+			s.log.WithError(err).Warn("Failed to register slash commands")
+		}
+	}
+
+	s.connectTargets()
+
 	return nil
 }
 
@@ -136,26 +386,40 @@ func (s *service) ensureMessage() error {
 
 // findOrCreateMessage searches for an existing message from this bot or creates a new one.
 func (s *service) findOrCreateMessage() error {
-	messages, err := s.session.ChannelMessages(s.cfg.ChannelID, 50, "", "", "")
+	ch, err := s.session.Channel(s.cfg.ChannelID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch channel messages: %w", err)
+		return fmt.Errorf("failed to look up status channel: %w", err)
+	}
+
+	if err := checkStatusChannel(ch); err != nil {
+		return err
 	}
 
-	botID := s.session.State.User.ID
+	if ch.Type == discordgo.ChannelTypeGuildNews && s.messageID == "" {
+		s.log.Info("Status channel is an announcement channel; the status message is edited in place and not published to followers")
+	}
 
-	// Look for our own message
-	for _, msg := range messages {
-		if msg.Author.ID == botID && len(msg.Embeds) > 0 {
-			s.messageID = msg.ID
-			s.log.WithField("message_id", s.messageID).Info("Found existing status message")
+	forum, err := s.findOrCreatePost(ch)
+	if err != nil {
+		return fmt.Errorf("failed to find or create forum post: %w", err)
+	}
 
-			return nil
-		}
+	if forum {
+		return nil
+	}
+
+	messages, err := s.session.ChannelMessages(s.channel(), 50, "", "", "")
+	if err != nil {
+		return fmt.Errorf("failed to fetch channel messages: %w", err)
+	}
+
+	if s.adoptOwnMessage(messages, s.session.State.User.ID) {
+		return nil
 	}
 
 	// Create new message with placeholder
 	embed := s.buildEmbed(nil)
-	msg, err := s.session.ChannelMessageSendEmbed(s.cfg.ChannelID, embed)
+	msg, err := s.session.ChannelMessageSendEmbed(s.channel(), embed)
 	if err != nil {
 		return fmt.Errorf("failed to create status message: %w", err)
 	}
@@ -166,9 +430,40 @@ func (s *service) findOrCreateMessage() error {
 	return nil
 }
 
-// Stop disconnects from Discord.
+// adoptOwnMessage picks up this bot's status message, and the extra messages
+// when the display has them, from the channel's recent messages. It returns
+// false when there is none. Must be called with s.mu held.
+func (s *service) adoptOwnMessage(messages []*discordgo.Message, botID string) bool {
+	if (s.display.MessagePerServer || s.display.Paginate) && s.adoptMessages(messages, botID) {
+		s.log.WithFields(logrus.Fields{
+			"message_id":     s.messageID,
+			"extra_messages": len(s.extraMessages),
+		}).Info("Found existing status messages")
+
+		return true
+	}
+
+	for _, msg := range messages {
+		if isStatusMessage(msg, botID) {
+			s.messageID = msg.ID
+			s.log.WithField("message_id", s.messageID).Info("Found existing status message")
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Stop disconnects from Discord. Stopping a stopped service does nothing.
 func (s *service) Stop() error {
-	s.closing.Store(true)
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if !s.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+
 	close(s.done)
 	s.wg.Wait()
 
@@ -181,6 +476,8 @@ func (s *service) Stop() error {
 		s.log.Info("Disconnected from Discord")
 	}
 
+	s.setTargetSession(nil)
+
 	return nil
 }
 
@@ -191,6 +488,12 @@ func (s *service) registerReconnectHandler() {
 	s.session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
 		s.startReconnect()
 	})
+
+	// discordgo waits out rate limits itself; count them so sustained
+	// throttling is visible.
+	s.session.AddHandler(func(_ *discordgo.Session, _ *discordgo.RateLimit) {
+		metrics.Error(metrics.ErrorDiscordRateLimit)
+	})
 }
 
 // startReconnect launches the backoff reconnect loop unless one is already
@@ -214,10 +517,11 @@ func (s *service) startReconnect() {
 func (s *service) reconnectLoop() {
 	defer s.wg.Done()
 	defer s.reconnecting.Store(false)
+	defer errreport.Recover()
 
 	s.log.Warn("Discord gateway disconnected, reconnecting with backoff")
 
-	delay := reconnectBaseDelay
+	delay := s.backoff
 
 	for {
 		select {
@@ -235,16 +539,14 @@ func (s *service) reconnectLoop() {
 		}
 
 		if err := s.connect(); err != nil {
-			delay *= 2
-			if delay > reconnectMaxDelay {
-				delay = reconnectMaxDelay
-			}
+			delay = min(delay*2, s.maxBackoff)
 
 			s.log.WithError(err).WithField("retry_in", delay).Warn("Discord reconnect attempt failed")
 
 			continue
 		}
 
+		metrics.Reconnect(metrics.ReconnectDiscord)
 		s.log.Info("Reconnected to Discord gateway")
 
 		return
@@ -295,8 +597,19 @@ func (s *service) recordOpen() {
 	s.openTimes = append(s.openTimes, time.Now())
 }
 
-// UpdateStatus updates the Discord message with the current TeamSpeak state.
+// UpdateStatus updates the status messages with the current TeamSpeak state.
+// Only failures of the main channel are returned; those of further targets
+// are logged.
 func (s *service) UpdateStatus(ctx context.Context, state *teamspeak.State) error {
+	err := s.updateStatus(ctx, state)
+
+	s.updateTargets(ctx, state)
+
+	return err
+}
+
+// updateStatus updates this service's status message.
+func (s *service) updateStatus(ctx context.Context, state *teamspeak.State) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -304,43 +617,322 @@ func (s *service) UpdateStatus(ctx context.Context, state *teamspeak.State) erro
 		return fmt.Errorf("not connected to Discord")
 	}
 
-	embed := s.buildEmbed(state)
+	if state != nil {
+		state = s.display.ChannelFilter.Apply(state)
+	}
+
+	// A target whose message could not be set up on connect retries here.
+	if s.messageID == "" {
+		if err := s.findOrCreateMessage(); err != nil {
+			return fmt.Errorf("failed to find or create status message: %w", err)
+		}
+	}
+
+	if s.checkConflict(time.Now()) {
+		return ErrConflict
+	}
+
+	// Other bots or admins may have deleted or altered the message.
+	if time.Since(s.lastVerified) >= verifyInterval {
+		if err := s.verifyMessage(ctx); err != nil {
+			s.log.WithError(err).Warn("Failed to verify status message")
+		}
+	}
+
+	if state != nil {
+		s.trackQuiet(state, time.Now())
+		s.lastState = state
+	}
+
+	s.expireView(time.Now())
+
+	msg, err := s.editMessage(ctx, state)
+	if isArchivedThread(err) {
+		if err := s.unarchive(ctx); err != nil {
+			return err
+		}
+
+		msg, err = s.editMessage(ctx, state)
+	}
+
+	if isUnknownMessage(err) {
+		s.log.Warn("Status message is gone; reposting")
+
+		if err := s.repost(ctx); err != nil {
+			return err
+		}
+
+		msg, err = s.editMessage(ctx, state)
+	}
 
-	_, err := s.session.ChannelMessageEditEmbed(s.cfg.ChannelID, s.messageID, embed)
 	if err != nil {
+		recordEditError(err)
+
+		// A deleted forum post is started again with the next update.
+		if s.post != "" && isUnknownChannel(err) {
+			s.post, s.messageID = "", ""
+		}
+
 		return fmt.Errorf("failed to update status message: %w", err)
 	}
 
+	s.lastHash = messageHash(msg)
+	s.imageDirty = false
+	s.recordEdit(msg)
+
+	s.updateServerMessages(ctx, state)
+	s.updatePages(ctx, state)
+	s.updateDetail(ctx, state)
+	s.maybeUpdatePost(ctx, state)
+
 	// Update channel name if configured and conditions are met
 	if s.display.ChannelNameFormat != "" && state != nil {
 		s.maybeUpdateChannelName(state)
 	}
 
+	s.maybeUpdateVoiceMirror(ctx, state, time.Now())
+	s.maybeUpdateVoiceStatus(ctx, state, time.Now())
+
+	if len(s.display.PresenceTemplates) > 0 && state != nil {
+		s.maybeUpdatePresence(state, time.Now())
+	}
+
+	if s.display.NicknameFormat != "" && state != nil {
+		s.maybeUpdateNickname(state, time.Now())
+	}
+
+	return nil
+}
+
+// recordEditError counts a failed status message edit.
+func recordEditError(err error) {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusTooManyRequests {
+		metrics.Error(metrics.ErrorDiscordRateLimit)
+	} else {
+		metrics.Error(metrics.ErrorDiscordEdit)
+	}
+}
+
+// editMessage renders the state into the status message, abandoning the
+// request when ctx is canceled. Must be called with s.mu held.
+func (s *service) editMessage(ctx context.Context, state *teamspeak.State) (*discordgo.Message, error) {
+	pages := s.buildPages(s.mainState(state))
+	embed := pages[0]
+	s.pages = pages[1:]
+
+	var text string
+	if s.cfg.LogEmbedDiff {
+		text = s.logEmbedDiff(embed)
+	}
+
+	edit := discordgo.NewMessageEdit(s.channel(), s.messageID)
+	edit.Attachments, edit.Files = s.attach(embed, state)
+	edit.Embeds = &[]*discordgo.MessageEmbed{embed}
+
+	if s.display.ViewButtons || s.display.ChannelSelect || s.display.WhatChanged || s.display.RefreshButton {
+		components := []discordgo.MessageComponent{}
+		if state != nil {
+			components = s.messageComponents(state)
+		}
+
+		edit.Components = &components
+	}
+
+	msg, err := s.session.ChannelMessageEditComplex(edit, discordgo.WithContext(ctx))
+	if err == nil && s.cfg.LogEmbedDiff {
+		s.lastEmbedText = text
+	}
+
+	return msg, err
+}
+
+// attach points the embed at the image and join QR code attachments, and
+// returns the attachments to keep and the files to upload with the edit:
+// nothing unless they changed. Later edits keep referencing the uploaded
+// attachments by name; a new upload replaces the previous ones rather than
+// accumulating files. Must be called with s.mu held.
+func (s *service) attach(embed *discordgo.MessageEmbed, state *teamspeak.State) (*[]*discordgo.MessageAttachment, []*discordgo.File) {
+	s.refreshJoinQR(s.mainState(state))
+
+	if s.image != nil && state != nil {
+		embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + imageName}
+	}
+
+	if s.joinQR != nil && state != nil {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: "attachment://" + joinQRName}
+	}
+
+	if !s.imageDirty {
+		return nil, nil
+	}
+
+	var files []*discordgo.File
+
+	if s.image != nil {
+		files = append(files, &discordgo.File{
+			Name:        imageName,
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(s.image),
+		})
+	}
+
+	if s.joinQR != nil {
+		files = append(files, &discordgo.File{
+			Name:        joinQRName,
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(s.joinQR),
+		})
+	}
+
+	return &[]*discordgo.MessageAttachment{}, files
+}
+
+// Notify posts content to channelID.
+func (s *service) Notify(ctx context.Context, channelID, content string, roles ...string) error {
+	s.mu.Lock()
+	session := s.session
+	s.mu.Unlock()
+
+	if session == nil {
+		return fmt.Errorf("not connected to Discord")
+	}
+
+	// A failed lookup does not hold back the alert; sending reports the error.
+	if typ, err := s.alertChannelType(ctx, session, channelID); err == nil && typ == discordgo.ChannelTypeGuildForum {
+		return fmt.Errorf("channel %s is a forum, which cannot hold alerts; send them to a text channel", channelID)
+	}
+
+	msg, err := session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowedMentions(roles),
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+
+	s.publishAsync(ctx, session, channelID, msg)
+
+	return nil
+}
+
+// DirectMessage opens (or reuses) the DM channel with a user and posts to it.
+func (s *service) DirectMessage(ctx context.Context, userID, content string) error {
+	s.mu.Lock()
+	session := s.session
+	s.mu.Unlock()
+
+	if session == nil {
+		return fmt.Errorf("not connected to Discord")
+	}
+
+	ch, err := session.UserChannelCreate(userID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to open DM channel: %w", err)
+	}
+
+	if _, err := session.ChannelMessageSendComplex(ch.ID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowedMentions(nil),
+	}, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to send direct message: %w", err)
+	}
+
 	return nil
 }
 
+// allowedMentions lets a message ping only the given roles. Content carries
+// nicknames and channel names from TeamSpeak, where anyone can call
+// themselves @everyone.
+func allowedMentions(roles []string) *discordgo.MessageAllowedMentions {
+	return &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}, Roles: roles}
+}
+
+// SetImage replaces the embed image uploaded with the next status update.
+func (s *service) SetImage(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.image = data
+	s.imageDirty = true
+
+	for _, t := range s.targets {
+		t.SetImage(data)
+	}
+}
+
+// SetAnnouncement sets the announcement line rendered with the next update.
+func (s *service) SetAnnouncement(text string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.announcement = text
+	s.announcementUntil = until
+
+	for _, t := range s.targets {
+		t.SetAnnouncement(text, until)
+	}
+}
+
+// SetForecast sets the forecast rendered with the next update.
+func (s *service) SetForecast(f Forecast) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.forecast = f
+
+	for _, t := range s.targets {
+		t.SetForecast(f)
+	}
+}
+
+// activeAnnouncement returns the announcement if it has not expired yet.
+func (s *service) activeAnnouncement(now time.Time) string {
+	if s.announcement == "" || !now.Before(s.announcementUntil) {
+		return ""
+	}
+
+	return s.announcement
+}
+
+// channelRenameInterval is the minimum time between channel renames; Discord
+// allows two per ten minutes.
+const channelRenameInterval = 5 * time.Minute
+
 // maybeUpdateChannelName updates the channel name if user count changed and rate limit allows.
 func (s *service) maybeUpdateChannelName(state *teamspeak.State) {
-	// Only rename if user count changed
-	if state.TotalUsers == s.lastUserCount {
+	newName := s.channelName(state, time.Now())
+
+	// Start from the channel's actual name so a restart does not rename
+	if s.lastChannelName == "" {
+		if ch, err := s.session.Channel(s.cfg.ChannelID); err == nil {
+			s.lastChannelName = ch.Name
+		}
+	}
+
+	// Only rename if the name changed
+	if newName == s.lastChannelName {
 		return
 	}
 
-	// Rate limit: minimum 5 minutes between renames (Discord allows 2 per 10 min)
-	if time.Since(s.lastChannelRename) < 5*time.Minute {
+	// Renames are limited to two per ten minutes; a dry run shows what a
+	// format produces without spending them.
+	if s.display.ChannelNameDryRun {
+		s.log.WithFields(logrus.Fields{"name": newName, "previous": s.lastChannelName}).Info("Dry run: would rename channel")
+		s.lastChannelName = newName
+
+		return
+	}
+
+	// Rate limit
+	if time.Since(s.lastChannelRename) < channelRenameInterval {
 		s.log.WithFields(logrus.Fields{
 			"last_rename":  s.lastChannelRename,
-			"next_allowed": s.lastChannelRename.Add(5 * time.Minute),
+			"next_allowed": s.lastChannelRename.Add(channelRenameInterval),
 		}).Debug("Skipping channel rename due to rate limit")
 		return
 	}
 
-	// Build new channel name from format
-	newName := s.display.ChannelNameFormat
-	newName = strings.ReplaceAll(newName, "{online}", fmt.Sprintf("%d", state.TotalUsers))
-	newName = strings.ReplaceAll(newName, "{max}", fmt.Sprintf("%d", state.MaxClients))
-	newName = strings.ReplaceAll(newName, "{server}", state.ServerName)
-
 	// Update the channel
 	_, err := s.session.ChannelEdit(s.cfg.ChannelID, &discordgo.ChannelEdit{
 		Name: newName,
@@ -350,30 +942,86 @@ func (s *service) maybeUpdateChannelName(state *teamspeak.State) {
 		return
 	}
 
-	s.lastUserCount = state.TotalUsers
+	s.lastChannelName = newName
 	s.lastChannelRename = time.Now()
 	s.log.WithField("name", newName).Info("Updated channel name")
 }
 
-// buildEmbed creates a Discord embed from the TeamSpeak state.
+// channelName returns the channel name for the state: the base name while the
+// server is empty during the reset window, the formatted name otherwise.
+func (s *service) channelName(state *teamspeak.State, now time.Time) string {
+	if reset := s.display.ChannelNameReset; reset != nil && state.TotalUsers == 0 && reset.Window.contains(now) {
+		return reset.Name
+	}
+
+	return s.formatName(s.display.ChannelNameFormat, state, now)
+}
+
+// formatName fills the placeholders shared by the channel name and the bot
+// nickname.
+func (s *service) formatName(format string, state *teamspeak.State, now time.Time) string {
+	name := strings.ReplaceAll(format, "{online}", fmt.Sprintf("%d", state.TotalUsers))
+	name = strings.ReplaceAll(name, "{max}", fmt.Sprintf("%d", state.MaxClients))
+	name = strings.ReplaceAll(name, "{status_emoji}", s.statusEmoji(state, now))
+
+	return strings.ReplaceAll(name, "{server}", state.ServerName)
+}
+
+// statusEmoji summarises server health for the channel name: offline while
+// the data is stale, busy when nearly full or when one of several servers is
+// not responding, online otherwise.
+func (s *service) statusEmoji(state *teamspeak.State, now time.Time) string {
+	emoji := s.display.StatusEmoji
+
+	if s.isStale(state, now) {
+		return emoji.Offline
+	}
+
+	for _, sv := range state.Servers {
+		if s.isStale(sv, now) {
+			return emoji.Busy
+		}
+	}
+
+	if state.MaxClients > 0 && capacityUsed(state) >= emoji.BusyCapacity {
+		return emoji.Busy
+	}
+
+	return emoji.Online
+}
+
+// buildEmbed creates a Discord embed from the TeamSpeak state, within
+// Discord's limits.
 func (s *service) buildEmbed(state *teamspeak.State) *discordgo.MessageEmbed {
+	return s.fit(s.renderEmbed(state))
+}
+
+// renderEmbed lays out the embed for the state.
+func (s *service) renderEmbed(state *teamspeak.State) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{
 		Color:     0x2B5B84, // TeamSpeak blue
-		Timestamp: time.Now().Format(time.RFC3339),
+		Timestamp: s.clock.correct(time.Now()).Format(time.RFC3339),
 		Author: &discordgo.MessageEmbedAuthor{
 			Name:    "TeamSpeak Server",
-			IconURL: "https://i.imgur.com/pK2qRkC.png", // TS3 icon
+			IconURL: statusIcon,
 		},
 	}
 
 	if state == nil {
 		embed.Description = "```\n⏳ Connecting to server...\n```"
+		if s.mobile() {
+			embed.Description = "Connecting to server..."
+		}
+
 		embed.Color = 0xFAA61A // Orange - connecting
 		return embed
 	}
 
 	// Server name as title
 	embed.Title = state.ServerName
+	if title, ok := s.execTemplate(s.display.Templates.Title, TemplateData{State: state}); ok && title != "" {
+		embed.Title = title
+	}
 
 	// Optional thumbnail
 	if s.display.ThumbnailURL != "" {
@@ -382,58 +1030,90 @@ func (s *service) buildEmbed(state *teamspeak.State) *discordgo.MessageEmbed {
 		}
 	}
 
-	// Dynamic color based on capacity
-	capacityPercent := float64(state.TotalUsers) / float64(state.MaxClients)
-	switch {
-	case state.TotalUsers == 0:
-		embed.Color = 0x95A5A6 // Gray - empty
-	case capacityPercent >= 0.8:
-		embed.Color = 0xE74C3C // Red - almost full
-	case capacityPercent >= 0.5:
-		embed.Color = 0xF39C12 // Orange - busy
-	default:
-		embed.Color = 0x2ECC71 // Green - available
-	}
+	// Color from the configured rules, or by capacity
+	now := time.Now()
+	embed.Color = s.embedColor(colorInput{
+		state:        state,
+		stale:        s.isStale(state, now) || !state.BannedUntil.IsZero(),
+		announcement: s.activeAnnouncement(now) != "",
+		now:          now,
+	})
 
-	var fields []*discordgo.MessageEmbedField
+	var stats []*discordgo.MessageEmbedField
 
-	// Stats row (inline fields)
-	fields = append(fields, &discordgo.MessageEmbedField{
-		Name:   "👥 Online",
-		Value:  fmt.Sprintf("**%d** / %d", state.TotalUsers, state.MaxClients),
-		Inline: true,
+	// Stats row
+	stats = append(stats, &discordgo.MessageEmbedField{
+		Name:  s.label("👥", "Online"),
+		Value: fmt.Sprintf("**%d** / %d", state.TotalUsers, state.MaxClients),
 	})
 
-	fields = append(fields, &discordgo.MessageEmbedField{
-		Name:   "⏱️ Uptime",
-		Value:  formatDuration(state.Uptime),
-		Inline: true,
-	})
+	// Aggregated servers each have their own uptime, and some sources do not
+	// report one at all.
+	if state.Uptime > 0 {
+		stats = append(stats, &discordgo.MessageEmbedField{
+			Name:  s.label("⏱️", "Uptime"),
+			Value: s.formatUptime(state),
+		})
+	}
+
+	if s.display.ShowLongestSession {
+		if user, ok := longestSession(state); ok {
+			stats = append(stats, &discordgo.MessageEmbedField{
+				Name: s.label("🏆", "Longest session"),
+				Value: fmt.Sprintf("%s (%s)", truncateRunes(user.Nickname, 64),
+					formatDuration(dataTime(state).Sub(user.ConnectedAt))),
+			})
+		}
+	}
+
+	if s.display.ShowNetwork && state.Network != nil {
+		stats = append(stats, &discordgo.MessageEmbedField{
+			Name:  s.label("📊", "Network"),
+			Value: formatNetwork(state.Network),
+		})
+	}
 
-	// Connection info (if configured)
-	if s.display.ServerAddress != "" {
-		connectValue := fmt.Sprintf("`%s`", s.display.ServerAddress)
+	// Connection info, from the config or else as reported by the server
+	address := s.display.ServerAddress
+	if address == "" {
+		address = state.Address
+	}
+
+	if address != "" {
+		connectValue := fmt.Sprintf("`%s`", address)
 		if s.display.ServerPassword != "" {
 			connectValue += fmt.Sprintf("\nPass: `%s`", s.display.ServerPassword)
 		}
 
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "🔗 Connect",
-			Value:  connectValue,
-			Inline: true,
+		stats = append(stats, &discordgo.MessageEmbedField{
+			Name:  s.label("🔗", "Connect"),
+			Value: connectValue,
 		})
 	}
 
-	// Build channel list with better formatting
-	channelContent := s.buildChannelList(state)
-	if channelContent != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "📢 Channels",
-			Value:  channelContent,
-			Inline: false,
+	if len(s.display.Links) > 0 {
+		stats = append(stats, &discordgo.MessageEmbedField{
+			Name:  s.label("🌐", "Links"),
+			Value: formatLinks(s.display.Links),
 		})
 	}
 
+	if templated, ok := s.templateFields(state); ok {
+		stats = templated
+	}
+
+	fields := s.layoutStats(stats)
+
+	if len(state.Servers) > 0 {
+		fields = append(fields, s.serverSections(state)...)
+	} else {
+		if s.display.ChannelFields && s.display.InlineChannels {
+			fields = endRow(fields)
+		}
+
+		fields = append(fields, s.channelFields(state, maxEmbedFields-len(fields))...)
+	}
+
 	embed.Fields = fields
 
 	// Clean footer
@@ -442,6 +1122,53 @@ func (s *service) buildEmbed(state *teamspeak.State) *discordgo.MessageEmbed {
 		footerText = s.display.CustomFooter
 	}
 
+	// The embed timestamp is the data time; when the data is stale, say so up
+	// front and make the render time explicit so the two are not confused.
+	if !state.FetchedAt.IsZero() {
+		fetched := s.clock.correct(state.FetchedAt)
+		embed.Timestamp = fetched.Format(time.RFC3339)
+
+		// Relative timestamp markup is rendered by the Discord client, so the
+		// age keeps counting up between edits and needs no extra API calls.
+		if s.display.RelativeTime {
+			embed.Description = "Updated " + relativeTimestamp(fetched)
+		}
+
+		if age := time.Since(state.FetchedAt); s.isStale(state, now) {
+			embed.Description = fmt.Sprintf("⚠️ Data is %s old (from %s) — TeamSpeak is not responding",
+				formatDuration(age), relativeTimestamp(fetched))
+			footerText = fmt.Sprintf("Rendered %s · data from", s.clock.correct(time.Now()).Format("15:04"))
+			if s.display.CustomFooter != "" {
+				footerText = s.display.CustomFooter + " · " + footerText
+			}
+		}
+	}
+
+	if !state.BannedUntil.IsZero() {
+		embed.Description = fmt.Sprintf("⛔ Bot temporarily banned from ServerQuery — retrying %s", relativeTimestamp(state.BannedUntil))
+	}
+
+	if note := s.quietNote(state, now); note != "" {
+		embed.Description = strings.TrimSuffix(note+"\n"+embed.Description, "\n")
+	}
+
+	if text := s.activeAnnouncement(time.Now()); text != "" {
+		embed.Description = strings.TrimSuffix("📢 **"+text+"**\n"+embed.Description, "\n")
+	}
+
+	if s.forecast.Hint != "" {
+		footerText = s.forecast.Hint + " · " + footerText
+	}
+
+	// Tells apart bridges whose servers share a name.
+	if state.Label != "" && state.Label != state.ServerName {
+		footerText = state.Label + " · " + footerText
+	}
+
+	if s.cfg.ShowStateVersion && state.Version > 0 {
+		footerText = fmt.Sprintf("v%d #%s · %s", state.Version, state.Hash, footerText)
+	}
+
 	embed.Footer = &discordgo.MessageEmbedFooter{
 		Text: footerText,
 	}
@@ -449,78 +1176,438 @@ func (s *service) buildEmbed(state *teamspeak.State) *discordgo.MessageEmbed {
 	return embed
 }
 
-// buildChannelList formats the channel and user list.
-func (s *service) buildChannelList(state *teamspeak.State) string {
-	var content strings.Builder
+// trackQuiet records when the server became empty. Stale data says nothing
+// about activity, so it is ignored.
+func (s *service) trackQuiet(state *teamspeak.State, now time.Time) {
+	switch {
+	case s.isStale(state, now):
+	case state.TotalUsers > 0:
+		s.emptySince = time.Time{}
+	case s.emptySince.IsZero():
+		s.emptySince = dataTime(state)
+	}
+}
+
+// quietNote returns the "quiet since" line once the server has been empty for
+// QuietAfter.
+func (s *service) quietNote(state *teamspeak.State, now time.Time) string {
+	if s.display.QuietAfter <= 0 || s.emptySince.IsZero() || state.TotalUsers > 0 || s.isStale(state, now) ||
+		now.Sub(s.emptySince) < s.display.QuietAfter {
+		return ""
+	}
 
-	hasContent := false
+	emptySince := s.clock.correct(s.emptySince)
 
-	for _, ch := range state.Channels {
-		// Skip channels with no users if configured
-		if !s.display.ShowEmptyChannels && len(ch.Users) == 0 {
-			continue
+	since := emptySince.Format("15:04")
+	if s.display.RelativeTime {
+		since = fmt.Sprintf("<t:%d:t>", emptySince.Unix())
+	}
+
+	return "💤 Server quiet since " + since
+}
+
+// isStale reports whether the state is older than the staleness threshold.
+func (s *service) isStale(state *teamspeak.State, now time.Time) bool {
+	return s.display.StaleAfter > 0 && !state.FetchedAt.IsZero() && now.Sub(state.FetchedAt) >= s.display.StaleAfter
+}
+
+// layoutStats arranges the stats fields according to the layout options.
+// Discord fits up to three inline fields per row; fewer per row is achieved by
+// padding each row with invisible inline fields.
+func (s *service) layoutStats(stats []*discordgo.MessageEmbedField) []*discordgo.MessageEmbedField {
+	if s.display.CompactLayout || !s.display.InlineStats || s.mobile() {
+		for _, f := range stats {
+			f.Inline = false
 		}
 
-		// Skip spacer channels
-		if strings.Contains(strings.ToLower(ch.Name), "spacer") {
-			continue
+		return stats
+	}
+
+	perRow := s.display.StatsPerRow
+	if perRow < 1 || perRow > 3 {
+		perRow = 3
+	}
+
+	fields := make([]*discordgo.MessageEmbedField, 0, len(stats)*3/perRow)
+
+	for i, f := range stats {
+		f.Inline = true
+		fields = append(fields, f)
+
+		// Pad a full row only when more stats follow; the next non-inline field
+		// breaks the row on its own.
+		if (i+1)%perRow == 0 && i+1 < len(stats) {
+			for j := perRow; j < 3; j++ {
+				fields = append(fields, &discordgo.MessageEmbedField{
+					Name:   "\u200b",
+					Value:  "\u200b",
+					Inline: true,
+				})
+			}
 		}
+	}
 
-		hasContent = true
+	return fields
+}
 
-		// Channel header with user count
-		if len(ch.Users) > 0 {
-			content.WriteString(fmt.Sprintf("**#%s** `%d`\n", ch.Name, len(ch.Users)))
-		} else {
-			content.WriteString(fmt.Sprintf("**#%s**\n", ch.Name))
+// Link is an entry of the links field.
+type Link struct {
+	Label string
+	URL   string
+}
+
+// formatLinks renders the links as masked links on one line.
+func formatLinks(links []Link) string {
+	parts := make([]string, 0, len(links))
+
+	for _, l := range links {
+		label := strings.NewReplacer("[", "", "]", "").Replace(l.Label)
+		// A parenthesis would close the masked link early.
+		target := strings.NewReplacer("(", "%28", ")", "%29").Replace(l.URL)
+		parts = append(parts, fmt.Sprintf("[%s](%s)", label, target))
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// mobile reports whether the mobile rendering style is selected.
+func (s *service) mobile() bool {
+	return s.display.Style == StyleMobile
+}
+
+// label prefixes a field name with its emoji, except in the mobile style.
+func (s *service) label(emoji, text string) string {
+	if s.mobile() {
+		return text
+	}
+
+	return emoji + " " + text
+}
+
+// serverSections renders one field per aggregated server. The channel lists
+// share the embed's total length budget so many busy servers stay within
+// Discord's limits.
+func (s *service) serverSections(state *teamspeak.State) []*discordgo.MessageEmbedField {
+	limit := min(maxFieldValue, maxSectionsLength/len(state.Servers))
+	fields := make([]*discordgo.MessageEmbedField, 0, len(state.Servers))
+
+	for _, sv := range state.Servers {
+		name := fmt.Sprintf("%s — %d/%d", truncateRunes(sv.ServerName, 200), sv.TotalUsers, sv.MaxClients)
+		if s.isStale(sv, time.Now()) {
+			name += " ⚠️ not responding"
+		}
+
+		value := s.buildChannelList(sv, limit)
+		if sv.Subtitle != "" {
+			subtitle := "*" + truncateRunes(sv.Subtitle, 100) + "*"
+			value = subtitle + "\n" + s.buildChannelList(sv, limit-utf8.RuneCountInString(subtitle)-1)
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  s.label("🖥️", name),
+			Value: value,
+		})
+	}
+
+	return fields
+}
+
+// buildChannelList formats the channel and user list within limit characters.
+func (s *service) buildChannelList(state *teamspeak.State, limit int) string {
+	// Count-only sources (e.g. Mumble pings) report users without channels.
+	if len(state.Channels) == 0 && state.TotalUsers > 0 {
+		return "*Channel list not available*"
+	}
+
+	if s.activeView() == ViewSummary {
+		return s.buildChannelSummary(state, limit)
+	}
+
+	blocks, sep := s.channelBlocks(state)
+	if len(blocks) == 0 {
+		return s.emptyText(state)
+	}
+
+	return fitBlocks(blocks, sep, limit)
+}
+
+// channelBlocks renders one block per shown channel, and the separator to
+// join them with.
+func (s *service) channelBlocks(state *teamspeak.State) ([]string, string) {
+	if s.mobile() {
+		return s.channelBlocksMobile(state), "\n"
+	}
+
+	doc := render.Build(state, s.renderOptions())
+
+	var blocks []string
+
+	for _, sec := range doc.Sections {
+		var content strings.Builder
+
+		var ch *teamspeak.Channel
+		if s.display.Templates.Channel != nil || s.display.Templates.User != nil {
+			ch = channelByID(state, sec.ID)
+		}
+
+		// Channel header with icon and user count
+		if icon := s.channelIcon(sec.IconID); icon != "" {
+			content.WriteString(icon + " ")
+		}
+
+		content.WriteString(s.channelHeader(state, ch, sec) + "\n")
+
+		if topic := topicLine(sec); topic != "" {
+			content.WriteString("ㅤ" + topic + "\n")
 		}
 
 		// User list
-		for _, user := range ch.Users {
-			status := buildUserStatus(user)
-			if status != "" {
-				content.WriteString(fmt.Sprintf("ㅤ• %s %s\n", user.Nickname, status))
-			} else {
-				content.WriteString(fmt.Sprintf("ㅤ• %s\n", user.Nickname))
+		for i := range sec.Lines {
+			content.WriteString(s.userLine(state, ch, sec, i, "ㅤ• ") + "\n")
+		}
+
+		blocks = append(blocks, strings.TrimRight(content.String(), "\n"))
+	}
+
+	return blocks, "\n\n"
+}
+
+// channelBlocksMobile renders each channel as one short header and one
+// comma-separated user line, keeping lines narrow for phones.
+func (s *service) channelBlocksMobile(state *teamspeak.State) []string {
+	var lines []string
+
+	for _, sec := range render.Build(state, s.renderOptions()).Sections {
+		header := fmt.Sprintf("**%s**", sec.Name)
+		if len(sec.Lines) > 0 {
+			header += fmt.Sprintf(" (%d)", len(sec.Lines))
+		}
+
+		if topic := topicLine(sec); topic != "" {
+			header += "\n" + topic
+		}
+
+		if len(sec.Lines) == 0 {
+			lines = append(lines, header)
+			continue
+		}
+
+		names := make([]string, 0, len(sec.Lines))
+		for _, line := range sec.Lines {
+			name := line.Name()
+			if icon := line.PrimaryWith(s.display.Emojis); icon != "" {
+				name += " " + icon
 			}
+
+			names = append(names, name)
 		}
 
-		content.WriteString("\n")
+		lines = append(lines, header+"\n"+strings.Join(names, ", "))
+	}
+
+	return lines
+}
+
+// renderOptions selects what the rendered document includes.
+func (s *service) renderOptions() render.Options {
+	return render.Options{
+		ShowEmptyChannels: s.display.ShowEmptyChannels,
+		ShowCountry:       s.display.ShowCountry,
+		ShowChannelTopics: s.display.ShowChannelTopics,
+		ShowConnectedTime: s.display.ShowConnectedTime,
+		ShowTalkPower:     s.display.ShowTalkPower,
+		ShowCodec:         s.display.ShowCodec,
+		GroupBadges:       s.display.GroupBadges,
 	}
+}
+
+// maxTopicLength bounds a channel topic shown under its header.
+const maxTopicLength = 100
 
-	if !hasContent {
-		return "*No active channels*"
+// topicLine returns the section's topic as an italic subtitle, or "" when it
+// has none.
+func topicLine(sec render.Section) string {
+	if sec.Topic == "" {
+		return ""
 	}
 
-	return strings.TrimRight(content.String(), "\n")
+	return "*" + strings.ReplaceAll(truncateRunes(sec.Topic, maxTopicLength), "*", "\\*") + "*"
 }
 
-// buildUserStatus creates a status string with icons for a user.
-func buildUserStatus(user teamspeak.User) string {
-	var status strings.Builder
+// fitBlocks joins per-channel blocks with sep, keeping the result within limit
+// characters. Blocks that do not fit are dropped from the end and summarised,
+// and a single oversized block is cut at a line boundary.
+func fitBlocks(blocks []string, sep string, limit int) string {
+	joined := strings.Join(blocks, sep)
+	if utf8.RuneCountInString(joined) <= limit {
+		return joined
+	}
+
+	// Leave room for the "…and N more channels" note.
+	budget := limit - 32
 
-	if user.IsRecording {
-		status.WriteString("🔴")
+	var (
+		b    strings.Builder
+		used int
+		kept int
+	)
+
+	for _, block := range blocks {
+		size := utf8.RuneCountInString(block)
+		if kept > 0 {
+			size += utf8.RuneCountInString(sep)
+		}
+
+		if used+size > budget {
+			if kept == 0 {
+				b.WriteString(truncateLines(block, budget))
+				kept++
+			}
+
+			break
+		}
+
+		if kept > 0 {
+			b.WriteString(sep)
+		}
+
+		b.WriteString(block)
+		used += size
+		kept++
 	}
 
-	if user.OutputMuted {
-		status.WriteString("🔇") // Deafened (can't hear)
-	} else if user.InputMuted {
-		status.WriteString("🎙️") // Mic muted
+	if rest := len(blocks) - kept; rest > 0 {
+		fmt.Fprintf(&b, "\n*…and %d more channels*", rest)
+	} else {
+		b.WriteString("\n*…*")
+	}
+
+	return b.String()
+}
+
+// truncateLines cuts s to at most limit characters, ending on a whole line
+// where possible.
+func truncateLines(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	cut := string(runes[:limit])
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+
+	return cut
+}
+
+// truncateRunes cuts s to at most limit characters, marking the cut.
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
 	}
 
-	if user.Away {
-		status.WriteString("💤")
+	return string(runes[:limit-1]) + "…"
+}
+
+// buildUserStatus creates a status string with icons for a user's line. now
+// is the time the state was fetched, used to anchor relative timestamps.
+func (s *service) buildUserStatus(line render.Line, now time.Time) string {
+	var status strings.Builder
+
+	status.WriteString(strings.Join(line.IconsWith(s.display.Emojis), ""))
+
+	if line.Idle > 0 {
+		if s.display.Emojis.Idle != "" {
+			status.WriteString(" " + s.display.Emojis.Idle)
+		}
+
+		if s.display.RelativeTime {
+			status.WriteString(fmt.Sprintf(" (active %s)", relativeTimestamp(s.clock.correct(now.Add(-line.Idle)))))
+		} else {
+			status.WriteString(fmt.Sprintf(" (%s idle)", formatIdleTime(line.Idle)))
+		}
 	}
 
-	// Show idle time if > 5 minutes
-	if user.IdleTime > 5*time.Minute {
-		status.WriteString(fmt.Sprintf(" (%s idle)", formatIdleTime(user.IdleTime)))
+	if !line.ConnectedAt.IsZero() {
+		if s.display.RelativeTime {
+			status.WriteString(fmt.Sprintf(" · joined %s", relativeTimestamp(line.ConnectedAt)))
+		} else {
+			status.WriteString(fmt.Sprintf(" · on %s", formatDuration(now.Sub(line.ConnectedAt))))
+		}
 	}
 
 	return status.String()
 }
 
+// longestSession returns the online user with the earliest session start.
+// Users whose connection time is unknown are ignored.
+func longestSession(state *teamspeak.State) (teamspeak.User, bool) {
+	var (
+		best  teamspeak.User
+		found bool
+	)
+
+	for _, ch := range state.Channels {
+		for _, u := range ch.Users {
+			if u.ConnectedAt.IsZero() {
+				continue
+			}
+
+			if !found || u.ConnectedAt.Before(best.ConnectedAt) {
+				best, found = u, true
+			}
+		}
+	}
+
+	return best, found
+}
+
+// formatUptime renders the server uptime, as a live "since" timestamp when
+// relative time is enabled.
+func (s *service) formatUptime(state *teamspeak.State) string {
+	if !s.display.RelativeTime {
+		return formatDuration(state.Uptime)
+	}
+
+	return "since " + relativeTimestamp(s.clock.correct(dataTime(state)).Add(-state.Uptime))
+}
+
+// dataTime returns when the state was fetched, falling back to now for states
+// that do not carry a fetch time.
+func dataTime(state *teamspeak.State) time.Time {
+	if state.FetchedAt.IsZero() {
+		return time.Now()
+	}
+
+	return state.FetchedAt
+}
+
+// relativeTimestamp returns Discord timestamp markup that the client renders as
+// a live relative time, e.g. "2 minutes ago".
+func relativeTimestamp(t time.Time) string {
+	return fmt.Sprintf("<t:%d:R>", t.Unix())
+}
+
+// formatNetwork renders traffic as "⬇ 1.2 MB/s ⬆ 340 KB/s" over the packet
+// loss.
+func formatNetwork(n *teamspeak.NetworkStats) string {
+	return fmt.Sprintf("⬇ %s ⬆ %s\n%.1f%% loss", formatRate(n.BandwidthIn), formatRate(n.BandwidthOut), n.PacketLoss*100)
+}
+
+// formatRate formats bytes per second with a decimal unit.
+func formatRate(bytes float64) string {
+	switch {
+	case bytes >= 1e6:
+		return fmt.Sprintf("%.1f MB/s", bytes/1e6)
+	case bytes >= 1e3:
+		return fmt.Sprintf("%.0f KB/s", bytes/1e3)
+	default:
+		return fmt.Sprintf("%.0f B/s", bytes)
+	}
+}
+
 // formatIdleTime formats idle duration in a compact way.
 func formatIdleTime(d time.Duration) string {
 	hours := int(d.Hours())
@@ -0,0 +1,1167 @@
+package discord
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/notify"
+	"github.com/samcm/ts-discord-status/internal/render"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestLongestSession(t *testing.T) {
+	now := time.Now()
+	state := &teamspeak.State{
+		FetchedAt: now,
+		Channels: []teamspeak.Channel{
+			{Users: []teamspeak.User{{Nickname: "alice", ConnectedAt: now.Add(-time.Hour)}, {Nickname: "bot"}}},
+			{Users: []teamspeak.User{{Nickname: "Dave", ConnectedAt: now.Add(-(6*time.Hour + 12*time.Minute))}}},
+		},
+	}
+
+	user, ok := longestSession(state)
+	require.True(t, ok)
+	require.Equal(t, "Dave", user.Nickname)
+
+	svc := newTestService(DisplayConfig{ShowLongestSession: true})
+	embed := svc.buildEmbed(state)
+
+	var found bool
+
+	for _, f := range embed.Fields {
+		if f.Value == "Dave (6h 12m)" {
+			found = true
+		}
+	}
+
+	require.True(t, found)
+
+	_, ok = longestSession(&teamspeak.State{})
+	require.False(t, ok)
+}
+
+func TestChannelNameReset(t *testing.T) {
+	svc := newTestService(DisplayConfig{
+		ChannelNameFormat: "TS: {online}/{max}",
+		ChannelNameReset:  &ChannelNameReset{Name: "teamspeak-status", Window: ClockRange{From: 60, To: 8 * 60}},
+	})
+
+	night := time.Date(2024, 1, 1, 3, 0, 0, 0, time.Local)
+	day := time.Date(2024, 1, 1, 15, 0, 0, 0, time.Local)
+
+	require.Equal(t, "teamspeak-status", svc.channelName(&teamspeak.State{MaxClients: 32}, night))
+	require.Equal(t, "TS: 0/32", svc.channelName(&teamspeak.State{MaxClients: 32}, day))
+	require.Equal(t, "TS: 2/32", svc.channelName(&teamspeak.State{TotalUsers: 2, MaxClients: 32}, night))
+}
+
+func TestPresenceText(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	day := time.Date(2024, 1, 1, 20, 0, 0, 0, time.Local)
+
+	svc.trackPeak(&teamspeak.State{TotalUsers: 14}, day)
+	svc.trackPeak(&teamspeak.State{TotalUsers: 7}, day.Add(time.Hour))
+
+	state := &teamspeak.State{TotalUsers: 7, MaxClients: 32, Uptime: 75 * time.Hour}
+	require.Equal(t, "7/32 online", svc.presenceText("{online}/{max} online", state))
+	require.Equal(t, "Uptime 3d 3h", svc.presenceText("Uptime {uptime}", state))
+	require.Equal(t, "Peak today 14", svc.presenceText("Peak today {peak_today}", state))
+
+	svc.trackPeak(&teamspeak.State{TotalUsers: 2}, day.Add(24*time.Hour))
+	require.Equal(t, "Peak today 2", svc.presenceText("Peak today {peak_today}", state))
+}
+
+func TestStatusEmoji(t *testing.T) {
+	svc := newTestService(DisplayConfig{
+		ChannelNameFormat: "{status_emoji} ts-{online}",
+		StaleAfter:        time.Minute,
+		StatusEmoji:       StatusEmoji{Online: "🟢", Busy: "🟡", Offline: "🔴", BusyCapacity: 0.8},
+	})
+
+	now := time.Now()
+
+	require.Equal(t, "🟢 ts-3", svc.channelName(&teamspeak.State{TotalUsers: 3, MaxClients: 10, FetchedAt: now}, now))
+	require.Equal(t, "🟡 ts-8", svc.channelName(&teamspeak.State{TotalUsers: 8, MaxClients: 10, FetchedAt: now}, now))
+	require.Equal(t, "🔴 ts-3", svc.channelName(&teamspeak.State{TotalUsers: 3, MaxClients: 10, FetchedAt: now.Add(-time.Hour)}, now))
+
+	partial := &teamspeak.State{
+		TotalUsers: 3, MaxClients: 20, FetchedAt: now,
+		Servers: []*teamspeak.State{{FetchedAt: now}, {FetchedAt: now.Add(-time.Hour)}},
+	}
+	require.Equal(t, "🟡 ts-3", svc.channelName(partial, now))
+}
+
+func TestQuietNote(t *testing.T) {
+	svc := newTestService(DisplayConfig{QuietAfter: 2 * time.Hour})
+
+	start := time.Date(2024, 1, 1, 23, 10, 0, 0, time.Local)
+	empty := &teamspeak.State{FetchedAt: start}
+
+	svc.trackQuiet(empty, start)
+	require.Empty(t, svc.quietNote(empty, start.Add(time.Hour)))
+
+	later := &teamspeak.State{FetchedAt: start.Add(3 * time.Hour)}
+	svc.trackQuiet(later, later.FetchedAt)
+	require.Equal(t, "💤 Server quiet since 23:10", svc.quietNote(later, later.FetchedAt))
+
+	busy := &teamspeak.State{TotalUsers: 1, FetchedAt: start.Add(4 * time.Hour)}
+	svc.trackQuiet(busy, busy.FetchedAt)
+	require.Empty(t, svc.quietNote(busy, busy.FetchedAt))
+	require.True(t, svc.emptySince.IsZero())
+}
+
+func TestChannelTopics(t *testing.T) {
+	state := &teamspeak.State{
+		Channels: []teamspeak.Channel{
+			{Name: "Lobby", Topic: "Say *hi*\n to everyone", Users: []teamspeak.User{{Nickname: "alice"}}},
+		},
+		TotalUsers: 1,
+	}
+
+	require.NotContains(t, newTestService(DisplayConfig{}).buildChannelList(state, maxFieldValue), "hi")
+	require.Equal(t, "**#Lobby** `1`\nㅤ*Say \\*hi\\* to everyone*\nㅤ• alice",
+		newTestService(DisplayConfig{ShowChannelTopics: true}).buildChannelList(state, maxFieldValue))
+}
+
+func TestNetworkField(t *testing.T) {
+	state := &teamspeak.State{
+		ServerName: "Game Night",
+		MaxClients: 32,
+		Network:    &teamspeak.NetworkStats{BandwidthIn: 1203442, BandwidthOut: 340210, PacketLoss: 0.014},
+	}
+
+	field := func(display DisplayConfig) string {
+		for _, f := range newTestService(display).buildEmbed(state).Fields {
+			if strings.Contains(f.Name, "Network") {
+				return f.Value
+			}
+		}
+
+		return ""
+	}
+
+	require.Empty(t, field(DisplayConfig{}))
+	require.Equal(t, "⬇ 1.2 MB/s ⬆ 340 KB/s\n1.4% loss", field(DisplayConfig{ShowNetwork: true}))
+}
+
+func TestSummaryView(t *testing.T) {
+	state := &teamspeak.State{
+		Channels: []teamspeak.Channel{
+			{Name: "Lobby", Users: []teamspeak.User{{Nickname: "alice"}, {Nickname: "bob"}}},
+			{Name: "Empty"},
+			{Name: "Games", Users: []teamspeak.User{{Nickname: "carol"}}},
+		},
+		TotalUsers: 3,
+	}
+
+	svc := newTestService(DisplayConfig{ViewButtons: true, ShowEmptyChannels: true, ViewRevertAfter: time.Minute})
+	require.Contains(t, svc.buildChannelList(state, maxFieldValue), "alice")
+
+	now := time.Now()
+	svc.view = ViewSummary
+	svc.viewUntil = now.Add(time.Minute)
+	require.Equal(t, "**#Lobby** `2`\n**#Games** `1`", svc.buildChannelList(state, maxFieldValue))
+
+	svc.expireView(now.Add(time.Minute))
+	require.Equal(t, ViewDetailed, svc.activeView())
+}
+
+func TestChannelDrillDown(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	state := &teamspeak.State{
+		FetchedAt: now,
+		Channels: []teamspeak.Channel{
+			{ID: 1, Name: "Empty"},
+			{ID: 2, Name: "Lobby", Users: []teamspeak.User{
+				{Nickname: "alice", Away: true, AwayMessage: "brb", IdleTime: 12 * time.Minute},
+				{Nickname: "bob", InputMuted: true, ConnectedAt: now.Add(-90 * time.Minute)},
+			}},
+			{ID: 2, Name: "Other server", Users: []teamspeak.User{{Nickname: "carol", Server: 1}}},
+		},
+	}
+
+	svc := newTestService(DisplayConfig{ChannelSelect: true})
+	row, ok := svc.channelSelect(state)
+	require.True(t, ok)
+
+	menu := row.Components[0].(discordgo.SelectMenu)
+	require.Len(t, menu.Options, 2)
+	require.Equal(t, "0:2", menu.Options[0].Value)
+	require.Equal(t, "2 users", menu.Options[0].Description)
+	require.Equal(t, "1:2", menu.Options[1].Value)
+
+	ch, ok := findChannel(state, "1:2")
+	require.True(t, ok)
+	require.Equal(t, "Other server", ch.Name)
+
+	ch, _ = findChannel(state, "0:2")
+	require.Equal(t, "**#Lobby** `2`\n"+
+		"• **alice** — 💤 away: brb · 12m idle\n"+
+		fmt.Sprintf("• **bob** — 🎙️ muted · connected <t:%d:R> (1h 30m)", now.Add(-90*time.Minute).Unix()),
+		channelDetail(ch, now, render.DefaultEmojis))
+
+	_, ok = svc.channelSelect(&teamspeak.State{Channels: []teamspeak.Channel{{ID: 1}}})
+	require.False(t, ok)
+}
+
+func TestCheckConflict(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	now := time.Now()
+
+	ours := now.Add(-time.Minute)
+	svc.recordEdit(&discordgo.Message{EditedTimestamp: &ours})
+	svc.seenEdit = ours
+	require.False(t, svc.checkConflict(now))
+
+	// Another instance edited after our last edit.
+	svc.seenEdit = now.Add(-10 * time.Second)
+	require.True(t, svc.checkConflict(now))
+	require.True(t, svc.checkConflict(now.Add(time.Minute)))
+
+	// It stopped: resume once the standby has passed.
+	require.False(t, svc.checkConflict(svc.seenEdit.Add(conflictStandby)))
+}
+
+func TestMessagePerServer(t *testing.T) {
+	a := &teamspeak.State{ServerName: "A"}
+	b := &teamspeak.State{ServerName: "B"}
+	combined := &teamspeak.State{ServerName: "All", Servers: []*teamspeak.State{a, b}}
+
+	require.Same(t, combined, newTestService(DisplayConfig{}).mainState(combined))
+
+	svc := newTestService(DisplayConfig{MessagePerServer: true})
+	require.Same(t, a, svc.mainState(combined))
+
+	bot := &discordgo.User{ID: "bot"}
+	other := &discordgo.User{ID: "other"}
+	embeds := []*discordgo.MessageEmbed{{Author: &discordgo.MessageEmbedAuthor{IconURL: statusIcon}}}
+	alert := []*discordgo.MessageEmbed{{Title: "Server offline"}}
+
+	// Newest first, as Discord returns them. The bot's other embeds, such as
+	// alerts, are not status messages.
+	require.True(t, svc.adoptMessages([]*discordgo.Message{
+		{ID: "4", Author: bot, Embeds: alert},
+		{ID: "3", Author: bot, Embeds: embeds},
+		{ID: "2", Author: other, Embeds: embeds},
+		{ID: "1", Author: bot, Embeds: embeds},
+	}, "bot"))
+	require.Equal(t, "1", svc.messageID)
+	require.Equal(t, []string{"3"}, svc.extraMessages)
+}
+
+func TestBannedDescription(t *testing.T) {
+	until := time.Unix(1_700_000_600, 0)
+	state := &teamspeak.State{
+		ServerName:  "Game Night",
+		MaxClients:  32,
+		FetchedAt:   time.Now(),
+		BannedUntil: until,
+	}
+
+	embed := newTestService(DisplayConfig{RelativeTime: true}).buildEmbed(state)
+	require.Equal(t, "⛔ Bot temporarily banned from ServerQuery — retrying <t:1700000600:R>", embed.Description)
+}
+
+func TestWhoReply(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	state := &teamspeak.State{
+		FetchedAt: now,
+		Channels: []teamspeak.Channel{
+			{Name: "Lobby", Users: []teamspeak.User{{Nickname: "Alice", InputMuted: true}, {Nickname: "alicebot"}}},
+			{Name: "Games", Users: []teamspeak.User{{Nickname: "malice", IdleTime: 12 * time.Minute, Server: 1}}},
+		},
+		Servers: []*teamspeak.State{{ServerName: "EU"}, {ServerName: "US"}},
+	}
+
+	// An exact match, ignoring case, wins over partial ones.
+	require.Equal(t, "**Alice** is in **#Lobby** on **EU** — 🎙️ muted", whoReply(state, "alice", now, render.DefaultEmojis))
+
+	require.Equal(t, "3 users match \"li\":\n"+
+		"• **Alice** in **#Lobby** on **EU** — 🎙️ muted\n"+
+		"• **alicebot** in **#Lobby** on **EU**\n"+
+		"• **malice** in **#Games** on **US** — 12m idle",
+		whoReply(state, "li", now, render.DefaultEmojis))
+
+	require.Equal(t, `Nobody called "carol" is online.`, whoReply(state, "carol", now, render.DefaultEmojis))
+}
+
+func TestRefreshCooldown(t *testing.T) {
+	svc := newTestService(DisplayConfig{RefreshButton: true, RefreshCooldown: time.Minute})
+	now := time.Now()
+
+	_, ok := svc.allowRefresh("alice", now)
+	require.True(t, ok)
+
+	// Refreshes from all users are spaced by minRefreshInterval.
+	next, ok := svc.allowRefresh("bob", now.Add(time.Second))
+	require.False(t, ok)
+	require.Equal(t, now.Add(minRefreshInterval), next)
+
+	// Each user has their own cooldown.
+	_, ok = svc.allowRefresh("bob", now.Add(30*time.Second))
+	require.True(t, ok)
+
+	next, ok = svc.allowRefresh("alice", now.Add(30*time.Second))
+	require.False(t, ok)
+	require.Equal(t, now.Add(time.Minute), next)
+
+	_, ok = svc.allowRefresh("alice", now.Add(time.Minute))
+	require.True(t, ok)
+	require.Len(t, svc.refreshedBy, 2)
+}
+
+func TestFooterLabel(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	state := &teamspeak.State{ServerName: "Game Night", Label: "eu-1", FetchedAt: time.Now()}
+
+	require.Equal(t, "eu-1 · Last updated", svc.buildEmbed(state).Footer.Text)
+
+	// A label repeating the title is left out.
+	state.Label = "Game Night"
+	require.Equal(t, "Last updated", svc.buildEmbed(state).Footer.Text)
+}
+
+func TestPreviewName(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(DisplayConfig{ChannelNameFormat: "TS: {online}/{max}"})
+
+	require.Equal(t, "The bot is still starting, try again in a moment.", svc.previewName(now))
+
+	svc.lastState = &teamspeak.State{TotalUsers: 3, MaxClients: 32, FetchedAt: now}
+	require.Equal(t, "The channel name would be `TS: 3/32`.\nIt is applied with the next update.", svc.previewName(now))
+
+	svc.lastChannelRename = now.Add(-time.Minute)
+	require.Equal(t, fmt.Sprintf("The channel name would be `TS: 3/32`.\nThe next rename is allowed <t:%d:R>.",
+		now.Add(4*time.Minute).Unix()), svc.previewName(now))
+
+	// A dry run stands in for the rename.
+	svc.display.ChannelNameDryRun = true
+	svc.lastChannelName = "TS: 2/32"
+	svc.maybeUpdateChannelName(svc.lastState)
+	require.Equal(t, "TS: 3/32", svc.lastChannelName)
+	require.Equal(t, "The channel name would be `TS: 3/32`.\nDry run is on, so the channel is not renamed.", svc.previewName(now))
+}
+
+func TestParseWebhookURL(t *testing.T) {
+	id, token, err := parseWebhookURL("https://discord.com/api/webhooks/123/abc-DEF")
+	require.NoError(t, err)
+	require.Equal(t, "123", id)
+	require.Equal(t, "abc-DEF", token)
+
+	id, _, err = parseWebhookURL("https://discord.com/api/v10/webhooks/456/tok/")
+	require.NoError(t, err)
+	require.Equal(t, "456", id)
+
+	for _, raw := range []string{"https://discord.com/api/webhooks/123", "https://discord.com/api/channels/1/2", "://"} {
+		_, _, err := parseWebhookURL(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestWebhookUpdateStatus(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	w := NewService(log, Config{WebhookURL: "https://discord.com/api/webhooks/123/tok"}, DisplayConfig{}).(*webhookService)
+	state := &teamspeak.State{ServerName: "Game Night", FetchedAt: time.Now()}
+	ctx := context.Background()
+
+	require.ErrorContains(t, w.UpdateStatus(ctx, state), "not started")
+
+	session, fake := newFakeSession(t)
+	w.session, w.webhookID, w.token = session, "123", "tok"
+
+	posted := 0
+	fake.handle("POST", "/webhooks/123/tok", func([]byte) (int, any) {
+		posted++
+
+		return 200, map[string]any{"id": fmt.Sprintf("m%d", posted)}
+	})
+
+	// Without a message one is posted, then edited with the state.
+	require.NoError(t, w.UpdateStatus(ctx, state))
+	require.Equal(t, "m1", w.messageID)
+	require.Len(t, fake.calls("PATCH", "/webhooks/123/tok/messages/m1"), 1)
+
+	require.NoError(t, w.UpdateStatus(ctx, state))
+	require.Len(t, fake.calls("POST", "/webhooks/123/tok"), 1)
+	require.Len(t, fake.calls("PATCH", "/webhooks/123/tok/messages/m1"), 2)
+
+	// A deleted message is posted again.
+	fake.handle("PATCH", "/webhooks/123/tok/messages/m1", func([]byte) (int, any) {
+		return 404, map[string]any{"code": discordgo.ErrCodeUnknownMessage, "message": "Unknown Message"}
+	})
+
+	require.NoError(t, w.UpdateStatus(ctx, state))
+	require.Equal(t, "m2", w.messageID)
+	require.Len(t, fake.calls("PATCH", "/webhooks/123/tok/messages/m2"), 1)
+
+	// Other failures are returned.
+	fake.handle("PATCH", "/webhooks/123/tok/messages/m2", func([]byte) (int, any) {
+		return 403, map[string]any{"code": 50013, "message": "Missing Permissions"}
+	})
+
+	require.ErrorContains(t, w.UpdateStatus(ctx, state), "failed to update status message")
+	require.Equal(t, "m2", w.messageID)
+}
+
+func TestDetailThread(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	svc := NewService(log, Config{ChannelID: "1", DetailThread: "Channel details"},
+		DisplayConfig{ViewButtons: true, DefaultView: ViewDetailed, ChannelNameFormat: "TS {online}"}).(*service)
+
+	// The status message summarizes; the thread holds the details without
+	// buttons or renames of its own.
+	require.Equal(t, ViewSummary, svc.activeView())
+	require.NotNil(t, svc.detail)
+	require.Equal(t, ViewDetailed, svc.detail.activeView())
+	require.False(t, svc.detail.display.ViewButtons)
+	require.Empty(t, svc.detail.display.ChannelNameFormat)
+	require.Empty(t, svc.detail.cfg.ChannelID)
+
+	require.Nil(t, newTestService(DisplayConfig{}).detail)
+}
+
+func TestForumPost(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(DisplayConfig{StaleAfter: time.Minute})
+	svc.cfg.Forum = Forum{OnlineTag: "Online", OfflineTag: "offline"}
+	svc.forumTags = map[string]string{"online": "10", "offline": "11", "events": "12"}
+	svc.lastPostTags = "12,11"
+
+	state := &teamspeak.State{ServerName: "Game Night", TotalUsers: 2, FetchedAt: now}
+	require.Equal(t, "Game Night", svc.postTitle(state, now))
+
+	// The status tag is swapped; other tags are kept.
+	require.Equal(t, []string{"12", "10"}, svc.postTags(state, now))
+
+	state.FetchedAt = now.Add(-time.Hour)
+	require.Equal(t, []string{"12", "11"}, svc.postTags(state, now))
+
+	svc.cfg.Forum.Title = strings.Repeat("x", 120) + " {online}"
+	require.Equal(t, maxChannelName, utf8.RuneCountInString(svc.postTitle(state, now)))
+
+	svc.cfg.Forum = Forum{}
+	require.Nil(t, svc.postTags(state, now))
+}
+
+func TestOwnPost(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+
+	status := map[string]any{
+		"id": "archived", "author": map[string]any{"id": "bot"},
+		"embeds": []any{map[string]any{"author": map[string]any{"name": "TeamSpeak Server", "icon_url": statusIcon}}},
+	}
+	other := map[string]any{"id": "mine", "author": map[string]any{"id": "bot"}, "content": "Welcome!"}
+
+	fake.handle("GET", "/guilds/guild/threads/active", func([]byte) (int, any) {
+		return 200, map[string]any{"threads": []any{
+			map[string]any{"id": "deleted", "parent_id": "forum", "owner_id": "bot"},
+			map[string]any{"id": "theirs", "parent_id": "forum", "owner_id": "someone"},
+			map[string]any{"id": "mine", "parent_id": "forum", "owner_id": "bot"},
+		}}
+	})
+	fake.handle("GET", "/channels/forum/threads/archived/public", func([]byte) (int, any) {
+		return 200, map[string]any{"threads": []any{map[string]any{"id": "archived", "parent_id": "forum", "owner_id": "bot"}}}
+	})
+	fake.handle("GET", "/channels/deleted/messages/deleted", func([]byte) (int, any) {
+		return 404, map[string]any{"code": discordgo.ErrCodeUnknownMessage, "message": "Unknown Message"}
+	})
+	fake.handle("GET", "/channels/mine/messages/mine", func([]byte) (int, any) { return 200, other })
+	fake.handle("GET", "/channels/archived/messages/archived", func([]byte) (int, any) { return 200, status })
+
+	// Only the post whose starter message is a status message is adopted:
+	// not one whose status message was deleted, nor another post of the bot.
+	post := svc.ownPost(&discordgo.Channel{ID: "forum", GuildID: "guild", Type: discordgo.ChannelTypeGuildForum})
+	require.NotNil(t, post)
+	require.Equal(t, "archived", post.ID)
+	require.Empty(t, fake.calls("GET", "/channels/theirs/messages/theirs"))
+}
+
+func TestRepostStartsNewForumPost(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.ChannelID = "forum"
+	svc.post, svc.messageID, svc.lastHash = "old", "old", "hash"
+
+	fake.handle("POST", "/channels/forum/threads", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "new", "name": "Server status", "parent_id": "forum"}
+	})
+
+	require.NoError(t, svc.repost(t.Context()))
+
+	// The replacement is the first message of a new post, not a reply in the
+	// old one, and the old post is removed.
+	require.Equal(t, "new", svc.post)
+	require.Equal(t, "new", svc.messageID)
+	require.Empty(t, svc.lastHash)
+	require.Empty(t, fake.calls("POST", "/channels/old/messages"))
+	require.Len(t, fake.calls("DELETE", "/channels/old"), 1)
+}
+
+func TestVoiceMirror(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(DisplayConfig{
+		VoiceMirror:       &VoiceMirror{ChannelID: "9", Format: "🔊 TS: {online} online"},
+		ChannelNameDryRun: true,
+	})
+	svc.mirrorName = "🔊 TS: 2 online"
+
+	// Independent of the status channel's name.
+	svc.maybeUpdateVoiceMirror(t.Context(), &teamspeak.State{TotalUsers: 7}, now)
+	require.Equal(t, "🔊 TS: 7 online", svc.mirrorName)
+	require.Empty(t, svc.lastChannelName)
+}
+
+func TestVoiceStatus(t *testing.T) {
+	svc := newTestService(DisplayConfig{VoiceStatus: &VoiceStatus{ChannelID: "9", Format: "{online} in TeamSpeak"}})
+	session, fake := newFakeSession(t)
+	svc.session = session
+
+	const path = "/channels/9/voice-status"
+
+	fake.handle("PUT", path, func([]byte) (int, any) {
+		return 403, map[string]any{"code": discordgo.ErrCodeMissingPermissions, "message": "Missing Permissions"}
+	})
+
+	ctx, now, state := t.Context(), time.Now(), &teamspeak.State{TotalUsers: 7}
+
+	// Failures back off, doubling the wait.
+	svc.maybeUpdateVoiceStatus(ctx, state, now)
+	svc.maybeUpdateVoiceStatus(ctx, state, now.Add(10*time.Second))
+	require.Len(t, fake.calls("PUT", path), 1)
+
+	svc.maybeUpdateVoiceStatus(ctx, state, now.Add(31*time.Second))
+	svc.maybeUpdateVoiceStatus(ctx, state, now.Add(80*time.Second))
+	require.Len(t, fake.calls("PUT", path), 2)
+	require.Empty(t, svc.voiceStatus)
+
+	fake.handle("PUT", path, func([]byte) (int, any) { return 204, nil })
+
+	svc.maybeUpdateVoiceStatus(ctx, state, now.Add(92*time.Second))
+	calls := fake.calls("PUT", path)
+	require.Len(t, calls, 3)
+	require.JSONEq(t, `{"status":"7 in TeamSpeak"}`, string(calls[2].Body))
+	require.Equal(t, "7 in TeamSpeak", svc.voiceStatus)
+	require.Zero(t, svc.voiceStatusWait)
+
+	// An unchanged status is not set again.
+	svc.maybeUpdateVoiceStatus(ctx, state, now.Add(time.Hour))
+	require.Len(t, fake.calls("PUT", path), 3)
+}
+
+func TestEmptyPhrase(t *testing.T) {
+	es := EmptyState{
+		Enabled:  true,
+		Phrases:  []string{"{greeting}! Usually picks up around {start}", "Busy {start}–{end}"},
+		Fallback: "Nobody online",
+	}
+	evening := Forecast{Known: true, Start: 19, End: 23}
+
+	// January 2nd is day 2, so the first phrase.
+	at := func(hour int) time.Time { return time.Date(2024, 1, 2, hour, 30, 0, 0, time.UTC) }
+
+	require.Equal(t, "Good night! Usually picks up around 19:00", emptyPhrase(es, evening, at(2)))
+	require.Equal(t, "Good afternoon! Usually picks up around 19:00", emptyPhrase(es, evening, at(14)))
+	require.Equal(t, "Busy 19:00–23:00", emptyPhrase(es, evening, at(14).AddDate(0, 0, 1)))
+
+	// During the busy hours, or without a forecast, the fallback is shown.
+	require.Equal(t, "Nobody online", emptyPhrase(es, evening, at(20)))
+	require.Equal(t, "Nobody online", emptyPhrase(es, Forecast{}, at(14)))
+
+	// A window past midnight.
+	require.Equal(t, "Nobody online", emptyPhrase(es, Forecast{Known: true, Start: 22, End: 2}, at(1)))
+}
+
+func TestCheckStatusChannel(t *testing.T) {
+	for typ, ok := range map[discordgo.ChannelType]bool{
+		discordgo.ChannelTypeGuildText:         true,
+		discordgo.ChannelTypeGuildNews:         true,
+		discordgo.ChannelTypeGuildForum:        true,
+		discordgo.ChannelTypeGuildPublicThread: true,
+		discordgo.ChannelTypeGuildCategory:     false,
+		discordgo.ChannelTypeGuildStageVoice:   false,
+	} {
+		err := checkStatusChannel(&discordgo.Channel{ID: "1", Name: "status", Type: typ})
+		if ok {
+			require.NoError(t, err, typ)
+		} else {
+			require.ErrorContains(t, err, "cannot host the status message", typ)
+		}
+	}
+}
+
+func TestEmbedTemplates(t *testing.T) {
+	parse := func(text string) *template.Template {
+		tmpl, err := ParseTemplate("test", text)
+		require.NoError(t, err)
+
+		return tmpl
+	}
+
+	svc := newTestService(DisplayConfig{Templates: Templates{
+		Title: parse("{{ upper .State.ServerName }}"),
+		Fields: []FieldTemplate{
+			{Name: parse("Players"), Value: parse("{{ .State.TotalUsers }} of {{ .State.MaxClients }}")},
+			{Name: parse("Hidden"), Value: parse("{{ if .State.Uptime }}up{{ end }}")},
+		},
+		Channel: parse("__{{ .Name }}__ ({{ len .Channel.Users }})"),
+		User:    parse("- {{ .User.Nickname }}{{ if .User.Away }} (away){{ end }}"),
+	}})
+
+	state := &teamspeak.State{
+		ServerName: "Lobby",
+		TotalUsers: 2,
+		MaxClients: 32,
+		FetchedAt:  time.Now(),
+		Channels: []teamspeak.Channel{{ID: 7, Name: "General", Users: []teamspeak.User{
+			{Nickname: "alice"}, {Nickname: "bob", Away: true},
+		}}},
+	}
+
+	embed := svc.buildEmbed(state)
+	require.Equal(t, "LOBBY", embed.Title)
+	require.Equal(t, "Players", embed.Fields[0].Name)
+	require.Equal(t, "2 of 32", embed.Fields[0].Value)
+	require.Equal(t, "__General__ (2)\n- alice\n- bob (away)", embed.Fields[1].Value)
+
+	// A template failing at runtime falls back to the built-in line, and is
+	// warned about once rather than per user and update.
+	log, hook := logtest.NewNullLogger()
+	svc.log = log
+	svc.display.Templates.User = parse("{{ .Channel.Users.Missing }}")
+	require.Contains(t, svc.buildEmbed(state).Fields[1].Value, "ㅤ• alice")
+	svc.buildEmbed(state)
+	require.Len(t, hook.AllEntries(), 1)
+
+	svc.display.Templates.User = parse("{{ .User.Missing }}")
+	svc.buildEmbed(state)
+	require.Len(t, hook.AllEntries(), 2)
+}
+
+func TestPerChannelFields(t *testing.T) {
+	state := &teamspeak.State{
+		ServerName: "Lobby",
+		TotalUsers: 3,
+		MaxClients: 32,
+		FetchedAt:  time.Now(),
+		Channels: []teamspeak.Channel{
+			{ID: 1, Name: "General", Users: []teamspeak.User{{Nickname: "alice"}, {Nickname: "bob"}}},
+			{ID: 2, Name: "Empty"},
+			{ID: 3, Name: "Games", Users: []teamspeak.User{{Nickname: "carol"}}},
+		},
+	}
+
+	svc := newTestService(DisplayConfig{ChannelFields: true, InlineChannels: true})
+	fields := svc.channelFields(state, maxEmbedFields)
+	require.Len(t, fields, 2)
+	require.Equal(t, "General (2)", fields[0].Name)
+	require.Equal(t, "• alice\n• bob", fields[0].Value)
+	require.True(t, fields[1].Inline)
+
+	// Channels past the room left are summarised.
+	fields = svc.channelFields(state, 1)
+	require.Len(t, fields, 1)
+	require.Equal(t, "*…and 2 more channels*", fields[0].Value)
+}
+
+func TestFormatLinks(t *testing.T) {
+	require.Equal(t, "[Website](https://example.com) · [Rules v2](https://example.com/rules)",
+		formatLinks([]Link{{"Website", "https://example.com"}, {"[Rules] v2", "https://example.com/rules"}}))
+	require.Equal(t, "[Wiki](https://en.wikipedia.org/wiki/Go_%28game%29)",
+		formatLinks([]Link{{"Wiki", "https://en.wikipedia.org/wiki/Go_(game)"}}))
+}
+
+func TestNotifyAllowsOnlyGivenMentions(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+
+	fake.handle("POST", "/channels/alerts/messages", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "1", "channel_id": "alerts"}
+	})
+	fake.handle("POST", "/users/@me/channels", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "dm"}
+	})
+
+	ctx := t.Context()
+
+	require.NoError(t, svc.Notify(ctx, "alerts", "👋 **@everyone** joined"))
+	require.NoError(t, svc.Notify(ctx, "alerts", "<@&42> down", "42"))
+	require.NoError(t, svc.DirectMessage(ctx, "user", "<@&42> online"))
+
+	var sent []map[string]any
+
+	for _, r := range append(fake.calls("POST", "/channels/alerts/messages"), fake.calls("POST", "/channels/dm/messages")...) {
+		var m struct {
+			AllowedMentions map[string]any `json:"allowed_mentions"`
+		}
+
+		require.NoError(t, json.Unmarshal(r.Body, &m))
+		sent = append(sent, m.AllowedMentions)
+	}
+
+	require.Len(t, sent, 3)
+	require.Equal(t, map[string]any{"parse": []any{}, "replied_user": false}, sent[0])
+	require.Equal(t, map[string]any{"parse": []any{}, "roles": []any{"42"}, "replied_user": false}, sent[1])
+	require.Equal(t, sent[0], sent[2])
+}
+
+func TestNotifyRejectsForums(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+
+	fake.handle("GET", "/channels/forum", func([]byte) (int, any) { return 200, map[string]any{"id": "forum", "type": 15} })
+
+	err := svc.Notify(t.Context(), "forum", "Server is down")
+	require.ErrorContains(t, err, "channel forum is a forum")
+	require.Empty(t, fake.calls("POST", "/channels/forum/messages"))
+}
+
+func TestPublishAlerts(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.PublishAlerts = true
+
+	fake.handle("GET", "/channels/news", func([]byte) (int, any) { return 200, map[string]any{"id": "news", "type": 5} })
+	fake.handle("GET", "/channels/text", func([]byte) (int, any) { return 200, map[string]any{"id": "text", "type": 0} })
+	fake.handle("POST", "/channels/news/messages", func([]byte) (int, any) { return 200, map[string]any{"id": "m1"} })
+	fake.handle("POST", "/channels/text/messages", func([]byte) (int, any) { return 200, map[string]any{"id": "m2"} })
+
+	const crosspost = "/channels/news/messages/m1/crosspost"
+
+	// Publishing happens after Notify returns, and only in announcement channels.
+	require.NoError(t, svc.Notify(t.Context(), "news", "Server is down"))
+	require.NoError(t, svc.Notify(t.Context(), "text", "Server is down"))
+	require.Eventually(t, func() bool { return len(fake.calls("POST", crosspost)) == 1 }, time.Second, 5*time.Millisecond)
+	svc.wg.Wait()
+	require.Empty(t, fake.calls("POST", "/channels/text/messages/m2/crosspost"))
+
+	// Past Discord's hourly limit, alerts are left unpublished.
+	now := time.Now()
+	for range maxPublishes {
+		svc.publish(t.Context(), session, "news", &discordgo.Message{ID: "m1"}, now)
+	}
+
+	require.Len(t, fake.calls("POST", crosspost), maxPublishes)
+
+	svc.publish(t.Context(), session, "news", &discordgo.Message{ID: "m1"}, now.Add(publishWindow))
+	require.Len(t, fake.calls("POST", crosspost), maxPublishes+1)
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	require.Equal(t, `\*\*bold\*\* \_x\_ \<@&1\> \[a\]\(b\)`, EscapeMarkdown("**bold** _x_ <@&1> [a](b)"))
+	require.Equal(t, "Jörg", EscapeMarkdown("Jörg"))
+}
+
+func TestUploadIconEmoji(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	target := newTestService(DisplayConfig{})
+	svc.targets = []*service{target}
+
+	const endpoint = "/applications/bot/emojis"
+
+	fake.handle("GET", endpoint, func([]byte) (int, any) {
+		return 200, map[string]any{"items": []any{map[string]any{"id": "1", "name": "ts_icon_7"}}}
+	})
+	fake.handle("POST", endpoint, func([]byte) (int, any) { return 200, map[string]any{"id": "2", "name": "ts_icon_9"} })
+
+	ctx := t.Context()
+
+	// An emoji uploaded on a previous run is reused without fetching the icon.
+	require.NoError(t, svc.UploadIconEmoji(ctx, 7, func() ([]byte, error) {
+		t.Fatal("icon fetched although its emoji exists")
+		return nil, nil
+	}))
+	require.Equal(t, "<:ts_icon_7:1>", svc.channelIcon(7))
+	require.Equal(t, "<:ts_icon_7:1>", target.channelIcon(7))
+
+	// The icon is fetched without holding the service lock.
+	png := []byte("\x89PNG\r\n\x1a\n")
+	require.NoError(t, svc.UploadIconEmoji(ctx, 9, func() ([]byte, error) {
+		require.True(t, svc.mu.TryLock())
+		svc.mu.Unlock()
+
+		return png, nil
+	}))
+	require.Equal(t, "<:ts_icon_9:2>", svc.channelIcon(9))
+
+	posts := fake.calls("POST", endpoint)
+	require.Len(t, posts, 1)
+	require.JSONEq(t, `{"name":"ts_icon_9","image":"data:image/png;base64,`+base64.StdEncoding.EncodeToString(png)+`"}`, string(posts[0].Body))
+	require.Len(t, fake.calls("GET", endpoint), 1)
+
+	err := svc.UploadIconEmoji(ctx, 11, func() ([]byte, error) { return make([]byte, maxEmojiSize+1), nil })
+	require.ErrorContains(t, err, "too large")
+	require.Empty(t, svc.channelIcon(11))
+}
+
+func TestTargets(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	mirror := &VoiceMirror{ChannelID: "voice", Format: "{online}"}
+	status := &VoiceStatus{ChannelID: "voice", Format: "{online}"}
+
+	svc := NewService(log, Config{
+		ChannelID:    "main",
+		DetailThread: "Details",
+		Targets: []Target{
+			{ChannelID: "a", Display: DisplayConfig{PresenceTemplates: []string{"{online}"}, VoiceMirror: mirror, VoiceStatus: status}},
+			{ChannelID: "b", Display: DisplayConfig{Style: "mobile"}},
+		},
+	}, DisplayConfig{}).(*service)
+
+	require.Len(t, svc.targets, 2)
+
+	// Each target keeps its own channel and display, but not the options
+	// that only the main service may drive.
+	a, b := svc.targets[0], svc.targets[1]
+	require.Equal(t, "a", a.cfg.ChannelID)
+	require.Empty(t, a.cfg.Targets)
+	require.Nil(t, a.display.PresenceTemplates)
+	require.Nil(t, a.display.VoiceMirror)
+	require.Nil(t, a.display.VoiceStatus)
+	require.Equal(t, "mobile", b.display.Style)
+
+	require.Same(t, a, svc.owner("a"))
+	require.Same(t, b, svc.owner("b"))
+	require.Same(t, svc, svc.owner("main"))
+	require.Same(t, svc, svc.owner("unknown"))
+
+	// A target publishing to a forum post owns that post's thread.
+	b.post = "thread"
+	require.Same(t, b, svc.owner("thread"))
+
+	session := &discordgo.Session{}
+	svc.setTargetSession(session)
+	require.Same(t, session, a.session)
+	require.Same(t, session, b.session)
+	require.Same(t, session, svc.detail.session)
+
+	svc.setTargetSession(nil)
+	require.Nil(t, a.session)
+	require.Nil(t, svc.detail.session)
+}
+
+func TestViewButtonCooldown(t *testing.T) {
+	svc := newTestService(DisplayConfig{ViewButtons: true, ViewRevertAfter: time.Minute, RefreshCooldown: time.Minute})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.ChannelID = "status"
+	svc.messageID = "m"
+	svc.lastState = &teamspeak.State{ServerName: "Game Night", FetchedAt: time.Now()}
+
+	fake.handle("PATCH", "/channels/status/messages/m", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "m", "channel_id": "status"}
+	})
+
+	click := func(id, user, view string) map[string]any {
+		i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ID: id, Token: "tok", Type: discordgo.InteractionMessageComponent,
+			Member:  &discordgo.Member{User: &discordgo.User{ID: user}},
+			Message: &discordgo.Message{ID: "m"},
+		}}
+		svc.onViewButton(session, i, view)
+
+		calls := fake.calls("POST", "/interactions/"+id+"/tok/callback")
+		require.Len(t, calls, 1)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(calls[0].Body, &resp))
+
+		return resp
+	}
+
+	click("1", "alice", ViewSummary)
+	require.Equal(t, ViewSummary, svc.view)
+	require.Len(t, fake.calls("PATCH", "/channels/status/messages/m"), 1)
+
+	// Switching back right away, even by someone else, is refused.
+	resp := click("2", "bob", ViewDetailed)
+	require.EqualValues(t, discordgo.InteractionResponseChannelMessageWithSource, resp["type"])
+	require.Contains(t, resp["data"].(map[string]any)["content"], "You can switch the view again")
+	require.Equal(t, ViewSummary, svc.view)
+
+	// The current view needs no edit and no cooldown.
+	click("3", "bob", ViewSummary)
+	require.Len(t, fake.calls("PATCH", "/channels/status/messages/m"), 1)
+}
+
+func TestRegisterCommandsOnce(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.cfg.ChannelID = "status"
+
+	fake.handle("GET", "/channels/status", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "status", "guild_id": "g"}
+	})
+	fake.handle("PUT", "/applications/bot/guilds/g/commands", func([]byte) (int, any) { return 500, nil })
+
+	// A failed registration is retried on the next connect.
+	require.Error(t, svc.registerCommands())
+
+	fake.handle("PUT", "/applications/bot/guilds/g/commands", func([]byte) (int, any) { return 200, []any{} })
+	require.NoError(t, svc.registerCommands())
+
+	// Reconnects leave the registered commands alone.
+	require.NoError(t, svc.registerCommands())
+	require.Len(t, fake.calls("PUT", "/applications/bot/guilds/g/commands"), 2)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.backoff, svc.maxBackoff = 5*time.Millisecond, 20*time.Millisecond
+
+	var (
+		mu       sync.Mutex
+		attempts []time.Time
+	)
+
+	// The gateway lookup fails, so every open fails before dialing.
+	fake.handle("GET", "/gateway", func([]byte) (int, any) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts = append(attempts, time.Now())
+
+		return 500, nil
+	})
+
+	start := time.Now()
+	svc.startReconnect()
+	svc.startReconnect()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(attempts) == maxOpensPerHour
+	}, 5*time.Second, time.Millisecond)
+
+	// The delay doubles up to the cap.
+	mu.Lock()
+	require.GreaterOrEqual(t, attempts[0].Sub(start), 5*time.Millisecond)
+	require.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), 10*time.Millisecond)
+
+	for i := 2; i < len(attempts); i++ {
+		gap := attempts[i].Sub(attempts[i-1])
+		require.GreaterOrEqual(t, gap, 20*time.Millisecond)
+		require.Less(t, gap, 500*time.Millisecond)
+	}
+	mu.Unlock()
+
+	// The hourly login cap holds further attempts back until the service
+	// stops.
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, fake.calls("GET", "/gateway"), maxOpensPerHour)
+	require.True(t, svc.reconnecting.Load())
+
+	close(svc.done)
+	svc.wg.Wait()
+	require.False(t, svc.reconnecting.Load())
+}
+
+func TestWaitForOpenBudget(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+
+	// Logins older than an hour no longer count.
+	for range maxOpensPerHour {
+		svc.openTimes = append(svc.openTimes, time.Now().Add(-2*time.Hour))
+	}
+
+	require.True(t, svc.waitForOpenBudget())
+	require.Empty(t, svc.openTimes)
+
+	for range maxOpensPerHour {
+		svc.recordOpen()
+	}
+
+	close(svc.done)
+	require.False(t, svc.waitForOpenBudget())
+}
+
+func TestNickname(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	svc := NewService(log, Config{ChannelID: "status"}, DisplayConfig{NicknameFormat: "TS {online}/{max}", NicknameInterval: time.Minute}).(*service)
+	session, fake := newFakeSession(t)
+	svc.session = session
+
+	warnings := func() int {
+		n := 0
+
+		for _, e := range hook.AllEntries() {
+			if e.Level == logrus.WarnLevel {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	const nickPath = "/guilds/g/members/@me/nick"
+
+	state := func(online int) *teamspeak.State {
+		return &teamspeak.State{TotalUsers: online, MaxClients: 32}
+	}
+
+	now := time.Now()
+
+	// A failing guild lookup is warned about once.
+	fake.handle("GET", "/channels/status", func([]byte) (int, any) { return 500, nil })
+	svc.maybeUpdateNickname(state(1), now)
+	svc.maybeUpdateNickname(state(1), now)
+	require.Equal(t, 1, warnings())
+	require.Empty(t, fake.calls("PATCH", nickPath))
+
+	fake.handle("GET", "/channels/status", func([]byte) (int, any) {
+		return 200, map[string]any{"id": "status", "guild_id": "g"}
+	})
+	fake.handle("PATCH", nickPath, func([]byte) (int, any) { return 403, map[string]any{"code": 50013} })
+
+	// A failing change is retried once per interval and warned about once.
+	svc.maybeUpdateNickname(state(1), now)
+	svc.maybeUpdateNickname(state(2), now.Add(time.Second))
+	require.Len(t, fake.calls("PATCH", nickPath), 1)
+	require.Equal(t, 2, warnings())
+
+	svc.maybeUpdateNickname(state(2), now.Add(time.Minute))
+	require.Len(t, fake.calls("PATCH", nickPath), 2)
+	require.Equal(t, 2, warnings())
+
+	// Once it works, an unchanged nickname is not sent again.
+	fake.handle("PATCH", nickPath, func([]byte) (int, any) { return 200, map[string]any{} })
+	svc.maybeUpdateNickname(state(2), now.Add(2*time.Minute))
+	svc.maybeUpdateNickname(state(2), now.Add(4*time.Minute))
+
+	calls := fake.calls("PATCH", nickPath)
+	require.Len(t, calls, 3)
+	require.JSONEq(t, `{"nick": "TS 2/32"}`, string(calls[2].Body))
+	require.Equal(t, "Bot nickname updates recovered", hook.LastEntry().Message)
+
+	// A new failure warns again.
+	fake.handle("PATCH", nickPath, func([]byte) (int, any) { return 403, map[string]any{"code": 50013} })
+	svc.maybeUpdateNickname(state(3), now.Add(5*time.Minute))
+	require.Equal(t, 3, warnings())
+}
+
+func TestPermissions(t *testing.T) {
+	needed := func(svc *service, post string) []string {
+		var names []string
+
+		for _, p := range svc.permissions(post) {
+			if p.needed {
+				names = append(names, p.name)
+			}
+		}
+
+		return names
+	}
+
+	base := []string{"ViewChannel", "SendMessages", "EmbedLinks", "ReadMessageHistory"}
+	require.Equal(t, base, needed(newTestService(DisplayConfig{}), ""))
+
+	svc := newTestService(DisplayConfig{JoinQR: true, ChannelNameFormat: "{online}", NicknameFormat: "TS"})
+	require.Equal(t, append(base, "AttachFiles", "ManageChannels", "ChangeNickname"), needed(svc, ""))
+
+	require.Equal(t, append(base, "SendMessagesInThreads", "ManageThreads"), needed(newTestService(DisplayConfig{}), "post"))
+}
+
+func TestDiagnostics(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	svc := NewService(log, Config{ChannelID: "status"}, DisplayConfig{}).(*service)
+	session, fake := newFakeSession(t)
+	svc.session = session
+	svc.messageID = "m"
+
+	// The lookups run without the service lock, so updates are not held up.
+	locked := false
+	fake.handle("GET", "/channels/status", func([]byte) (int, any) {
+		if svc.mu.TryLock() {
+			svc.mu.Unlock()
+		} else {
+			locked = true
+		}
+
+		return 200, map[string]any{"id": "status", "name": "status", "guild_id": "g"}
+	})
+	fake.handle("GET", "/guilds/g", func([]byte) (int, any) {
+		perms := discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionEmbedLinks
+
+		return 200, map[string]any{"id": "g", "name": "Guild", "roles": []any{
+			map[string]any{"id": "g", "permissions": fmt.Sprint(perms)},
+		}}
+	})
+	fake.handle("GET", "/guilds/g/members/bot", func([]byte) (int, any) {
+		return 200, map[string]any{"user": map[string]any{"id": "bot"}, "roles": []any{}}
+	})
+
+	svc.diagnoseOnce()
+	require.False(t, locked)
+
+	entry := hook.LastEntry()
+	require.Equal(t, logrus.WarnLevel, entry.Level)
+	require.Equal(t, "Guild", entry.Data["guild"])
+	require.Equal(t, "m", entry.Data["message_id"])
+	require.Equal(t, "ViewChannel,SendMessages,EmbedLinks", entry.Data["permissions"])
+	require.Equal(t, "ReadMessageHistory", entry.Data["missing"])
+
+	// Reconnects do not log them again.
+	svc.diagnoseOnce()
+	require.Len(t, hook.AllEntries(), 1)
+}
+
+func TestAdoptOwnMessage(t *testing.T) {
+	svc := newTestService(DisplayConfig{})
+	bot := &discordgo.User{ID: "bot"}
+	status := []*discordgo.MessageEmbed{{Author: &discordgo.MessageEmbedAuthor{IconURL: statusIcon}}}
+
+	// An alert the bot posted later is not taken for the status message.
+	require.False(t, svc.adoptOwnMessage([]*discordgo.Message{
+		{ID: "2", Author: bot, Embeds: []*discordgo.MessageEmbed{{Title: "Server offline"}}},
+	}, "bot"))
+
+	require.True(t, svc.adoptOwnMessage([]*discordgo.Message{
+		{ID: "2", Author: bot, Embeds: []*discordgo.MessageEmbed{{Title: "Server offline"}}},
+		{ID: "1", Author: bot, Embeds: status},
+	}, "bot"))
+	require.Equal(t, "1", svc.messageID)
+}
+
+func TestSilenceEvents(t *testing.T) {
+	values := make([]any, 0, len(silenceEvents))
+	for _, choice := range silenceEvents {
+		values = append(values, choice.Value)
+	}
+
+	want := []any{SilenceAll}
+	for _, event := range notify.Events {
+		want = append(want, event)
+	}
+
+	require.Equal(t, want, values)
+}
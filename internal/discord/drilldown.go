@@ -0,0 +1,176 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/render"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// channelSelectID is the custom id of the channel drill-down menu.
+	channelSelectID = "channel-detail"
+
+	// maxSelectOptions is Discord's limit on options in a select menu.
+	maxSelectOptions = 25
+
+	// maxReplyLength is Discord's message content limit.
+	maxReplyLength = 2000
+)
+
+// channelSelect returns a select menu of the occupied channels, or false when
+// there are none (Discord rejects empty menus). Option values are
+// "server:channel id" since ids repeat across aggregated servers.
+func (s *service) channelSelect(state *teamspeak.State) (discordgo.ActionsRow, bool) {
+	var options []discordgo.SelectMenuOption
+
+	for _, ch := range state.Channels {
+		if len(ch.Users) == 0 {
+			continue
+		}
+
+		if len(options) == maxSelectOptions {
+			break
+		}
+
+		users := "1 user"
+		if len(ch.Users) != 1 {
+			users = fmt.Sprintf("%d users", len(ch.Users))
+		}
+
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncateRunes(ch.Name, 100),
+			Value:       fmt.Sprintf("%d:%d", ch.Users[0].Server, ch.ID),
+			Description: users,
+		})
+	}
+
+	if len(options) == 0 {
+		return discordgo.ActionsRow{}, false
+	}
+
+	return discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.SelectMenu{
+			CustomID:    channelSelectID,
+			Placeholder: "Who's in a channel?",
+			Options:     options,
+		},
+	}}, true
+}
+
+// onChannelSelect replies, only to the viewer, with the full detail of the
+// chosen channel's users from the last state.
+func (s *service) onChannelSelect(i *discordgo.InteractionCreate) *discordgo.InteractionResponse {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return ephemeral("No channel selected.")
+	}
+
+	s.mu.Lock()
+	state := s.lastState
+	s.mu.Unlock()
+
+	if state == nil {
+		return ephemeral("The bot is still starting, try again in a moment.")
+	}
+
+	ch, ok := findChannel(state, values[0])
+	if !ok || len(ch.Users) == 0 {
+		return ephemeral("That channel is empty now.")
+	}
+
+	return ephemeral(channelDetail(ch, dataTime(state), s.display.Emojis))
+}
+
+// findChannel resolves a channelSelect option value.
+func findChannel(state *teamspeak.State, value string) (teamspeak.Channel, bool) {
+	serverPart, idPart, _ := strings.Cut(value, ":")
+
+	server, err := strconv.Atoi(serverPart)
+	if err != nil {
+		return teamspeak.Channel{}, false
+	}
+
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return teamspeak.Channel{}, false
+	}
+
+	for _, ch := range state.Channels {
+		if ch.ID == id && (len(ch.Users) == 0 || ch.Users[0].Server == server) {
+			return ch, true
+		}
+	}
+
+	return teamspeak.Channel{}, false
+}
+
+// channelDetail lists every user in the channel with their audio state, away
+// message, idle time and session start, whatever the embed shows.
+func channelDetail(ch teamspeak.Channel, now time.Time, emojis render.Emojis) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "**#%s** `%d`\n", ch.Name, len(ch.Users))
+
+	for _, user := range ch.Users {
+		line := "• **" + user.Nickname + "**"
+		if detail := userDetail(user, now, emojis); detail != "" {
+			line += " — " + detail
+		}
+
+		b.WriteString(line + "\n")
+	}
+
+	return truncateLines(strings.TrimSuffix(b.String(), "\n"), maxReplyLength)
+}
+
+// userDetail describes a user's audio state, away message, idle time and
+// session start, or returns "" when there is nothing to say.
+func userDetail(user teamspeak.User, now time.Time, emojis render.Emojis) string {
+	var parts []string
+
+	switch {
+	case user.OutputMuted:
+		parts = append(parts, withEmoji(emojis.Deafened, "deafened"))
+	case user.InputMuted:
+		parts = append(parts, withEmoji(emojis.Muted, "muted"))
+	}
+
+	if user.IsRecording {
+		parts = append(parts, withEmoji(emojis.Recording, "recording"))
+	}
+
+	if user.Away {
+		away := withEmoji(emojis.Away, "away")
+		if user.AwayMessage != "" {
+			away += ": " + truncateRunes(user.AwayMessage, 100)
+		}
+
+		parts = append(parts, away)
+	}
+
+	if user.IdleTime >= time.Minute {
+		parts = append(parts, withEmoji(emojis.Idle, formatIdleTime(user.IdleTime)+" idle"))
+	}
+
+	if !user.ConnectedAt.IsZero() {
+		parts = append(parts, fmt.Sprintf("connected %s (%s)",
+			relativeTimestamp(user.ConnectedAt), formatDuration(now.Sub(user.ConnectedAt))))
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// withEmoji prefixes text with emoji, if there is one.
+func withEmoji(emoji, text string) string {
+	if emoji == "" {
+		return text
+	}
+
+	return emoji + " " + text
+}
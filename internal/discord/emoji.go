@@ -0,0 +1,175 @@
+package discord
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxEmojiSize is Discord's upload limit for emoji images.
+const maxEmojiSize = 256 * 1024
+
+// applicationEmojis is the response of the application emoji list endpoint.
+type applicationEmojis struct {
+	Items []*discordgo.Emoji `json:"items"`
+}
+
+// UploadIconEmoji makes a TeamSpeak icon available as an application emoji,
+// reusing one uploaded on a previous run, and shows it in front of channels
+// using that icon from the next update on. fetch is only called when the icon
+// actually has to be uploaded.
+func (s *service) UploadIconEmoji(ctx context.Context, iconID uint32, fetch func() ([]byte, error)) error {
+	if err := s.uploadIconEmoji(ctx, iconID, fetch); err != nil {
+		return err
+	}
+
+	// Application emojis work in every guild, so targets share the upload.
+	s.mu.Lock()
+	markup := s.iconEmojis[iconID]
+	s.mu.Unlock()
+
+	if markup == "" {
+		return nil
+	}
+
+	for _, t := range s.targets {
+		t.mu.Lock()
+		t.iconEmojis[iconID] = markup
+		t.mu.Unlock()
+	}
+
+	return nil
+}
+
+// uploadIconEmoji looks up or uploads the emoji of iconID. Only the service
+// state is read and written under s.mu; the icon fetch and the Discord calls,
+// which can take a while, run without it.
+func (s *service) uploadIconEmoji(ctx context.Context, iconID uint32, fetch func() ([]byte, error)) error {
+	s.mu.Lock()
+	session := s.session
+	known := s.channelIcon(iconID) != ""
+	s.mu.Unlock()
+
+	if session == nil {
+		return fmt.Errorf("not connected to Discord")
+	}
+
+	if known {
+		return nil
+	}
+
+	name := fmt.Sprintf("ts_icon_%d", iconID)
+
+	e, err := s.applicationEmoji(ctx, session, name)
+	if err != nil {
+		return err
+	}
+
+	if e == nil {
+		if e, err = createApplicationEmoji(ctx, session, name, iconID, fetch); err != nil {
+			return err
+		}
+
+		s.log.WithField("emoji", name).Info("Uploaded channel icon as application emoji")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appEmojis[name] = e
+	s.iconEmojis[iconID] = e.MessageFormat()
+
+	return nil
+}
+
+// applicationEmoji returns the application emoji called name, or nil, listing
+// the application's emojis on first use.
+func (s *service) applicationEmoji(ctx context.Context, session *discordgo.Session, name string) (*discordgo.Emoji, error) {
+	s.mu.Lock()
+	loaded, e := s.appEmojis != nil, s.appEmojis[name]
+	s.mu.Unlock()
+
+	if loaded {
+		return e, nil
+	}
+
+	endpoint := discordgo.EndpointApplication(applicationID(session)) + "/emojis"
+
+	body, err := session.RequestWithBucketID(http.MethodGet, endpoint, nil, endpoint, discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list application emojis: %w", err)
+	}
+
+	var list applicationEmojis
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode application emojis: %w", err)
+	}
+
+	emojis := make(map[string]*discordgo.Emoji, len(list.Items))
+	for _, e := range list.Items {
+		emojis[e.Name] = e
+	}
+
+	s.mu.Lock()
+	s.appEmojis = emojis
+	s.mu.Unlock()
+
+	return emojis[name], nil
+}
+
+// createApplicationEmoji uploads the icon fetch returns as an application
+// emoji called name.
+func createApplicationEmoji(ctx context.Context, session *discordgo.Session, name string, iconID uint32, fetch func() ([]byte, error)) (*discordgo.Emoji, error) {
+	data, err := fetch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch icon %d: %w", iconID, err)
+	}
+
+	if len(data) > maxEmojiSize {
+		return nil, fmt.Errorf("icon %d is too large for an emoji (%d bytes)", iconID, len(data))
+	}
+
+	image := fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(data), base64.StdEncoding.EncodeToString(data))
+	endpoint := discordgo.EndpointApplication(applicationID(session)) + "/emojis"
+
+	body, err := session.RequestWithBucketID(http.MethodPost, endpoint, map[string]string{"name": name, "image": image},
+		endpoint, discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload icon emoji: %w", err)
+	}
+
+	var e discordgo.Emoji
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode uploaded emoji: %w", err)
+	}
+
+	return &e, nil
+}
+
+// applicationID returns the bot's application id; for bots it matches the bot
+// user id when the gateway has not reported it separately.
+func applicationID(session *discordgo.Session) string {
+	if app := session.State.Application; app != nil && app.ID != "" {
+		return app.ID
+	}
+
+	return session.State.User.ID
+}
+
+// channelIcon returns the emoji shown in front of a channel with the given
+// icon, preferring configured mappings over uploaded emojis.
+func (s *service) channelIcon(iconID uint32) string {
+	if iconID == 0 {
+		return ""
+	}
+
+	if e, ok := s.display.IconEmojis[iconID]; ok {
+		return e
+	}
+
+	return s.iconEmojis[iconID]
+}
@@ -0,0 +1,87 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// noChannels is the channel list shown when there is nothing to list.
+const noChannels = "*No active channels*"
+
+// EmptyState replaces the bare "No active channels" with a friendlier line
+// while nobody is online.
+type EmptyState struct {
+	Enabled bool
+	// Phrases are rotated daily while the busy window is known and ahead;
+	// {start} and {end} are replaced with its hours and {greeting} with
+	// e.g. "Good evening".
+	Phrases []string
+	// Fallback is shown instead when no busy window is known, or during it.
+	Fallback string
+}
+
+// Forecast is what recorded history says about the busy hours.
+type Forecast struct {
+	Hint  string // Footer hint, e.g. "Usually busy around 20:00–23:00"; empty shows none
+	Known bool   // Start and End hold the busy window
+	Start int    // First busy hour of day
+	End   int    // Hour of day the busy window ends, exclusive; may wrap past midnight
+}
+
+// emptyText returns what the channel list shows when it has no channels: the
+// empty state while nobody is online, else noChannels. Must be called with
+// s.mu held.
+func (s *service) emptyText(state *teamspeak.State) string {
+	es := s.display.EmptyState
+	if !es.Enabled || state.TotalUsers > 0 || s.isStale(state, time.Now()) {
+		return noChannels
+	}
+
+	if text := emptyPhrase(es, s.forecast, time.Now()); text != "" {
+		return "*" + text + "*"
+	}
+
+	return noChannels
+}
+
+// emptyPhrase picks the phrase for now, in now's time zone.
+func emptyPhrase(es EmptyState, f Forecast, now time.Time) string {
+	if !f.Known || len(es.Phrases) == 0 || inWindow(now.Hour(), f.Start, f.End) {
+		return es.Fallback
+	}
+
+	phrase := es.Phrases[now.YearDay()%len(es.Phrases)]
+
+	return strings.NewReplacer(
+		"{start}", fmt.Sprintf("%02d:00", f.Start),
+		"{end}", fmt.Sprintf("%02d:00", f.End),
+		"{greeting}", greeting(now.Hour()),
+	).Replace(phrase)
+}
+
+// inWindow reports whether hour falls in [start, end), which may wrap past
+// midnight.
+func inWindow(hour, start, end int) bool {
+	if start <= end {
+		return hour >= start && hour < end
+	}
+
+	return hour >= start || hour < end
+}
+
+// greeting returns the greeting for an hour of day.
+func greeting(hour int) string {
+	switch {
+	case hour >= 5 && hour < 12:
+		return "Good morning"
+	case hour >= 12 && hour < 18:
+		return "Good afternoon"
+	case hour >= 18 && hour < 22:
+		return "Good evening"
+	default:
+		return "Good night"
+	}
+}
@@ -0,0 +1,102 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRequest is a REST call received by fakeDiscord.
+type fakeRequest struct {
+	Method string
+	Path   string // Path below the API root, e.g. /channels/1/messages
+	Body   []byte
+}
+
+// fakeDiscord answers the REST calls of a session in place of Discord.
+// Handlers are keyed by method and path; calls without one get an empty
+// object.
+type fakeDiscord struct {
+	mu       sync.Mutex
+	requests []fakeRequest
+	handlers map[string]func(body []byte) (int, any)
+}
+
+// newFakeSession returns a session whose REST calls go to a fakeDiscord.
+func newFakeSession(t *testing.T) (*discordgo.Session, *fakeDiscord) {
+	t.Helper()
+
+	session, err := discordgo.New("Bot test")
+	require.NoError(t, err)
+
+	fake := &fakeDiscord{handlers: make(map[string]func([]byte) (int, any))}
+	session.Client = &http.Client{Transport: fake}
+	session.State.User = &discordgo.User{ID: "bot"}
+	session.MaxRestRetries = 0
+
+	return session, fake
+}
+
+// handle sets the reply to method on path.
+func (f *fakeDiscord) handle(method, path string, h func(body []byte) (int, any)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers[method+" "+path] = h
+}
+
+// calls returns the requests received for method on path.
+func (f *fakeDiscord) calls(method, path string) []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out []fakeRequest
+
+	for _, r := range f.requests {
+		if r.Method == method && r.Path == path {
+			out = append(out, r)
+		}
+	}
+
+	return out
+}
+
+func (f *fakeDiscord) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/api/v"+discordgo.APIVersion)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{Method: req.Method, Path: path, Body: body})
+	h := f.handlers[req.Method+" "+path]
+	f.mu.Unlock()
+
+	status, reply := http.StatusOK, any(map[string]any{})
+	if h != nil {
+		status, reply = h(body)
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/render"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// channelFields renders the channel list as embed fields, using at most room
+// fields unless the embed is paginated. With ChannelFields, each occupied
+// channel is a field of its own. With Paginate, the whole list is packed into
+// as many fields as it needs, for paginate to spread over messages; otherwise
+// it is cut to fit a single field.
+func (s *service) channelFields(state *teamspeak.State, room int) []*discordgo.MessageEmbedField {
+	name := s.label("📢", "Channels")
+	listed := len(state.Channels) > 0 && s.activeView() != ViewSummary
+
+	if s.display.ChannelFields && listed {
+		if fields := s.perChannelFields(state, room); len(fields) > 0 {
+			return fields
+		}
+	}
+
+	if s.display.Paginate && listed {
+		if blocks, sep := s.channelBlocks(state); len(blocks) > 0 {
+			values := packBlocks(blocks, sep, maxFieldValue)
+			fields := make([]*discordgo.MessageEmbedField, 0, len(values))
+
+			for i, value := range values {
+				if i > 0 {
+					name = "\u200b"
+				}
+
+				fields = append(fields, &discordgo.MessageEmbedField{Name: name, Value: value})
+			}
+
+			return fields
+		}
+	}
+
+	content := s.buildChannelList(state, maxFieldValue)
+	if content == "" {
+		return nil
+	}
+
+	return []*discordgo.MessageEmbedField{{Name: name, Value: content}}
+}
+
+// perChannelFields renders one field per occupied channel, named after the
+// channel and listing its users. Without Paginate, channels past room fields
+// are summarised in the last one.
+func (s *service) perChannelFields(state *teamspeak.State, room int) []*discordgo.MessageEmbedField {
+	var sections []render.Section
+
+	for _, sec := range render.Build(state, s.renderOptions()).Sections {
+		if len(sec.Lines) > 0 {
+			sections = append(sections, sec)
+		}
+	}
+
+	rest := 0
+	if !s.display.Paginate && len(sections) > room {
+		keep := max(room-1, 0)
+		rest = len(sections) - keep
+		sections = sections[:keep]
+	}
+
+	fields := make([]*discordgo.MessageEmbedField, 0, len(sections)+1)
+
+	for _, sec := range sections {
+		ch := channelByID(state, sec.ID)
+
+		name := sec.Name
+		if icon := s.channelIcon(sec.IconID); icon != "" {
+			name = icon + " " + name
+		}
+
+		lines := make([]string, 0, len(sec.Lines)+1)
+		if topic := topicLine(sec); topic != "" {
+			lines = append(lines, topic)
+		}
+
+		for i := range sec.Lines {
+			lines = append(lines, s.userLine(state, ch, sec, i, "• "))
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   truncateRunes(fmt.Sprintf("%s (%d)", name, len(sec.Lines)), maxFieldName),
+			Value:  truncateLines(strings.Join(lines, "\n"), maxFieldValue),
+			Inline: s.display.InlineChannels,
+		})
+	}
+
+	if rest > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "\u200b",
+			Value: fmt.Sprintf("*…and %d more channels*", rest),
+		})
+	}
+
+	return fields
+}
+
+// endRow pads a trailing row of inline fields to Discord's three per row, so
+// the inline fields that follow start a row of their own.
+func endRow(fields []*discordgo.MessageEmbedField) []*discordgo.MessageEmbedField {
+	inline := 0
+	for i := len(fields) - 1; i >= 0 && fields[i].Inline; i-- {
+		inline++
+	}
+
+	for ; inline%3 != 0; inline++ {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "\u200b", Value: "\u200b", Inline: true})
+	}
+
+	return fields
+}
@@ -0,0 +1,263 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Forum configures the status post used when the status channel is a forum,
+// where messages can only be posted inside posts.
+type Forum struct {
+	Title      string // Post title, with the channel name placeholders (default: "{server}")
+	OnlineTag  string // Name of the forum tag applied while the server is up
+	OfflineTag string // Name of the forum tag applied while the data is stale
+}
+
+// maxChannelName is the longest channel or post name Discord accepts.
+const maxChannelName = 100
+
+// channel returns the channel the status messages are in: the forum post
+// when the status channel is a forum, the status channel otherwise. Must be
+// called with s.mu held.
+func (s *service) channel() string {
+	if s.post != "" {
+		return s.post
+	}
+
+	return s.cfg.ChannelID
+}
+
+// findOrCreatePost adopts the bot's post in the status channel when it is a
+// forum, or starts one with a placeholder embed. Its first message is the
+// status message, and has the id of the post. It reports false for channels
+// that are not forums. Must be called with s.mu held.
+func (s *service) findOrCreatePost(forum *discordgo.Channel) (bool, error) {
+	if forum.Type != discordgo.ChannelTypeGuildForum {
+		s.post = ""
+
+		return false, nil
+	}
+
+	s.forumTags = make(map[string]string, len(forum.AvailableTags))
+	for _, tag := range forum.AvailableTags {
+		s.forumTags[strings.ToLower(tag.Name)] = tag.ID
+	}
+
+	for _, name := range []string{s.cfg.Forum.OnlineTag, s.cfg.Forum.OfflineTag} {
+		if name != "" && s.forumTags[strings.ToLower(name)] == "" {
+			s.log.WithField("tag", name).Warn("Forum has no such tag; create it in the forum settings")
+		}
+	}
+
+	if post := s.ownPost(forum); post != nil {
+		s.adoptPost(post)
+		s.log.WithField("post_id", post.ID).Info("Found existing forum post")
+
+		return true, nil
+	}
+
+	if err := s.startPost(context.Background()); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// startPost starts the status post with a placeholder embed. Must be called
+// with s.mu held.
+func (s *service) startPost(ctx context.Context) error {
+	title := "Server status"
+	if s.lastState != nil {
+		title = s.postTitle(s.lastState, time.Now())
+	}
+
+	post, err := s.session.ForumThreadStartComplex(s.cfg.ChannelID, &discordgo.ThreadStart{
+		Name:                title,
+		AutoArchiveDuration: threadArchiveMinutes,
+	}, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{s.buildEmbed(nil)}}, discordgo.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	s.adoptPost(post)
+	s.lastPostRename = time.Now()
+	s.log.WithField("post_id", post.ID).Info("Created forum post")
+
+	return nil
+}
+
+// repostPost replaces the status post whose starter message is gone or
+// unusable: a message posted inside the post would not be its first, and
+// would not be found again after a restart. Must be called with s.mu held.
+func (s *service) repostPost(ctx context.Context) error {
+	old := s.post
+
+	if err := s.startPost(ctx); err != nil {
+		return fmt.Errorf("failed to start new forum post: %w", err)
+	}
+
+	if _, err := s.session.ChannelDelete(old, discordgo.WithContext(ctx)); err != nil && !isUnknownChannel(err) {
+		s.log.WithError(err).WithField("post_id", old).Warn("Failed to delete old forum post")
+	}
+
+	return nil
+}
+
+// ownPost returns the bot's newest status post in the forum, open or
+// archived, or nil. Posts count when their starter message is a status
+// message, so other posts of the bot, and a post whose status message was
+// deleted, are left alone. Lookup failures are logged and treated as no
+// post. Must be called with s.mu held.
+func (s *service) ownPost(forum *discordgo.Channel) *discordgo.Channel {
+	botID := s.session.State.User.ID
+
+	isStatusPost := func(th *discordgo.Channel) bool {
+		if th.OwnerID != botID {
+			return false
+		}
+
+		starter, err := s.session.ChannelMessage(th.ID, th.ID)
+		if err != nil {
+			if !isUnknownMessage(err) {
+				s.log.WithError(err).WithField("post_id", th.ID).Warn("Failed to fetch forum post message")
+			}
+
+			return false
+		}
+
+		return isStatusMessage(starter, botID)
+	}
+
+	active, err := s.session.GuildThreadsActive(forum.GuildID)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to list active forum posts")
+	} else {
+		for _, th := range active.Threads {
+			if th.ParentID == forum.ID && isStatusPost(th) {
+				return th
+			}
+		}
+	}
+
+	archived, err := s.session.ThreadsArchived(forum.ID, nil, 50)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to list archived forum posts")
+
+		return nil
+	}
+
+	for _, th := range archived.Threads {
+		if isStatusPost(th) {
+			return th
+		}
+	}
+
+	return nil
+}
+
+// adoptPost makes post the status post. Must be called with s.mu held.
+func (s *service) adoptPost(post *discordgo.Channel) {
+	s.post = post.ID
+	s.messageID = post.ID
+	s.lastPostTitle = post.Name
+	s.lastPostTags = strings.Join(post.AppliedTags, ",")
+}
+
+// maybeUpdatePost keeps the post title and the online/offline tag in step
+// with the state. Titles count as renames and share their rate limit; tags
+// are applied right away. Must be called with s.mu held.
+func (s *service) maybeUpdatePost(ctx context.Context, state *teamspeak.State) {
+	if s.post == "" || state == nil {
+		return
+	}
+
+	now := time.Now()
+	edit := &discordgo.ChannelEdit{}
+	changed := false
+
+	title := s.postTitle(state, now)
+	if title != s.lastPostTitle && now.Sub(s.lastPostRename) >= channelRenameInterval {
+		edit.Name = title
+		changed = true
+	}
+
+	tags := s.postTags(state, now)
+	if joined := strings.Join(tags, ","); tags != nil && joined != s.lastPostTags {
+		edit.AppliedTags = &tags
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+
+	if _, err := s.session.ChannelEdit(s.post, edit, discordgo.WithContext(ctx)); err != nil {
+		s.log.WithError(err).Warn("Failed to update forum post")
+
+		return
+	}
+
+	if edit.Name != "" {
+		s.lastPostTitle = edit.Name
+		s.lastPostRename = now
+	}
+
+	if edit.AppliedTags != nil {
+		s.lastPostTags = strings.Join(tags, ",")
+	}
+
+	s.log.WithFields(logrus.Fields{"title": s.lastPostTitle, "tags": s.lastPostTags}).Debug("Updated forum post")
+}
+
+// postTitle formats the post title, cut to what Discord accepts.
+func (s *service) postTitle(state *teamspeak.State, now time.Time) string {
+	format := s.cfg.Forum.Title
+	if format == "" {
+		format = "{server}"
+	}
+
+	title := strings.TrimSpace(s.formatName(format, state, now))
+	if title == "" {
+		title = "Server status"
+	}
+
+	return truncateRunes(title, maxChannelName)
+}
+
+// postTags returns the tag ids the post should have: the offline tag while
+// the data is stale, the online tag otherwise. Tags other than these two are
+// not touched. It returns nil when neither tag is configured.
+func (s *service) postTags(state *teamspeak.State, now time.Time) []string {
+	online := s.forumTags[strings.ToLower(s.cfg.Forum.OnlineTag)]
+	offline := s.forumTags[strings.ToLower(s.cfg.Forum.OfflineTag)]
+
+	if online == "" && offline == "" {
+		return nil
+	}
+
+	want := online
+	if s.isStale(state, now) {
+		want = offline
+	}
+
+	tags := []string{}
+
+	for _, id := range strings.Split(s.lastPostTags, ",") {
+		if id != "" && id != online && id != offline {
+			tags = append(tags, id)
+		}
+	}
+
+	if want != "" {
+		tags = append(tags, want)
+	}
+
+	return tags
+}
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"github.com/samcm/ts-discord-status/internal/qr"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// joinQRName is the attachment name of the join QR code.
+	joinQRName = "join.png"
+
+	// joinQRScale is the pixels per module. Discord shows the thumbnail
+	// small but opens it full size, where it has to scan from a screen.
+	joinQRScale = 8
+)
+
+// refreshJoinQR regenerates the join QR code when the join link changes and
+// marks the attachments for upload. Must be called with s.mu held.
+func (s *service) refreshJoinQR(state *teamspeak.State) {
+	if !s.display.JoinQR || state == nil {
+		return
+	}
+
+	address := s.display.ServerAddress
+	if address == "" {
+		address = state.Address
+	}
+
+	uri := teamspeak.JoinURI(address, s.display.ServerPassword)
+	if uri == s.joinURI {
+		return
+	}
+
+	s.joinURI = uri
+	s.joinQR = nil
+	s.imageDirty = true
+
+	if uri == "" {
+		return
+	}
+
+	code, err := qr.Encode(uri)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to encode join QR code")
+
+		return
+	}
+
+	if s.joinQR, err = code.PNG(joinQRScale); err != nil {
+		s.log.WithError(err).Warn("Failed to render join QR code")
+	}
+}
@@ -0,0 +1,151 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/metrics"
+)
+
+// Discord's embed limits, in characters. An embed over any of them is
+// rejected as a whole.
+const (
+	maxEmbedFields      = 25
+	maxEmbedTitle       = 256
+	maxEmbedDescription = 4096
+	maxAuthorName       = 256
+	maxFooterText       = 2048
+	maxFieldName        = 256
+	maxFieldValue       = 1024
+	maxEmbedTotal       = 6000
+)
+
+// fitEmbed trims e in place to Discord's limits and describes each trim.
+// Trimming is deterministic, so the same content always renders the same:
+// texts are cut on line boundaries where possible, fields past the 25th are
+// dropped, and an embed still over the total loses text from its last field
+// backwards, then from the description and the footer.
+func fitEmbed(e *discordgo.MessageEmbed) []string {
+	var warnings []string
+
+	cut := func(what string, s *string, limit int, lines bool) {
+		n := utf8.RuneCountInString(*s)
+		if n <= limit {
+			return
+		}
+
+		if lines {
+			*s = cutLines(*s, limit)
+		} else {
+			*s = truncateRunes(*s, limit)
+		}
+
+		warnings = append(warnings, fmt.Sprintf("%s cut from %d to %d characters", what, n, utf8.RuneCountInString(*s)))
+	}
+
+	cut("title", &e.Title, maxEmbedTitle, false)
+	cut("description", &e.Description, maxEmbedDescription, true)
+
+	if e.Author != nil {
+		cut("author", &e.Author.Name, maxAuthorName, false)
+	}
+
+	if e.Footer != nil {
+		cut("footer", &e.Footer.Text, maxFooterText, false)
+	}
+
+	if n := len(e.Fields); n > maxEmbedFields {
+		e.Fields = e.Fields[:maxEmbedFields]
+		warnings = append(warnings, fmt.Sprintf("%d of %d fields dropped", n-maxEmbedFields, n))
+	}
+
+	for _, f := range e.Fields {
+		cut(fmt.Sprintf("field %q name", f.Name), &f.Name, maxFieldName, false)
+		cut(fmt.Sprintf("field %q value", f.Name), &f.Value, maxFieldValue, true)
+	}
+
+	for i := len(e.Fields) - 1; i >= 0 && embedTotal(e) > maxEmbedTotal; i-- {
+		f := e.Fields[i]
+		keep := utf8.RuneCountInString(f.Value) - (embedTotal(e) - maxEmbedTotal)
+
+		// A field that cannot keep a line of its own goes entirely.
+		if keep < 32 {
+			e.Fields = append(e.Fields[:i], e.Fields[i+1:]...)
+			warnings = append(warnings, fmt.Sprintf("field %q dropped to fit the %d character total", f.Name, maxEmbedTotal))
+
+			continue
+		}
+
+		cut(fmt.Sprintf("field %q value", f.Name), &f.Value, keep, true)
+	}
+
+	if over := embedTotal(e) - maxEmbedTotal; over > 0 {
+		cut("description", &e.Description, max(0, utf8.RuneCountInString(e.Description)-over), true)
+	}
+
+	if over := embedTotal(e) - maxEmbedTotal; over > 0 && e.Footer != nil {
+		cut("footer", &e.Footer.Text, max(1, utf8.RuneCountInString(e.Footer.Text)-over), false)
+	}
+
+	return warnings
+}
+
+// fit trims the embed to Discord's limits. Trims are counted on every
+// render and logged when they differ from the previous render's, so a large
+// server does not log the same warning with every update. Must be called with
+// s.mu held.
+func (s *service) fit(e *discordgo.MessageEmbed) *discordgo.MessageEmbed {
+	warnings := fitEmbed(e)
+	if len(warnings) > 0 {
+		metrics.Error(metrics.ErrorEmbedLimit)
+	}
+
+	if joined := strings.Join(warnings, "; "); joined != s.lastLimitWarnings {
+		s.lastLimitWarnings = joined
+
+		if joined != "" {
+			s.log.WithField("trimmed", joined).Warn("Embed exceeded Discord's limits and was trimmed")
+		}
+	}
+
+	return e
+}
+
+// embedTotal counts the characters Discord's 6000 character limit applies to.
+func embedTotal(e *discordgo.MessageEmbed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+
+	if e.Author != nil {
+		n += utf8.RuneCountInString(e.Author.Name)
+	}
+
+	if e.Footer != nil {
+		n += utf8.RuneCountInString(e.Footer.Text)
+	}
+
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+
+	return n
+}
+
+// cutLines cuts s to at most limit characters on a line boundary, marking
+// the cut, and closes a code block the cut left open.
+func cutLines(s string, limit int) string {
+	const fence, more = "\n```", "\n…"
+
+	if limit <= utf8.RuneCountInString(fence+more) {
+		return truncateRunes(s, max(limit, 1))
+	}
+
+	cut := truncateLines(s, limit-len(fence)-utf8.RuneCountInString(more))
+	if strings.Count(cut, "```")%2 == 1 {
+		return cut + fence
+	}
+
+	return cut + more
+}
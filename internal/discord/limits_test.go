@@ -0,0 +1,93 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFitEmbed(t *testing.T) {
+	lines := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "user %03d\n", i)
+		}
+
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+
+	e := &discordgo.MessageEmbed{
+		Title:       strings.Repeat("t", 300),
+		Description: "```\n" + lines(30) + "\n```",
+		Footer:      &discordgo.MessageEmbedFooter{Text: "footer"},
+	}
+
+	for i := 0; i < 30; i++ {
+		e.Fields = append(e.Fields, &discordgo.MessageEmbedField{Name: fmt.Sprintf("Channel %d", i), Value: "```\n" + lines(200) + "\n```"})
+	}
+
+	warnings := fitEmbed(e)
+	require.NotEmpty(t, warnings)
+	requireWithinLimits(t, e)
+
+	require.Equal(t, maxEmbedTitle, len([]rune(e.Title)))
+	require.Contains(t, warnings, "5 of 30 fields dropped")
+
+	// Cut code blocks are closed again, and cuts end on whole lines.
+	for _, f := range e.Fields {
+		require.Equal(t, 0, strings.Count(f.Value, "```")%2, f.Name)
+		require.True(t, strings.HasSuffix(f.Value, "\n```"), f.Name)
+	}
+
+	// The same content trims the same way, and content within the limits is
+	// left alone.
+	again := fitEmbed(e)
+	require.Empty(t, again)
+
+	small := &discordgo.MessageEmbed{Title: "ok", Fields: []*discordgo.MessageEmbedField{{Name: "a", Value: "b"}}}
+	require.Empty(t, fitEmbed(small))
+	require.Equal(t, "b", small.Fields[0].Value)
+}
+
+func TestPaginate(t *testing.T) {
+	var blocks []string
+	for i := 0; i < 60; i++ {
+		blocks = append(blocks, fmt.Sprintf("**#channel %02d** `1`\nㅤ• %s", i, strings.Repeat("u", 200)))
+	}
+
+	// A block longer than a field is split on line boundaries.
+	blocks = append(blocks, "**#lobby**\n"+strings.TrimSuffix(strings.Repeat("ㅤ• user\n", 200), "\n"))
+
+	values := packBlocks(blocks, "\n\n", maxFieldValue)
+	for _, v := range values {
+		require.LessOrEqual(t, len([]rune(v)), maxFieldValue)
+	}
+
+	require.Equal(t, 260, strings.Count(strings.Join(values, "\n"), "ㅤ• "))
+
+	e := &discordgo.MessageEmbed{Title: "Server", Footer: &discordgo.MessageEmbedFooter{Text: "Last updated"}}
+	for _, v := range values {
+		e.Fields = append(e.Fields, &discordgo.MessageEmbedField{Name: "\u200b", Value: v})
+	}
+
+	pages := paginate(e)
+	require.Greater(t, len(pages), 1)
+
+	var fields int
+
+	for i, p := range pages {
+		require.Empty(t, fitEmbed(p), "page %d", i+1)
+		fields += len(p.Fields)
+	}
+
+	require.Equal(t, len(values), fields)
+	require.Equal(t, fmt.Sprintf("Page 1/%d · Last updated", len(pages)), pages[0].Footer.Text)
+	require.Equal(t, "Server (continued)", pages[1].Title)
+
+	// Embeds within the limits stay whole.
+	small := &discordgo.MessageEmbed{Fields: e.Fields[:1]}
+	require.Equal(t, []*discordgo.MessageEmbed{small}, paginate(small))
+}
@@ -0,0 +1,127 @@
+package discord
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+	"github.com/samcm/ts-discord-status/internal/teamspeak/teamspeaktest"
+)
+
+// Discord's documented embed limits.
+const (
+	limitFields     = 25
+	limitFieldName  = 256
+	limitFieldValue = 1024
+	limitTotal      = 6000
+)
+
+var loadSizes = []struct{ channels, users int }{
+	{channels: 10, users: 20},
+	{channels: 100, users: 500},
+	{channels: 300, users: 2000},
+	{channels: 500, users: 5000},
+}
+
+func newTestService(display DisplayConfig) *service {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	return NewService(log, Config{}, display).(*service)
+}
+
+func embedLength(e *discordgo.MessageEmbed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+
+	if e.Footer != nil {
+		n += utf8.RuneCountInString(e.Footer.Text)
+	}
+
+	if e.Author != nil {
+		n += utf8.RuneCountInString(e.Author.Name)
+	}
+
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+
+	return n
+}
+
+func requireWithinLimits(t *testing.T, e *discordgo.MessageEmbed) {
+	t.Helper()
+
+	require.LessOrEqual(t, len(e.Fields), limitFields)
+
+	for _, f := range e.Fields {
+		require.LessOrEqual(t, utf8.RuneCountInString(f.Name), limitFieldName, "field %q name", f.Name)
+		require.LessOrEqual(t, utf8.RuneCountInString(f.Value), limitFieldValue, "field %q value", f.Name)
+	}
+
+	require.LessOrEqual(t, embedLength(e), limitTotal)
+}
+
+func TestBuildEmbedLargeStateWithinLimits(t *testing.T) {
+	displays := map[string]DisplayConfig{
+		"default":     {InlineStats: true, StatsPerRow: 3, RelativeTime: true},
+		"static":      {InlineStats: true, StatsPerRow: 3, ShowEmptyChannels: true, ShowConnectedTime: true},
+		"mobile":      {Style: StyleMobile},
+		"with_server": {ServerAddress: "ts.example.com", ServerPassword: "secret", CustomFooter: "footer"},
+	}
+
+	for name, display := range displays {
+		for _, size := range loadSizes {
+			t.Run(fmt.Sprintf("%s/%dc_%du", name, size.channels, size.users), func(t *testing.T) {
+				svc := newTestService(display)
+				state := teamspeaktest.SyntheticState(size.channels, size.users)
+
+				start := time.Now()
+				embed := svc.buildEmbed(state)
+
+				// A generous bound that only trips on pathological (e.g. quadratic)
+				// rendering, not on slow CI machines.
+				require.Less(t, time.Since(start), 2*time.Second)
+				requireWithinLimits(t, embed)
+			})
+		}
+	}
+}
+
+func TestBuildEmbedAggregatedWithinLimits(t *testing.T) {
+	svc := newTestService(DisplayConfig{InlineStats: true, StatsPerRow: 3, ServerAddress: "ts.example.com"})
+
+	state := &teamspeak.State{ServerName: "Community", FetchedAt: time.Now()}
+	for i := 0; i < 10; i++ {
+		sv := teamspeaktest.SyntheticState(100, 500)
+		state.Servers = append(state.Servers, sv)
+		state.TotalUsers += sv.TotalUsers
+		state.MaxClients += sv.MaxClients
+	}
+
+	embed := svc.buildEmbed(state)
+
+	requireWithinLimits(t, embed)
+	require.Len(t, embed.Fields, 2+len(state.Servers))
+}
+
+func BenchmarkBuildEmbed(b *testing.B) {
+	svc := newTestService(DisplayConfig{InlineStats: true, StatsPerRow: 3, RelativeTime: true})
+
+	for _, size := range loadSizes {
+		state := teamspeaktest.SyntheticState(size.channels, size.users)
+
+		b.Run(fmt.Sprintf("%dc_%du", size.channels, size.users), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				svc.buildEmbed(state)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// VoiceMirror renames a voice channel, best kept empty and locked, to show
+// the server occupancy in the channel list. It is independent of
+// ChannelNameFormat and rate limited on its own.
+type VoiceMirror struct {
+	ChannelID string
+	Format    string // e.g. "🔊 TS: {online} online"
+}
+
+// maybeUpdateVoiceMirror renames the mirror channel when its name changed and
+// the rename limit allows. Must be called with s.mu held.
+func (s *service) maybeUpdateVoiceMirror(ctx context.Context, state *teamspeak.State, now time.Time) {
+	mirror := s.display.VoiceMirror
+	if mirror == nil || state == nil {
+		return
+	}
+
+	name := truncateRunes(s.formatName(mirror.Format, state, now), maxChannelName)
+	log := s.log.WithField("voice_channel_id", mirror.ChannelID)
+
+	// Start from the actual name so a restart does not rename.
+	if s.mirrorName == "" {
+		ch, err := s.session.Channel(mirror.ChannelID, discordgo.WithContext(ctx))
+		if err != nil {
+			log.WithError(err).Warn("Failed to fetch voice mirror channel")
+
+			return
+		}
+
+		if ch.Type != discordgo.ChannelTypeGuildVoice && ch.Type != discordgo.ChannelTypeGuildStageVoice {
+			log.Warn("Voice mirror channel is not a voice channel")
+		}
+
+		s.mirrorName = ch.Name
+	}
+
+	if name == s.mirrorName {
+		return
+	}
+
+	if s.display.ChannelNameDryRun {
+		log.WithFields(logrus.Fields{"name": name, "previous": s.mirrorName}).Info("Dry run: would rename voice mirror")
+		s.mirrorName = name
+
+		return
+	}
+
+	if now.Sub(s.mirrorRenamed) < channelRenameInterval {
+		return
+	}
+
+	if _, err := s.session.ChannelEdit(mirror.ChannelID, &discordgo.ChannelEdit{Name: name}, discordgo.WithContext(ctx)); err != nil {
+		log.WithError(err).Warn("Failed to rename voice mirror")
+
+		return
+	}
+
+	s.mirrorName = name
+	s.mirrorRenamed = now
+	log.WithField("name", name).Info("Renamed voice mirror")
+}
@@ -0,0 +1,85 @@
+package discord
+
+import (
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// maxNicknameLength is Discord's guild nickname length limit.
+const maxNicknameLength = 32
+
+// sentNickname is the last nickname set in one guild.
+type sentNickname struct {
+	text   string
+	at     time.Time // When it was last attempted, successful or not
+	failed bool      // The last attempt failed
+}
+
+// maybeUpdateNickname sets the bot's nickname in the status channel's guild to
+// NicknameFormat, at most once per NicknameInterval. Unlike a channel rename
+// it leaves the channel list alone. Must be called with s.mu held.
+func (s *service) maybeUpdateNickname(state *teamspeak.State, now time.Time) {
+	guildID := s.statusGuild()
+	if guildID == "" {
+		return
+	}
+
+	nick := truncateRunes(s.formatName(s.display.NicknameFormat, state, now), maxNicknameLength)
+
+	last := s.nicknames[guildID]
+	if nick == last.text {
+		return
+	}
+
+	if !last.at.IsZero() && now.Sub(last.at) < s.display.NicknameInterval {
+		return
+	}
+
+	// Failed attempts are recorded too, so a missing Change Nickname
+	// permission is retried once per interval rather than on every update,
+	// and warned about once rather than on every retry.
+	if err := s.session.GuildMemberNickname(guildID, "@me", nick); err != nil {
+		log := s.log.WithError(err).WithField("guild", guildID)
+		if last.failed {
+			log.Debug("Failed to update bot nickname")
+		} else {
+			log.Warn("Failed to update bot nickname")
+		}
+
+		s.nicknames[guildID] = sentNickname{text: last.text, at: now, failed: true}
+
+		return
+	}
+
+	s.nicknames[guildID] = sentNickname{text: nick, at: now}
+
+	if last.failed {
+		s.log.WithField("nickname", nick).Info("Bot nickname updates recovered")
+	} else {
+		s.log.WithField("nickname", nick).Debug("Updated bot nickname")
+	}
+}
+
+// statusGuild returns the guild of the status channel, looked up once. A
+// failing lookup is warned about once until it succeeds.
+func (s *service) statusGuild() string {
+	if s.guildID != "" {
+		return s.guildID
+	}
+
+	ch, err := s.session.Channel(s.cfg.ChannelID)
+	if err != nil {
+		if !s.guildLookupFailed {
+			s.log.WithError(err).Warn("Failed to look up status channel guild")
+		}
+
+		s.guildLookupFailed = true
+
+		return ""
+	}
+
+	s.guildID, s.guildLookupFailed = ch.GuildID, false
+
+	return s.guildID
+}
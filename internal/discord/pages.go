@@ -0,0 +1,166 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// maxPages bounds the messages a paginated status spreads over.
+	maxPages = 10
+
+	// pageNoteRoom is kept free in each page for its "Page 2/3" footer note.
+	pageNoteRoom = 16
+)
+
+// buildPages renders the state into the main embed followed, with Paginate,
+// by the continuation pages holding the fields that do not fit it.
+func (s *service) buildPages(state *teamspeak.State) []*discordgo.MessageEmbed {
+	embed := s.renderEmbed(state)
+	if !s.display.Paginate {
+		return []*discordgo.MessageEmbed{s.fit(embed)}
+	}
+
+	pages := paginate(embed)
+	s.fit(pages[0])
+
+	for _, page := range pages[1:] {
+		fitEmbed(page)
+	}
+
+	return pages
+}
+
+// updatePages renders the continuation pages of the last edit into the
+// messages following the main one. Must be called with s.mu held.
+func (s *service) updatePages(ctx context.Context, state *teamspeak.State) {
+	if !s.display.Paginate || state == nil {
+		return
+	}
+
+	s.syncExtraMessages(ctx, s.pages)
+}
+
+// paginate splits an embed over Discord's field count or total length into
+// pages, moving trailing fields to continuation embeds, and numbers the pages
+// in their footers. Past maxPages, the remaining fields are dropped.
+func paginate(e *discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+	if len(e.Fields) <= maxEmbedFields && embedTotal(e) <= maxEmbedTotal {
+		return []*discordgo.MessageEmbed{e}
+	}
+
+	fields := e.Fields
+	e.Fields = nil
+
+	pages := []*discordgo.MessageEmbed{e}
+	page := e
+	dropped := 0
+
+	for _, f := range fields {
+		size := utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+
+		if len(page.Fields) > 0 && (len(page.Fields) == maxEmbedFields || embedTotal(page)+size > maxEmbedTotal-pageNoteRoom) {
+			if len(pages) == maxPages {
+				dropped++
+
+				continue
+			}
+
+			page = &discordgo.MessageEmbed{Title: e.Title + " (continued)", Color: e.Color}
+			pages = append(pages, page)
+		}
+
+		page.Fields = append(page.Fields, f)
+	}
+
+	for i, p := range pages {
+		note := fmt.Sprintf("Page %d/%d", i+1, len(pages))
+		if i == len(pages)-1 && dropped > 0 {
+			note += fmt.Sprintf(" · %d more fields not shown", dropped)
+		}
+
+		if p.Footer == nil {
+			p.Footer = &discordgo.MessageEmbedFooter{Text: note}
+		} else {
+			p.Footer.Text = note + " · " + p.Footer.Text
+		}
+	}
+
+	return pages
+}
+
+// packBlocks joins blocks with sep into as few values of at most limit
+// characters as the order allows, splitting a block too long for one value
+// on line boundaries.
+func packBlocks(blocks []string, sep string, limit int) []string {
+	var pieces []string
+
+	for _, block := range blocks {
+		if utf8.RuneCountInString(block) <= limit {
+			pieces = append(pieces, block)
+
+			continue
+		}
+
+		pieces = append(pieces, splitLines(block, limit)...)
+	}
+
+	var (
+		values []string
+		value  string
+	)
+
+	for _, piece := range pieces {
+		switch {
+		case value == "":
+			value = piece
+		case utf8.RuneCountInString(value)+utf8.RuneCountInString(sep)+utf8.RuneCountInString(piece) <= limit:
+			value += sep + piece
+		default:
+			values = append(values, value)
+			value = piece
+		}
+	}
+
+	if value != "" {
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// splitLines splits s into runs of whole lines of at most limit characters;
+// a single longer line is cut.
+func splitLines(s string, limit int) []string {
+	var (
+		pieces []string
+		piece  string
+	)
+
+	for _, line := range strings.Split(s, "\n") {
+		line = truncateRunes(line, limit)
+
+		if piece != "" && utf8.RuneCountInString(piece)+1+utf8.RuneCountInString(line) > limit {
+			pieces = append(pieces, piece)
+			piece = ""
+		}
+
+		if piece == "" {
+			piece = line
+		} else {
+			piece += "\n" + line
+		}
+	}
+
+	if piece != "" {
+		pieces = append(pieces, piece)
+	}
+
+	return pieces
+}
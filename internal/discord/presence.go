@@ -0,0 +1,74 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// presenceMinGap spaces presence updates well inside the gateway's
+	// presence rate limit even when counts change every tick.
+	presenceMinGap = 15 * time.Second
+
+	// maxPresenceLength is Discord's custom status length limit.
+	maxPresenceLength = 128
+)
+
+// maybeUpdatePresence shows the current presence template as the bot's
+// custom status, moving to the next template every PresenceInterval. The
+// status is only sent when its text changes. Must be called with s.mu held.
+func (s *service) maybeUpdatePresence(state *teamspeak.State, now time.Time) {
+	s.trackPeak(state, now)
+
+	switch {
+	case s.presenceRotated.IsZero():
+		s.presenceRotated = now
+	case now.Sub(s.presenceRotated) >= s.display.PresenceInterval:
+		s.presenceIndex = (s.presenceIndex + 1) % len(s.display.PresenceTemplates)
+		s.presenceRotated = now
+	}
+
+	text := s.presenceText(s.display.PresenceTemplates[s.presenceIndex], state)
+	if text == s.lastPresence || now.Sub(s.lastPresenceSent) < presenceMinGap {
+		return
+	}
+
+	if err := s.session.UpdateCustomStatus(text); err != nil {
+		s.log.WithError(err).Warn("Failed to update bot presence")
+
+		return
+	}
+
+	s.lastPresence = text
+	s.lastPresenceSent = now
+}
+
+// trackPeak records the highest user count seen today (local time).
+func (s *service) trackPeak(state *teamspeak.State, now time.Time) {
+	if day := now.Format(time.DateOnly); day != s.peakDay {
+		s.peakDay = day
+		s.peakToday = 0
+	}
+
+	s.peakToday = max(s.peakToday, state.TotalUsers)
+}
+
+// presenceText fills a presence template's placeholders.
+func (s *service) presenceText(template string, state *teamspeak.State) string {
+	text := strings.NewReplacer(
+		"{online}", fmt.Sprintf("%d", state.TotalUsers),
+		"{max}", fmt.Sprintf("%d", state.MaxClients),
+		"{server}", state.ServerName,
+		"{uptime}", formatDuration(state.Uptime),
+		"{peak_today}", fmt.Sprintf("%d", s.peakToday),
+	).Replace(template)
+
+	if r := []rune(text); len(r) > maxPresenceLength {
+		text = string(r[:maxPresenceLength-1]) + "…"
+	}
+
+	return text
+}
@@ -0,0 +1,19 @@
+package discord
+
+import (
+	"io"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Preview renders the status embed for a state without connecting to Discord,
+// for development tooling.
+func Preview(display DisplayConfig, state *teamspeak.State) *discordgo.MessageEmbed {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	return NewService(log, Config{}, display).(*service).buildEmbed(state)
+}
@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// minRefreshInterval spaces refreshes from all users, so many users clicking
+// at once cannot each trigger a TeamSpeak poll and an edit.
+const minRefreshInterval = 10 * time.Second
+
+// onRefresh handles a click on the Refresh button: it requests an immediate
+// update unless the user refreshed within the cooldown.
+func (s *service) onRefresh(i *discordgo.InteractionCreate, now time.Time) *discordgo.InteractionResponse {
+	user := interactionUser(i)
+
+	s.mu.Lock()
+	commands := s.commands
+	if commands == nil || user == "" {
+		s.mu.Unlock()
+
+		return ephemeral("The bot is still starting, try again in a moment.")
+	}
+
+	next, ok := s.allowRefresh(user, now)
+	s.mu.Unlock()
+
+	if !ok {
+		return ephemeral(fmt.Sprintf("You can refresh again <t:%d:R>.", next.Unix()))
+	}
+
+	commands.Refresh()
+
+	return ephemeral("🔁 Refreshing, the status updates in a moment.")
+}
+
+// allowRefresh records a refresh by user at now, or returns when they may
+// refresh next if they are still within their cooldown or any refresh ran
+// within minRefreshInterval. Entries past the cooldown are dropped. Must be
+// called with s.mu held.
+func (s *service) allowRefresh(user string, now time.Time) (time.Time, bool) {
+	for id, at := range s.refreshedBy {
+		if !now.Before(at.Add(s.display.RefreshCooldown)) {
+			delete(s.refreshedBy, id)
+		}
+	}
+
+	if at, ok := s.refreshedBy[user]; ok {
+		return at.Add(s.display.RefreshCooldown), false
+	}
+
+	if next := s.lastRefresh.Add(minRefreshInterval); now.Before(next) {
+		return next, false
+	}
+
+	s.refreshedBy[user] = now
+	s.lastRefresh = now
+
+	return time.Time{}, true
+}
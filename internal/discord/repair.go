@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// verifyInterval is how often the status message is fetched back and compared
+// with what was last sent.
+const verifyInterval = 10 * time.Minute
+
+// messageHash fingerprints the parts of a message another bot or an admin can
+// change: the rendered embed, suppressed embeds, and removed attachments.
+// Both sides of a comparison come from Discord's responses, so normalisation
+// Discord applies to embeds does not register as a change.
+func messageHash(m *discordgo.Message) string {
+	h := sha256.New()
+
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	write(strconv.Itoa(int(m.Flags & discordgo.MessageFlagsSuppressEmbeds)))
+	write(strconv.Itoa(len(m.Attachments)))
+	write(strconv.Itoa(len(m.Embeds)))
+
+	for _, e := range m.Embeds {
+		write(e.Title)
+		write(e.Description)
+		write(strconv.Itoa(e.Color))
+
+		if e.Footer != nil {
+			write(e.Footer.Text)
+		}
+
+		for _, f := range e.Fields {
+			write(f.Name)
+			write(f.Value)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// statusIcon is the TeamSpeak icon in the author line of every status embed.
+// It also marks the bot's status messages apart from its other messages.
+const statusIcon = "https://i.imgur.com/pK2qRkC.png"
+
+// isStatusMessage reports whether msg is a status message of the bot: one
+// whose embed has the status author icon, or a continuation page.
+func isStatusMessage(msg *discordgo.Message, botID string) bool {
+	if msg.Author == nil || msg.Author.ID != botID {
+		return false
+	}
+
+	for _, e := range msg.Embeds {
+		if e.Author != nil && e.Author.IconURL == statusIcon {
+			return true
+		}
+
+		if strings.HasSuffix(e.Title, " (continued)") && e.Footer != nil && strings.HasPrefix(e.Footer.Text, "Page ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isUnknownMessage reports whether err means the message no longer exists.
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+}
+
+// verifyMessage fetches the status message and repairs it when it was deleted,
+// had its embeds suppressed, or no longer matches the last edit. A content
+// mismatch is repaired by the full edit that follows; the other cases need a
+// fresh message. Must be called with s.mu held.
+func (s *service) verifyMessage(ctx context.Context) error {
+	s.lastVerified = time.Now()
+
+	msg, err := s.session.ChannelMessage(s.channel(), s.messageID, discordgo.WithContext(ctx))
+	if isUnknownMessage(err) {
+		s.log.Warn("Status message was deleted; reposting")
+
+		return s.repost(ctx)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to fetch status message: %w", err)
+	}
+
+	if msg.Flags&discordgo.MessageFlagsSuppressEmbeds != 0 {
+		s.log.Warn("Status message embeds were suppressed; reposting")
+
+		return s.repost(ctx)
+	}
+
+	if s.lastHash != "" && messageHash(msg) != s.lastHash {
+		s.log.Warn("Status message was changed externally; repairing")
+
+		// Re-upload the image in case attachments were removed.
+		s.imageDirty = true
+	}
+
+	return nil
+}
+
+// repost deletes the status message (if it still exists) and creates a new
+// one; in a forum, the post is started again. Must be called with s.mu held.
+func (s *service) repost(ctx context.Context) error {
+	if s.post != "" {
+		if err := s.repostPost(ctx); err != nil {
+			return err
+		}
+
+		s.resetEdit()
+
+		return nil
+	}
+
+	if err := s.session.ChannelMessageDelete(s.channel(), s.messageID, discordgo.WithContext(ctx)); err != nil && !isUnknownMessage(err) {
+		s.log.WithError(err).Debug("Failed to delete old status message")
+	}
+
+	msg, err := s.session.ChannelMessageSendEmbed(s.channel(), s.buildEmbed(nil), discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to repost status message: %w", err)
+	}
+
+	s.messageID = msg.ID
+	s.resetEdit()
+
+	s.log.WithField("message_id", s.messageID).Info("Reposted status message")
+
+	return nil
+}
+
+// resetEdit forgets the last edit after the status message was replaced.
+// Must be called with s.mu held.
+func (s *service) resetEdit() {
+	s.lastHash = ""
+	s.imageDirty = true
+	s.lastEdited = time.Time{}
+	s.seenEdit = time.Time{}
+}
@@ -0,0 +1,47 @@
+package discord
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageHash(t *testing.T) {
+	msg := func() *discordgo.Message {
+		return &discordgo.Message{
+			Embeds: []*discordgo.MessageEmbed{{
+				Title:  "Server",
+				Fields: []*discordgo.MessageEmbedField{{Name: "Online", Value: "2 / 32"}},
+			}},
+			Attachments: []*discordgo.MessageAttachment{{Filename: imageName}},
+		}
+	}
+
+	base := messageHash(msg())
+	require.Equal(t, base, messageHash(msg()))
+
+	edited := msg()
+	edited.Embeds[0].Fields[0].Value = "spam"
+	require.NotEqual(t, base, messageHash(edited))
+
+	suppressed := msg()
+	suppressed.Flags = discordgo.MessageFlagsSuppressEmbeds
+	require.NotEqual(t, base, messageHash(suppressed))
+
+	stripped := msg()
+	stripped.Attachments = nil
+	require.NotEqual(t, base, messageHash(stripped))
+}
+
+func TestIsUnknownMessage(t *testing.T) {
+	err := &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusNotFound},
+		Message:  &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMessage},
+	}
+
+	require.True(t, isUnknownMessage(err))
+	require.False(t, isUnknownMessage(errors.New("timeout")))
+}
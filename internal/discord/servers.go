@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"context"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// mainState returns the part of the state rendered into the main status
+// message: with MessagePerServer, only the first aggregated server.
+func (s *service) mainState(state *teamspeak.State) *teamspeak.State {
+	if s.display.MessagePerServer && state != nil && len(state.Servers) > 1 {
+		return state.Servers[0]
+	}
+
+	return state
+}
+
+// adoptMessages picks up the status messages of a previous run: the oldest is
+// the main message and later ones are the extra messages, for the second and
+// following servers or the continuation pages. It returns false when there
+// are none. Must be called with s.mu held.
+func (s *service) adoptMessages(messages []*discordgo.Message, botID string) bool {
+	var ids []string
+
+	for _, msg := range messages {
+		if isStatusMessage(msg, botID) {
+			ids = append(ids, msg.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return false
+	}
+
+	// Discord lists messages newest first.
+	slices.Reverse(ids)
+
+	s.messageID = ids[0]
+	s.extraMessages = ids[1:]
+
+	return true
+}
+
+// updateServerMessages renders each aggregated server after the first into a
+// message of its own. Must be called with s.mu held.
+func (s *service) updateServerMessages(ctx context.Context, state *teamspeak.State) {
+	if !s.display.MessagePerServer || state == nil || len(state.Servers) < 2 {
+		return
+	}
+
+	embeds := make([]*discordgo.MessageEmbed, 0, len(state.Servers)-1)
+	for _, sv := range state.Servers[1:] {
+		embeds = append(embeds, s.buildEmbed(sv))
+	}
+
+	s.syncExtraMessages(ctx, embeds)
+}
+
+// syncExtraMessages renders embeds into the messages following the main
+// message, one each, creating missing messages and deleting ones no longer
+// needed. Failures are logged; the main message is what UpdateStatus reports
+// on. Must be called with s.mu held.
+func (s *service) syncExtraMessages(ctx context.Context, embeds []*discordgo.MessageEmbed) {
+	for i, embed := range embeds {
+		log := s.log.WithField("message", i+2)
+
+		if i < len(s.extraMessages) {
+			_, err := s.session.ChannelMessageEditEmbed(s.channel(), s.extraMessages[i], embed, discordgo.WithContext(ctx))
+			if err == nil {
+				continue
+			}
+
+			if !isUnknownMessage(err) {
+				log.WithError(err).Warn("Failed to update extra status message")
+
+				continue
+			}
+		}
+
+		msg, err := s.session.ChannelMessageSendEmbed(s.channel(), embed, discordgo.WithContext(ctx))
+		if err != nil {
+			log.WithError(err).Warn("Failed to create extra status message")
+
+			// Later messages keep their positions; retry on the next update.
+			if i >= len(s.extraMessages) {
+				return
+			}
+
+			continue
+		}
+
+		if i < len(s.extraMessages) {
+			s.extraMessages[i] = msg.ID
+		} else {
+			s.extraMessages = append(s.extraMessages, msg.ID)
+		}
+	}
+
+	for _, id := range s.extraMessages[min(len(embeds), len(s.extraMessages)):] {
+		if err := s.session.ChannelMessageDelete(s.channel(), id, discordgo.WithContext(ctx)); err != nil && !isUnknownMessage(err) {
+			s.log.WithError(err).WithField("message_id", id).Warn("Failed to delete unused status message")
+		}
+	}
+
+	s.extraMessages = s.extraMessages[:min(len(embeds), len(s.extraMessages))]
+}
@@ -0,0 +1,103 @@
+package discord
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Target is a further status channel, possibly in another guild. Its message
+// is rendered with its own display options from the same state.
+type Target struct {
+	ChannelID string
+	Display   DisplayConfig
+}
+
+// newTargets creates a service per target of s. Targets share the session of
+// s and are driven by it; the bot presence is only set by s itself.
+func newTargets(s *service) []*service {
+	targets := make([]*service, 0, len(s.cfg.Targets))
+
+	for _, t := range s.cfg.Targets {
+		cfg := s.cfg
+		cfg.ChannelID = t.ChannelID
+		cfg.Targets = nil
+
+		// The voice mirror and status change one channel; a copy per target
+		// would go past their rate limits.
+		display := t.Display
+		display.PresenceTemplates = nil
+		display.VoiceMirror = nil
+		display.VoiceStatus = nil
+
+		targets = append(targets, newService(s.log.WithField("channel_id", t.ChannelID), cfg, display, s.clock))
+	}
+
+	return targets
+}
+
+// owner returns the service publishing to channelID: one of the targets, or s
+// for its own channel and any other.
+func (s *service) owner(channelID string) *service {
+	for _, t := range s.targets {
+		t.mu.Lock()
+		post := t.post
+		t.mu.Unlock()
+
+		if t.cfg.ChannelID == channelID || (post != "" && post == channelID) {
+			return t
+		}
+	}
+
+	return s
+}
+
+// setTargetSession hands the gateway session to the targets and the detail
+// thread, or takes it away when nil.
+func (s *service) setTargetSession(session *discordgo.Session) {
+	if s.detail != nil {
+		s.detail.mu.Lock()
+		s.detail.session = session
+		s.detail.mu.Unlock()
+	}
+
+	for _, t := range s.targets {
+		t.mu.Lock()
+		t.session = session
+		t.mu.Unlock()
+	}
+}
+
+// connectTargets finds or creates the targets' status messages and registers
+// the commands in their guilds. A target that fails does not fail the
+// connection; its next update tries again.
+func (s *service) connectTargets() {
+	for _, t := range s.targets {
+		if err := t.ensureMessage(); err != nil {
+			t.log.WithError(err).Warn("Failed to find or create status message")
+
+			continue
+		}
+
+		t.diagnoseOnce()
+
+		if t.cfg.SlashCommands {
+			if err := t.registerCommands(); err != nil {
+				t.log.WithError(err).Warn("Failed to register slash commands")
+			}
+		}
+	}
+}
+
+// updateTargets renders state into every target's status message.
+func (s *service) updateTargets(ctx context.Context, state *teamspeak.State) {
+	for _, t := range s.targets {
+		// A conflict is logged when it starts, not on every update.
+		if err := t.updateStatus(ctx, state); err != nil && !errors.Is(err, ErrConflict) {
+			t.log.WithError(err).Warn("Failed to update status message")
+		}
+	}
+}
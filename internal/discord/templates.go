@@ -0,0 +1,182 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/render"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Templates replace parts of the built-in embed layout. Unset templates keep
+// the built-in rendering, and a template failing to execute falls back to it
+// for that update.
+type Templates struct {
+	Title   *template.Template // Embed title
+	Fields  []FieldTemplate    // Replace the stats fields above the channel list
+	Channel *template.Template // Channel header line in the channel list
+	User    *template.Template // User line in the channel list
+}
+
+// FieldTemplate renders one embed field; fields rendering to an empty value
+// are left out.
+type FieldTemplate struct {
+	Name   *template.Template
+	Value  *template.Template
+	Inline bool
+}
+
+// TemplateData is what templates are executed with. Channel and User are nil
+// where they do not apply.
+type TemplateData struct {
+	State   *teamspeak.State
+	Channel *teamspeak.Channel
+	User    *teamspeak.User
+	Name    string // The channel name with its codec, or the nickname with its flag and badges
+	Status  string // The user's status icons and idle time, as in the built-in line
+}
+
+// templateFuncs are available to every template.
+var templateFuncs = template.FuncMap{
+	"duration":  formatDuration,
+	"timestamp": relativeTimestamp,
+	"since":     time.Since,
+	"truncate":  func(n int, s string) string { return truncateRunes(s, n) },
+	"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+}
+
+// ParseTemplate parses an embed template, with the template functions
+// available.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// execTemplate executes t, reporting false when there is no template or it
+// failed, in which case the caller renders the built-in text.
+func (s *service) execTemplate(t *template.Template, data TemplateData) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+
+	if err := t.Execute(&buf, data); err != nil {
+		// The user template runs once per user on every update; warn about
+		// each distinct failure of a template once.
+		log := s.log.WithError(err).WithField("template", t.Name())
+		if s.newTemplateError(t.Name(), err) {
+			log.Warn("Failed to execute embed template")
+		} else {
+			log.Debug("Failed to execute embed template")
+		}
+
+		return "", false
+	}
+
+	return strings.TrimSpace(buf.String()), true
+}
+
+// newTemplateError records err as the last failure of the named template and
+// reports whether it differs from the previous one.
+func (s *service) newTemplateError(name string, err error) bool {
+	s.templateMu.Lock()
+	defer s.templateMu.Unlock()
+
+	if s.templateErrs == nil {
+		s.templateErrs = make(map[string]string)
+	}
+
+	if s.templateErrs[name] == err.Error() {
+		return false
+	}
+
+	s.templateErrs[name] = err.Error()
+
+	return true
+}
+
+// templateFields renders the field templates, or reports false when none are
+// configured or one failed.
+func (s *service) templateFields(state *teamspeak.State) ([]*discordgo.MessageEmbedField, bool) {
+	if len(s.display.Templates.Fields) == 0 {
+		return nil, false
+	}
+
+	data := TemplateData{State: state}
+	fields := make([]*discordgo.MessageEmbedField, 0, len(s.display.Templates.Fields))
+
+	for _, ft := range s.display.Templates.Fields {
+		value, ok := s.execTemplate(ft.Value, data)
+		if !ok {
+			return nil, false
+		}
+
+		if value == "" {
+			continue
+		}
+
+		name, _ := s.execTemplate(ft.Name, data)
+		if name == "" {
+			name = "\u200b"
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: ft.Inline})
+	}
+
+	return fields, true
+}
+
+// channelHeader renders the channel template for a section of channel ch,
+// or the built-in header.
+func (s *service) channelHeader(state *teamspeak.State, ch *teamspeak.Channel, sec render.Section) string {
+	if ch != nil {
+		if text, ok := s.execTemplate(s.display.Templates.Channel, TemplateData{State: state, Channel: ch, Name: sec.Name}); ok {
+			return text
+		}
+	}
+
+	if len(sec.Lines) > 0 {
+		return fmt.Sprintf("**#%s** `%d`", sec.Name, len(sec.Lines))
+	}
+
+	return fmt.Sprintf("**#%s**", sec.Name)
+}
+
+// userLine renders the user template for the i-th line of a section of
+// channel ch, or the built-in line starting with bullet.
+func (s *service) userLine(state *teamspeak.State, ch *teamspeak.Channel, sec render.Section, i int, bullet string) string {
+	line := sec.Lines[i]
+	status := s.buildUserStatus(line, dataTime(state))
+
+	// Sections list the channel's users in order.
+	if ch != nil && i < len(ch.Users) {
+		data := TemplateData{State: state, Channel: ch, User: &ch.Users[i], Name: line.Name(), Status: status}
+		if text, ok := s.execTemplate(s.display.Templates.User, data); ok {
+			return text
+		}
+	}
+
+	if status != "" {
+		return fmt.Sprintf("%s%s %s", bullet, line.Name(), status)
+	}
+
+	return bullet + line.Name()
+}
+
+// channelByID returns the channel with the given id, or nil.
+func channelByID(state *teamspeak.State, id int) *teamspeak.Channel {
+	for i := range state.Channels {
+		if state.Channels[i].ID == id {
+			return &state.Channels[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// threadArchiveMinutes is how long the detail thread stays open without new
+// messages before Discord archives it: the longest it allows.
+const threadArchiveMinutes = 10080
+
+// newDetailThread creates the service rendering the detailed view into a
+// thread under the status message, or nil unless DetailThread is set. The
+// thread has the id of the message it starts from, so its channel is only
+// known once the status message is; it has no components of its own.
+func newDetailThread(s *service) *service {
+	if s.cfg.DetailThread == "" {
+		return nil
+	}
+
+	cfg := s.cfg
+	cfg.ChannelID = ""
+	cfg.Targets = nil
+	cfg.SlashCommands = false
+	cfg.DetailThread = ""
+
+	display := s.display
+	display.DefaultView = ViewDetailed
+	display.ViewButtons = false
+	display.ChannelSelect = false
+	display.WhatChanged = false
+	display.RefreshButton = false
+	display.MessagePerServer = false
+	display.Paginate = false
+	display.JoinQR = false
+	display.ChannelNameFormat = ""
+	display.ChannelNameReset = nil
+	display.VoiceMirror = nil
+	display.VoiceStatus = nil
+	display.PresenceTemplates = nil
+	display.NicknameFormat = ""
+
+	return newService(s.log.WithField("thread", s.cfg.DetailThread), cfg, display, s.clock)
+}
+
+// updateDetail renders state into the detail thread, starting the thread
+// under the status message first if needed. Failures are logged: the status
+// message itself is up to date. Must be called with s.mu held.
+func (s *service) updateDetail(ctx context.Context, state *teamspeak.State) {
+	// Forum posts are threads already and cannot hold one.
+	if s.detail == nil || state == nil || s.post != "" {
+		return
+	}
+
+	if err := s.ensureDetailThread(ctx); err != nil {
+		s.log.WithError(err).Warn("Failed to set up detail thread")
+
+		return
+	}
+
+	err := s.detail.updateStatus(ctx, state)
+
+	// A deleted thread is started again with the next update.
+	if isUnknownChannel(err) {
+		s.detail.mu.Lock()
+		s.detail.cfg.ChannelID = ""
+		s.detail.mu.Unlock()
+	}
+
+	if err != nil && !errors.Is(err, ErrConflict) {
+		s.detail.log.WithError(err).Warn("Failed to update detail thread")
+	}
+}
+
+// ensureDetailThread points the detail service at the thread of the current
+// status message, starting the thread when there is none. A reposted status
+// message gets a new thread. Must be called with s.mu held.
+func (s *service) ensureDetailThread(ctx context.Context) error {
+	s.detail.mu.Lock()
+	threadID := s.detail.cfg.ChannelID
+	maps.Copy(s.detail.iconEmojis, s.iconEmojis)
+	s.detail.mu.Unlock()
+
+	if threadID == s.messageID {
+		return nil
+	}
+
+	thread, err := s.session.Channel(s.messageID, discordgo.WithContext(ctx))
+	if isUnknownChannel(err) {
+		thread, err = s.session.MessageThreadStartComplex(s.channel(), s.messageID, &discordgo.ThreadStart{
+			Name:                s.cfg.DetailThread,
+			AutoArchiveDuration: threadArchiveMinutes,
+		}, discordgo.WithContext(ctx))
+		if err == nil {
+			s.log.WithField("thread_id", thread.ID).Info("Started detail thread")
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to start thread: %w", err)
+	}
+
+	s.detail.mu.Lock()
+	defer s.detail.mu.Unlock()
+
+	s.detail.cfg.ChannelID = thread.ID
+	s.detail.messageID = ""
+	s.detail.lastHash = ""
+
+	return nil
+}
+
+// unarchive reopens the status channel when it is a thread Discord archived
+// for inactivity; edits do not count as activity. Must be called with s.mu
+// held.
+func (s *service) unarchive(ctx context.Context) error {
+	archived := false
+
+	if _, err := s.session.ChannelEdit(s.channel(), &discordgo.ChannelEdit{Archived: &archived}, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to unarchive thread: %w", err)
+	}
+
+	s.log.Info("Reopened archived status thread")
+
+	return nil
+}
+
+func isArchivedThread(err error) bool {
+	var restErr *discordgo.RESTError
+
+	return errors.As(err, &restErr) && restErr.Message != nil &&
+		restErr.Message.Code == discordgo.ErrCodePerformedOperationOnArchivedThread
+}
+
+func isUnknownChannel(err error) bool {
+	var restErr *discordgo.RESTError
+
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownChannel
+}
@@ -0,0 +1,244 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Views selectable with the buttons under the status message.
+const (
+	ViewDetailed = "detailed" // Channels with their users
+	ViewSummary  = "summary"  // Occupied channels with counts only
+)
+
+const (
+	// viewButtonPrefix prefixes the custom ids of the view buttons.
+	viewButtonPrefix = "view:"
+
+	// whatChangedID is the custom id of the "What changed?" button.
+	whatChangedID = "what-changed"
+
+	// refreshID is the custom id of the "Refresh" button.
+	refreshID = "refresh"
+)
+
+// activeView returns the view the embed is rendered in. Must be called with
+// s.mu held.
+func (s *service) activeView() string {
+	if s.view != "" {
+		return s.view
+	}
+
+	if s.display.DefaultView != "" {
+		return s.display.DefaultView
+	}
+
+	return ViewDetailed
+}
+
+// expireView reverts a view picked by a viewer once ViewRevertAfter has
+// passed. Must be called with s.mu held.
+func (s *service) expireView(now time.Time) {
+	if s.view != "" && !now.Before(s.viewUntil) {
+		s.view = ""
+	}
+}
+
+// messageComponents returns the interactive rows shown under the status
+// message. Must be called with s.mu held.
+func (s *service) messageComponents(state *teamspeak.State) []discordgo.MessageComponent {
+	rows := []discordgo.MessageComponent{}
+
+	var buttons []discordgo.MessageComponent
+
+	if s.display.ViewButtons {
+		buttons = append(buttons, s.viewButtons()...)
+	}
+
+	if s.display.WhatChanged {
+		buttons = append(buttons, discordgo.Button{
+			Label:    "What changed?",
+			Style:    discordgo.SecondaryButton,
+			CustomID: whatChangedID,
+			Emoji:    &discordgo.ComponentEmoji{Name: "🔄"},
+		})
+	}
+
+	if s.display.RefreshButton {
+		buttons = append(buttons, discordgo.Button{
+			Label:    "Refresh",
+			Style:    discordgo.SecondaryButton,
+			CustomID: refreshID,
+			Emoji:    &discordgo.ComponentEmoji{Name: "🔁"},
+		})
+	}
+
+	if len(buttons) > 0 {
+		rows = append(rows, discordgo.ActionsRow{Components: buttons})
+	}
+
+	if s.display.ChannelSelect {
+		if menu, ok := s.channelSelect(state); ok {
+			rows = append(rows, menu)
+		}
+	}
+
+	return rows
+}
+
+// viewButtons returns the buttons switching between views, with the active
+// view's button disabled.
+func (s *service) viewButtons() []discordgo.MessageComponent {
+	active := s.activeView()
+
+	button := func(view, label, emoji string) discordgo.Button {
+		b := discordgo.Button{
+			Label:    label,
+			Style:    discordgo.SecondaryButton,
+			CustomID: viewButtonPrefix + view,
+			Emoji:    &discordgo.ComponentEmoji{Name: emoji},
+			Disabled: view == active,
+		}
+
+		if view == active {
+			b.Style = discordgo.PrimaryButton
+		}
+
+		return b
+	}
+
+	return []discordgo.MessageComponent{
+		button(ViewSummary, "Summary", "📊"),
+		button(ViewDetailed, "Detailed", "📋"),
+	}
+}
+
+// onWhatChanged replies, only to the viewer, with what changed since they
+// last clicked.
+func (s *service) onWhatChanged(sess *discordgo.Session, i *discordgo.InteractionCreate) {
+	// The store lookup may outlast the interaction deadline.
+	if err := sess.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		s.log.WithError(err).Warn("Failed to acknowledge what changed button")
+
+		return
+	}
+
+	s.mu.Lock()
+	commands := s.commands
+	s.mu.Unlock()
+
+	content := "The bot is still starting, try again in a moment."
+	if viewer := interactionUser(i); commands != nil && viewer != "" {
+		content = truncateLines(commands.WhatChanged(context.Background(), viewer), maxReplyLength)
+	}
+
+	if _, err := sess.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		s.log.WithError(err).Warn("Failed to reply to what changed button")
+	}
+}
+
+// interactionUser returns the id of the user behind an interaction, in a guild
+// or a DM.
+func interactionUser(i *discordgo.InteractionCreate) string {
+	switch {
+	case i.Member != nil && i.Member.User != nil:
+		return i.Member.User.ID
+	case i.User != nil:
+		return i.User.ID
+	}
+
+	return ""
+}
+
+// onViewButton switches the status message to the clicked view and re-renders
+// it from the last state. The switch edits the message everyone sees, so it
+// shares the Refresh button's cooldown; a click within it gets an ephemeral
+// reply instead.
+func (s *service) onViewButton(sess *discordgo.Session, i *discordgo.InteractionCreate, view string) {
+	s.mu.Lock()
+	next, ok := time.Time{}, true
+	if view != s.view {
+		next, ok = s.allowRefresh(interactionUser(i), time.Now())
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		if err := sess.InteractionRespond(i.Interaction,
+			ephemeral(fmt.Sprintf("You can switch the view again <t:%d:R>.", next.Unix()))); err != nil {
+			s.log.WithError(err).Warn("Failed to respond to view button")
+		}
+
+		return
+	}
+
+	// Acknowledge first: the edit below may take longer than the interaction
+	// deadline.
+	if err := sess.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		s.log.WithError(err).Warn("Failed to acknowledge view button")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if view != ViewSummary && view != ViewDetailed {
+		return
+	}
+
+	if i.Message == nil || i.Message.ID != s.messageID || s.lastState == nil || s.conflict {
+		return
+	}
+
+	// Clicking the current view only extends it.
+	if view == s.view {
+		s.viewUntil = time.Now().Add(s.display.ViewRevertAfter)
+
+		return
+	}
+
+	s.view = view
+	s.viewUntil = time.Now().Add(s.display.ViewRevertAfter)
+
+	msg, err := s.editMessage(context.Background(), s.lastState)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to switch status message view")
+
+		return
+	}
+
+	s.lastHash = messageHash(msg)
+	s.imageDirty = false
+	s.recordEdit(msg)
+}
+
+// buildChannelSummary lists the occupied channels with their user counts.
+func (s *service) buildChannelSummary(state *teamspeak.State, limit int) string {
+	var lines []string
+
+	for _, ch := range state.Channels {
+		if len(ch.Users) == 0 {
+			continue
+		}
+
+		if s.mobile() {
+			lines = append(lines, fmt.Sprintf("**%s** (%d)", ch.Name, len(ch.Users)))
+		} else {
+			lines = append(lines, fmt.Sprintf("**#%s** `%d`", ch.Name, len(ch.Users)))
+		}
+	}
+
+	if len(lines) == 0 {
+		return s.emptyText(state)
+	}
+
+	return fitBlocks(lines, "\n", limit)
+}
@@ -0,0 +1,75 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// maxVoiceStatus is Discord's limit on a voice channel status.
+	maxVoiceStatus = 500
+
+	// voiceStatusInterval is the minimum time between voice status changes.
+	// Discord allows far more than the two renames per ten minutes, but a
+	// count flapping every poll is not worth an API call each time.
+	voiceStatusInterval = 30 * time.Second
+
+	// voiceStatusMaxBackoff caps the wait after repeated failures, e.g. a
+	// missing Set Voice Channel Status permission.
+	voiceStatusMaxBackoff = 10 * time.Minute
+)
+
+// VoiceStatus keeps a one-line status such as "7 in TeamSpeak" in a voice
+// channel's status field, shown under the channel name in the channel list.
+type VoiceStatus struct {
+	ChannelID string
+	Format    string // Placeholders as for ChannelNameFormat
+}
+
+// maybeUpdateVoiceStatus sets the voice channel status when its text changed.
+// Must be called with s.mu held.
+func (s *service) maybeUpdateVoiceStatus(ctx context.Context, state *teamspeak.State, now time.Time) {
+	vs := s.display.VoiceStatus
+	if vs == nil || state == nil {
+		return
+	}
+
+	text := truncateRunes(s.formatName(vs.Format, state, now), maxVoiceStatus)
+	if text == s.voiceStatus {
+		return
+	}
+
+	log := s.log.WithField("voice_channel_id", vs.ChannelID)
+
+	if s.display.ChannelNameDryRun {
+		log.WithFields(logrus.Fields{"status": text, "previous": s.voiceStatus}).Info("Dry run: would set voice channel status")
+		s.voiceStatus = text
+
+		return
+	}
+
+	if now.Sub(s.voiceStatusSet) < max(s.voiceStatusWait, voiceStatusInterval) {
+		return
+	}
+
+	s.voiceStatusSet = now
+
+	endpoint := discordgo.EndpointChannel(vs.ChannelID) + "/voice-status"
+	if _, err := s.session.RequestWithBucketID(http.MethodPut, endpoint, map[string]string{"status": text}, endpoint,
+		discordgo.WithContext(ctx)); err != nil {
+		s.voiceStatusWait = min(max(2*s.voiceStatusWait, voiceStatusInterval), voiceStatusMaxBackoff)
+		log.WithError(err).WithField("retry_in", s.voiceStatusWait).Warn("Failed to set voice channel status")
+
+		return
+	}
+
+	s.voiceStatus = text
+	s.voiceStatusWait = 0
+	log.WithField("status", text).Debug("Set voice channel status")
+}
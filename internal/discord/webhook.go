@@ -0,0 +1,223 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// errNeedsBot is returned by the operations a webhook cannot perform.
+var errNeedsBot = fmt.Errorf("not available with discord.webhook_url, which has no bot")
+
+// webhookService publishes the status message through a webhook instead of a
+// bot, for guilds where no bot can be added. There is no gateway, so slash
+// commands, buttons, channel renames, presence, nicknames, emoji uploads and
+// messages to other channels or users are unavailable.
+type webhookService struct {
+	*service
+
+	webhookID string
+	token     string
+}
+
+// newWebhookService wraps s, whose rendering it reuses.
+func newWebhookService(s *service) *webhookService {
+	// Only the main message is maintained.
+	s.display.MessagePerServer = false
+	s.messageID = s.cfg.WebhookMessageID
+
+	return &webhookService{service: s}
+}
+
+// parseWebhookURL returns the id and token of a webhook URL such as
+// "https://discord.com/api/webhooks/<id>/<token>".
+func parseWebhookURL(raw string) (string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid webhook URL: %w", err)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	for i, p := range parts {
+		if p == "webhooks" && i+2 == len(parts)-1 {
+			return parts[i+1], parts[i+2], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("invalid webhook URL: expected .../webhooks/<id>/<token>")
+}
+
+// Start prepares the REST session and finds or posts the status message. An
+// unreachable Discord is retried on the next update.
+func (w *webhookService) Start(ctx context.Context) error {
+	w.lifecycle.Lock()
+	defer w.lifecycle.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.session != nil {
+		return nil
+	}
+
+	id, token, err := parseWebhookURL(w.cfg.WebhookURL)
+	if err != nil {
+		return err
+	}
+
+	session, err := discordgo.New("")
+	if err != nil {
+		return fmt.Errorf("failed to create Discord session: %w", err)
+	}
+
+	session.Client.Transport = w.clock.transport(session.Client.Transport)
+
+	w.webhookID, w.token = id, token
+	w.session = session
+
+	if err := w.findOrPost(ctx); err != nil {
+		w.log.WithError(err).Warn("Failed to find or post status message; retrying with the next update")
+	}
+
+	return nil
+}
+
+// Stop drops the REST session. Stopping a stopped service does nothing.
+func (w *webhookService) Stop() error {
+	w.lifecycle.Lock()
+	defer w.lifecycle.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.session = nil
+
+	return nil
+}
+
+// findOrPost checks the configured message still exists, posting a new one
+// when there is none. Must be called with w.mu held.
+func (w *webhookService) findOrPost(ctx context.Context) error {
+	if w.messageID != "" {
+		_, err := w.session.WebhookMessage(w.webhookID, w.token, w.messageID, discordgo.WithContext(ctx))
+		if err == nil {
+			w.log.WithField("message_id", w.messageID).Info("Found existing status message")
+
+			return nil
+		}
+
+		if !isUnknownMessage(err) {
+			return fmt.Errorf("failed to fetch status message: %w", err)
+		}
+
+		w.log.WithField("message_id", w.messageID).Warn("Status message is gone; posting a new one")
+	}
+
+	return w.post(ctx)
+}
+
+// post creates the status message. A webhook cannot list the channel, so the
+// id is logged for discord.webhook_message_id to find it again after a
+// restart. Must be called with w.mu held.
+func (w *webhookService) post(ctx context.Context) error {
+	msg, err := w.session.WebhookExecute(w.webhookID, w.token, true, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{w.buildEmbed(nil)},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to post status message: %w", err)
+	}
+
+	w.messageID = msg.ID
+	w.imageDirty = true
+
+	w.log.WithField("message_id", msg.ID).Warn("Posted a new status message; " +
+		"set discord.webhook_message_id to it to keep editing it after a restart")
+
+	return nil
+}
+
+// UpdateStatus edits the status message with the current state.
+func (w *webhookService) UpdateStatus(ctx context.Context, state *teamspeak.State) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.session == nil {
+		return fmt.Errorf("webhook service not started")
+	}
+
+	if w.messageID == "" {
+		if err := w.post(ctx); err != nil {
+			return err
+		}
+	}
+
+	if state != nil {
+		state = w.display.ChannelFilter.Apply(state)
+		w.trackQuiet(state, time.Now())
+		w.lastState = state
+	}
+
+	err := w.edit(ctx, state)
+	if isUnknownMessage(err) {
+		w.log.Warn("Status message is gone; reposting")
+
+		if err := w.post(ctx); err != nil {
+			return err
+		}
+
+		err = w.edit(ctx, state)
+	}
+
+	if err != nil {
+		recordEditError(err)
+
+		return fmt.Errorf("failed to update status message: %w", err)
+	}
+
+	w.imageDirty = false
+
+	return nil
+}
+
+// edit renders the state into the status message. Must be called with w.mu
+// held.
+func (w *webhookService) edit(ctx context.Context, state *teamspeak.State) error {
+	embed := w.buildEmbed(w.mainState(state))
+
+	var text string
+	if w.cfg.LogEmbedDiff {
+		text = w.logEmbedDiff(embed)
+	}
+
+	params := &discordgo.WebhookEdit{Embeds: &[]*discordgo.MessageEmbed{embed}}
+	params.Attachments, params.Files = w.attach(embed, state)
+
+	_, err := w.session.WebhookMessageEdit(w.webhookID, w.token, w.messageID, params, discordgo.WithContext(ctx))
+	if err == nil && w.cfg.LogEmbedDiff {
+		w.lastEmbedText = text
+	}
+
+	return err
+}
+
+// UploadIconEmoji is unavailable: application emojis need the bot.
+func (w *webhookService) UploadIconEmoji(context.Context, uint32, func() ([]byte, error)) error {
+	return errNeedsBot
+}
+
+// Notify is unavailable; notification routes can target webhook URLs instead.
+func (w *webhookService) Notify(context.Context, string, string, ...string) error {
+	return errNeedsBot
+}
+
+// DirectMessage is unavailable.
+func (w *webhookService) DirectMessage(context.Context, string, string) error {
+	return errNeedsBot
+}
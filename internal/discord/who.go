@@ -0,0 +1,140 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/samcm/ts-discord-status/internal/render"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// maxWhoMatches bounds the users listed by one /ts who reply.
+const maxWhoMatches = 10
+
+// userMatch is a user found by /ts who and where they are.
+type userMatch struct {
+	user    teamspeak.User
+	channel string
+	server  string // Set only when several servers are aggregated
+}
+
+// findUsers returns the users whose nickname is query, ignoring case, or
+// failing that the users whose nickname contains it.
+func findUsers(state *teamspeak.State, query string) []userMatch {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var exact, partial []userMatch
+
+	for _, ch := range state.Channels {
+		for _, user := range ch.Users {
+			nick := strings.ToLower(user.Nickname)
+			if !strings.Contains(nick, query) {
+				continue
+			}
+
+			m := userMatch{user: user, channel: ch.Name}
+			if len(state.Servers) > 1 && user.Server < len(state.Servers) {
+				m.server = state.Servers[user.Server].ServerName
+			}
+
+			if nick == query {
+				exact = append(exact, m)
+			} else {
+				partial = append(partial, m)
+			}
+		}
+	}
+
+	if len(exact) > 0 {
+		return exact
+	}
+
+	return partial
+}
+
+// whoReply describes where the users matching query are and what they are
+// doing.
+func whoReply(state *teamspeak.State, query string, now time.Time, emojis render.Emojis) string {
+	matches := findUsers(state, query)
+
+	switch len(matches) {
+	case 0:
+		return fmt.Sprintf("Nobody called %q is online.", query)
+	case 1:
+		return "**" + matches[0].user.Nickname + "** is in " + matchLine(matches[0], now, emojis)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d users match %q:\n", len(matches), query)
+
+	for _, m := range matches[:min(len(matches), maxWhoMatches)] {
+		b.WriteString("• **" + m.user.Nickname + "** in " + matchLine(m, now, emojis) + "\n")
+	}
+
+	if extra := len(matches) - maxWhoMatches; extra > 0 {
+		fmt.Fprintf(&b, "…and %d more\n", extra)
+	}
+
+	return truncateLines(strings.TrimSuffix(b.String(), "\n"), maxReplyLength)
+}
+
+// matchLine is the channel, server and detail part of a /ts who line.
+func matchLine(m userMatch, now time.Time, emojis render.Emojis) string {
+	line := "**#" + m.channel + "**"
+	if m.server != "" {
+		line += " on **" + m.server + "**"
+	}
+
+	if detail := userDetail(m.user, now, emojis); detail != "" {
+		line += " — " + detail
+	}
+
+	return line
+}
+
+// onStatus handles /ts status with the current embed, visible only to the
+// invoking user.
+func (s *service) onStatus() *discordgo.InteractionResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastState == nil {
+		return ephemeral("The bot is still starting, try again in a moment.")
+	}
+
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{s.buildEmbed(s.lastState)},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
+
+// onWho handles /ts who.
+func (s *service) onWho(sub *discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponse {
+	var nickname string
+
+	for _, opt := range sub.Options {
+		if opt.Name == "nickname" {
+			nickname = opt.StringValue()
+		}
+	}
+
+	s.mu.Lock()
+	state := s.lastState
+	s.mu.Unlock()
+
+	if state == nil {
+		return ephemeral("The bot is still starting, try again in a moment.")
+	}
+
+	return ephemeral(whoReply(state, nickname, dataTime(state), s.display.Emojis))
+}
@@ -0,0 +1,179 @@
+// Package errreport sends panics and persistent errors to Sentry, so failures
+// on self-hosted installs can be diagnosed from stack traces.
+package errreport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+)
+
+// flushTimeout bounds how long pending events are sent for on panic or
+// shutdown.
+const flushTimeout = 2 * time.Second
+
+// Config holds Sentry settings. Errors logged at error level are always
+// reported; a warning is reported once it repeats RepeatThreshold times within
+// RepeatWindow, so transient blips stay local.
+type Config struct {
+	DSN             string
+	Environment     string
+	Release         string
+	Tags            map[string]string // Attached to every event, e.g. channel id
+	RepeatThreshold int
+	RepeatWindow    time.Duration
+}
+
+// Init configures Sentry and hooks it into the logger. Without a DSN it does
+// nothing, and Recover and Flush remain safe to call.
+func Init(log *logrus.Logger, cfg Config) error {
+	if cfg.DSN == "" {
+		return nil
+	}
+
+	if err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		AttachStacktrace: true,
+	}); err != nil {
+		return fmt.Errorf("failed to initialise Sentry: %w", err)
+	}
+
+	sentry.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetTags(cfg.Tags)
+	})
+
+	log.AddHook(&hook{
+		threshold: max(cfg.RepeatThreshold, 1),
+		window:    cfg.RepeatWindow,
+		seen:      make(map[string]*repeat),
+		now:       time.Now,
+	})
+
+	return nil
+}
+
+// Recover reports a panic and re-raises it. Use it deferred at the top of
+// each long-running goroutine.
+func Recover() {
+	if r := recover(); r != nil {
+		sentry.CurrentHub().Recover(r)
+		sentry.Flush(flushTimeout)
+
+		panic(r)
+	}
+}
+
+// Report sends a recovered panic to Sentry without re-raising it, for loops
+// that carry on after a panic.
+func Report(r any) {
+	sentry.CurrentHub().Recover(r)
+}
+
+// Flush sends pending events before the process exits.
+func Flush() {
+	sentry.Flush(flushTimeout)
+}
+
+// hook forwards log entries to Sentry.
+type hook struct {
+	threshold int
+	window    time.Duration
+
+	mu   sync.Mutex
+	seen map[string]*repeat
+	now  func() time.Time
+}
+
+type repeat struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (h *hook) Fire(entry *logrus.Entry) error {
+	if entry.Level == logrus.WarnLevel && !h.repeated(entry) {
+		return nil
+	}
+
+	sentry.CaptureEvent(event(entry))
+
+	return nil
+}
+
+// repeated counts a warning and reports whether it just reached the threshold
+// within its window. Each window is reported at most once.
+func (h *hook) repeated(entry *logrus.Entry) bool {
+	key := fmt.Sprint(entry.Data["component"], "|", entry.Message)
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.seen[key]
+	if !ok || now.Sub(r.start) > h.window {
+		r = &repeat{start: now}
+		h.seen[key] = r
+	}
+
+	r.count++
+
+	if r.reported || r.count < h.threshold {
+		return false
+	}
+
+	r.reported = true
+
+	return true
+}
+
+// event converts a log entry, using its fields as tags (component, server)
+// or extra context.
+func event(entry *logrus.Entry) *sentry.Event {
+	ev := sentry.NewEvent()
+	ev.Level = level(entry.Level)
+	ev.Message = entry.Message
+	ev.Logger = "logrus"
+
+	for k, v := range entry.Data {
+		switch k {
+		case logrus.ErrorKey:
+			if err, ok := v.(error); ok {
+				ev.Exception = []sentry.Exception{{
+					Type:       fmt.Sprintf("%T", err),
+					Value:      err.Error(),
+					Stacktrace: sentry.NewStacktrace(),
+				}}
+
+				continue
+			}
+
+			ev.Extra[k] = fmt.Sprint(v)
+		case "component", "server", "source":
+			ev.Tags[k] = fmt.Sprint(v)
+		default:
+			ev.Extra[k] = fmt.Sprint(v)
+		}
+	}
+
+	return ev
+}
+
+func level(l logrus.Level) sentry.Level {
+	switch l {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return sentry.LevelFatal
+	case logrus.ErrorLevel:
+		return sentry.LevelError
+	default:
+		return sentry.LevelWarning
+	}
+}
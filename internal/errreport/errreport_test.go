@@ -0,0 +1,51 @@
+package errreport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepeatedWarnings(t *testing.T) {
+	now := time.Now()
+	h := &hook{threshold: 3, window: 10 * time.Minute, seen: make(map[string]*repeat), now: func() time.Time { return now }}
+
+	entry := &logrus.Entry{Message: "Query failed", Data: logrus.Fields{"component": "teamspeak"}}
+	other := &logrus.Entry{Message: "Query failed", Data: logrus.Fields{"component": "mumble"}}
+
+	require.False(t, h.repeated(entry))
+	require.False(t, h.repeated(entry))
+	require.False(t, h.repeated(other))
+	require.True(t, h.repeated(entry))
+	require.False(t, h.repeated(entry), "reported once per window")
+
+	now = now.Add(11 * time.Minute)
+
+	require.False(t, h.repeated(entry))
+	require.False(t, h.repeated(entry))
+	require.True(t, h.repeated(entry))
+}
+
+func TestReport(t *testing.T) {
+	transport := &sentry.MockTransport{}
+	require.NoError(t, sentry.Init(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport}))
+	t.Cleanup(func() { _ = sentry.Init(sentry.ClientOptions{}) })
+
+	// The panic is reported without being raised again.
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				Report(r)
+			}
+		}()
+
+		panic("boom")
+	}()
+
+	events := transport.Events()
+	require.Len(t, events, 1)
+	require.Equal(t, sentry.LevelFatal, events[0].Level)
+}
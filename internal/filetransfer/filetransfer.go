@@ -0,0 +1,269 @@
+// Package filetransfer implements the TeamSpeak ServerQuery file transfer
+// protocol (ftinitdownload followed by a raw TCP transfer) with a local cache
+// keyed by content checksum.
+package filetransfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ts3 "github.com/multiplay/go-ts3"
+)
+
+const (
+	// defaultTimeout bounds the TCP leg of a transfer.
+	defaultTimeout = 15 * time.Second
+
+	// defaultMaxSize bounds a single download; avatars and icons are capped
+	// well below this by TeamSpeak itself.
+	defaultMaxSize = 1 << 20
+
+	// memoryEntries caps the in-memory cache.
+	memoryEntries = 256
+)
+
+// avatarHash matches a client_flag_avatar checksum. Clients set the flag
+// themselves and it becomes part of a cache file name, so anything else is
+// rejected.
+var avatarHash = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ErrNotFound is returned when the requested file does not exist, e.g. a client
+// without an avatar or a built-in icon.
+var ErrNotFound = errors.New("file not found")
+
+// Querier runs a ServerQuery command on the caller's connection and returns
+// the raw response lines. Callers usually wrap their own connection lock around
+// it so transfers do not race with other queries.
+type Querier func(cmd *ts3.Cmd) ([]string, error)
+
+// Config holds file transfer settings.
+type Config struct {
+	Host     string        // File transfer host, usually the ServerQuery host
+	CacheDir string        // Optional directory downloads are persisted in
+	Timeout  time.Duration // Per-transfer timeout (default 15s)
+	MaxSize  int64         // Largest accepted file (default 1 MiB)
+}
+
+// Client downloads files from a virtual server.
+type Client struct {
+	cfg Config
+	ids atomic.Int32
+
+	mu  sync.Mutex
+	mem map[string][]byte
+}
+
+// initDownload is the response to ftinitdownload.
+type initDownload struct {
+	ClientFTFID int    `ms:"clientftfid"`
+	ServerFTFID int    `ms:"serverftfid"`
+	Key         string `ms:"ftkey"`
+	Port        int    `ms:"port"`
+	Size        int64  `ms:"size"`
+	Status      int    `ms:"status"`
+	Msg         string `ms:"msg"`
+}
+
+// statusFileNotFound is the ftinitdownload status for a missing file.
+const statusFileNotFound = 2051
+
+// New creates a file transfer client.
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+
+	return &Client{
+		cfg: cfg,
+		mem: make(map[string][]byte, 32),
+	}
+}
+
+// Icon fetches an icon by id. TeamSpeak icon ids are the CRC32 of the icon
+// file, so a cached copy can never go stale and downloads are verified against
+// the id. Ids below 1000 are the client's built-in icons and cannot be fetched.
+func (c *Client) Icon(ctx context.Context, q Querier, id uint32) ([]byte, error) {
+	if id < 1000 {
+		return nil, ErrNotFound
+	}
+
+	return c.cached(ctx, q, fmt.Sprintf("icon_%d", id), fmt.Sprintf("/icon_%d", id), func(data []byte) error {
+		if sum := crc32.ChecksumIEEE(data); sum != id {
+			return fmt.Errorf("icon %d failed checksum (got %d)", id, sum)
+		}
+
+		return nil
+	})
+}
+
+// Avatar fetches a client's avatar. name is the avatar file name derived from
+// the client's unique id and hash the client_flag_avatar checksum, which
+// changes whenever the client uploads a new avatar. A hash that is not a hex
+// checksum is treated as no avatar.
+func (c *Client) Avatar(ctx context.Context, q Querier, name, hash string) ([]byte, error) {
+	if !avatarHash.MatchString(hash) {
+		return nil, ErrNotFound
+	}
+
+	return c.cached(ctx, q, name+"_"+hash, "/"+name, nil)
+}
+
+// Download fetches path from the virtual server's file area, bypassing the
+// cache.
+func (c *Client) Download(ctx context.Context, q Querier, path string) ([]byte, error) {
+	lines, err := q(ts3.NewCmd("ftinitdownload").WithArgs(
+		ts3.NewArg("clientftfid", c.ids.Add(1)),
+		ts3.NewArg("name", path),
+		ts3.NewArg("cid", 0),
+		ts3.NewArg("cpw", ""),
+		ts3.NewArg("seekpos", 0),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init download of %s: %w", path, err)
+	}
+
+	var init initDownload
+	if err := ts3.DecodeResponse(lines, &init); err != nil {
+		return nil, fmt.Errorf("failed to decode download of %s: %w", path, err)
+	}
+
+	switch {
+	case init.Status == statusFileNotFound:
+		return nil, ErrNotFound
+	case init.Status != 0:
+		return nil, fmt.Errorf("download of %s rejected: %s (status %d)", path, init.Msg, init.Status)
+	case init.Size < 0:
+		return nil, fmt.Errorf("failed to decode download of %s: negative size %d", path, init.Size)
+	case init.Size > c.cfg.MaxSize:
+		return nil, fmt.Errorf("%s too large: %d bytes", path, init.Size)
+	}
+
+	return c.transfer(ctx, init)
+}
+
+// transfer runs the TCP leg: send the key, then read exactly size bytes.
+func (c *Client) transfer(ctx context.Context, init initDownload) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(c.cfg.Host, strconv.Itoa(init.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to file transfer port: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, init.Key); err != nil {
+		return nil, fmt.Errorf("failed to send transfer key: %w", err)
+	}
+
+	data := make([]byte, init.Size)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// cached returns the file stored under key, downloading path on a miss and
+// optionally verifying the result before it is cached.
+func (c *Client) cached(ctx context.Context, q Querier, key, path string, verify func([]byte) error) ([]byte, error) {
+	if data, ok := c.lookup(key); ok {
+		return data, nil
+	}
+
+	data, err := c.Download(ctx, q, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if verify != nil {
+		if err := verify(data); err != nil {
+			return nil, err
+		}
+	}
+
+	c.store(key, data)
+
+	return data, nil
+}
+
+// lookup checks the memory cache, then the cache directory.
+func (c *Client) lookup(key string) ([]byte, bool) {
+	c.mu.Lock()
+	data, ok := c.mem[key]
+	c.mu.Unlock()
+
+	if ok || c.cfg.CacheDir == "" {
+		return data, ok
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.cfg.CacheDir, key))
+	if err != nil {
+		return nil, false
+	}
+
+	c.remember(key, data)
+
+	return data, true
+}
+
+// store caches data in memory and, when configured, on disk. A failed disk
+// write only costs a later re-download, so it is not reported.
+func (c *Client) store(key string, data []byte) {
+	c.remember(key, data)
+
+	if c.cfg.CacheDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(c.cfg.CacheDir, 0o755); err != nil {
+		return
+	}
+
+	tmp := filepath.Join(c.cfg.CacheDir, key+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+
+	_ = os.Rename(tmp, filepath.Join(c.cfg.CacheDir, key))
+}
+
+// remember adds data to the bounded memory cache, evicting arbitrary entries
+// once it is full.
+func (c *Client) remember(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.mem) >= memoryEntries {
+		for k := range c.mem {
+			delete(c.mem, k)
+
+			if len(c.mem) < memoryEntries/2 {
+				break
+			}
+		}
+	}
+
+	c.mem[key] = data
+}
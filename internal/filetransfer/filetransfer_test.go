@@ -0,0 +1,159 @@
+package filetransfer
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	ts3 "github.com/multiplay/go-ts3"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	hash1 = "0123456789abcdef0123456789abcdef"
+	hash2 = "fedcba9876543210fedcba9876543210"
+)
+
+// fakeServer serves one file over a local file transfer port and counts how
+// many downloads were initiated.
+type fakeServer struct {
+	t     *testing.T
+	ln    net.Listener
+	data  []byte
+	key   string
+	inits atomic.Int32
+}
+
+func newFakeServer(t *testing.T, data []byte) *fakeServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	f := &fakeServer{t: t, ln: ln, data: data, key: "secretkey"}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			key := make([]byte, len(f.key))
+			if _, err := io.ReadFull(conn, key); err == nil && string(key) == f.key {
+				_, _ = conn.Write(f.data)
+			}
+
+			conn.Close()
+		}
+	}()
+
+	t.Cleanup(func() { ln.Close() })
+
+	return f
+}
+
+func (f *fakeServer) querier(status int) Querier {
+	return func(cmd *ts3.Cmd) ([]string, error) {
+		require.True(f.t, strings.HasPrefix(cmd.String(), "ftinitdownload "), cmd.String())
+		f.inits.Add(1)
+
+		port := f.ln.Addr().(*net.TCPAddr).Port
+
+		return []string{fmt.Sprintf("clientftfid=1 serverftfid=7 ftkey=%s port=%d size=%d status=%d",
+			f.key, port, len(f.data), status)}, nil
+	}
+}
+
+func TestIconDownloadIsVerifiedAndCached(t *testing.T) {
+	data := []byte("not really a png")
+	id := crc32.ChecksumIEEE(data)
+	srv := newFakeServer(t, data)
+
+	c := New(Config{Host: "127.0.0.1"})
+
+	got, err := c.Icon(context.Background(), srv.querier(0), id)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	_, err = c.Icon(context.Background(), srv.querier(0), id)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, srv.inits.Load(), "second lookup should hit the cache")
+}
+
+func TestIconChecksumMismatch(t *testing.T) {
+	srv := newFakeServer(t, []byte("tampered"))
+	c := New(Config{Host: "127.0.0.1"})
+
+	_, err := c.Icon(context.Background(), srv.querier(0), 123456)
+	require.ErrorContains(t, err, "checksum")
+}
+
+func TestBuiltinIconAndMissingAvatar(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	c := New(Config{Host: "127.0.0.1"})
+
+	_, err := c.Icon(context.Background(), srv.querier(0), 300)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Avatar(context.Background(), srv.querier(0), "avatar_abc", "")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Avatar(context.Background(), srv.querier(statusFileNotFound), "avatar_abc", hash1)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDiskCacheSurvivesRestart(t *testing.T) {
+	data := []byte("avatar bytes")
+	srv := newFakeServer(t, data)
+	dir := filepath.Join(t.TempDir(), "files")
+
+	first := New(Config{Host: "127.0.0.1", CacheDir: dir})
+	_, err := first.Avatar(context.Background(), srv.querier(0), "avatar_abc", hash1)
+	require.NoError(t, err)
+
+	second := New(Config{Host: "127.0.0.1", CacheDir: dir})
+	got, err := second.Avatar(context.Background(), srv.querier(0), "avatar_abc", hash1)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	require.EqualValues(t, 1, srv.inits.Load())
+
+	// A new avatar hash misses the cache.
+	_, err = second.Avatar(context.Background(), srv.querier(0), "avatar_abc", hash2)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, srv.inits.Load())
+}
+
+func TestAvatarHashTraversal(t *testing.T) {
+	srv := newFakeServer(t, []byte("avatar bytes"))
+	root := t.TempDir()
+	c := New(Config{Host: "127.0.0.1", CacheDir: filepath.Join(root, "a", "files")})
+
+	for _, hash := range []string{"../../x", "../" + hash1, strings.ToUpper(hash1)} {
+		_, err := c.Avatar(context.Background(), srv.querier(0), "avatar_abc", hash)
+		require.ErrorIs(t, err, ErrNotFound, hash)
+	}
+
+	require.Zero(t, srv.inits.Load())
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Empty(t, entries, "nothing should be written for a rejected hash")
+}
+
+func TestNegativeSize(t *testing.T) {
+	c := New(Config{Host: "127.0.0.1"})
+	q := func(*ts3.Cmd) ([]string, error) {
+		return []string{"clientftfid=1 serverftfid=7 ftkey=k port=1 size=-1 status=0"}, nil
+	}
+
+	_, err := c.Download(context.Background(), q, "/avatar_abc")
+	require.ErrorContains(t, err, "negative size")
+}
@@ -0,0 +1,164 @@
+// Package hosted reads TeamSpeak state from the REST APIs of hosting
+// providers, for servers whose owners have no ServerQuery access.
+//
+// Two kinds of API are supported:
+//
+//   - webquery: TeamSpeak's own HTTP query interface (server 3.12 and later),
+//     which many hosts hand out as an API key instead of a ServerQuery login.
+//   - panel: a provider's own control panel API returning JSON. Panels differ
+//     in layout, so the fields are located by dotted paths from the config.
+package hosted
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Providers.
+const (
+	ProviderWebQuery = "webquery"
+	ProviderPanel    = "panel"
+)
+
+const (
+	// defaultTimeout bounds a single request.
+	defaultTimeout = 10 * time.Second
+
+	// maxResponseSize bounds a response body.
+	maxResponseSize = 1 << 20
+)
+
+// Config holds hosting API settings.
+type Config struct {
+	Name     string // Display name overriding the server's own
+	Provider string // ProviderWebQuery or ProviderPanel
+	// URL is the WebQuery base URL, e.g. "https://ts.example.com:10443", or
+	// the panel endpoint returning the server status.
+	URL      string
+	APIKey   string            // Sent as x-api-key to WebQuery
+	ServerID int               // WebQuery virtual server id (default: 1)
+	Headers  map[string]string // Extra request headers, e.g. a panel's Authorization
+	Fields   Fields            // Where a panel response holds each value
+	Timeout  time.Duration
+}
+
+// Fields locates values in a panel response by dotted path, e.g.
+// "data.server.slots"; numeric path elements index arrays. The user fields
+// are relative to each entry of Users. Empty paths are skipped.
+type Fields struct {
+	ServerName    string
+	MaxUsers      string
+	TotalUsers    string // Default: the number of entries in Users
+	UptimeSeconds string
+	Users         string // Array of connected users
+	UserName      string
+	UserChannel   string // Channel name; users without one are listed under "Online"
+	UserAway      string
+	UserMuted     string
+}
+
+type service struct {
+	log    logrus.FieldLogger
+	cfg    Config
+	client *http.Client
+}
+
+// NewService creates a hosting API source.
+func NewService(log logrus.FieldLogger, cfg Config) teamspeak.Source {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.ServerID == 0 {
+		cfg.ServerID = 1
+	}
+
+	return &service{
+		log:    log.WithField("component", "hosted"),
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Start checks the API answers with a usable state.
+func (s *service) Start(ctx context.Context) error {
+	if _, err := s.GetState(ctx); err != nil {
+		return err
+	}
+
+	s.log.WithField("provider", s.cfg.Provider).Info("Hosting API reachable")
+
+	return nil
+}
+
+// Stop is a no-op; every fetch is a separate request.
+func (s *service) Stop() error {
+	return nil
+}
+
+// GetState fetches the state from the provider's API.
+func (s *service) GetState(ctx context.Context) (*teamspeak.State, error) {
+	var (
+		state *teamspeak.State
+		err   error
+	)
+
+	switch s.cfg.Provider {
+	case ProviderWebQuery:
+		state, err = s.webQuery(ctx)
+	case ProviderPanel:
+		state, err = s.panel(ctx)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", s.cfg.Provider)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state.FetchedAt = time.Now()
+
+	if s.cfg.Name != "" {
+		state.ServerName = s.cfg.Name
+	}
+
+	return state, nil
+}
+
+// get requests url and decodes the JSON response into v.
+func (s *service) get(ctx context.Context, url string, headers map[string]string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch state: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
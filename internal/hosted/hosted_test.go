@@ -0,0 +1,105 @@
+package hosted
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebQuery(t *testing.T) {
+	responses := map[string]string{
+		"/1/serverinfo": `{"body":[{"virtualserver_name":"Game Night","virtualserver_maxclients":"32","virtualserver_uptime":"7200"}],
+			"status":{"code":0,"message":"ok"}}`,
+		"/1/channellist": `{"body":[
+			{"cid":"1","pid":"0","channel_order":"0","channel_name":"Lobby","channel_flag_default":"1","channel_flag_permanent":"1"},
+			{"cid":"2","pid":"0","channel_order":"1","channel_name":"Music","channel_flag_semi_permanent":"1"}],
+			"status":{"code":0,"message":"ok"}}`,
+		"/1/clientlist": `{"body":[
+			{"clid":"5","cid":"1","client_nickname":"alice","client_type":"0","client_input_muted":"1","client_idle_time":"30000"},
+			{"clid":"6","cid":"2","client_nickname":"bob","client_type":"0","client_away":"1","client_away_message":"brb"},
+			{"clid":"7","cid":"1","client_nickname":"serveradmin","client_type":"1"}],
+			"status":{"code":0,"message":"ok"}}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" {
+			_, _ = w.Write([]byte(`{"status":{"code":5122,"message":"invalid apikey"}}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(responses[r.URL.Path]))
+	}))
+	t.Cleanup(srv.Close)
+
+	svc := NewService(logrus.New(), Config{Provider: ProviderWebQuery, URL: srv.URL + "/", APIKey: "key"})
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Game Night", state.ServerName)
+	require.Equal(t, 32, state.MaxClients)
+	require.Equal(t, 2*time.Hour, state.Uptime)
+	require.Equal(t, 2, state.TotalUsers)
+	require.Len(t, state.Channels, 2)
+	require.True(t, state.Channels[0].IsDefault)
+	require.True(t, state.Channels[1].IsSemiPermanent)
+
+	// The query client is not listed.
+	require.Len(t, state.Channels[0].Users, 1)
+	require.True(t, state.Channels[0].Users[0].InputMuted)
+	require.Equal(t, 30*time.Second, state.Channels[0].Users[0].IdleTime)
+	require.Equal(t, "brb", state.Channels[1].Users[0].AwayMessage)
+
+	_, err = NewService(logrus.New(), Config{Provider: ProviderWebQuery, URL: srv.URL}).GetState(context.Background())
+	require.ErrorContains(t, err, "invalid apikey")
+}
+
+func TestPanelState(t *testing.T) {
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"data": {
+			"server": {"name": "Hosted TS", "slots": "16", "uptime": 60},
+			"clients": [
+				{"nick": "alice", "channel": {"name": "Lobby"}, "away": false, "muted": 1},
+				{"nick": "bob", "channel": {"name": "Games"}, "away": "yes"},
+				{"nick": "carol", "channel": {"name": "Lobby"}}
+			]
+		}
+	}`), &doc))
+
+	state, err := panelState(doc, Fields{
+		ServerName:    "data.server.name",
+		MaxUsers:      "data.server.slots",
+		UptimeSeconds: "data.server.uptime",
+		Users:         "data.clients",
+		UserName:      "nick",
+		UserChannel:   "channel.name",
+		UserAway:      "away",
+		UserMuted:     "muted",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hosted TS", state.ServerName)
+	require.Equal(t, 16, state.MaxClients)
+	require.Equal(t, time.Minute, state.Uptime)
+	require.Equal(t, 3, state.TotalUsers)
+	require.Len(t, state.Channels, 2)
+	require.Equal(t, "Lobby", state.Channels[0].Name)
+	require.Len(t, state.Channels[0].Users, 2)
+	require.True(t, state.Channels[0].Users[0].InputMuted)
+	require.True(t, state.Channels[1].Users[0].Away)
+
+	// Count-only panels report a total without a user list.
+	state, err = panelState(doc, Fields{ServerName: "data.server.name", TotalUsers: "data.clients.0.muted"})
+	require.NoError(t, err)
+	require.Equal(t, 1, state.TotalUsers)
+	require.Empty(t, state.Channels)
+
+	_, err = panelState(doc, Fields{Users: "data.players"})
+	require.Error(t, err)
+}
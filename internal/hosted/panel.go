@@ -0,0 +1,148 @@
+package hosted
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// panelChannel is the channel users are listed under when the panel does not
+// say where they are.
+const panelChannel = "Online"
+
+// panel fetches the panel endpoint and maps its response with the configured
+// fields.
+func (s *service) panel(ctx context.Context) (*teamspeak.State, error) {
+	var doc any
+	if err := s.get(ctx, s.cfg.URL, s.cfg.Headers, &doc); err != nil {
+		return nil, err
+	}
+
+	return panelState(doc, s.cfg.Fields)
+}
+
+// panelState maps a decoded panel response to the state model.
+func panelState(doc any, f Fields) (*teamspeak.State, error) {
+	state := &teamspeak.State{
+		ServerName: text(lookup(doc, f.ServerName)),
+		MaxClients: number(lookup(doc, f.MaxUsers)),
+		Uptime:     time.Duration(number(lookup(doc, f.UptimeSeconds))) * time.Second,
+	}
+
+	if f.Users != "" {
+		users, ok := lookup(doc, f.Users).([]any)
+		if !ok {
+			return nil, fmt.Errorf("response has no user list at %q", f.Users)
+		}
+
+		index := make(map[string]int)
+
+		for _, u := range users {
+			name := text(lookup(u, f.UserChannel))
+			if name == "" {
+				name = panelChannel
+			}
+
+			i, ok := index[name]
+			if !ok {
+				i = len(state.Channels)
+				index[name] = i
+				state.Channels = append(state.Channels, teamspeak.Channel{
+					ID:          i + 1,
+					Name:        name,
+					Order:       i,
+					IsPermanent: true,
+				})
+			}
+
+			ch := &state.Channels[i]
+			ch.Users = append(ch.Users, teamspeak.User{
+				Nickname:   text(lookup(u, f.UserName)),
+				ChannelID:  ch.ID,
+				Away:       flag(lookup(u, f.UserAway)),
+				InputMuted: flag(lookup(u, f.UserMuted)),
+			})
+		}
+
+		state.TotalUsers = len(users)
+	}
+
+	if f.TotalUsers != "" {
+		state.TotalUsers = number(lookup(doc, f.TotalUsers))
+	}
+
+	return state, nil
+}
+
+// lookup follows a dotted path through decoded JSON, returning nil when any
+// element is missing or the path is empty.
+func lookup(v any, path string) any {
+	if path == "" {
+		return nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+// text returns a string or number value as a string.
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return ""
+}
+
+// number returns a number, or a string holding one, as an int.
+func number(v any) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.ParseFloat(v, 64)
+
+		return int(n)
+	}
+
+	return 0
+}
+
+// flag interprets booleans, non-zero numbers and "true", "yes" or "1" as set.
+func flag(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(v) {
+		case "1", "true", "yes":
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package hosted
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// webQueryEmpty is the status code WebQuery answers a command with when there
+// is nothing to list, e.g. clientlist on an empty server.
+const webQueryEmpty = 1281
+
+// webQueryResponse is the envelope of every WebQuery answer. Values are
+// strings, as on ServerQuery.
+type webQueryResponse struct {
+	Body   []map[string]string `json:"body"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// webQuery fetches the state with the same three commands the ServerQuery
+// client uses.
+func (s *service) webQuery(ctx context.Context) (*teamspeak.State, error) {
+	info, err := s.webQueryCommand(ctx, "serverinfo", "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info) == 0 {
+		return nil, fmt.Errorf("webquery serverinfo returned no server")
+	}
+
+	channels, err := s.webQueryCommand(ctx, "channellist", "-flags&-topic")
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := s.webQueryCommand(ctx, "clientlist", "-uid&-away&-voice&-times&-country")
+	if err != nil {
+		return nil, err
+	}
+
+	return webQueryState(info[0], channels, clients), nil
+}
+
+// webQueryCommand runs one command on the configured virtual server.
+func (s *service) webQueryCommand(ctx context.Context, command, flags string) ([]map[string]string, error) {
+	url := fmt.Sprintf("%s/%d/%s", strings.TrimRight(s.cfg.URL, "/"), s.cfg.ServerID, command)
+	if flags != "" {
+		url += "?" + flags
+	}
+
+	headers := maps.Clone(s.cfg.Headers)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+
+	headers["x-api-key"] = s.cfg.APIKey
+
+	var resp webQueryResponse
+	if err := s.get(ctx, url, headers, &resp); err != nil {
+		return nil, fmt.Errorf("webquery %s: %w", command, err)
+	}
+
+	switch resp.Status.Code {
+	case 0:
+		return resp.Body, nil
+	case webQueryEmpty:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("webquery %s: %s (code %d)", command, resp.Status.Message, resp.Status.Code)
+	}
+}
+
+// webQueryState builds the state from the serverinfo, channellist and
+// clientlist answers. Query clients are not listed or counted.
+func webQueryState(info map[string]string, channels, clients []map[string]string) *teamspeak.State {
+	state := &teamspeak.State{
+		ServerName: info["virtualserver_name"],
+		MaxClients: atoi(info["virtualserver_maxclients"]),
+		Uptime:     time.Duration(atoi(info["virtualserver_uptime"])) * time.Second,
+		Channels:   make([]teamspeak.Channel, 0, len(channels)),
+	}
+
+	index := make(map[int]int, len(channels))
+
+	for _, ch := range channels {
+		channel := teamspeak.Channel{
+			ID:              atoi(ch["cid"]),
+			ParentID:        atoi(ch["pid"]),
+			Name:            ch["channel_name"],
+			Order:           atoi(ch["channel_order"]),
+			Topic:           ch["channel_topic"],
+			IsDefault:       ch["channel_flag_default"] == "1",
+			IsPermanent:     ch["channel_flag_permanent"] == "1",
+			IsSemiPermanent: ch["channel_flag_semi_permanent"] == "1",
+		}
+
+		index[channel.ID] = len(state.Channels)
+		state.Channels = append(state.Channels, channel)
+	}
+
+	for _, cl := range clients {
+		if cl["client_type"] == "1" {
+			continue
+		}
+
+		user := teamspeak.User{
+			ID:          atoi(cl["clid"]),
+			UniqueID:    cl["client_unique_identifier"],
+			Nickname:    cl["client_nickname"],
+			ChannelID:   atoi(cl["cid"]),
+			InputMuted:  cl["client_input_muted"] == "1",
+			OutputMuted: cl["client_output_muted"] == "1",
+			Away:        cl["client_away"] == "1",
+			AwayMessage: cl["client_away_message"],
+			IdleTime:    time.Duration(atoi(cl["client_idle_time"])) * time.Millisecond,
+			Country:     cl["client_country"],
+		}
+
+		if t := atoi(cl["client_lastconnected"]); t > 0 {
+			user.ConnectedAt = time.Unix(int64(t), 0)
+		}
+
+		state.TotalUsers++
+
+		if i, ok := index[user.ChannelID]; ok {
+			state.Channels[i].Users = append(state.Channels[i].Users, user)
+		}
+	}
+
+	return state
+}
+
+// atoi parses a number, treating anything else as 0.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+
+	return n
+}
@@ -0,0 +1,190 @@
+// Package jsonsource reads presence state from a user-provided HTTP endpoint
+// returning JSON, so arbitrary systems can feed the Discord embed.
+//
+// The endpoint must answer GET requests with a document like:
+//
+//	{
+//	  "name": "Game Night",
+//	  "max_users": 32,
+//	  "uptime_seconds": 7200,
+//	  "channels": [
+//	    {
+//	      "name": "Lobby",
+//	      "users": [
+//	        {"name": "alice", "away": false, "muted": true, "deafened": false, "idle_seconds": 30}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Every field is optional. total_users may be given instead of channels for
+// count-only systems; otherwise it is the number of listed users.
+package jsonsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// defaultTimeout bounds a single fetch.
+	defaultTimeout = 10 * time.Second
+
+	// maxDocumentSize bounds the response body.
+	maxDocumentSize = 1 << 20
+)
+
+// Config holds JSON endpoint settings.
+type Config struct {
+	Name    string            // Display name overriding the document's name
+	URL     string            // Endpoint returning the state document
+	Headers map[string]string // Extra request headers, e.g. Authorization
+	Timeout time.Duration
+}
+
+// Document is the schema served by the endpoint.
+type Document struct {
+	Name          string    `json:"name"`
+	MaxUsers      int       `json:"max_users"`
+	TotalUsers    *int      `json:"total_users"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Channels      []Channel `json:"channels"`
+}
+
+// Channel is a channel in the document.
+type Channel struct {
+	Name  string `json:"name"`
+	Users []User `json:"users"`
+}
+
+// User is a user in the document.
+type User struct {
+	Name        string `json:"name"`
+	Away        bool   `json:"away"`
+	AwayMessage string `json:"away_message"`
+	Muted       bool   `json:"muted"`
+	Deafened    bool   `json:"deafened"`
+	IdleSeconds int64  `json:"idle_seconds"`
+}
+
+type service struct {
+	log    logrus.FieldLogger
+	cfg    Config
+	client *http.Client
+}
+
+// NewService creates a JSON endpoint source.
+func NewService(log logrus.FieldLogger, cfg Config) teamspeak.Source {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &service{
+		log:    log.WithField("component", "jsonsource"),
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Start checks the endpoint is reachable and serves a valid document.
+func (s *service) Start(ctx context.Context) error {
+	if _, err := s.GetState(ctx); err != nil {
+		return err
+	}
+
+	s.log.WithField("url", s.cfg.URL).Info("JSON source reachable")
+
+	return nil
+}
+
+// Stop is a no-op; every fetch is a separate request.
+func (s *service) Stop() error {
+	return nil
+}
+
+// GetState fetches and converts the document.
+func (s *service) GetState(ctx context.Context) (*teamspeak.State, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch state: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var doc Document
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	state := doc.State()
+	state.FetchedAt = time.Now()
+
+	if s.cfg.Name != "" {
+		state.ServerName = s.cfg.Name
+	}
+
+	return state, nil
+}
+
+// State converts the document to the shared state model.
+func (d *Document) State() *teamspeak.State {
+	state := &teamspeak.State{
+		ServerName: d.Name,
+		MaxClients: d.MaxUsers,
+		Uptime:     time.Duration(d.UptimeSeconds) * time.Second,
+		Channels:   make([]teamspeak.Channel, 0, len(d.Channels)),
+	}
+
+	for i, ch := range d.Channels {
+		channel := teamspeak.Channel{
+			ID:          i + 1,
+			Name:        ch.Name,
+			Order:       i,
+			IsPermanent: true,
+			Users:       make([]teamspeak.User, 0, len(ch.Users)),
+		}
+
+		for _, u := range ch.Users {
+			channel.Users = append(channel.Users, teamspeak.User{
+				Nickname:    u.Name,
+				ChannelID:   channel.ID,
+				Away:        u.Away,
+				AwayMessage: u.AwayMessage,
+				InputMuted:  u.Muted,
+				OutputMuted: u.Deafened,
+				IdleTime:    time.Duration(u.IdleSeconds) * time.Second,
+			})
+		}
+
+		state.TotalUsers += len(channel.Users)
+		state.Channels = append(state.Channels, channel)
+	}
+
+	if d.TotalUsers != nil {
+		state.TotalUsers = *d.TotalUsers
+	}
+
+	return state
+}
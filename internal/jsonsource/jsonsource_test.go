@@ -0,0 +1,56 @@
+package jsonsource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetState(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{
+			"name": "Game Night",
+			"max_users": 16,
+			"uptime_seconds": 60,
+			"channels": [
+				{"name": "Lobby", "users": [{"name": "alice", "muted": true}, {"name": "bob", "away": true}]},
+				{"name": "Empty"}
+			]
+		}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	svc := NewService(logrus.New(), Config{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Game Night", state.ServerName)
+	require.Equal(t, 2, state.TotalUsers)
+	require.Equal(t, 16, state.MaxClients)
+	require.Equal(t, time.Minute, state.Uptime)
+	require.Len(t, state.Channels, 2)
+	require.True(t, state.Channels[0].Users[0].InputMuted)
+	require.True(t, state.Channels[0].Users[1].Away)
+	require.Empty(t, state.Channels[1].Users)
+
+	_, err = NewService(logrus.New(), Config{URL: srv.URL}).GetState(context.Background())
+	require.Error(t, err)
+}
+
+func TestDocumentTotalUsersOverride(t *testing.T) {
+	total := 12
+	doc := Document{Name: "Counts only", TotalUsers: &total}
+
+	require.Equal(t, 12, doc.State().TotalUsers)
+}
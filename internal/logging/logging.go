@@ -0,0 +1,76 @@
+// Package logging provides per-component log level overrides on top of a
+// shared logrus logger.
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Components are the names accepted in level overrides, matching the
+// "component" field each service logs with.
+var Components = []string{"a2s", "api", "bridge", "discord", "jsonsource", "minecraft", "mumble", "store", "teamspeak"}
+
+// Loggers hands out the logger for each component: the base logger, or a copy
+// writing to the same output at an overridden level.
+type Loggers struct {
+	base      *logrus.Logger
+	overrides map[string]*logrus.Logger
+}
+
+// New creates loggers with the given level overrides, keyed by component.
+func New(base *logrus.Logger, levels map[string]string) (*Loggers, error) {
+	l := &Loggers{base: base, overrides: make(map[string]*logrus.Logger, len(levels))}
+
+	// Sort so errors are reported in a stable order.
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !known(name) {
+			return nil, fmt.Errorf("unknown log component %q (known: %s)", name, strings.Join(Components, ", "))
+		}
+
+		level, err := logrus.ParseLevel(levels[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q for %s: %w", levels[name], name, err)
+		}
+
+		l.overrides[name] = &logrus.Logger{
+			Out:          base.Out,
+			Hooks:        base.Hooks,
+			Formatter:    base.Formatter,
+			ReportCaller: base.ReportCaller,
+			Level:        level,
+			ExitFunc:     base.ExitFunc,
+		}
+	}
+
+	return l, nil
+}
+
+// For returns the logger for a component.
+func (l *Loggers) For(component string) logrus.FieldLogger {
+	if logger, ok := l.overrides[component]; ok {
+		return logger
+	}
+
+	return l.base
+}
+
+func known(name string) bool {
+	for _, c := range Components {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
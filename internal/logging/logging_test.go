@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelOverrides(t *testing.T) {
+	var buf bytes.Buffer
+
+	base := logrus.New()
+	base.SetOutput(&buf)
+	base.SetLevel(logrus.InfoLevel)
+
+	loggers, err := New(base, map[string]string{"discord": "debug", "teamspeak": "warn"})
+	require.NoError(t, err)
+
+	loggers.For("discord").Debug("discord debug")
+	loggers.For("teamspeak").Info("teamspeak info")
+	loggers.For("bridge").Info("bridge info")
+	loggers.For("bridge").Debug("bridge debug")
+
+	out := buf.String()
+	require.Contains(t, out, "discord debug")
+	require.Contains(t, out, "bridge info")
+	require.NotContains(t, out, "teamspeak info")
+	require.NotContains(t, out, "bridge debug")
+
+	_, err = New(base, map[string]string{"discrod": "debug"})
+	require.ErrorContains(t, err, "unknown log component")
+
+	_, err = New(base, map[string]string{"discord": "loud"})
+	require.ErrorContains(t, err, "invalid log level")
+}
@@ -0,0 +1,92 @@
+// Package logsample rate-limits repeated log lines so a persistent failure
+// does not flood the log with identical warnings.
+package logsample
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sampler lets the first Burst lines of each key through per Interval and
+// suppresses the rest, reporting how many were dropped on the next line that
+// gets through. A nil Sampler logs everything.
+type Sampler struct {
+	interval time.Duration
+	burst    int
+
+	mu      sync.Mutex
+	entries map[string]*window
+	now     func() time.Time
+}
+
+type window struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// New creates a sampler. A burst or interval of zero disables sampling.
+func New(interval time.Duration, burst int) *Sampler {
+	if interval <= 0 || burst <= 0 {
+		return nil
+	}
+
+	return &Sampler{
+		interval: interval,
+		burst:    burst,
+		entries:  make(map[string]*window),
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a line with key should be logged now, and how many
+// lines with that key were suppressed since the last one logged.
+func (s *Sampler) Allow(key string) (bool, int) {
+	if s == nil {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	w, ok := s.entries[key]
+	if !ok || now.Sub(w.start) >= s.interval {
+		suppressed := 0
+		if ok {
+			suppressed = w.suppressed
+		}
+
+		s.entries[key] = &window{start: now, count: 1}
+
+		return true, suppressed
+	}
+
+	if w.count < s.burst {
+		w.count++
+
+		return true, 0
+	}
+
+	w.suppressed++
+
+	return false, 0
+}
+
+// Warn logs msg at warn level unless lines with the same message are being
+// suppressed.
+func (s *Sampler) Warn(entry *logrus.Entry, msg string) {
+	ok, suppressed := s.Allow(msg)
+	if !ok {
+		return
+	}
+
+	if suppressed > 0 {
+		entry = entry.WithField("suppressed", suppressed)
+	}
+
+	entry.Warn(msg)
+}
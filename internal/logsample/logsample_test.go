@@ -0,0 +1,39 @@
+package logsample
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(time.Minute, 2)
+	s.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false, false, false} {
+		ok, suppressed := s.Allow("query failed")
+		require.Equal(t, want, ok, "line %d", i)
+		require.Zero(t, suppressed)
+	}
+
+	// Other keys are sampled independently.
+	ok, _ := s.Allow("edit failed")
+	require.True(t, ok)
+
+	now = now.Add(time.Minute)
+
+	ok, suppressed := s.Allow("query failed")
+	require.True(t, ok)
+	require.Equal(t, 3, suppressed)
+}
+
+func TestNilSamplerAllowsEverything(t *testing.T) {
+	var s *Sampler
+
+	ok, suppressed := s.Allow("x")
+	require.True(t, ok)
+	require.Zero(t, suppressed)
+	require.Nil(t, New(0, 3))
+}
@@ -0,0 +1,174 @@
+// Package metrics defines the Prometheus metrics exported by the service.
+// Only the user gauges are labelled by server; the counters and update
+// durations cover every server together, since one update cycle and one
+// Discord session serve them all.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "ts_discord_status"
+
+// Error categories counted by Error.
+const (
+	ErrorTSConnect        = "ts_connect"         // Connecting or logging in to a voice server
+	ErrorTSQuery          = "ts_query"           // Querying state from a connected server
+	ErrorDiscordEdit      = "discord_edit"       // Editing the status message
+	ErrorDiscordRateLimit = "discord_rate_limit" // Requests held back by Discord rate limits
+	ErrorRender           = "render"             // Building images or other embed content
+	ErrorEmbedLimit       = "embed_limit"        // Embed content trimmed to fit Discord's limits
+)
+
+// Reconnect targets counted by Reconnect.
+const (
+	ReconnectDiscord   = "discord"
+	ReconnectTeamSpeak = "teamspeak"
+)
+
+// Update cycle phases timed by ObserveUpdate.
+const (
+	PhaseFetch   = "fetch"   // Fetching state from the voice servers
+	PhaseDiscord = "discord" // Rendering and editing the status message
+	PhaseCycle   = "cycle"   // The whole update, including notifications and recording
+)
+
+var errorCategories = []string{ErrorTSConnect, ErrorTSQuery, ErrorDiscordEdit, ErrorDiscordRateLimit, ErrorRender, ErrorEmbedLimit}
+
+var registry = prometheus.NewRegistry()
+
+var errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "errors_total",
+	Help:      "Errors by category.",
+}, []string{"category"})
+
+var reconnectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "reconnects_total",
+	Help:      "Successful reconnects after a lost connection, by target.",
+}, []string{"target"})
+
+var usersOnline = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "users_online",
+	Help:      "Users online at the last successful fetch, by server.",
+}, []string{"server"})
+
+var maxClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "max_clients",
+	Help:      "Slots available at the last successful fetch, by server.",
+}, []string{"server"})
+
+var channelUsers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "channel_users",
+	Help:      "Users in each allowlisted channel at the last successful fetch, by server.",
+}, []string{"server", "channel"})
+
+var updateSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "update_duration_seconds",
+	Help:      "Duration of update cycle phases.",
+	Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+}, []string{"phase"})
+
+func init() {
+	registry.MustRegister(
+		errorsTotal,
+		reconnectsTotal,
+		usersOnline,
+		maxClients,
+		channelUsers,
+		updateSeconds,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	// Export every category from the start so rates work before the first
+	// error.
+	for _, c := range errorCategories {
+		errorsTotal.WithLabelValues(c)
+	}
+
+	reconnectsTotal.WithLabelValues(ReconnectDiscord)
+	reconnectsTotal.WithLabelValues(ReconnectTeamSpeak)
+}
+
+// Error counts an error of the given category.
+func Error(category string) {
+	errorsTotal.WithLabelValues(category).Inc()
+}
+
+// Reconnect counts a successful reconnect to the given target.
+func Reconnect(target string) {
+	reconnectsTotal.WithLabelValues(target).Inc()
+}
+
+// Occupancy sets the user gauges of one server.
+func Occupancy(server string, online, slots int) {
+	usersOnline.WithLabelValues(server).Set(float64(online))
+	maxClients.WithLabelValues(server).Set(float64(slots))
+}
+
+// ChannelUsers sets the user gauge of one channel on a server. Callers bound
+// the channel names themselves, since every name is a time series.
+func ChannelUsers(server, channel string, users int) {
+	channelUsers.WithLabelValues(server, channel).Set(float64(users))
+}
+
+// ResetOccupancy removes every user gauge, so servers no longer shown (or
+// renamed) stop being exported.
+func ResetOccupancy() {
+	usersOnline.Reset()
+	maxClients.Reset()
+	channelUsers.Reset()
+}
+
+// ObserveUpdate records how long an update cycle phase took.
+func ObserveUpdate(phase string, d time.Duration) {
+	updateSeconds.WithLabelValues(phase).Observe(d.Seconds())
+}
+
+// Totals are counter values since the process started, for in-process
+// summaries.
+type Totals struct {
+	Errors     map[string]uint64 // By error category
+	Reconnects map[string]uint64 // By reconnect target
+}
+
+// Snapshot returns the current counter values.
+func Snapshot() Totals {
+	t := Totals{Errors: make(map[string]uint64), Reconnects: make(map[string]uint64)}
+
+	for _, c := range errorCategories {
+		t.Errors[c] = counterValue(errorsTotal.WithLabelValues(c))
+	}
+
+	for _, target := range []string{ReconnectDiscord, ReconnectTeamSpeak} {
+		t.Reconnects[target] = counterValue(reconnectsTotal.WithLabelValues(target))
+	}
+
+	return t
+}
+
+func counterValue(c prometheus.Counter) uint64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+
+	return uint64(m.GetCounter().GetValue())
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
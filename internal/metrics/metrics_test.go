@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounters(t *testing.T) {
+	before := Snapshot()
+
+	Error(ErrorTSQuery)
+	Error(ErrorTSQuery)
+	Reconnect(ReconnectDiscord)
+
+	require.Equal(t, float64(before.Errors[ErrorTSQuery]+2), testutil.ToFloat64(errorsTotal.WithLabelValues(ErrorTSQuery)))
+	require.Equal(t, float64(before.Reconnects[ReconnectDiscord]+1), testutil.ToFloat64(reconnectsTotal.WithLabelValues(ReconnectDiscord)))
+
+	after := Snapshot()
+	require.Equal(t, before.Errors[ErrorTSQuery]+2, after.Errors[ErrorTSQuery])
+	require.Equal(t, before.Reconnects[ReconnectTeamSpeak], after.Reconnects[ReconnectTeamSpeak])
+
+	// Every category and target is exported before it first counts.
+	require.Equal(t, len(errorCategories), testutil.CollectAndCount(errorsTotal))
+	require.Equal(t, 2, testutil.CollectAndCount(reconnectsTotal))
+}
+
+func TestOccupancy(t *testing.T) {
+	t.Cleanup(ResetOccupancy)
+
+	Occupancy("eu-1", 3, 32)
+	Occupancy("us-1", 0, 64)
+	ChannelUsers("eu-1", "Lobby", 2)
+
+	require.Equal(t, 3.0, testutil.ToFloat64(usersOnline.WithLabelValues("eu-1")))
+	require.Equal(t, 64.0, testutil.ToFloat64(maxClients.WithLabelValues("us-1")))
+	require.Equal(t, 2.0, testutil.ToFloat64(channelUsers.WithLabelValues("eu-1", "Lobby")))
+
+	require.NoError(t, testutil.CollectAndCompare(usersOnline, strings.NewReader(`
+# HELP ts_discord_status_users_online Users online at the last successful fetch, by server.
+# TYPE ts_discord_status_users_online gauge
+ts_discord_status_users_online{server="eu-1"} 3
+ts_discord_status_users_online{server="us-1"} 0
+`)))
+
+	// A reset drops every server's series.
+	ResetOccupancy()
+	require.Zero(t, testutil.CollectAndCount(usersOnline))
+	require.Zero(t, testutil.CollectAndCount(maxClients))
+	require.Zero(t, testutil.CollectAndCount(channelUsers))
+}
+
+func TestObserveUpdate(t *testing.T) {
+	ObserveUpdate(PhaseFetch, 200*time.Millisecond)
+	ObserveUpdate(PhaseCycle, time.Second)
+
+	require.Equal(t, 2, testutil.CollectAndCount(updateSeconds, namespace+"_update_duration_seconds"))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Contains(t, rec.Body.String(), `ts_discord_status_update_duration_seconds_bucket{phase="fetch",le="0.25"} 1`)
+	require.Contains(t, rec.Body.String(), `ts_discord_status_update_duration_seconds_count{phase="cycle"} 1`)
+}
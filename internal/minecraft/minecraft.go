@@ -0,0 +1,302 @@
+// Package minecraft reports Minecraft server status as a teamspeak.Source
+// using the server list ping protocol (Minecraft 1.7 and later).
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+const (
+	// DefaultPort is the default Minecraft server port.
+	DefaultPort = 25565
+
+	// defaultTimeout bounds a single status exchange.
+	defaultTimeout = 5 * time.Second
+
+	// maxResponseSize bounds the status JSON; servers with huge favicons
+	// stay well below this.
+	maxResponseSize = 1 << 20
+
+	// protocolUnknown asks the server to answer regardless of version.
+	protocolUnknown = -1
+)
+
+// formatCodes matches legacy § formatting codes in descriptions.
+var formatCodes = regexp.MustCompile("§.")
+
+// Config holds Minecraft server settings.
+type Config struct {
+	Name    string // Display name (defaults to the server description)
+	Host    string
+	Port    int // 0 looks up the _minecraft._tcp SRV record, then falls back to DefaultPort
+	Timeout time.Duration
+}
+
+// status is the JSON document of a status response.
+type status struct {
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+		Sample []struct {
+			Name string `json:"name"`
+			ID   string `json:"id"`
+		} `json:"sample"`
+	} `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+// chat is a (possibly nested) chat component used for descriptions.
+type chat struct {
+	Text  string `json:"text"`
+	Extra []chat `json:"extra"`
+}
+
+type service struct {
+	log logrus.FieldLogger
+	cfg Config
+}
+
+// NewService creates a Minecraft source.
+func NewService(log logrus.FieldLogger, cfg Config) teamspeak.Source {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &service{
+		log: log.WithField("component", "minecraft"),
+		cfg: cfg,
+	}
+}
+
+// Start checks the server answers status requests.
+func (s *service) Start(ctx context.Context) error {
+	if _, err := s.GetState(ctx); err != nil {
+		return err
+	}
+
+	s.log.WithField("host", s.cfg.Host).Info("Minecraft server reachable")
+
+	return nil
+}
+
+// Stop is a no-op; every fetch uses its own connection.
+func (s *service) Stop() error {
+	return nil
+}
+
+// GetState pings the server and reports its players. Online players listed in
+// the server's sample are shown in a single "Players" channel.
+func (s *service) GetState(ctx context.Context) (*teamspeak.State, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	host, port := s.resolve(ctx)
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Minecraft server: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if dl, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(dl); err != nil {
+			return nil, fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+
+	st, err := ping(conn, s.cfg.Host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	name := s.cfg.Name
+	if name == "" {
+		name = description(st.Description)
+	}
+
+	if name == "" {
+		name = s.cfg.Host
+	}
+
+	state := &teamspeak.State{
+		ServerName: name,
+		TotalUsers: st.Players.Online,
+		MaxClients: st.Players.Max,
+		FetchedAt:  time.Now(),
+	}
+
+	if len(st.Players.Sample) > 0 {
+		players := teamspeak.Channel{ID: 1, Name: "Players", IsPermanent: true}
+		for _, p := range st.Players.Sample {
+			players.Users = append(players.Users, teamspeak.User{UniqueID: p.ID, Nickname: p.Name, ChannelID: 1})
+		}
+
+		state.Channels = []teamspeak.Channel{players}
+	}
+
+	return state, nil
+}
+
+// resolve returns the address to connect to, honouring SRV records when no
+// port is configured.
+func (s *service) resolve(ctx context.Context) (string, int) {
+	if s.cfg.Port != 0 {
+		return s.cfg.Host, s.cfg.Port
+	}
+
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "minecraft", "tcp", s.cfg.Host)
+	if err != nil || len(addrs) == 0 {
+		return s.cfg.Host, DefaultPort
+	}
+
+	return strings.TrimSuffix(addrs[0].Target, "."), int(addrs[0].Port)
+}
+
+// ping performs the handshake and status request on conn.
+func ping(conn io.ReadWriter, host string, port int) (*status, error) {
+	var handshake bytes.Buffer
+
+	writeVarInt(&handshake, 0x00) // handshake packet id
+	writeVarInt(&handshake, protocolUnknown)
+	writeString(&handshake, host)
+	_ = binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, 1) // next state: status
+
+	var out bytes.Buffer
+
+	writePacket(&out, handshake.Bytes())
+	writePacket(&out, []byte{0x00}) // status request
+
+	if _, err := conn.Write(out.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send status request: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status response: %w", err)
+	}
+
+	if length <= 0 || length > maxResponseSize {
+		return nil, fmt.Errorf("invalid status response length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, fmt.Errorf("failed to read status response: %w", err)
+	}
+
+	pr := bytes.NewReader(packet)
+
+	if id, err := readVarInt(pr); err != nil || id != 0x00 {
+		return nil, fmt.Errorf("unexpected status response packet")
+	}
+
+	n, err := readVarInt(pr)
+	if err != nil || n < 0 || n > pr.Len() {
+		return nil, fmt.Errorf("invalid status response string")
+	}
+
+	var st status
+	if err := json.Unmarshal(packet[len(packet)-pr.Len():][:n], &st); err != nil {
+		return nil, fmt.Errorf("failed to decode status: %w", err)
+	}
+
+	return &st, nil
+}
+
+// description flattens a description, which is either a plain string or a
+// chat component, to plain text.
+func description(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		var c chat
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return ""
+		}
+
+		text = c.flatten()
+	}
+
+	text = formatCodes.ReplaceAllString(text, "")
+
+	// Descriptions are often two lines; the first is the server's name.
+	first, _, _ := strings.Cut(text, "\n")
+
+	return strings.TrimSpace(first)
+}
+
+func (c chat) flatten() string {
+	var b strings.Builder
+
+	b.WriteString(c.Text)
+
+	for _, e := range c.Extra {
+		b.WriteString(e.flatten())
+	}
+
+	return b.String()
+}
+
+func writePacket(w *bytes.Buffer, payload []byte) {
+	writeVarInt(w, int32(len(payload)))
+	w.Write(payload)
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	writeVarInt(w, int32(len(s)))
+	w.WriteString(s)
+}
+
+func writeVarInt(w *bytes.Buffer, v int32) {
+	u := uint32(v)
+
+	for {
+		if u&^0x7F == 0 {
+			w.WriteByte(byte(u))
+
+			return
+		}
+
+		w.WriteByte(byte(u&0x7F | 0x80))
+		u >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int, error) {
+	var v uint32
+
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		v |= uint32(b&0x7F) << (7 * i)
+
+		if b&0x80 == 0 {
+			return int(int32(v)), nil
+		}
+	}
+
+	return 0, errors.New("varint too long")
+}
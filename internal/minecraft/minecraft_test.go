@@ -0,0 +1,88 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// statusServer answers one status request per connection with body.
+func statusServer(t *testing.T, body string) *net.TCPAddr {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			r := bufio.NewReader(conn)
+
+			// Handshake, then the status request.
+			for i := 0; i < 2; i++ {
+				n, err := readVarInt(r)
+				if err != nil {
+					break
+				}
+
+				_, _ = io.CopyN(io.Discard, r, int64(n))
+			}
+
+			var payload, out bytes.Buffer
+
+			writeVarInt(&payload, 0x00)
+			writeString(&payload, body)
+			writePacket(&out, payload.Bytes())
+
+			_, _ = conn.Write(out.Bytes())
+			_ = conn.Close()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func TestGetState(t *testing.T) {
+	addr := statusServer(t, `{
+		"version": {"name": "1.21", "protocol": 767},
+		"players": {"max": 20, "online": 3, "sample": [{"name": "Steve", "id": "1"}, {"name": "Alex", "id": "2"}]},
+		"description": {"text": "§aCraft", "extra": [{"text": "land\nsecond line"}]}
+	}`)
+
+	svc := NewService(logrus.New(), Config{Host: addr.IP.String(), Port: addr.Port})
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Craftland", state.ServerName)
+	require.Equal(t, 3, state.TotalUsers)
+	require.Equal(t, 20, state.MaxClients)
+	require.Len(t, state.Channels, 1)
+	require.Equal(t, "Steve", state.Channels[0].Users[0].Nickname)
+}
+
+func TestDescriptionPlainString(t *testing.T) {
+	require.Equal(t, "Hello", description([]byte(`"§lHello\n§7world"`)))
+}
+
+func TestVarIntRoundTrip(t *testing.T) {
+	for _, v := range []int32{0, 1, 127, 128, 25565, 2097151, -1} {
+		var b bytes.Buffer
+
+		writeVarInt(&b, v)
+
+		got, err := readVarInt(&b)
+		require.NoError(t, err)
+		require.Equal(t, int(v), got)
+	}
+}
@@ -0,0 +1,65 @@
+package mumble
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// pingServer answers Mumble pings with fixed counts.
+func pingServer(t *testing.T, users, max uint32) *net.UDPAddr {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 64)
+
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			if n != pingRequestSize {
+				continue
+			}
+
+			resp := make([]byte, pingResponseSize)
+			binary.BigEndian.PutUint32(resp[0:], 0x00010500)
+			copy(resp[4:12], buf[4:12])
+			binary.BigEndian.PutUint32(resp[12:], users)
+			binary.BigEndian.PutUint32(resp[16:], max)
+			binary.BigEndian.PutUint32(resp[20:], 72000)
+
+			_, _ = conn.WriteToUDP(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestGetState(t *testing.T) {
+	addr := pingServer(t, 7, 50)
+
+	svc := NewService(logrus.New(), Config{
+		Name:    "Mumble",
+		Host:    addr.IP.String(),
+		Port:    addr.Port,
+		Timeout: time.Second,
+	})
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Mumble", state.ServerName)
+	require.Equal(t, 7, state.TotalUsers)
+	require.Equal(t, 50, state.MaxClients)
+	require.Empty(t, state.Channels)
+}
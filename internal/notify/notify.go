@@ -0,0 +1,291 @@
+// Package notify queues Discord notifications and batches bursts of similar
+// events, so a mass join after a server restart becomes one "5 users joined"
+// message instead of a message per user that trips Discord's rate limits.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/errreport"
+)
+
+// Event types routes subscribe to.
+const (
+	EventJoin       = "join"       // A user connected
+	EventLeave      = "leave"      // A user disconnected
+	EventOffline    = "offline"    // The server stopped answering, or came back
+	EventCapacity   = "capacity"   // The server is nearly full
+	EventModeration = "moderation" // A user is idling outside the AFK channels
+)
+
+// Events lists the event types routes and silences accept.
+var Events = []string{EventJoin, EventLeave, EventOffline, EventCapacity, EventModeration}
+
+// maxSubjects bounds the names listed in one batched message.
+const maxSubjects = 10
+
+// Config holds queue settings.
+type Config struct {
+	Size        int           // Events held at most; further events are dropped until it drains
+	BatchWindow time.Duration // How long the first event of a kind waits for others to join it
+}
+
+// Event is one notification.
+type Event struct {
+	Target string // Discord channel ID or webhook URL, as understood by the Sender
+	// Kind groups events for batching: events of the same kind to the same
+	// target within the batch window are sent as one message.
+	Kind    string
+	Subject string // Name listed in a batched message, e.g. a nickname
+	Text    string // Message when the event is sent on its own
+	// Summary heads a batched message; {count} is replaced with the number
+	// of events, e.g. "👋 {count} users joined".
+	Summary string
+	// Format wraps the message, single or batched; {text} is replaced with
+	// it. Empty sends the message as is.
+	Format string
+	// Roles are the role IDs the message may ping, e.g. those Format
+	// mentions; no other mention pings anyone.
+	Roles []string
+}
+
+// Sender delivers one message to a target, pinging only roles.
+type Sender func(ctx context.Context, target, content string, roles []string) error
+
+// Service defines the notification queue interface.
+type Service interface {
+	Start(ctx context.Context) error
+	Stop() error
+	// Push queues an event, reporting false if the queue is full and the
+	// event was dropped.
+	Push(e Event) bool
+}
+
+// batch is the pending events of one kind for one channel.
+type batch struct {
+	target  string
+	format  string
+	roles   []string
+	events  []Event
+	dropped int       // Events of the batch dropped while the queue was full
+	due     time.Time // When the batch is sent
+}
+
+type service struct {
+	log  logrus.FieldLogger
+	cfg  Config
+	send Sender
+
+	mu      sync.Mutex
+	batches map[string]*batch // By target, kind and format
+	order   []string          // Batch keys, oldest first
+	pending int               // Events across all batches
+
+	lifecycle sync.Mutex // Serializes Start and Stop
+	running   bool
+	wake      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewService creates a notification queue delivering through send.
+func NewService(log logrus.FieldLogger, cfg Config, send Sender) Service {
+	return &service{
+		log:     log.WithField("component", "notify"),
+		cfg:     cfg,
+		send:    send,
+		batches: make(map[string]*batch),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Start begins delivering queued events. Starting a running queue does
+// nothing.
+func (s *service) Start(ctx context.Context) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	s.running = true
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+
+	go s.loop(ctx, s.done)
+
+	return nil
+}
+
+// Stop stops the queue, discarding events still waiting for their batch
+// window. It can be started again afterwards.
+func (s *service) Stop() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+	close(s.done)
+	s.wg.Wait()
+
+	return nil
+}
+
+// Push queues an event. An event dropped because the queue is full is
+// counted in the message of its batch, which is sent with only the count if
+// none of its events could be queued.
+func (s *service) Push(e Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := e.Target + "\x00" + e.Kind + "\x00" + e.Format
+
+	b, ok := s.batches[key]
+	if !ok {
+		b = &batch{target: e.Target, format: e.Format, roles: e.Roles, due: time.Now().Add(s.cfg.BatchWindow)}
+		s.batches[key] = b
+		s.order = append(s.order, key)
+	}
+
+	if s.pending >= s.cfg.Size {
+		b.dropped++
+
+		return false
+	}
+
+	b.events = append(b.events, e)
+	s.pending++
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+func (s *service) loop(ctx context.Context, done <-chan struct{}) {
+	defer s.wg.Done()
+	defer errreport.Recover()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		for {
+			b, ok := s.next(time.Now())
+			if !ok {
+				break
+			}
+
+			s.deliver(ctx, b)
+		}
+
+		timer.Reset(s.untilDue(time.Now()))
+
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// next takes the oldest batch if it is due.
+func (s *service) next(now time.Time) (*batch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) == 0 {
+		return nil, false
+	}
+
+	key := s.order[0]
+	b := s.batches[key]
+
+	if now.Before(b.due) {
+		return nil, false
+	}
+
+	s.order = s.order[1:]
+	delete(s.batches, key)
+	s.pending -= len(b.events)
+
+	return b, true
+}
+
+// untilDue returns how long until the oldest batch is due.
+func (s *service) untilDue(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) == 0 {
+		return time.Hour
+	}
+
+	return max(s.batches[s.order[0]].due.Sub(now), 0)
+}
+
+func (s *service) deliver(ctx context.Context, b *batch) {
+	if err := s.send(ctx, b.target, message(b), b.roles); err != nil {
+		s.log.WithError(err).WithField("target", redact(b.target)).Warn("Failed to send notification")
+	}
+}
+
+// message formats a batch: a single event as its own text, several as the
+// summary and the first maxSubjects names, wrapped in the events' Format and
+// followed by the number of events dropped.
+func message(b *batch) string {
+	if len(b.events) == 0 {
+		return Format(b.format, fmt.Sprintf("*%d notifications were dropped while the queue was full*", b.dropped))
+	}
+
+	content := Format(b.format, text(b.events))
+
+	if b.dropped > 0 {
+		content += fmt.Sprintf("\n*%d more notifications were dropped while the queue was full*", b.dropped)
+	}
+
+	return content
+}
+
+// Format wraps text in format, replacing {text}. An empty format returns text
+// unchanged.
+func Format(format, text string) string {
+	if format == "" {
+		return text
+	}
+
+	return strings.ReplaceAll(format, "{text}", text)
+}
+
+func text(events []Event) string {
+	if len(events) == 1 {
+		return events[0].Text
+	}
+
+	names := make([]string, 0, min(len(events), maxSubjects))
+	for _, e := range events[:min(len(events), maxSubjects)] {
+		names = append(names, e.Subject)
+	}
+
+	list := strings.Join(names, ", ")
+	if extra := len(events) - len(names); extra > 0 {
+		list += fmt.Sprintf(" and %d more", extra)
+	}
+
+	return strings.ReplaceAll(events[0].Summary, "{count}", fmt.Sprintf("%d", len(events))) + ": " + list
+}
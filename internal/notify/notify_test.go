@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func join(name string) Event {
+	return Event{
+		Target:  "1",
+		Kind:    "join",
+		Subject: name,
+		Text:    "👋 " + name + " joined",
+		Summary: "👋 {count} users joined",
+	}
+}
+
+func TestBatching(t *testing.T) {
+	s := NewService(logrus.New(), Config{Size: 13, BatchWindow: 10 * time.Second}, nil).(*service)
+	now := time.Now()
+
+	require.True(t, s.Push(join("alice")))
+
+	_, ok := s.next(now)
+	require.False(t, ok, "waits for the batch window")
+
+	b, ok := s.next(now.Add(11 * time.Second))
+	require.True(t, ok)
+	require.Equal(t, "1", b.target)
+	require.Equal(t, "👋 alice joined", message(b))
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		require.True(t, s.Push(join(name)))
+	}
+
+	require.True(t, s.Push(Event{Target: "2", Kind: "idle", Text: "💤 m is idle"}))
+	require.False(t, s.Push(join("n")), "the queue is full")
+
+	b, ok = s.next(now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, "👋 12 users joined: a, b, c, d, e, f, g, h, i, j and 2 more\n"+
+		"*1 more notifications were dropped while the queue was full*", message(b))
+
+	b, ok = s.next(now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, "2", b.target)
+	require.Equal(t, "💤 m is idle", message(b))
+
+	_, ok = s.next(now.Add(time.Minute))
+	require.False(t, ok)
+}
+
+func TestRestart(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+
+	s := NewService(logrus.New(), Config{Size: 10}, func(_ context.Context, _, content string, _ []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sent = append(sent, content)
+
+		return nil
+	})
+	ctx := context.Background()
+
+	require.NoError(t, s.Stop())
+	require.NoError(t, s.Start(ctx))
+	require.NoError(t, s.Start(ctx))
+	require.NoError(t, s.Stop())
+	require.NoError(t, s.Stop())
+
+	// A restarted queue delivers again.
+	require.NoError(t, s.Start(ctx))
+	t.Cleanup(func() { _ = s.Stop() })
+
+	require.True(t, s.Push(join("alice")))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(sent) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflow(t *testing.T) {
+	s := NewService(logrus.New(), Config{Size: 1}, nil).(*service)
+	now := time.Now().Add(time.Second)
+
+	require.True(t, s.Push(join("alice")))
+	require.False(t, s.Push(Event{Target: "2", Kind: "idle", Text: "💤 m is idle", Format: "[{text}]"}))
+	require.False(t, s.Push(join("bob")))
+
+	// Each drop is reported with the batch it belongs to, also when none of
+	// that batch's events made it into the queue.
+	b, ok := s.next(now)
+	require.True(t, ok)
+	require.Equal(t, "1", b.target)
+	require.Equal(t, "👋 alice joined\n*1 more notifications were dropped while the queue was full*", message(b))
+
+	b, ok = s.next(now)
+	require.True(t, ok)
+	require.Equal(t, "2", b.target)
+	require.Equal(t, "[*1 notifications were dropped while the queue was full*]", message(b))
+
+	// The drained queue takes events again and reports no old drops.
+	require.True(t, s.Push(join("carol")))
+
+	b, ok = s.next(now)
+	require.True(t, ok)
+	require.Equal(t, "👋 carol joined", message(b))
+}
+
+func TestPostWebhook(t *testing.T) {
+	var body map[string]any
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	// Only the given roles are pinged, whatever the content mentions.
+	require.NoError(t, PostWebhook(context.Background(), srv.URL, "<@&42> @everyone joined", "42"))
+	require.Equal(t, map[string]any{"parse": []any{}, "roles": []any{"42"}}, body["allowed_mentions"])
+
+	require.NoError(t, PostWebhook(context.Background(), srv.URL, "@everyone joined"))
+	require.Equal(t, map[string]any{"parse": []any{}}, body["allowed_mentions"])
+}
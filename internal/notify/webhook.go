@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds one webhook post.
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// IsWebhook reports whether target is a webhook URL rather than a channel ID.
+func IsWebhook(target string) bool {
+	return strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://")
+}
+
+// allowedMentions is the allowed_mentions object of a webhook message.
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// PostWebhook posts content to a Discord webhook URL. Mentions in content
+// ping nobody besides the given roles.
+func PostWebhook(ctx context.Context, url, content string, roles ...string) error {
+	body, err := json.Marshal(map[string]any{
+		"content":          content,
+		"allowed_mentions": allowedMentions{Parse: []string{}, Roles: roles},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach webhook: %w", redactErr(err, url))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// redact hides the token part of a webhook URL for logging; channel IDs are
+// returned unchanged.
+func redact(target string) string {
+	if !IsWebhook(target) {
+		return target
+	}
+
+	if i := strings.LastIndex(target, "/"); i > 0 {
+		return target[:i] + "/…"
+	}
+
+	return target
+}
+
+// redactErr replaces the webhook URL, which embeds its token, in errors from
+// the HTTP client.
+func redactErr(err error, url string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), url, redact(url)))
+}
@@ -0,0 +1,99 @@
+// Package pipeline chains the stages a state passes through between its source
+// and the renderers: sanitize, filter, enrich, render. Each stage is a
+// middleware wrapping the next, so stages can be added, reordered and tested
+// on their own.
+package pipeline
+
+import (
+	"context"
+
+	"github.com/samcm/ts-discord-status/internal/contentfilter"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// Handler consumes a state, e.g. by rendering it.
+type Handler func(ctx context.Context, state *teamspeak.State) error
+
+// Middleware is one stage. It passes a (possibly rewritten) state on to next,
+// or stops the chain by returning without calling it. Stages must not modify
+// the state they receive; they pass on a copy instead.
+type Middleware func(next Handler) Handler
+
+// Chain wraps h in the stages, the first stage running first.
+func Chain(h Handler, stages ...Middleware) Handler {
+	for i := len(stages) - 1; i >= 0; i-- {
+		h = stages[i](h)
+	}
+
+	return h
+}
+
+// Apply runs the stages and returns the state that reaches the end of the
+// chain, or nil if a stage stopped it.
+func Apply(ctx context.Context, state *teamspeak.State, stages ...Middleware) (*teamspeak.State, error) {
+	var out *teamspeak.State
+
+	err := Chain(func(_ context.Context, s *teamspeak.State) error {
+		out = s
+
+		return nil
+	}, stages...)(ctx, state)
+
+	return out, err
+}
+
+// Map returns a stage rewriting each non-nil state with fn.
+func Map(fn func(*teamspeak.State) *teamspeak.State) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, state *teamspeak.State) error {
+			if state != nil {
+				state = fn(state)
+			}
+
+			return next(ctx, state)
+		}
+	}
+}
+
+// Sanitize strips invisible characters from every displayed string, for
+// sources that do not clean their own names.
+func Sanitize() Middleware {
+	return Map(func(state *teamspeak.State) *teamspeak.State {
+		out := state.Clone()
+		sanitize(out)
+
+		return out
+	})
+}
+
+func sanitize(s *teamspeak.State) {
+	s.ServerName = teamspeak.Sanitize(s.ServerName)
+	s.Subtitle = teamspeak.Sanitize(s.Subtitle)
+
+	for i := range s.Channels {
+		ch := &s.Channels[i]
+		ch.Name = teamspeak.Sanitize(ch.Name)
+		ch.Topic = teamspeak.Sanitize(ch.Topic)
+
+		for j := range ch.Users {
+			ch.Users[j].Nickname = teamspeak.Sanitize(ch.Users[j].Nickname)
+			ch.Users[j].AwayMessage = teamspeak.Sanitize(ch.Users[j].AwayMessage)
+		}
+	}
+
+	for _, sv := range s.Servers {
+		sanitize(sv)
+	}
+}
+
+// ContentFilter rewrites filtered words and patterns; a nil filter passes
+// states through.
+func ContentFilter(f *contentfilter.Filter) Middleware {
+	return Map(f.State)
+}
+
+// HideChannels drops spacer channels and those matched by filter, so no
+// renderer shows them. User totals are left as reported.
+func HideChannels(filter teamspeak.ChannelFilter) Middleware {
+	return Map(filter.Apply)
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/contentfilter"
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+
+	stage := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, state *teamspeak.State) error {
+				order = append(order, name)
+
+				return next(ctx, state)
+			}
+		}
+	}
+
+	stop := func(Handler) Handler {
+		return func(context.Context, *teamspeak.State) error { return nil }
+	}
+
+	out, err := Apply(context.Background(), &teamspeak.State{}, stage("sanitize"), stage("filter"))
+	require.NoError(t, err)
+	require.NotNil(t, out)
+	require.Equal(t, []string{"sanitize", "filter"}, order)
+
+	out, err = Apply(context.Background(), &teamspeak.State{}, stop, stage("render"))
+	require.NoError(t, err)
+	require.Nil(t, out)
+	require.Equal(t, []string{"sanitize", "filter"}, order)
+}
+
+func TestStages(t *testing.T) {
+	in := &teamspeak.State{
+		ServerName: "Game​Night",
+		Channels: []teamspeak.Channel{
+			{Name: "Lobby", Users: []teamspeak.User{{Nickname: "darn⠀"}}},
+			{Name: "[spacer0]---"},
+			{Name: "Staff", IsPermanent: true},
+		},
+		TotalUsers: 1,
+	}
+
+	filter, err := contentfilter.New(contentfilter.Config{Words: []string{"darn"}})
+	require.NoError(t, err)
+
+	out, err := Apply(context.Background(), in,
+		Sanitize(),
+		ContentFilter(filter),
+		HideChannels(teamspeak.ChannelFilter{HideNames: []string{"staff"}}),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, "GameNight", out.ServerName)
+	require.Len(t, out.Channels, 1)
+	require.Equal(t, "✱✱✱✱", out.Channels[0].Users[0].Nickname)
+	require.Equal(t, 1, out.TotalUsers)
+
+	// The input is left as fetched.
+	require.Equal(t, "Game​Night", in.ServerName)
+	require.Len(t, in.Channels, 3)
+	require.Equal(t, "darn⠀", in.Channels[0].Users[0].Nickname)
+}
@@ -0,0 +1,290 @@
+package qr
+
+// matrix is a symbol being drawn. function marks the modules of the fixed
+// patterns, which codewords and masks leave alone.
+type matrix struct {
+	size     int
+	version  int
+	dark     []bool
+	function []bool
+}
+
+func newMatrix(version int) *matrix {
+	size := 17 + 4*version
+
+	return &matrix{
+		size:     size,
+		version:  version,
+		dark:     make([]bool, size*size),
+		function: make([]bool, size*size),
+	}
+}
+
+// set draws a function module.
+func (m *matrix) set(x, y int, dark bool) {
+	m.dark[y*m.size+x] = dark
+	m.function[y*m.size+x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and
+// the version information, and reserves the format information areas.
+func (m *matrix) drawFunctionPatterns() {
+	for i := 0; i < m.size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	centers := alignments[m.version]
+	last := len(centers) - 1
+
+	for i, x := range centers {
+		for j, y := range centers {
+			// Positions overlapping the finders are skipped.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+
+			m.drawAlignment(x, y)
+		}
+	}
+
+	m.drawFormat(0)
+	m.drawVersion()
+}
+
+// drawFinder draws a finder pattern and its separator around center x, y.
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= m.size || yy < 0 || yy >= m.size {
+				continue
+			}
+
+			dist := max(abs(dx), abs(dy))
+			m.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M and the
+// mask, and the dark module next to them.
+func (m *matrix) drawFormat(mask int) {
+	data := mask // Level M is 00
+	rem := data
+
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+
+	m.set(8, m.size-8, true)
+}
+
+// drawVersion draws both copies of the version information, from version 7.
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+
+	rem := m.version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+
+	bits := m.version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := m.size-11+i%3, i/3
+
+		m.set(a, b, dark)
+		m.set(b, a, dark)
+	}
+}
+
+// drawCodewords fills the free modules with the codewords in the zigzag
+// order of the standard: two-module columns from the right, alternately
+// upwards and downwards, skipping the vertical timing pattern.
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+
+				if m.function[y*m.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+
+				m.dark[y*m.size+x] = codewords[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the free modules selected by one of the eight mask
+// patterns. Applying a mask twice undoes it.
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var invert bool
+
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			if invert && !m.function[y*m.size+x] {
+				m.dark[y*m.size+x] = !m.dark[y*m.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the symbol is to scan, following the four rules of
+// the standard: long runs, 2x2 blocks, finder-like patterns and imbalance
+// between dark and light modules.
+func (m *matrix) penalty() int {
+	total, darkCount := 0, 0
+
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			x, y = y, x
+		}
+
+		return m.dark[y*m.size+x]
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			run := 0
+
+			for x := 0; x < m.size; x++ {
+				if x > 0 && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+				} else {
+					run = 1
+				}
+
+				if run == 5 {
+					total += 3
+				} else if run > 5 {
+					total++
+				}
+
+				if x+11 <= m.size && (finderLike(at, x, y, transpose, false) || finderLike(at, x, y, transpose, true)) {
+					total += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			d := m.dark[y*m.size+x]
+			if d {
+				darkCount++
+			}
+
+			if x+1 < m.size && y+1 < m.size && d == m.dark[y*m.size+x+1] &&
+				d == m.dark[(y+1)*m.size+x] && d == m.dark[(y+1)*m.size+x+1] {
+				total += 3
+			}
+		}
+	}
+
+	percent := darkCount * 100 / (m.size * m.size)
+	total += abs(percent-50) / 5 * 10
+
+	return total
+}
+
+// finderPattern is the 1:1:3:1:1 ratio of a finder with four light modules
+// on one side.
+var finderPattern = [11]bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// finderLike reports whether the 11 modules from x along the row (or column
+// when transposed) match finderPattern, or its reverse.
+func finderLike(at func(x, y int, transpose bool) bool, x, y int, transpose, reverse bool) bool {
+	for i := 0; i < 11; i++ {
+		want := finderPattern[i]
+		if reverse {
+			want = finderPattern[10-i]
+		}
+
+		if at(x+i, y, transpose) != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
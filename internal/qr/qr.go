@@ -0,0 +1,239 @@
+// Package qr encodes short texts, such as TeamSpeak join links, as QR codes.
+//
+// Only what join links need is implemented: byte mode, error correction level
+// M (15% of the code may be damaged), and versions 1 to 10, which hold up to
+// 213 bytes.
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// maxVersion is the largest version supported.
+const maxVersion = 10
+
+// quietZone is the light border around the code, in modules, that scanners
+// need to find it.
+const quietZone = 4
+
+// blockLayout is the error correction block structure of a version at level
+// M: the EC codewords per block, and the number and data size of the blocks
+// in each of the two groups.
+type blockLayout struct {
+	ec             int
+	blocks1, data1 int
+	blocks2, data2 int
+}
+
+var layouts = [maxVersion + 1]blockLayout{
+	1:  {10, 1, 16, 0, 0},
+	2:  {16, 1, 28, 0, 0},
+	3:  {26, 1, 44, 0, 0},
+	4:  {18, 2, 32, 0, 0},
+	5:  {24, 2, 43, 0, 0},
+	6:  {16, 4, 27, 0, 0},
+	7:  {18, 4, 31, 0, 0},
+	8:  {22, 2, 38, 2, 39},
+	9:  {22, 3, 36, 2, 37},
+	10: {26, 4, 43, 1, 44},
+}
+
+// alignments are the alignment pattern center coordinates by version.
+var alignments = [maxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+func (l blockLayout) dataCodewords() int {
+	return l.blocks1*l.data1 + l.blocks2*l.data2
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	Size    int // Modules per side
+	modules []bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y*c.Size+x]
+}
+
+// Encode encodes text in the smallest version that holds it.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+
+	for version := 1; version <= maxVersion; version++ {
+		// Mode indicator, then an 8 bit length up to version 9 and 16 bits from
+		// version 10.
+		header := 12
+		if version >= 10 {
+			header = 20
+		}
+
+		if header+8*len(data) <= 8*layouts[version].dataCodewords() {
+			return encode(data, version), nil
+		}
+	}
+
+	return nil, fmt.Errorf("text of %d bytes is too long for a QR code", len(data))
+}
+
+func encode(data []byte, version int) *Code {
+	m := symbol(data, version)
+
+	best, bestPenalty := -1, 0
+
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormat(mask)
+
+		if p := m.penalty(); best < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+
+		m.applyMask(mask) // XOR again to undo
+	}
+
+	m.applyMask(best)
+	m.drawFormat(best)
+
+	return &Code{Size: m.size, modules: m.dark}
+}
+
+// symbol draws the function patterns and the unmasked codewords of data.
+func symbol(data []byte, version int) *matrix {
+	m := newMatrix(version)
+	m.drawFunctionPatterns()
+	m.drawCodewords(interleave(dataCodewords(data, version), layouts[version]))
+
+	return m
+}
+
+// dataCodewords builds the byte mode segment, terminated and padded to the
+// version's data capacity.
+func dataCodewords(data []byte, version int) []byte {
+	var w bitWriter
+
+	w.write(0b0100, 4) // Byte mode
+
+	if version >= 10 {
+		w.write(len(data), 16)
+	} else {
+		w.write(len(data), 8)
+	}
+
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+
+	capacity := 8 * layouts[version].dataCodewords()
+
+	w.write(0, min(4, capacity-w.n))
+	w.write(0, (8-w.n%8)%8)
+
+	for pad := 0xEC; w.n < capacity; pad ^= 0xEC ^ 0x11 {
+		w.write(pad, 8)
+	}
+
+	return w.bytes
+}
+
+// interleave splits data into the version's blocks, appends each block's
+// error correction, and interleaves the codewords as the symbol stores them.
+func interleave(data []byte, layout blockLayout) []byte {
+	divisor := rsDivisor(layout.ec)
+
+	var blocks, ecs [][]byte
+
+	for i := 0; i < layout.blocks1+layout.blocks2; i++ {
+		size := layout.data1
+		if i >= layout.blocks1 {
+			size = layout.data2
+		}
+
+		blocks = append(blocks, data[:size])
+		ecs = append(ecs, rsRemainder(data[:size], divisor))
+		data = data[size:]
+	}
+
+	var out []byte
+
+	for i := 0; i < max(layout.data1, layout.data2); i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+
+	for i := 0; i < layout.ec; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+
+	return out
+}
+
+// PNG renders the code with a quiet zone, scale pixels per module.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("invalid scale %d", scale)
+	}
+
+	side := (c.Size + 2*quietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quietZone)*scale+dx, (y+quietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+type bitWriter struct {
+	bytes []byte
+	n     int // Bits written
+}
+
+// write appends the low count bits of v, most significant first.
+func (w *bitWriter) write(v, count int) {
+	for i := count - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+
+		if v>>i&1 == 1 {
+			w.bytes[w.n/8] |= 0x80 >> (w.n % 8)
+		}
+
+		w.n++
+	}
+}
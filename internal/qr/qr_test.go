@@ -0,0 +1,167 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the worked example of the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+
+	require.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, rsRemainder(data, rsDivisor(10)))
+}
+
+func TestEncodeVersions(t *testing.T) {
+	for _, tc := range []struct {
+		length, size int
+	}{
+		{14, 21},  // Version 1
+		{15, 25},  // Version 2
+		{107, 45}, // Version 7, with version information
+		{213, 57}, // Version 10
+	} {
+		code, err := Encode(strings.Repeat("a", tc.length))
+		require.NoError(t, err)
+		require.Equal(t, tc.size, code.Size, "length %d", tc.length)
+	}
+
+	_, err := Encode(strings.Repeat("a", 214))
+	require.Error(t, err)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	const text = "ts3server://ts.example.com?port=9987"
+
+	code, err := Encode(text)
+	require.NoError(t, err)
+
+	// Read the symbol back the way a scanner does: format, unmask, zigzag.
+	m := newMatrix((code.Size - 17) / 4)
+	m.drawFunctionPatterns()
+
+	// The mask is in the top bits of the first copy, left of the top-left
+	// finder.
+	format := 0
+	for i := 9; i < 15; i++ {
+		if code.Dark(14-i, 8) {
+			format |= 1 << i
+		}
+	}
+
+	mask := (format ^ 0x5412) >> 10 & 0b111
+	copy(m.dark, code.modules)
+	m.applyMask(mask)
+
+	var bits []bool
+
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+
+				if !m.function[y*m.size+x] {
+					bits = append(bits, m.dark[y*m.size+x])
+				}
+			}
+		}
+	}
+
+	read := func(n int) int {
+		v := 0
+		for _, b := range bits[:n] {
+			v <<= 1
+			if b {
+				v |= 1
+			}
+		}
+
+		bits = bits[n:]
+
+		return v
+	}
+
+	// A single block up to version 3, so the data comes first.
+	require.Equal(t, 0b0100, read(4))
+
+	length := read(8)
+	got := make([]byte, length)
+
+	for i := range got {
+		got[i] = byte(read(8))
+	}
+
+	require.Equal(t, text, string(got))
+
+	img, err := code.PNG(4)
+	require.NoError(t, err)
+
+	decoded, err := png.Decode(bytes.NewReader(img))
+	require.NoError(t, err)
+	require.Equal(t, (code.Size+2*quietZone)*4, decoded.Bounds().Dx())
+}
+
+// goldenInputs are the texts of testdata/golden.txt, by index.
+var goldenInputs = []string{
+	"ts3server://ts.example.com?port=9987",
+	"ts3server://voice.example.org?port=9987&password=hunter2&nickname=Guest&channel=Lobby%2FGeneral&x=0123456789",
+	"ts3server://" + strings.Repeat("abcdefghijklmnopqrstuvwxyz0123456789", 5) + "?port=1",
+}
+
+func TestEncodeMatchesReferenceEncoder(t *testing.T) {
+	// The golden symbols come from an independent encoder, one per input and
+	// mask: single and multiple blocks, version information and both block
+	// groups are covered.
+	golden, err := os.ReadFile("testdata/golden.txt")
+	require.NoError(t, err)
+
+	checked := 0
+
+	for _, line := range strings.Split(string(golden), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var input, version, mask int
+		var modules string
+
+		_, err := fmt.Sscan(line, &input, &version, &mask, &modules)
+		require.NoError(t, err)
+
+		data := []byte(goldenInputs[input])
+
+		code, err := Encode(goldenInputs[input])
+		require.NoError(t, err)
+		require.Equal(t, version*4+17, code.Size, "input %d", input)
+
+		m := symbol(data, version)
+		m.applyMask(mask)
+		m.drawFormat(mask)
+
+		bits, err := hex.DecodeString(modules + strings.Repeat("0", len(modules)%2))
+		require.NoError(t, err)
+
+		for i, dark := range m.dark {
+			want := bits[i/8]&(0x80>>(i%8)) != 0
+			require.Equal(t, want, dark, "input %d mask %d module (%d, %d)", input, mask, i%m.size, i/m.size)
+		}
+
+		checked++
+	}
+
+	require.Equal(t, 8*len(goldenInputs), checked)
+}
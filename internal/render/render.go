@@ -0,0 +1,235 @@
+// Package render turns a state into a presentation-neutral document of
+// sections, lines and badges. The Discord embed, the CLI printer and other
+// text outputs format the same document in their own markup, so they agree on
+// what is shown and only differ in how.
+package render
+
+import (
+	"strings"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// IdleAfter is how long a user must be idle before their idle time is shown.
+const IdleAfter = 5 * time.Minute
+
+// Options selects what the document includes.
+type Options struct {
+	ShowEmptyChannels bool
+	ShowCountry       bool           // Country flag before each nickname
+	ShowChannelTopics bool           // Channel topics as section subtitles
+	ShowConnectedTime bool           // Session start on each line
+	ShowTalkPower     bool           // Priority speaker and talk power badges
+	ShowCodec         bool           // Codec after each channel name, e.g. "(Opus Music)"
+	GroupBadges       map[int]string // Server group id -> badge after member nicknames
+}
+
+// Document is what a state renders to.
+type Document struct {
+	Title      string
+	Online     int
+	MaxClients int
+	Uptime     time.Duration
+	Sections   []Section // Shown channels, in server order
+}
+
+// Section is one channel.
+type Section struct {
+	ID     int // TeamSpeak channel id
+	Name   string
+	Topic  string // Empty unless topics are shown
+	IconID uint32 // Channel icon (0 if none), for outputs that can show it
+	Lines  []Line // One per user
+}
+
+// Line is one user with the badges shown next to them.
+type Line struct {
+	Nickname string
+	Flag     string   // Country flag emoji (empty unless shown and known)
+	Badges   []string // Server group badges, in group order
+
+	PrioritySpeaker bool // Shown with talk power badges
+	CanTalk         bool // Has talk power in a moderated channel
+
+	Recording   bool
+	Deafened    bool // Cannot hear; takes precedence over Muted
+	Muted       bool // Microphone muted
+	Away        bool
+	AwayMessage string
+	Idle        time.Duration // Zero until the user has been idle for IdleAfter
+	ConnectedAt time.Time     // Zero unless connected time is shown and known
+}
+
+// Build renders the channels of a single (non-aggregated) state.
+func Build(state *teamspeak.State, opts Options) Document {
+	doc := Document{
+		Title:      state.ServerName,
+		Online:     state.TotalUsers,
+		MaxClients: state.MaxClients,
+		Uptime:     state.Uptime,
+	}
+
+	for _, ch := range state.Channels {
+		if !opts.ShowEmptyChannels && len(ch.Users) == 0 {
+			continue
+		}
+
+		section := Section{ID: ch.ID, Name: ch.Name, IconID: ch.IconID, Lines: make([]Line, 0, len(ch.Users))}
+
+		if opts.ShowCodec && ch.Codec != "" {
+			section.Name += " (" + ch.Codec + ")"
+		}
+
+		if opts.ShowChannelTopics {
+			section.Topic = strings.Join(strings.Fields(ch.Topic), " ")
+		}
+
+		for _, u := range ch.Users {
+			section.Lines = append(section.Lines, buildLine(u, ch, opts))
+		}
+
+		doc.Sections = append(doc.Sections, section)
+	}
+
+	return doc
+}
+
+func buildLine(u teamspeak.User, ch teamspeak.Channel, opts Options) Line {
+	l := Line{
+		Nickname:    u.Nickname,
+		Recording:   u.IsRecording,
+		Deafened:    u.OutputMuted,
+		Muted:       u.InputMuted && !u.OutputMuted,
+		Away:        u.Away,
+		AwayMessage: u.AwayMessage,
+	}
+
+	if opts.ShowCountry {
+		l.Flag = CountryFlag(u.Country)
+	}
+
+	if opts.ShowTalkPower {
+		l.PrioritySpeaker = u.PrioritySpeaker
+		l.CanTalk = ch.IsModerated() && u.CanTalk(ch)
+	}
+
+	for _, id := range u.ServerGroups {
+		if badge := opts.GroupBadges[id]; badge != "" {
+			l.Badges = append(l.Badges, badge)
+		}
+	}
+
+	if u.IdleTime > IdleAfter {
+		l.Idle = u.IdleTime
+	}
+
+	if opts.ShowConnectedTime {
+		l.ConnectedAt = u.ConnectedAt
+	}
+
+	return l
+}
+
+// Name is the nickname with the country flag before it and the talk power
+// and group badges after it.
+func (l Line) Name() string {
+	name := l.Nickname
+
+	if l.Flag != "" {
+		name = l.Flag + " " + name
+	}
+
+	if l.PrioritySpeaker {
+		name += " ⭐"
+	}
+
+	if l.CanTalk {
+		name += " 🎤"
+	}
+
+	for _, b := range l.Badges {
+		name += " " + b
+	}
+
+	return name
+}
+
+// Emojis are the status emojis. Outputs that can show them may use custom
+// emojis such as "<:micoff:123456789012345678>"; an empty emoji is left out.
+type Emojis struct {
+	Recording string
+	Deafened  string
+	Muted     string
+	Away      string
+	Idle      string // Before the idle time; none by default
+}
+
+// DefaultEmojis are the unicode status emojis.
+var DefaultEmojis = Emojis{Recording: "🔴", Deafened: "🔇", Muted: "🎙️", Away: "💤"}
+
+// Icons returns the default status emojis in display order: recording,
+// deafened or muted, away.
+func (l Line) Icons() []string {
+	return l.IconsWith(DefaultEmojis)
+}
+
+// IconsWith returns the status emojis from e in display order.
+func (l Line) IconsWith(e Emojis) []string {
+	var icons []string
+
+	if l.Recording && e.Recording != "" {
+		icons = append(icons, e.Recording)
+	}
+
+	switch {
+	case l.Deafened && e.Deafened != "":
+		icons = append(icons, e.Deafened)
+	case l.Muted && !l.Deafened && e.Muted != "":
+		icons = append(icons, e.Muted)
+	}
+
+	if l.Away && e.Away != "" {
+		icons = append(icons, e.Away)
+	}
+
+	return icons
+}
+
+// Primary returns the single most significant default status emoji for
+// compact layouts, or "" when there is nothing notable.
+func (l Line) Primary() string {
+	return l.PrimaryWith(DefaultEmojis)
+}
+
+// PrimaryWith returns the most significant status emoji from e, or "".
+func (l Line) PrimaryWith(e Emojis) string {
+	switch {
+	case l.Away:
+		return e.Away
+	case l.Deafened:
+		return e.Deafened
+	default:
+		return ""
+	}
+}
+
+// CountryFlag returns the flag emoji for an ISO 3166-1 alpha-2 code, made of
+// the two matching regional indicator symbols, or "" for anything else.
+func CountryFlag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+
+	var flag strings.Builder
+
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+
+	return flag.String()
+}
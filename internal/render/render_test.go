@@ -0,0 +1,99 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+func TestCountryFlag(t *testing.T) {
+	require.Equal(t, "🇩🇪", CountryFlag("DE"))
+	require.Empty(t, CountryFlag(""))
+	require.Empty(t, CountryFlag("de"))
+	require.Empty(t, CountryFlag("DEU"))
+}
+
+func TestBuild(t *testing.T) {
+	state := &teamspeak.State{
+		ServerName: "Game Night",
+		Channels: []teamspeak.Channel{
+			{Name: "Lobby", Topic: "  Say\n hi ", Users: []teamspeak.User{
+				{Nickname: "alice", Country: "SE", ServerGroups: []int{6, 7, 9}, InputMuted: true, OutputMuted: true},
+				{Nickname: "bob", Away: true, IdleTime: time.Minute},
+				{Nickname: "carol", InputMuted: true, IdleTime: time.Hour},
+			}},
+			{Name: "Empty"},
+		},
+		TotalUsers: 3,
+	}
+
+	doc := Build(state, Options{})
+	require.Len(t, doc.Sections, 1)
+	require.Empty(t, doc.Sections[0].Topic)
+	require.Equal(t, "alice", doc.Sections[0].Lines[0].Name())
+
+	doc = Build(state, Options{
+		ShowEmptyChannels: true,
+		ShowCountry:       true,
+		ShowChannelTopics: true,
+		GroupBadges:       map[int]string{6: "🛡️", 9: "VIP"},
+	})
+	require.Len(t, doc.Sections, 2)
+	require.Equal(t, "Say hi", doc.Sections[0].Topic)
+
+	alice, bob, carol := doc.Sections[0].Lines[0], doc.Sections[0].Lines[1], doc.Sections[0].Lines[2]
+	require.Equal(t, "🇸🇪 alice 🛡️ VIP", alice.Name())
+	require.Equal(t, []string{"🔇"}, alice.Icons())
+	require.Equal(t, "💤", bob.Primary())
+	require.Zero(t, bob.Idle)
+	require.Equal(t, []string{"🎙️"}, carol.Icons())
+	require.Empty(t, carol.Primary())
+	require.Equal(t, time.Hour, carol.Idle)
+
+	custom := Emojis{Deafened: "<:deaf:123>", Away: "<:away:456>"}
+	require.Equal(t, []string{"<:deaf:123>"}, alice.IconsWith(custom))
+	require.Equal(t, "<:away:456>", bob.PrimaryWith(custom))
+	require.Empty(t, carol.IconsWith(custom)) // No muted emoji hides the state
+}
+
+func TestTalkPowerBadges(t *testing.T) {
+	state := &teamspeak.State{
+		Channels: []teamspeak.Channel{
+			{Name: "Stage", NeededTalkPower: 50, Users: []teamspeak.User{
+				{Nickname: "host", TalkPower: 75, PrioritySpeaker: true},
+				{Nickname: "guest", IsTalker: true},
+				{Nickname: "audience", TalkPower: 10},
+			}},
+			{Name: "Lobby", Users: []teamspeak.User{{Nickname: "dave", TalkPower: 75}}},
+		},
+	}
+
+	doc := Build(state, Options{})
+	require.Equal(t, "host", doc.Sections[0].Lines[0].Name())
+
+	doc = Build(state, Options{ShowTalkPower: true})
+	stage := doc.Sections[0].Lines
+	require.Equal(t, "host ⭐ 🎤", stage[0].Name())
+	require.Equal(t, "guest 🎤", stage[1].Name())
+	require.Equal(t, "audience", stage[2].Name())
+	require.Equal(t, "dave", doc.Sections[1].Lines[0].Name())
+}
+
+func TestCodec(t *testing.T) {
+	state := &teamspeak.State{
+		Channels: []teamspeak.Channel{
+			{Name: "Music", Codec: "Opus Music", CodecQuality: 10},
+			{Name: "Lobby"},
+		},
+	}
+
+	doc := Build(state, Options{ShowEmptyChannels: true, ShowCodec: true})
+	require.Equal(t, "Music (Opus Music)", doc.Sections[0].Name)
+	require.Equal(t, "Lobby", doc.Sections[1].Name)
+
+	doc = Build(state, Options{ShowEmptyChannels: true})
+	require.Equal(t, "Music", doc.Sections[0].Name)
+}
@@ -0,0 +1,201 @@
+// Package scheduler runs the periodic tasks of a service, such as the update
+// loop, keepalives, daily digests and retention pruning, each on its own
+// goroutine so a slow, failing or panicking task does not hold up the others.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/errreport"
+)
+
+// Task is a job run at a fixed interval.
+type Task struct {
+	Name     string
+	Interval time.Duration
+	// Jitter adds a random delay of up to this much to every wait, so the
+	// same task of several instances does not run in lockstep.
+	Jitter time.Duration
+	// Immediate runs the task as soon as the scheduler starts rather than
+	// after the first interval.
+	Immediate bool
+	// Run does the work. An error is logged and the task runs again at the
+	// next interval.
+	Run func(ctx context.Context) error
+}
+
+// Service defines the scheduler interface.
+type Service interface {
+	// Add registers a task. Tasks added while the scheduler runs start
+	// with the next Start.
+	Add(task Task)
+	Start(ctx context.Context) error
+	// Stop waits for running tasks to finish and stops scheduling them. The
+	// scheduler can be started again.
+	Stop() error
+	// Trigger runs the named task now and restarts its interval. Triggers
+	// arriving while one is pending are coalesced.
+	Trigger(name string)
+}
+
+// task is a registered task.
+type task struct {
+	Task
+	trigger chan struct{}
+}
+
+type service struct {
+	log logrus.FieldLogger
+
+	mu    sync.Mutex
+	tasks []*task
+
+	lifecycle sync.Mutex // Serializes Start and Stop
+	running   bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New creates a scheduler. Its log entries carry the task name, on top of
+// the fields of log.
+func New(log logrus.FieldLogger) Service {
+	return &service{log: log}
+}
+
+// Add registers a task.
+func (s *service) Add(t Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks = append(s.tasks, &task{Task: t, trigger: make(chan struct{}, 1)})
+}
+
+// Start starts scheduling the registered tasks.
+func (s *service) Start(ctx context.Context) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	s.mu.Lock()
+	tasks := append([]*task(nil), s.tasks...)
+	s.mu.Unlock()
+
+	for _, t := range tasks {
+		if t.Interval <= 0 {
+			return fmt.Errorf("task %s has no interval", t.Name)
+		}
+	}
+
+	s.running = true
+	s.done = make(chan struct{})
+
+	for _, t := range tasks {
+		s.wg.Add(1)
+
+		go s.loop(ctx, s.done, t)
+	}
+
+	return nil
+}
+
+// Stop stops scheduling and waits for running tasks. Stopping a stopped
+// scheduler does nothing.
+func (s *service) Stop() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+	close(s.done)
+	s.wg.Wait()
+
+	return nil
+}
+
+// Trigger queues an immediate run of the named task.
+func (s *service) Trigger(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tasks {
+		if t.Name != name {
+			continue
+		}
+
+		select {
+		case t.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// loop runs t until done is closed. Runs start at a fixed rate, measured from
+// the start of the previous run, and never overlap.
+func (s *service) loop(ctx context.Context, done <-chan struct{}, t *task) {
+	defer s.wg.Done()
+
+	if t.Immediate {
+		s.run(ctx, t)
+	}
+
+	timer := time.NewTimer(t.wait())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-t.trigger:
+		}
+
+		start := time.Now()
+		s.run(ctx, t)
+		timer.Reset(t.wait() - time.Since(start))
+	}
+}
+
+// run runs t once. A panic is reported to Sentry and logged with its stack
+// rather than taking down the process, and the task is scheduled again as
+// usual.
+func (s *service) run(ctx context.Context, t *task) {
+	log := s.log.WithField("task", t.Name)
+
+	defer func() {
+		if r := recover(); r != nil {
+			errreport.Report(r)
+			log.WithFields(logrus.Fields{
+				"panic": r,
+				"stack": string(debug.Stack()),
+			}).Error("Scheduled task panicked")
+		}
+	}()
+
+	if err := t.Run(ctx); err != nil {
+		log.WithError(err).Warn("Scheduled task failed")
+	}
+}
+
+// wait returns the time until the next run.
+func (t *task) wait() time.Duration {
+	if t.Jitter <= 0 {
+		return t.Interval
+	}
+
+	return t.Interval + rand.N(t.Jitter)
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicDoesNotStopOtherTasks(t *testing.T) {
+	s := New(logrus.New())
+
+	var panics, runs atomic.Int32
+
+	s.Add(Task{Name: "panics", Interval: time.Millisecond, Run: func(context.Context) error {
+		panics.Add(1)
+		panic("boom")
+	}})
+	s.Add(Task{Name: "fails", Interval: time.Millisecond, Run: func(context.Context) error {
+		runs.Add(1)
+
+		return errors.New("failed")
+	}})
+
+	require.NoError(t, s.Start(context.Background()))
+
+	require.Eventually(t, func() bool { return panics.Load() >= 3 && runs.Load() >= 3 }, time.Second, time.Millisecond)
+	require.NoError(t, s.Stop())
+}
+
+func TestTrigger(t *testing.T) {
+	s := New(logrus.New())
+	runs := make(chan struct{}, 10)
+
+	s.Add(Task{Name: "update", Interval: time.Hour, Run: func(context.Context) error {
+		runs <- struct{}{}
+
+		return nil
+	}})
+
+	// Triggers before Start are kept, and coalesced.
+	s.Trigger("update")
+	s.Trigger("update")
+	s.Trigger("unknown")
+
+	require.NoError(t, s.Start(context.Background()))
+
+	<-runs
+
+	s.Trigger("update")
+	<-runs
+
+	require.NoError(t, s.Stop())
+	require.Empty(t, runs)
+}
+
+func TestStartRejectsMissingInterval(t *testing.T) {
+	s := New(logrus.New())
+	s.Add(Task{Name: "broken", Run: func(context.Context) error { return nil }})
+
+	require.Error(t, s.Start(context.Background()))
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveAnnouncement stores the single current announcement, replacing any
+// previous one.
+func (s *service) SaveAnnouncement(ctx context.Context, text string, expires time.Time) error {
+	if text == "" {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM announcement`); err != nil {
+			return fmt.Errorf("failed to clear announcement: %w", err)
+		}
+
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO announcement (id, text, expires) VALUES (1, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET text = excluded.text, expires = excluded.expires`,
+		text, expires.Unix(),
+	); err != nil {
+		return fmt.Errorf("failed to save announcement: %w", err)
+	}
+
+	return nil
+}
+
+// Announcement loads the stored announcement. Expired announcements are
+// returned as-is; callers decide whether to show them.
+func (s *service) Announcement(ctx context.Context) (string, time.Time, error) {
+	var (
+		text    string
+		expires int64
+	)
+
+	err := s.db.QueryRowContext(ctx, `SELECT text, expires FROM announcement WHERE id = 1`).Scan(&text, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, nil
+	}
+
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to load announcement: %w", err)
+	}
+
+	return text, time.Unix(expires, 0), nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Change kinds.
+const (
+	ChangeJoin  = "join"
+	ChangeLeave = "leave"
+	ChangeMove  = "move"
+)
+
+// Change is a user joining, leaving or switching channels.
+type Change struct {
+	Time     time.Time
+	Kind     string
+	Nickname string
+	From     string // Channel left; empty for joins
+	To       string // Channel entered; empty for leaves
+}
+
+// CatchUp is what a viewer missed since their cursor.
+type CatchUp struct {
+	Since   time.Time
+	Changes []Change // The most recent changes, oldest first
+	Total   int      // All changes since Since, including ones beyond the limit
+}
+
+// SaveChanges appends changes to the log in one transaction.
+func (s *service) SaveChanges(ctx context.Context, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO changes (ts, kind, nickname, from_channel, to_channel) VALUES (?, ?, ?, ?, ?)`,
+			c.Time.Unix(), c.Kind, c.Nickname, c.From, c.To,
+		); err != nil {
+			return fmt.Errorf("failed to save change: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	return nil
+}
+
+// CatchUp reads the viewer's cursor, loads up to limit of the latest changes
+// after it and advances the cursor to now.
+func (s *service) CatchUp(ctx context.Context, viewer string, fallback, now time.Time, limit int) (CatchUp, error) {
+	since := fallback.Unix()
+
+	err := s.db.QueryRowContext(ctx, `SELECT ts FROM change_cursors WHERE viewer = ?`, viewer).Scan(&since)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return CatchUp{}, fmt.Errorf("failed to load change cursor: %w", err)
+	}
+
+	out := CatchUp{Since: time.Unix(since, 0)}
+
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM changes WHERE ts > ? AND ts <= ?`, since, now.Unix(),
+	).Scan(&out.Total); err != nil {
+		return CatchUp{}, fmt.Errorf("failed to count changes: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT ts, kind, nickname, from_channel, to_channel FROM changes
+		 WHERE ts > ? AND ts <= ? ORDER BY ts DESC, rowid DESC LIMIT ?`,
+		since, now.Unix(), limit,
+	)
+	if err != nil {
+		return CatchUp{}, fmt.Errorf("failed to load changes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			c  Change
+			ts int64
+		)
+
+		if err := rows.Scan(&ts, &c.Kind, &c.Nickname, &c.From, &c.To); err != nil {
+			return CatchUp{}, fmt.Errorf("failed to read change: %w", err)
+		}
+
+		c.Time = time.Unix(ts, 0)
+		out.Changes = append(out.Changes, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return CatchUp{}, fmt.Errorf("failed to load changes: %w", err)
+	}
+
+	// Newest first was only needed to keep the latest under the limit.
+	for i, j := 0, len(out.Changes)-1; i < j; i, j = i+1, j-1 {
+		out.Changes[i], out.Changes[j] = out.Changes[j], out.Changes[i]
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO change_cursors (viewer, ts) VALUES (?, ?)
+		 ON CONFLICT(viewer) DO UPDATE SET ts = excluded.ts`,
+		viewer, now.Unix(),
+	); err != nil {
+		return CatchUp{}, fmt.Errorf("failed to save change cursor: %w", err)
+	}
+
+	return out, nil
+}
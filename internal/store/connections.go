@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Links whose connection state is recorded.
+const (
+	LinkBridge    = "bridge"    // The bridge itself; down between a stop and the next start
+	LinkTeamSpeak = "teamspeak" // Fetching the TeamSpeak state
+	LinkDiscord   = "discord"   // Updating the Discord status
+)
+
+// Outage is a window in which a link was down.
+type Outage struct {
+	Link  string
+	Start time.Time
+	End   time.Time // Zero while the outage is ongoing
+	// Crash marks a bridge outage without a clean stop; its start is the last
+	// activity recorded before the restart.
+	Crash bool
+}
+
+// Duration returns how long the outage lasted, up to now while ongoing.
+func (o Outage) Duration(now time.Time) time.Duration {
+	if o.End.IsZero() {
+		return now.Sub(o.Start)
+	}
+
+	return o.End.Sub(o.Start)
+}
+
+// SaveConnection records a link going up or down at the given time.
+func (s *service) SaveConnection(ctx context.Context, link string, up bool, at time.Time) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO connections (ts, link, up) VALUES (?, ?, ?)`, at.Unix(), link, up,
+	); err != nil {
+		return fmt.Errorf("failed to save connection event: %w", err)
+	}
+
+	return nil
+}
+
+// Outages derives the outages overlapping [since, now] from the recorded
+// connection events.
+func (s *service) Outages(ctx context.Context, since, now time.Time) ([]Outage, error) {
+	return LoadOutages(ctx, s.db, since, now)
+}
+
+// LoadOutages derives the outages overlapping [since, now] from the
+// connection events in db. It takes the database rather than the service so
+// the recap can read a database the running bridge has open.
+func LoadOutages(ctx context.Context, db *sql.DB, since, now time.Time) ([]Outage, error) {
+	// The latest event of each link before since tells whether it was already
+	// down when the window opened.
+	rows, err := db.QueryContext(ctx,
+		`SELECT ts, link, up FROM connections
+		 WHERE (ts >= ? AND ts <= ?)
+		    OR rowid IN (SELECT MAX(rowid) FROM connections WHERE ts < ? GROUP BY link)
+		 ORDER BY ts, rowid`,
+		since.Unix(), now.Unix(), since.Unix(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connection events: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		outages []Outage
+		open    = make(map[string]int) // Index of each link's ongoing outage
+		crashes []int                  // Bridge starts without a preceding stop
+		lastTS  int64                  // Latest event, as an upper bound for crash starts
+		started bool                   // A bridge start was seen
+	)
+
+	for rows.Next() {
+		var (
+			ts   int64
+			link string
+			up   bool
+		)
+
+		if err := rows.Scan(&ts, &link, &up); err != nil {
+			return nil, fmt.Errorf("failed to read connection event: %w", err)
+		}
+
+		at := time.Unix(ts, 0)
+
+		switch i, down := open[link]; {
+		case !up && !down:
+			open[link] = len(outages)
+			outages = append(outages, Outage{Link: link, Start: at})
+		case up && down:
+			outages[i].End = at
+			delete(open, link)
+		case up && link == LinkBridge && started:
+			// Started again without stopping: the previous run crashed.
+			crashes = append(crashes, len(outages))
+			outages = append(outages, Outage{Link: LinkBridge, Start: time.Unix(lastTS, 0), End: at, Crash: true})
+		}
+
+		if link == LinkBridge && up {
+			started = true
+		}
+
+		lastTS = ts
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load connection events: %w", err)
+	}
+
+	// A crashed run kept recording snapshots until it died, so the last one
+	// before the restart is a closer start than the last connection event.
+	for _, i := range crashes {
+		var last sql.NullInt64
+
+		if err := db.QueryRowContext(ctx,
+			`SELECT MAX(ts) FROM samples WHERE ts >= ? AND ts < ?`,
+			outages[i].Start.Unix(), outages[i].End.Unix(),
+		).Scan(&last); err != nil {
+			return nil, fmt.Errorf("failed to find last snapshot: %w", err)
+		}
+
+		if last.Valid {
+			outages[i].Start = time.Unix(last.Int64, 0)
+		}
+	}
+
+	kept := outages[:0]
+
+	for _, o := range outages {
+		if o.End.IsZero() || !o.End.Before(since) {
+			kept = append(kept, o)
+		}
+	}
+
+	// Crash windows are appended at the restart but open earlier.
+	slices.SortStableFunc(kept, func(a, b Outage) int { return a.Start.Compare(b.Start) })
+
+	return kept, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SaveSilence stores when the silence of event expires, replacing any
+// previous one; a zero expiry lifts it.
+func (s *service) SaveSilence(ctx context.Context, event string, expires time.Time) error {
+	if expires.IsZero() {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM silences WHERE event = ?`, event); err != nil {
+			return fmt.Errorf("failed to lift silence: %w", err)
+		}
+
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO silences (event, expires) VALUES (?, ?)
+		 ON CONFLICT(event) DO UPDATE SET expires = excluded.expires`,
+		event, expires.Unix(),
+	); err != nil {
+		return fmt.Errorf("failed to save silence: %w", err)
+	}
+
+	return nil
+}
+
+// Silences loads the stored silences by event, dropping expired ones.
+func (s *service) Silences(ctx context.Context) (map[string]time.Time, error) {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM silences WHERE expires <= ?`, time.Now().Unix()); err != nil {
+		return nil, fmt.Errorf("failed to prune silences: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT event, expires FROM silences`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load silences: %w", err)
+	}
+	defer rows.Close()
+
+	silences := make(map[string]time.Time)
+
+	for rows.Next() {
+		var (
+			event   string
+			expires int64
+		)
+
+		if err := rows.Scan(&event, &expires); err != nil {
+			return nil, fmt.Errorf("failed to read silence: %w", err)
+		}
+
+		silences[event] = time.Unix(expires, 0)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load silences: %w", err)
+	}
+
+	return silences, nil
+}
@@ -0,0 +1,8 @@
+//go:build !nosqlite
+
+package store
+
+import _ "modernc.org/sqlite" // pure-Go driver, so builds need no cgo
+
+// Available reports whether this build includes SQLite support.
+const Available = true
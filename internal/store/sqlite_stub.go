@@ -0,0 +1,7 @@
+//go:build nosqlite
+
+package store
+
+// Available reports whether this build includes SQLite support. Building with
+// -tags nosqlite leaves the driver out, for platforms it does not support.
+const Available = false
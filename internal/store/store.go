@@ -13,8 +13,8 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
-	_ "modernc.org/sqlite"
 
+	"github.com/samcm/ts-discord-status/internal/scheduler"
 	"github.com/samcm/ts-discord-status/internal/teamspeak"
 )
 
@@ -26,6 +26,10 @@ const (
 
 	// retentionInterval is how often expired rows are pruned.
 	retentionInterval = 24 * time.Hour
+
+	// retentionJitter spreads pruning so it does not always run at the hour
+	// the bridge was restarted.
+	retentionJitter = time.Hour
 )
 
 // Presence status flags, packed into the presence.flags bitfield.
@@ -61,6 +65,39 @@ CREATE TABLE IF NOT EXISTS presence (
 	channel_id INTEGER NOT NULL DEFAULT 0,
 	flags      INTEGER NOT NULL DEFAULT 0,
 	PRIMARY KEY (ts, user_id)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS announcement (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	text    TEXT NOT NULL,
+	expires INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS changes (
+	ts           INTEGER NOT NULL,
+	kind         TEXT NOT NULL,
+	nickname     TEXT NOT NULL,
+	from_channel TEXT NOT NULL DEFAULT '',
+	to_channel   TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS changes_ts ON changes (ts);
+CREATE TABLE IF NOT EXISTS change_cursors (
+	viewer TEXT PRIMARY KEY,
+	ts     INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS silences (
+	event   TEXT PRIMARY KEY,
+	expires INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS connections (
+	ts   INTEGER NOT NULL,
+	link TEXT NOT NULL,
+	up   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS connections_ts ON connections (ts);
+CREATE TABLE IF NOT EXISTS subscriptions (
+	user_id  TEXT NOT NULL,
+	nickname TEXT NOT NULL DEFAULT '',
+	users    INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (user_id, nickname, users)
 ) WITHOUT ROWID;`
 
 // pragmas are applied once on open. auto_vacuum must run before any table is
@@ -84,6 +121,40 @@ type Service interface {
 	Start(ctx context.Context) error
 	Stop() error
 	Record(ctx context.Context, state *teamspeak.State) error
+	// SaveAnnouncement persists the current announcement; an empty text
+	// clears it.
+	SaveAnnouncement(ctx context.Context, text string, expires time.Time) error
+	// Announcement returns the persisted announcement, or an empty text when
+	// there is none.
+	Announcement(ctx context.Context) (string, time.Time, error)
+	// SaveSilence persists when the silence of a notification event expires;
+	// a zero time lifts it.
+	SaveSilence(ctx context.Context, event string, expires time.Time) error
+	// Silences returns the persisted silences that have not expired, by
+	// event.
+	Silences(ctx context.Context) (map[string]time.Time, error)
+	// HourlyUsage averages the recorded user counts since the given time by
+	// hour of day in loc.
+	HourlyUsage(ctx context.Context, since time.Time, loc *time.Location) (HourlyUsage, error)
+	// SaveChanges appends joins, leaves and moves to the change log.
+	SaveChanges(ctx context.Context, changes []Change) error
+	// CatchUp returns the changes since the viewer's previous call (or since
+	// fallback on their first) and moves their cursor to now.
+	CatchUp(ctx context.Context, viewer string, fallback, now time.Time, limit int) (CatchUp, error)
+	// SaveConnection records a link (LinkBridge, LinkTeamSpeak or
+	// LinkDiscord) going up or down.
+	SaveConnection(ctx context.Context, link string, up bool, at time.Time) error
+	// Outages returns the outages of every link overlapping [since, now],
+	// oldest first.
+	Outages(ctx context.Context, since, now time.Time) ([]Outage, error)
+	// Subscribe stores a DM subscription, or returns ErrSubscriptionLimit
+	// when the user already holds limit of them.
+	Subscribe(ctx context.Context, sub Subscription, limit int) error
+	// Unsubscribe deletes a subscription, or all of the user's when neither
+	// Nickname nor Users is set, returning how many were deleted.
+	Unsubscribe(ctx context.Context, sub Subscription) (int, error)
+	// Subscriptions returns every stored subscription.
+	Subscriptions(ctx context.Context) ([]Subscription, error)
 }
 
 type service struct {
@@ -94,23 +165,43 @@ type service struct {
 	mu         sync.Mutex
 	userIDs    map[string]int64
 	channelIDs map[string]int64
-	done       chan struct{}
-	wg         sync.WaitGroup
+
+	scheduler scheduler.Service // Runs retention pruning
+	lifecycle sync.Mutex        // Serializes Start and Stop
+	running   bool
 }
 
 // NewService creates a new status recorder.
 func NewService(log logrus.FieldLogger, cfg Config) Service {
-	return &service{
+	s := &service{
 		log:        log.WithField("component", "store"),
 		cfg:        cfg,
 		userIDs:    make(map[string]int64, 32),
 		channelIDs: make(map[string]int64, 16),
-		done:       make(chan struct{}),
 	}
+
+	s.scheduler = scheduler.New(s.log)
+	s.scheduler.Add(scheduler.Task{
+		Name:      "retention",
+		Interval:  retentionInterval,
+		Jitter:    retentionJitter,
+		Immediate: true,
+		Run:       s.prune,
+	})
+
+	return s
 }
 
 // Start opens the database, applies the schema, and begins retention pruning.
+// Starting an open store does nothing.
 func (s *service) Start(ctx context.Context) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	if s.running {
+		return nil
+	}
+
 	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0o755); err != nil {
 		return fmt.Errorf("failed to create database directory: %w", err)
 	}
@@ -140,9 +231,13 @@ func (s *service) Start(ctx context.Context) error {
 
 	s.db = db
 
-	s.wg.Add(1)
+	// Pruning runs until Stop rather than for the lifetime of ctx.
+	if err := s.scheduler.Start(context.Background()); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to start retention pruning: %w", err)
+	}
 
-	go s.retentionLoop()
+	s.running = true
 
 	s.log.WithFields(logrus.Fields{
 		"path":           s.cfg.Path,
@@ -152,15 +247,22 @@ func (s *service) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop checkpoints the WAL and closes the database.
+// Stop checkpoints the WAL and closes the database. Stopping a closed store
+// does nothing; it can be started again afterwards.
 func (s *service) Stop() error {
-	close(s.done)
-	s.wg.Wait()
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
 
-	if s.db == nil {
+	if !s.running {
 		return nil
 	}
 
+	s.running = false
+
+	if err := s.scheduler.Stop(); err != nil {
+		s.log.WithError(err).Warn("Failed to stop scheduler")
+	}
+
 	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
 		s.log.WithError(err).Warn("Failed to checkpoint database on shutdown")
 	}
@@ -328,29 +430,11 @@ func userFlags(u teamspeak.User) int {
 	return f
 }
 
-// retentionLoop prunes rows older than the retention window once a day.
-func (s *service) retentionLoop() {
-	defer s.wg.Done()
-
-	ticker := time.NewTicker(retentionInterval)
-	defer ticker.Stop()
-
-	s.prune()
-
-	for {
-		select {
-		case <-s.done:
-			return
-		case <-ticker.C:
-			s.prune()
-		}
-	}
-}
-
-// prune deletes expired rows and reclaims the freed pages.
-func (s *service) prune() {
+// prune deletes rows older than the retention window and reclaims the freed
+// pages. It is the daily retention task.
+func (s *service) prune(ctx context.Context) error {
 	if s.cfg.RetentionDays <= 0 {
-		return
+		return nil
 	}
 
 	cutoff := time.Now().AddDate(0, 0, -s.cfg.RetentionDays).Unix()
@@ -358,15 +442,18 @@ func (s *service) prune() {
 	for _, stmt := range []string{
 		"DELETE FROM presence WHERE ts < ?",
 		"DELETE FROM samples WHERE ts < ?",
+		"DELETE FROM changes WHERE ts < ?",
+		"DELETE FROM change_cursors WHERE ts < ?",
+		"DELETE FROM connections WHERE ts < ?",
 	} {
-		if _, err := s.db.Exec(stmt, cutoff); err != nil {
-			s.log.WithError(err).Warn("Failed to prune expired rows")
-
-			return
+		if _, err := s.db.ExecContext(ctx, stmt, cutoff); err != nil {
+			return fmt.Errorf("failed to prune expired rows: %w", err)
 		}
 	}
 
-	if _, err := s.db.Exec("PRAGMA incremental_vacuum"); err != nil {
-		s.log.WithError(err).Warn("Failed to reclaim database space")
+	if _, err := s.db.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+		return fmt.Errorf("failed to reclaim database space: %w", err)
 	}
+
+	return nil
 }
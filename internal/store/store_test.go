@@ -1,3 +1,5 @@
+//go:build !nosqlite
+
 package store
 
 import (
@@ -124,8 +126,193 @@ func TestPruneDropsExpiredRows(t *testing.T) {
 	require.NoError(t, svc.recordAt(ctx, old, state("ancient")))
 	require.NoError(t, svc.recordAt(ctx, recent, state("alice")))
 
-	svc.prune()
+	require.NoError(t, svc.prune(ctx))
 
 	require.Equal(t, 1, count(t, svc, "SELECT COUNT(*) FROM samples"))
 	require.Equal(t, 1, count(t, svc, "SELECT COUNT(*) FROM presence"))
 }
+
+func TestAnnouncementRoundTrip(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+
+	text, _, err := svc.Announcement(ctx)
+	require.NoError(t, err)
+	require.Empty(t, text)
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, svc.SaveAnnouncement(ctx, "Event at 20:00!", expires))
+	require.NoError(t, svc.SaveAnnouncement(ctx, "Event at 21:00!", expires))
+
+	text, got, err := svc.Announcement(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Event at 21:00!", text)
+	require.True(t, expires.Equal(got))
+
+	require.NoError(t, svc.SaveAnnouncement(ctx, "", time.Time{}))
+
+	text, _, err = svc.Announcement(ctx)
+	require.NoError(t, err)
+	require.Empty(t, text)
+}
+
+func TestSilences(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, svc.SaveSilence(ctx, "offline", expires))
+	require.NoError(t, svc.SaveSilence(ctx, "join", expires))
+	require.NoError(t, svc.SaveSilence(ctx, "join", time.Time{}))
+	require.NoError(t, svc.SaveSilence(ctx, "leave", time.Now().Add(-time.Minute)))
+
+	got, err := svc.Silences(ctx)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.True(t, expires.Equal(got["offline"]))
+}
+
+func TestHourlyUsage(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	// Two days of 20:00 samples, one of 21:00.
+	for _, at := range []time.Time{day.Add(20 * time.Hour), day.Add(20*time.Hour + time.Minute), day.Add(44 * time.Hour), day.Add(21 * time.Hour)} {
+		users := []string{"alice", "bob"}
+		if at.Day() == 5 {
+			users = append(users, "carol", "dave")
+		}
+
+		require.NoError(t, svc.recordAt(ctx, at.Unix(), state(users...)))
+	}
+
+	usage, err := svc.HourlyUsage(ctx, day, time.FixedZone("CET", 3600))
+	require.NoError(t, err)
+	require.Equal(t, 2, usage.Hours[21])
+	require.InDelta(t, 3, usage.Average[21], 0.001)
+	require.Equal(t, 1, usage.Hours[22])
+	require.Zero(t, usage.Hours[20])
+}
+
+func TestCatchUp(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+	base := time.Unix(1_700_000_000, 0)
+
+	require.NoError(t, svc.SaveChanges(ctx, []Change{
+		{Time: base.Add(time.Minute), Kind: ChangeJoin, Nickname: "alice", To: "Lobby"},
+		{Time: base.Add(2 * time.Minute), Kind: ChangeMove, Nickname: "alice", From: "Lobby", To: "Games"},
+		{Time: base.Add(3 * time.Minute), Kind: ChangeLeave, Nickname: "bob", From: "Lobby"},
+	}))
+
+	got, err := svc.CatchUp(ctx, "viewer", base, base.Add(5*time.Minute), 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Total)
+	require.Len(t, got.Changes, 2)
+	require.Equal(t, ChangeMove, got.Changes[0].Kind)
+	require.Equal(t, "bob", got.Changes[1].Nickname)
+
+	// The cursor moved, so a second click only sees later changes.
+	require.NoError(t, svc.SaveChanges(ctx, []Change{
+		{Time: base.Add(6 * time.Minute), Kind: ChangeJoin, Nickname: "carol", To: "Lobby"},
+	}))
+
+	got, err = svc.CatchUp(ctx, "viewer", base, base.Add(7*time.Minute), 10)
+	require.NoError(t, err)
+	require.Equal(t, base.Add(5*time.Minute), got.Since)
+	require.Len(t, got.Changes, 1)
+	require.Equal(t, "carol", got.Changes[0].Nickname)
+}
+
+func TestRestart(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Start(ctx))
+	require.NoError(t, svc.SaveSilence(ctx, "join", time.Now().Add(time.Hour)))
+
+	require.NoError(t, svc.Stop())
+	require.NoError(t, svc.Stop())
+
+	// A restarted store reopens the same database.
+	require.NoError(t, svc.Start(ctx))
+
+	silences, err := svc.Silences(ctx)
+	require.NoError(t, err)
+	require.Contains(t, silences, "join")
+}
+
+func TestOutages(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+	base := time.Unix(1_700_000_040, 0) // On a minute, where snapshots are aligned
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+
+	for _, e := range []struct {
+		link string
+		up   bool
+		min  int
+	}{
+		{LinkBridge, true, 0},
+		{LinkTeamSpeak, true, 0},
+		{LinkTeamSpeak, false, 10}, // TeamSpeak outage 10-15
+		{LinkTeamSpeak, true, 15},
+		{LinkBridge, false, 20}, // Clean restart 20-22
+		{LinkBridge, true, 22},
+		{LinkBridge, true, 60}, // Crashed after the snapshot at 40
+		{LinkDiscord, false, 70},
+	} {
+		require.NoError(t, svc.SaveConnection(ctx, e.link, e.up, at(e.min)))
+	}
+
+	require.NoError(t, svc.recordAt(ctx, at(40).Unix(), state("alice")))
+
+	got, err := svc.Outages(ctx, at(12), at(80))
+	require.NoError(t, err)
+	require.Equal(t, []Outage{
+		{Link: LinkTeamSpeak, Start: at(10), End: at(15)},
+		{Link: LinkBridge, Start: at(20), End: at(22)},
+		{Link: LinkBridge, Start: at(40), End: at(60), Crash: true},
+		{Link: LinkDiscord, Start: at(70)},
+	}, got)
+
+	// Outages that ended before the window are left out.
+	got, err = svc.Outages(ctx, at(30), at(80))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+}
+
+func TestSubscriptions(t *testing.T) {
+	svc := newTestService(t, 0)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Subscribe(ctx, Subscription{UserID: "1", Nickname: "alice"}, 2))
+	require.NoError(t, svc.Subscribe(ctx, Subscription{UserID: "1", Nickname: "alice"}, 2))
+	require.NoError(t, svc.Subscribe(ctx, Subscription{UserID: "1", Users: 5}, 2))
+	require.NoError(t, svc.Subscribe(ctx, Subscription{UserID: "2", Nickname: "bob"}, 2))
+
+	// Past the limit nothing is stored, but repeating one still succeeds.
+	require.ErrorIs(t, svc.Subscribe(ctx, Subscription{UserID: "1", Nickname: "carol"}, 2), ErrSubscriptionLimit)
+	require.NoError(t, svc.Subscribe(ctx, Subscription{UserID: "1", Users: 5}, 2))
+
+	subs, err := svc.Subscriptions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Subscription{
+		{UserID: "1", Nickname: "alice"},
+		{UserID: "1", Users: 5},
+		{UserID: "2", Nickname: "bob"},
+	}, subs)
+
+	n, err := svc.Unsubscribe(ctx, Subscription{UserID: "1", Users: 5})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	n, err = svc.Unsubscribe(ctx, Subscription{UserID: "2"})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	subs, err = svc.Subscriptions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Subscription{{UserID: "1", Nickname: "alice"}}, subs)
+}
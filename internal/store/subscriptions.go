@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSubscriptionLimit is returned by Subscribe when the user already holds
+// the most subscriptions allowed.
+var ErrSubscriptionLimit = errors.New("subscription limit reached")
+
+// Subscription asks for a DM when a nickname comes online, or when the total
+// user count reaches Users.
+type Subscription struct {
+	UserID   string // Discord user to DM
+	Nickname string // TeamSpeak nickname, lowercased; empty for a user count
+	Users    int    // User count threshold; 0 for a nickname
+}
+
+// Subscribe stores a subscription unless the user already holds limit of
+// them, in which case it returns ErrSubscriptionLimit. The count and the
+// insert are one statement, so concurrent calls cannot exceed the limit.
+// Storing a subscription again does nothing.
+func (s *service) Subscribe(ctx context.Context, sub Subscription, limit int) error {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO subscriptions (user_id, nickname, users)
+		 SELECT ?, ?, ? WHERE (SELECT COUNT(*) FROM subscriptions WHERE user_id = ?) < ?
+		 ON CONFLICT DO NOTHING`,
+		sub.UserID, sub.Nickname, sub.Users, sub.UserID, limit,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM subscriptions WHERE user_id = ? AND nickname = ? AND users = ?)`,
+		sub.UserID, sub.Nickname, sub.Users,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	if !exists {
+		return ErrSubscriptionLimit
+	}
+
+	return nil
+}
+
+// Unsubscribe deletes a subscription, or every subscription of the user when
+// both Nickname and Users are unset, and returns how many were deleted.
+func (s *service) Unsubscribe(ctx context.Context, sub Subscription) (int, error) {
+	query, args := `DELETE FROM subscriptions WHERE user_id = ?`, []any{sub.UserID}
+	if sub.Nickname != "" || sub.Users != 0 {
+		query += ` AND nickname = ? AND users = ?`
+		args = append(args, sub.Nickname, sub.Users)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// Subscriptions loads every stored subscription.
+func (s *service) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT user_id, nickname, users FROM subscriptions ORDER BY user_id, users, nickname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+
+	for rows.Next() {
+		var sub Subscription
+
+		if err := rows.Scan(&sub.UserID, &sub.Nickname, &sub.Users); err != nil {
+			return nil, fmt.Errorf("failed to read subscription: %w", err)
+		}
+
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
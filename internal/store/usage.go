@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HourlyUsage is the average user count by local hour of day.
+type HourlyUsage struct {
+	Average [24]float64
+	Hours   [24]int // Recorded hours averaged into each slot, e.g. 28 over four weeks
+}
+
+// HourlyUsage averages the samples since the given time by hour of day in
+// loc. Samples are grouped by UTC hour first, so in zones with a fractional
+// offset each slot is off by that fraction.
+func (s *service) HourlyUsage(ctx context.Context, since time.Time, loc *time.Location) (HourlyUsage, error) {
+	var usage HourlyUsage
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT ts / 3600, AVG(total_users) FROM samples WHERE ts >= ? GROUP BY ts / 3600`,
+		since.Unix(),
+	)
+	if err != nil {
+		return usage, fmt.Errorf("failed to query hourly usage: %w", err)
+	}
+	defer rows.Close()
+
+	var sums [24]float64
+
+	for rows.Next() {
+		var (
+			hour int64
+			avg  float64
+		)
+
+		if err := rows.Scan(&hour, &avg); err != nil {
+			return usage, fmt.Errorf("failed to read hourly usage: %w", err)
+		}
+
+		h := time.Unix(hour*3600, 0).In(loc).Hour()
+		sums[h] += avg
+		usage.Hours[h]++
+	}
+
+	if err := rows.Err(); err != nil {
+		return usage, fmt.Errorf("failed to query hourly usage: %w", err)
+	}
+
+	for h, n := range usage.Hours {
+		if n > 0 {
+			usage.Average[h] = sums[h] / float64(n)
+		}
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,97 @@
+package teamspeak
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultVoicePort is the port TeamSpeak clients use when none is given.
+const defaultVoicePort = 9987
+
+// connectAddress derives the address clients join with from the virtual
+// server's bind addresses and the query host: the first public bind IP, else
+// the query host, with the voice port unless it is the default. It returns ""
+// when neither is reachable from outside, e.g. a loopback query host on a
+// server bound to all interfaces.
+func connectAddress(bindIPs, queryHost string, port int) string {
+	host := ""
+
+	for _, ip := range strings.Split(bindIPs, ",") {
+		if ip = strings.TrimSpace(ip); publicHost(ip) {
+			host = ip
+			break
+		}
+	}
+
+	if host == "" && publicHost(queryHost) {
+		host = queryHost
+	}
+
+	if host == "" {
+		return ""
+	}
+
+	if port == 0 || port == defaultVoicePort {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+
+		return host
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// publicHost reports whether host looks reachable by clients on the internet:
+// a public IP, or a dotted hostname other than localhost.
+func publicHost(host string) bool {
+	if host == "" {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return !ip.IsUnspecified() && !ip.IsLoopback() && !ip.IsPrivate() &&
+			!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast()
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	// Single-label names (e.g. a Docker service called "teamspeak") only
+	// resolve on the bot's network.
+	return strings.Contains(host, ".") && host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+// JoinURI returns the ts3server:// link that opens the TeamSpeak client on a
+// connect address such as "ts.example.com" or "203.0.113.7:9988", with the
+// server password if there is one. It returns "" without an address.
+func JoinURI(address, password string) string {
+	if address == "" {
+		return ""
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = strings.Trim(address, "[]"), ""
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	query := url.Values{}
+	if port != "" {
+		query.Set("port", port)
+	}
+
+	if password != "" {
+		query.Set("password", password)
+	}
+
+	if len(query) == 0 {
+		return "ts3server://" + host
+	}
+
+	return "ts3server://" + host + "?" + query.Encode()
+}
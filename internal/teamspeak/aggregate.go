@@ -0,0 +1,167 @@
+package teamspeak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/filetransfer"
+)
+
+// aggregate combines several voice servers into one state with a section per
+// server.
+type aggregate struct {
+	log     logrus.FieldLogger
+	title   string
+	members []Source
+	last    []*State // Last good state per member, reused while it is unreachable
+}
+
+// NewAggregate creates a Service that presents several servers as one. The
+// combined state carries each server in Servers and the union of their
+// channels and totals at the top level. A server that stops responding keeps
+// its last good section; only when all servers fail does GetState fail.
+func NewAggregate(log logrus.FieldLogger, title string, members ...Source) Service {
+	return &aggregate{
+		log:     log.WithField("component", "teamspeak-aggregate"),
+		title:   title,
+		members: members,
+		last:    make([]*State, len(members)),
+	}
+}
+
+// Start connects to every server. Servers that cannot be reached yet are
+// retried by their own reconnect logic on the next fetch.
+func (a *aggregate) Start(ctx context.Context) error {
+	var errs []error
+
+	for i, m := range a.members {
+		if err := m.Start(ctx); err != nil {
+			a.log.WithError(err).WithField("server", i).Warn("Failed to connect to TeamSpeak server")
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(a.members) {
+		return fmt.Errorf("failed to connect to any TeamSpeak server: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Stop disconnects from every server.
+func (a *aggregate) Stop() error {
+	var errs []error
+
+	for _, m := range a.members {
+		if err := m.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetState fetches every server and combines the results.
+func (a *aggregate) GetState(ctx context.Context) (*State, error) {
+	var (
+		errs    []error
+		fetched int
+	)
+
+	for i, m := range a.members {
+		state, err := m.GetState(ctx)
+		if err != nil {
+			a.log.WithError(err).WithField("server", i).Warn("Failed to get TeamSpeak state")
+			errs = append(errs, err)
+
+			continue
+		}
+
+		for c := range state.Channels {
+			for u := range state.Channels[c].Users {
+				state.Channels[c].Users[u].Server = i
+			}
+		}
+
+		a.last[i] = state
+		fetched++
+	}
+
+	if fetched == 0 {
+		return nil, fmt.Errorf("all TeamSpeak servers failed: %w", errors.Join(errs...))
+	}
+
+	out := &State{
+		ServerName: a.title,
+		FetchedAt:  time.Now(),
+	}
+
+	for _, state := range a.last {
+		if state == nil {
+			continue
+		}
+
+		out.Servers = append(out.Servers, state)
+		out.Channels = append(out.Channels, state.Channels...)
+		out.TotalUsers += state.TotalUsers
+		out.MaxClients += state.MaxClients
+	}
+
+	return out, nil
+}
+
+// Avatar downloads the avatar from the server the user is connected to.
+func (a *aggregate) Avatar(ctx context.Context, user User) ([]byte, error) {
+	if user.Server < 0 || user.Server >= len(a.members) {
+		return nil, fmt.Errorf("unknown server %d", user.Server)
+	}
+
+	files, ok := a.members[user.Server].(Files)
+	if !ok {
+		return nil, filetransfer.ErrNotFound
+	}
+
+	return files.Avatar(ctx, user)
+}
+
+// Icon downloads an icon from the first server that has it. Icon ids are
+// content checksums, so the same id is the same image on every server.
+func (a *aggregate) Icon(ctx context.Context, id uint32) ([]byte, error) {
+	err := filetransfer.ErrNotFound
+
+	for _, m := range a.members {
+		files, ok := m.(Files)
+		if !ok {
+			continue
+		}
+
+		data, iconErr := files.Icon(ctx, id)
+		if iconErr == nil {
+			return data, nil
+		}
+
+		if !errors.Is(iconErr, filetransfer.ErrNotFound) {
+			err = iconErr
+		}
+	}
+
+	return nil, err
+}
+
+// Poke pokes the user on the server they are connected to.
+func (a *aggregate) Poke(ctx context.Context, user User, msg string) error {
+	if user.Server < 0 || user.Server >= len(a.members) {
+		return fmt.Errorf("unknown server %d", user.Server)
+	}
+
+	poker, ok := a.members[user.Server].(Poker)
+	if !ok {
+		return fmt.Errorf("server %d does not support pokes", user.Server)
+	}
+
+	return poker.Poke(ctx, user, msg)
+}
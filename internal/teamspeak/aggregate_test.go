@@ -0,0 +1,66 @@
+package teamspeak
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	state *State
+	err   error
+}
+
+func (f *fakeServer) Start(context.Context) error { return nil }
+func (f *fakeServer) Stop() error                 { return nil }
+
+func (f *fakeServer) GetState(context.Context) (*State, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.state.Clone(), nil
+}
+
+func (f *fakeServer) Avatar(context.Context, User) ([]byte, error) { return nil, nil }
+func (f *fakeServer) Icon(context.Context, uint32) ([]byte, error) { return nil, nil }
+
+func TestAggregateGetState(t *testing.T) {
+	ctx := context.Background()
+
+	a := &fakeServer{state: &State{
+		ServerName: "A", TotalUsers: 1, MaxClients: 10,
+		Channels: []Channel{{Name: "Lobby", Users: []User{{Nickname: "alice"}}}},
+	}}
+	b := &fakeServer{state: &State{
+		ServerName: "B", TotalUsers: 2, MaxClients: 20,
+		Channels: []Channel{{Name: "Game", Users: []User{{Nickname: "bob"}, {Nickname: "carol"}}}},
+	}}
+
+	svc := NewAggregate(logrus.New(), "Community", a, b)
+
+	state, err := svc.GetState(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Community", state.ServerName)
+	require.Equal(t, 3, state.TotalUsers)
+	require.Equal(t, 30, state.MaxClients)
+	require.Len(t, state.Servers, 2)
+	require.Len(t, state.Channels, 2)
+	require.Equal(t, 1, state.Servers[1].Channels[0].Users[0].Server)
+
+	// A failing server keeps its last good section.
+	b.err = errors.New("timeout")
+
+	state, err = svc.GetState(ctx)
+	require.NoError(t, err)
+	require.Len(t, state.Servers, 2)
+	require.Equal(t, "B", state.Servers[1].ServerName)
+
+	a.err = errors.New("timeout")
+
+	_, err = svc.GetState(ctx)
+	require.Error(t, err)
+}
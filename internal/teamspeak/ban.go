@@ -0,0 +1,75 @@
+package teamspeak
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ts3 "github.com/multiplay/go-ts3"
+)
+
+// ServerQuery error ids for an address ban: a regular ban, and the automatic
+// ban for exceeding the query flood limit.
+const (
+	errIDBanned    = 3329
+	errIDFloodBan  = 3331
+	defaultBanWait = 10 * time.Minute // Assumed ban length when the server does not say
+)
+
+// banRetryPattern matches the ban duration in the server's extra_msg, raw or
+// with ServerQuery escapes (quoted once more) as it appears in a rejected
+// connection header.
+var banRetryPattern = regexp.MustCompile(`retry(?:\\+s| )in(?:\\+s| )(\d+)(?:\\+s| )seconds`)
+
+// BannedError reports that the server has banned the bot's address from
+// ServerQuery, typically for flooding.
+type BannedError struct {
+	Until time.Time // When the ban is expected to expire
+}
+
+func (e *BannedError) Error() string {
+	return fmt.Sprintf("banned from ServerQuery until %s", e.Until.Format(time.RFC3339))
+}
+
+// banError returns a *BannedError when err is a ban response, either to a
+// command or in place of the connection header, and nil otherwise.
+func banError(err error, now time.Time) *BannedError {
+	if err == nil {
+		return nil
+	}
+
+	var banned *BannedError
+	if errors.As(err, &banned) {
+		return banned
+	}
+
+	text := err.Error()
+
+	var qe *ts3.Error
+	switch {
+	case errors.As(err, &qe):
+		if qe.ID != errIDBanned && qe.ID != errIDFloodBan {
+			return nil
+		}
+
+		if extra, ok := qe.Details["extra_msg"].(string); ok {
+			text = extra
+		}
+	case !strings.Contains(text, fmt.Sprintf("error id=%d", errIDBanned)) &&
+		!strings.Contains(text, fmt.Sprintf("error id=%d", errIDFloodBan)):
+		return nil
+	}
+
+	wait := defaultBanWait
+
+	if m := banRetryPattern.FindStringSubmatch(text); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+	}
+
+	return &BannedError{Until: now.Add(wait)}
+}
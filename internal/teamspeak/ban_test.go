@@ -0,0 +1,28 @@
+package teamspeak
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ts3 "github.com/multiplay/go-ts3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanError(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	reply := &ts3.Error{ID: 3331, Msg: "flood ban", Details: map[string]any{"extra_msg": "you may retry in 300 seconds"}}
+	require.Equal(t, &BannedError{Until: now.Add(300 * time.Second)}, banError(fmt.Errorf("failed to get server info: %w", reply), now))
+
+	// A banned address is rejected in place of the connection header.
+	header := errors.New(`client: invalid connection header "error id=3329 msg=connection\\sfailed,\\syou\\sare\\sbanned extra_msg=you\\smay\\sretry\\sin\\s120\\sseconds"`)
+	require.Equal(t, &BannedError{Until: now.Add(2 * time.Minute)}, banError(header, now))
+
+	require.Equal(t, &BannedError{Until: now.Add(defaultBanWait)}, banError(&ts3.Error{ID: 3329, Msg: "banned"}, now))
+
+	require.Nil(t, banError(&ts3.Error{ID: 524, Msg: "client is flooding"}, now))
+	require.Nil(t, banError(errors.New("connection refused"), now))
+	require.Nil(t, banError(nil, now))
+}
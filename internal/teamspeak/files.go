@@ -0,0 +1,53 @@
+package teamspeak
+
+import (
+	"context"
+	"fmt"
+
+	ts3 "github.com/multiplay/go-ts3"
+)
+
+// clientAvatarInfo holds the clientinfo properties needed to fetch an avatar.
+type clientAvatarInfo struct {
+	AvatarHash string `ms:"client_flag_avatar"`
+	HashUID    string `ms:"client_base64HashClientUID"`
+}
+
+// Avatar downloads the avatar image of an online client. It returns
+// filetransfer.ErrNotFound for clients without an avatar.
+func (s *service) Avatar(ctx context.Context, user User) ([]byte, error) {
+	var info clientAvatarInfo
+
+	if _, err := s.query(ts3.NewCmd("clientinfo").WithArgs(ts3.NewArg("clid", user.ID)).WithResponse(&info)); err != nil {
+		return nil, fmt.Errorf("failed to get client info: %w", err)
+	}
+
+	return s.files.Avatar(ctx, s.query, "avatar_"+info.HashUID, info.AvatarHash)
+}
+
+// Icon downloads a server or channel icon by id. It returns
+// filetransfer.ErrNotFound for built-in icons.
+func (s *service) Icon(ctx context.Context, id uint32) ([]byte, error) {
+	return s.files.Icon(ctx, s.query, id)
+}
+
+// query runs a single command under the connection lock.
+func (s *service) query(cmd *ts3.Cmd) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	return s.client.ExecCmd(cmd)
+}
+
+// Poke sends an online client a popup message.
+func (s *service) Poke(ctx context.Context, user User, msg string) error {
+	if _, err := s.query(ts3.NewCmd("clientpoke").WithArgs(ts3.NewArg("clid", user.ID), ts3.NewArg("msg", msg))); err != nil {
+		return fmt.Errorf("failed to poke client: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package teamspeak
+
+import "strings"
+
+// ChannelFilter hides channels from display by flag or by name.
+type ChannelFilter struct {
+	HideDefault   bool     // Hide the server's default channel
+	HideTemporary bool     // Hide temporary channels
+	HidePermanent bool     // Hide permanent and semi-permanent channels
+	HideNames     []string // Case-insensitive substrings of channel names to hide
+}
+
+// Hidden reports whether the channel should be left out of the display.
+func (f ChannelFilter) Hidden(ch Channel) bool {
+	if f.HideDefault && ch.IsDefault {
+		return true
+	}
+
+	if f.HideTemporary && ch.IsTemporary() {
+		return true
+	}
+
+	if f.HidePermanent && !ch.IsTemporary() {
+		return true
+	}
+
+	name := strings.ToLower(ch.Name)
+
+	for _, n := range f.HideNames {
+		if n != "" && strings.Contains(name, strings.ToLower(n)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply returns a copy of state without spacer channels and those the filter
+// hides, in aggregated servers too. User totals are left as reported.
+func (f ChannelFilter) Apply(state *State) *State {
+	out := *state
+	out.Channels = make([]Channel, 0, len(state.Channels))
+
+	for _, ch := range state.Channels {
+		if strings.Contains(strings.ToLower(ch.Name), "spacer") || f.Hidden(ch) {
+			continue
+		}
+
+		out.Channels = append(out.Channels, ch)
+	}
+
+	if state.Servers != nil {
+		out.Servers = make([]*State, len(state.Servers))
+		for i, sv := range state.Servers {
+			out.Servers[i] = f.Apply(sv)
+		}
+	}
+
+	return &out
+}
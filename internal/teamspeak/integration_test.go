@@ -0,0 +1,280 @@
+//go:build integration
+
+// Integration tests against a real TeamSpeak server, covering the ServerQuery
+// parsing that unit tests cannot. Run them with
+//
+//	go test -tags integration ./internal/teamspeak/
+//
+// By default they start the official teamspeak Docker image (override with
+// TS3_TEST_IMAGE) and read the serveradmin password from its first-start log.
+// To use a running server instead, set TS3_TEST_HOST and TS3_TEST_PASSWORD
+// (and TS3_TEST_QUERY_PORT if not 10011); the tests create their channels on
+// virtual server 1 and delete them again.
+//
+// Voice clients cannot be connected through ServerQuery, so user fields are
+// only checked as far as a query connection allows: the test client itself
+// must not be listed.
+package teamspeak
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-ts3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+const defaultTestImage = "teamspeak:latest"
+
+// testServer is a ServerQuery endpoint and its serveradmin password.
+type testServer struct {
+	host      string
+	queryPort int
+	password  string
+}
+
+func TestIntegrationGetState(t *testing.T) {
+	srv := startTestServer(t)
+	admin := srv.client(t)
+
+	info, err := admin.Server.Info()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = admin.ExecCmd(ts3.NewCmd("serveredit").WithArgs(ts3.NewArg("virtualserver_name", info.Name)))
+	})
+
+	_, err = admin.ExecCmd(ts3.NewCmd("serveredit").WithArgs(ts3.NewArg("virtualserver_name", `Game Night | Test \ 1`)))
+	require.NoError(t, err)
+
+	// Names and topics exercise the ServerQuery escapes: spaces, pipes,
+	// slashes, backslashes and non-ASCII text.
+	parent := createChannel(t, admin,
+		ts3.NewArg("channel_name", "Games | Lobby"),
+		ts3.NewArg("channel_topic", `Rules: be nice / no spam \o/`),
+		ts3.NewArg("channel_flag_permanent", true),
+	)
+	child := createChannel(t, admin,
+		ts3.NewArg("channel_name", "Müsik 🎵"),
+		ts3.NewArg("cpid", parent),
+		ts3.NewArg("channel_flag_semi_permanent", true),
+		ts3.NewArg("channel_codec", 5),
+		ts3.NewArg("channel_codec_quality", 10),
+	)
+	stage := createChannel(t, admin,
+		ts3.NewArg("channel_name", "Stage"),
+		ts3.NewArg("cpid", parent),
+		ts3.NewArg("channel_order", child),
+		ts3.NewArg("channel_flag_permanent", true),
+		ts3.NewArg("channel_needed_talk_power", 50),
+	)
+
+	svc := NewService(logrus.New(), Config{
+		Host:      srv.host,
+		QueryPort: srv.queryPort,
+		Username:  "serveradmin",
+		Password:  srv.password,
+		ServerID:  1,
+	})
+	require.NoError(t, svc.Start(context.Background()))
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, `Game Night | Test \ 1`, state.ServerName)
+	require.Positive(t, state.MaxClients)
+	require.Positive(t, state.Uptime)
+	require.WithinDuration(t, time.Now(), state.FetchedAt, time.Minute)
+
+	// Only query clients are connected, and those are never listed.
+	require.Zero(t, state.TotalUsers)
+
+	channels := make(map[int]Channel, len(state.Channels))
+	for _, ch := range state.Channels {
+		require.Empty(t, ch.Users)
+		channels[ch.ID] = ch
+	}
+
+	lobby := channels[parent]
+	require.Equal(t, "Games | Lobby", lobby.Name)
+	require.Equal(t, `Rules: be nice / no spam \o/`, lobby.Topic)
+	require.True(t, lobby.IsPermanent)
+	require.Zero(t, lobby.ParentID)
+
+	music := channels[child]
+	require.Equal(t, "Müsik 🎵", music.Name)
+	require.Equal(t, parent, music.ParentID)
+	require.True(t, music.IsSemiPermanent)
+	require.False(t, music.IsPermanent)
+	require.Equal(t, "Opus Music", music.Codec)
+	require.Equal(t, 10, music.CodecQuality)
+
+	moderated := channels[stage]
+	require.Equal(t, parent, moderated.ParentID)
+	require.Equal(t, 50, moderated.NeededTalkPower)
+	require.Greater(t, moderated.Order, 0)
+
+	var defaults int
+	for _, ch := range state.Channels {
+		if ch.IsDefault {
+			defaults++
+		}
+	}
+	require.Equal(t, 1, defaults)
+}
+
+// startTestServer returns the server named by TS3_TEST_HOST, or starts a
+// container and removes it when the test ends.
+func startTestServer(t *testing.T) testServer {
+	t.Helper()
+
+	if host := os.Getenv("TS3_TEST_HOST"); host != "" {
+		port := 10011
+		if v := os.Getenv("TS3_TEST_QUERY_PORT"); v != "" {
+			var err error
+			port, err = strconv.Atoi(v)
+			require.NoError(t, err)
+		}
+
+		return testServer{host: host, queryPort: port, password: os.Getenv("TS3_TEST_PASSWORD")}
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not found; set TS3_TEST_HOST to use a running server")
+	}
+
+	image := os.Getenv("TS3_TEST_IMAGE")
+	if image == "" {
+		image = defaultTestImage
+	}
+
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "TS3SERVER_LICENSE=accept",
+		"-p", "127.0.0.1::10011",
+		image).Output()
+	require.NoError(t, err, "failed to start %s", image)
+
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() { _ = exec.Command("docker", "rm", "-f", id).Run() })
+
+	out, err = exec.Command("docker", "port", id, "10011/tcp").Output()
+	require.NoError(t, err)
+
+	_, portText, err := net.SplitHostPort(strings.TrimSpace(strings.Split(string(out), "\n")[0]))
+	require.NoError(t, err)
+
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+
+	srv := testServer{host: "127.0.0.1", queryPort: port, password: waitForPassword(t, id)}
+	waitForQuery(t, srv)
+
+	return srv
+}
+
+// passwordPattern matches the serveradmin credentials a fresh server logs.
+var passwordPattern = regexp.MustCompile(`loginname= "serveradmin", password= "([^"]+)"`)
+
+func waitForPassword(t *testing.T, id string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Minute)
+
+	for time.Now().Before(deadline) {
+		logs, err := exec.Command("docker", "logs", id).CombinedOutput()
+		require.NoError(t, err)
+
+		if m := passwordPattern.FindSubmatch(logs); m != nil {
+			return string(m[1])
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	t.Fatal("serveradmin password not found in the container log")
+
+	return ""
+}
+
+// waitForQuery waits until the query port accepts a login; the server logs
+// the password before the query interface is ready.
+func waitForQuery(t *testing.T, srv testServer) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Minute)
+
+	for {
+		client, err := srv.dial()
+		if err == nil {
+			client.Close()
+
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("query interface not ready: %v", err)
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+func (srv testServer) dial() (*ts3.Client, error) {
+	client, err := ts3.NewClient(fmt.Sprintf("%s:%d", srv.host, srv.queryPort))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Login("serveradmin", srv.password); err != nil {
+		client.Close()
+
+		return nil, err
+	}
+
+	if err := client.Use(1); err != nil {
+		client.Close()
+
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// client returns an admin query connection closed when the test ends.
+func (srv testServer) client(t *testing.T) *ts3.Client {
+	t.Helper()
+
+	client, err := srv.dial()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// createChannel creates a channel and deletes it when the test ends.
+func createChannel(t *testing.T, client *ts3.Client, args ...ts3.CmdArg) int {
+	t.Helper()
+
+	var created struct {
+		ID int `ms:"cid"`
+	}
+
+	_, err := client.ExecCmd(ts3.NewCmd("channelcreate").WithArgs(args...).WithResponse(&created))
+	require.NoError(t, err)
+	require.Positive(t, created.ID)
+
+	t.Cleanup(func() {
+		_, _ = client.ExecCmd(ts3.NewCmd("channeldelete").WithArgs(ts3.NewArg("cid", created.ID), ts3.NewArg("force", 1)))
+	})
+
+	return created.ID
+}
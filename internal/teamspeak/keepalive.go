@@ -0,0 +1,71 @@
+package teamspeak
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ts3 "github.com/multiplay/go-ts3"
+
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/scheduler"
+)
+
+// keepaliveTask pings the server whenever the connection has been idle for
+// the keepalive interval. go-ts3 already writes a blank line every 200s to
+// hold off the server's idle timeout, but it expects no reply: a connection
+// lost behind a NAT or firewall accepts the write and only shows up as the
+// next update's query failing. The ping is a command with a reply, so a dead
+// connection is noticed and replaced between updates.
+func (s *service) keepaliveTask() scheduler.Task {
+	interval := s.cfg.KeepaliveInterval
+
+	return scheduler.Task{
+		Name: "keepalive",
+		// Checking at a fraction of the interval bounds how far past the
+		// interval a ping can be.
+		Interval: interval / 4,
+		// Several virtual servers on one host would otherwise ping together.
+		Jitter: interval / 20,
+		Run: func(context.Context) error {
+			s.ping(interval)
+
+			return nil
+		},
+	}
+}
+
+// ping sends a no-op command if the connection has been idle for at least
+// interval, reconnecting straight away if it turns out to be dead.
+func (s *service) ping(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil || s.closing.Load() || time.Since(s.used) < interval {
+		return
+	}
+
+	_, err := s.client.ExecCmd(ts3.NewCmd("whoami"))
+
+	if ban := banError(err, time.Now()); ban != nil {
+		s.noteBan(ban)
+		s.startReconnect()
+
+		return
+	}
+
+	// An error reply still proves the connection is alive.
+	var reply *ts3.Error
+	if err == nil || errors.As(err, &reply) {
+		s.used = time.Now()
+
+		return
+	}
+
+	s.cfg.LogSampler.Warn(s.log.WithError(err), "Keepalive failed, attempting reconnect")
+
+	if reconnErr := s.reconnect(); reconnErr != nil {
+		metrics.Error(metrics.ErrorTSConnect)
+		s.startReconnect()
+	}
+}
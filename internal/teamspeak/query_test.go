@@ -0,0 +1,196 @@
+package teamspeak_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+	"github.com/samcm/ts-discord-status/internal/teamspeak/teamspeaktest"
+)
+
+func startFake(t *testing.T) (*teamspeaktest.QueryServer, teamspeak.Service) {
+	t.Helper()
+
+	transcript, err := os.ReadFile("testdata/getstate.txt")
+	require.NoError(t, err)
+
+	srv := teamspeaktest.NewQueryServer(t)
+	require.NoError(t, srv.Replay(string(transcript)))
+
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	svc := teamspeak.NewService(log, teamspeak.Config{
+		Host:      srv.Host,
+		QueryPort: srv.Port,
+		Username:  "serveradmin",
+		Password:  "secret",
+		ServerID:  1,
+	})
+	require.NoError(t, svc.Start(context.Background()))
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	return srv, svc
+}
+
+func TestGetStateFromTranscript(t *testing.T) {
+	srv, svc := startFake(t)
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"login client_login_name=serveradmin client_login_password=secret", "use sid=1"}, srv.Received()[:2])
+
+	require.Equal(t, "Game Night | EU", state.ServerName)
+	require.Equal(t, 32, state.MaxClients)
+	require.Equal(t, 2*time.Hour, state.Uptime)
+	require.Equal(t, "203.0.113.7", state.Address)
+	require.Equal(t, 2, state.TotalUsers)
+	require.Len(t, state.Channels, 3)
+	require.Equal(t, &teamspeak.NetworkStats{BandwidthIn: 1203442, BandwidthOut: 340210, PacketLoss: 0.0125}, state.Network)
+
+	lobby, music := state.Channels[0], state.Channels[1]
+	require.Equal(t, "Say hi / be nice", lobby.Topic)
+	require.True(t, lobby.IsDefault)
+	require.True(t, lobby.IsPermanent)
+	require.Equal(t, `Music \ Chill`, music.Name)
+	require.Equal(t, 1, music.ParentID)
+	require.True(t, music.IsSemiPermanent)
+	require.Equal(t, 50, music.NeededTalkPower)
+	require.Equal(t, "Opus Music", music.Codec)
+	require.Equal(t, 10, music.CodecQuality)
+
+	// The query client sitting in the lobby is not listed.
+	require.Len(t, lobby.Users, 1)
+
+	alice := lobby.Users[0]
+	require.Equal(t, "alice | admin", alice.Nickname)
+	require.Equal(t, "aliceUID=", alice.UniqueID)
+	require.Equal(t, "SE", alice.Country)
+	require.Equal(t, []int{6, 9}, alice.ServerGroups)
+	require.True(t, alice.InputMuted)
+	require.True(t, alice.PrioritySpeaker)
+	require.Equal(t, 75, alice.TalkPower)
+	require.Equal(t, 10*time.Minute, alice.IdleTime)
+	require.Equal(t, time.Unix(1700003600, 0), alice.ConnectedAt)
+
+	bob := music.Users[0]
+	require.True(t, bob.Away)
+	require.Equal(t, "brb dinner", bob.AwayMessage)
+	require.True(t, bob.OutputMuted)
+	require.True(t, bob.IsRecording)
+	require.True(t, bob.IsTalker)
+	require.True(t, bob.CanTalk(music))
+	require.True(t, bob.ConnectedAt.IsZero())
+	require.Empty(t, bob.Country)
+}
+
+func TestGetStateReconnects(t *testing.T) {
+	srv, svc := startFake(t)
+
+	_, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, srv.Connections())
+
+	// A dropped connection is re-established and the query retried within
+	// the same call.
+	srv.Drop()
+
+	state, err := svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, state.TotalUsers)
+	require.Equal(t, 2, srv.Connections())
+}
+
+func TestGetStateFlooding(t *testing.T) {
+	srv, svc := startFake(t)
+
+	srv.Fail("clientlist", teamspeaktest.ErrFlooding)
+
+	_, err := svc.GetState(context.Background())
+	require.ErrorContains(t, err, "flooding")
+
+	// The failed query was retried once on a fresh connection.
+	require.Equal(t, 2, srv.Connections())
+}
+
+func TestKeepalive(t *testing.T) {
+	transcript, err := os.ReadFile("testdata/getstate.txt")
+	require.NoError(t, err)
+
+	srv := teamspeaktest.NewQueryServer(t)
+	require.NoError(t, srv.Replay(string(transcript)))
+	srv.Handle("whoami", "virtualserver_status=online virtualserver_id=1")
+
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	svc := teamspeak.NewService(log, teamspeak.Config{
+		Host:              srv.Host,
+		QueryPort:         srv.Port,
+		Username:          "serveradmin",
+		Password:          "secret",
+		ServerID:          1,
+		KeepaliveInterval: 20 * time.Millisecond,
+	})
+	require.NoError(t, svc.Start(context.Background()))
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	require.Eventually(t, func() bool {
+		return slices.Contains(srv.Received(), "whoami")
+	}, time.Second, 5*time.Millisecond)
+
+	// A dead connection is noticed by the next ping and replaced without
+	// waiting for an update.
+	srv.Drop()
+
+	require.Eventually(t, func() bool { return srv.Connections() == 2 }, time.Second, 5*time.Millisecond)
+
+	_, err = svc.GetState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.Connections())
+}
+
+func TestGetStateBanned(t *testing.T) {
+	srv, svc := startFake(t)
+
+	srv.Fail("serverinfo", teamspeaktest.ErrBanned)
+
+	_, err := svc.GetState(context.Background())
+
+	var ban *teamspeak.BannedError
+	require.ErrorAs(t, err, &ban)
+	require.WithinDuration(t, time.Now().Add(600*time.Second), ban.Until, 5*time.Second)
+
+	// During the ban nothing is sent: no reconnect and no query.
+	received := len(srv.Received())
+
+	_, err = svc.GetState(context.Background())
+	require.ErrorAs(t, err, &ban)
+	require.Equal(t, 1, srv.Connections())
+	require.Len(t, srv.Received(), received)
+}
+
+func TestRestart(t *testing.T) {
+	srv, svc := startFake(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Start(ctx))
+	require.Equal(t, 1, srv.Connections())
+
+	require.NoError(t, svc.Stop())
+	require.NoError(t, svc.Stop())
+
+	// A stopped service reconnects on the next start.
+	require.NoError(t, svc.Start(ctx))
+
+	_, err := svc.GetState(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.Connections())
+}
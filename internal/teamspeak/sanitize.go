@@ -0,0 +1,81 @@
+package teamspeak
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// invisibleRunes are printable-category runes that render as blank space and
+// are commonly abused in nicknames to fake indentation or dodge searches.
+var invisibleRunes = map[rune]struct{}{
+	'\u115F': {}, // Hangul choseong filler
+	'\u1160': {}, // Hangul jungseong filler
+	'\u3164': {}, // Hangul filler
+	'\uFFA0': {}, // Halfwidth Hangul filler
+	'\u2800': {}, // Braille pattern blank
+}
+
+// sanitizeName strips control, zero-width and other invisible characters from
+// a display string and returns the cleaned value along with the removed runes.
+// The zero-width joiner is kept because emoji sequences depend on it. If
+// nothing visible would remain, the original string is returned unchanged.
+func sanitizeName(s string) (string, []rune) {
+	var (
+		b       strings.Builder
+		removed []rune
+	)
+
+	for _, r := range s {
+		if isInvisible(r) {
+			removed = append(removed, r)
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	if len(removed) == 0 {
+		return s, nil
+	}
+
+	cleaned := strings.TrimSpace(b.String())
+	if cleaned == "" {
+		return s, nil
+	}
+
+	return cleaned, removed
+}
+
+// Sanitize strips invisible characters from a display string, as sanitizeName
+// does, for strings from sources that do not clean their own names.
+func Sanitize(s string) string {
+	cleaned, _ := sanitizeName(s)
+
+	return cleaned
+}
+
+// isInvisible reports whether r should be stripped from display strings.
+func isInvisible(r rune) bool {
+	if r == '\u200D' { // zero-width joiner
+		return false
+	}
+
+	if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+		return true
+	}
+
+	_, ok := invisibleRunes[r]
+
+	return ok
+}
+
+// formatRunes renders runes as U+XXXX code points for logging.
+func formatRunes(runes []rune) string {
+	parts := make([]string, 0, len(runes))
+	for _, r := range runes {
+		parts = append(parts, fmt.Sprintf("U+%04X", r))
+	}
+
+	return strings.Join(parts, " ")
+}
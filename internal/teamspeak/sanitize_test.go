@@ -0,0 +1,32 @@
+package teamspeak
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		removed int
+	}{
+		{name: "plain", in: "Alice", want: "Alice"},
+		{name: "zero width space", in: "Al\u200Bice", want: "Alice", removed: 1},
+		{name: "control chars", in: "Bob\t\x07", want: "Bob", removed: 2},
+		{name: "hangul filler indent", in: "\u3164\u3164Carol", want: "Carol", removed: 2},
+		{name: "bidi override", in: "\u202EDave", want: "Dave", removed: 1},
+		{name: "emoji joiner kept", in: "👨\u200D👩", want: "👨\u200D👩"},
+		{name: "all invisible kept as is", in: "\u200B\u200B", want: "\u200B\u200B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := sanitizeName(tt.in)
+			require.Equal(t, tt.want, got)
+			require.Len(t, removed, tt.removed)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package teamspeak
+
+import "context"
+
+// Source defines the voice server source interface: state in this package's
+// model. TeamSpeak is the primary implementation; adapters for other voice
+// and game servers (e.g. Mumble) implement it too so they can share the
+// Discord status machinery.
+type Source interface {
+	Start(ctx context.Context) error
+	Stop() error
+	GetState(ctx context.Context) (*State, error)
+}
+
+// Files is implemented by sources that can download client avatars and
+// channel icons.
+type Files interface {
+	// Avatar downloads the avatar image of an online client.
+	Avatar(ctx context.Context, user User) ([]byte, error)
+	// Icon downloads a server or channel icon by id.
+	Icon(ctx context.Context, id uint32) ([]byte, error)
+}
+
+// Poker is implemented by sources that can send a user a popup message.
+type Poker interface {
+	Poke(ctx context.Context, user User, msg string) error
+}
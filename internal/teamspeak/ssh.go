@@ -0,0 +1,32 @@
+package teamspeak
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshConfig returns the SSH client settings for ServerQuery over SSH. The host
+// key must match HostKeyFingerprint; without one every key is refused rather
+// than sending the password to an unverified server.
+func (s *service) sshConfig() *ssh.ClientConfig {
+	want := s.cfg.HostKeyFingerprint
+
+	return &ssh.ClientConfig{
+		User: s.cfg.Username,
+		Auth: []ssh.AuthMethod{ssh.Password(s.cfg.Password)},
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			got := ssh.FingerprintSHA256(key)
+
+			switch {
+			case want == "":
+				return fmt.Errorf("host key %s is not pinned; set host_key_fingerprint", got)
+			case got != want:
+				return fmt.Errorf("host key fingerprint %s does not match %s", got, want)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package teamspeak
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHConfig(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name        string
+		fingerprint string
+		wantErr     string
+	}{
+		{name: "unpinned rejects any key", wantErr: "not pinned"},
+		{name: "pinned key matches", fingerprint: ssh.FingerprintSHA256(key)},
+		{name: "pinned key differs", fingerprint: "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", wantErr: "does not match"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &service{cfg: Config{Username: "serveradmin", Password: "secret", HostKeyFingerprint: tc.fingerprint}}
+
+			cfg := s.sshConfig()
+			require.Equal(t, "serveradmin", cfg.User)
+			require.Len(t, cfg.Auth, 1)
+
+			err := cfg.HostKeyCallback("ts.example.com:10022", nil, key)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -10,6 +10,35 @@ type State struct {
 	Channels   []Channel
 	TotalUsers int
 	MaxClients int
+	FetchedAt  time.Time     // When the state was queried from the server
+	IconID     uint32        // Server icon (0 if none)
+	Subtitle   string        // Short status line, e.g. a game server's current map
+	Address    string        // Address clients connect to, from the server (empty if unknown)
+	Network    *NetworkStats // Server traffic, for sources that report it (nil otherwise)
+	// Label is the short name from the config telling this server apart in
+	// logs, metrics, alerts and the footer; empty when none is configured.
+	Label string
+
+	// Version increases by one each time the bridge fetches a state whose
+	// content differs from the previous one; Hash identifies that content.
+	// Both are zero for states that have not passed through the bridge.
+	Version uint64
+	Hash    string
+
+	// BannedUntil is set on a re-rendered earlier state while the bot is
+	// banned from ServerQuery, to when the ban is expected to expire.
+	BannedUntil time.Time
+
+	// Servers holds one section per server when several servers are
+	// aggregated; the fields above are then the combined totals.
+	Servers []*State
+}
+
+// NetworkStats is the server's traffic, averaged over the last minute.
+type NetworkStats struct {
+	BandwidthIn  float64 // Bytes per second received
+	BandwidthOut float64 // Bytes per second sent
+	PacketLoss   float64 // Average packet loss since the server started, 0-1
 }
 
 // Channel represents a TeamSpeak channel with its users.
@@ -19,17 +48,80 @@ type Channel struct {
 	ParentID int
 	Order    int
 	Users    []User
+
+	IsDefault       bool   // Channel new clients join by default
+	IsPermanent     bool   // Survives server restarts
+	IsSemiPermanent bool   // Survives until the server restarts
+	IconID          uint32 // Channel icon (0 if none)
+	Topic           string // One-line channel topic (empty if none)
+	NeededTalkPower int    // Talk power needed to speak; above zero the channel is moderated
+	Codec           string // Voice codec as the TeamSpeak client names it, e.g. "Opus Music" (empty if unknown)
+	CodecQuality    int    // Codec quality, 0-10
+}
+
+// IsModerated reports whether speaking in the channel needs talk power.
+func (c Channel) IsModerated() bool {
+	return c.NeededTalkPower > 0
+}
+
+// CanTalk reports whether the user may speak in channel ch: always in an
+// unmoderated channel, otherwise with enough talk power or when granted it.
+func (u User) CanTalk(ch Channel) bool {
+	return !ch.IsModerated() || u.IsTalker || u.TalkPower >= ch.NeededTalkPower
+}
+
+// IsTemporary reports whether the channel is deleted once it empties.
+func (c Channel) IsTemporary() bool {
+	return !c.IsPermanent && !c.IsSemiPermanent
 }
 
 // User represents a connected TeamSpeak client.
 type User struct {
-	ID          int
-	Nickname    string
-	ChannelID   int
-	InputMuted  bool          // Microphone muted
-	OutputMuted bool          // Speakers/headphones muted (deafened)
-	Away        bool          // Away status
-	AwayMessage string        // Away message
-	IdleTime    time.Duration // How long they've been idle
-	IsRecording bool          // Currently recording
+	ID              int
+	UniqueID        string // Client identity, stable across sessions
+	Nickname        string
+	ChannelID       int
+	InputMuted      bool          // Microphone muted
+	OutputMuted     bool          // Speakers/headphones muted (deafened)
+	Away            bool          // Away status
+	AwayMessage     string        // Away message
+	IdleTime        time.Duration // How long they've been idle
+	IsRecording     bool          // Currently recording
+	ConnectedAt     time.Time     // When the current session started (zero if unknown)
+	Country         string        // ISO 3166-1 alpha-2 code from the client's IP, e.g. "DE" (empty if unknown)
+	ServerGroups    []int         // Server group ids the client belongs to
+	TalkPower       int           // Client talk power
+	IsTalker        bool          // Granted talk power in a moderated channel
+	PrioritySpeaker bool          // Priority speaker: others are dimmed while they talk
+	Server          int           // Index of the user's server in an aggregated state
+}
+
+// LabelOrName returns Label, or ServerName when no label is configured.
+func (s *State) LabelOrName() string {
+	if s.Label != "" {
+		return s.Label
+	}
+
+	return s.ServerName
+}
+
+// Clone returns a deep copy of the state so it can be modified without
+// affecting other consumers.
+func (s *State) Clone() *State {
+	out := *s
+	out.Channels = make([]Channel, len(s.Channels))
+
+	for i, ch := range s.Channels {
+		ch.Users = append([]User(nil), ch.Users...)
+		out.Channels[i] = ch
+	}
+
+	if s.Servers != nil {
+		out.Servers = make([]*State, len(s.Servers))
+		for i, sv := range s.Servers {
+			out.Servers[i] = sv.Clone()
+		}
+	}
+
+	return &out
 }
@@ -3,75 +3,230 @@ package teamspeak
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ts3 "github.com/multiplay/go-ts3"
 	"github.com/sirupsen/logrus"
+
+	"github.com/samcm/ts-discord-status/internal/errreport"
+	"github.com/samcm/ts-discord-status/internal/filetransfer"
+	"github.com/samcm/ts-discord-status/internal/logsample"
+	"github.com/samcm/ts-discord-status/internal/metrics"
+	"github.com/samcm/ts-discord-status/internal/scheduler"
 )
 
 // Config holds TeamSpeak connection settings.
 type Config struct {
+	Name      string // Display name overriding the server's own name
+	Label     string // Short name telling this server apart in logs, metrics, alerts and the footer
 	Host      string
 	QueryPort int
 	Username  string
 	Password  string
 	ServerID  int
+
+	// SSH uses ServerQuery over SSH instead of the raw protocol.
+	SSH bool
+	// HostKeyFingerprint pins the SSH host key ("SHA256:..."); required
+	// with SSH, the connection is refused without it.
+	HostKeyFingerprint string
+
+	FileCacheDir string // Optional directory for downloaded avatars and icons
+
+	// KeepaliveInterval pings the server when the connection has been idle
+	// this long, reconnecting if the ping fails (0 disables).
+	KeepaliveInterval time.Duration
+
+	// LogSampler limits repeated warnings during outages; nil logs all.
+	LogSampler *logsample.Sampler
+}
+
+// codecNames are the channel codecs by ServerQuery number.
+var codecNames = []string{"Speex Narrowband", "Speex Wideband", "Speex Ultra-Wideband", "CELT Mono", "Opus Voice", "Opus Music"}
+
+// codecName returns the name of a channel codec, or "" for unknown codecs.
+func codecName(codec int) string {
+	if codec < 0 || codec >= len(codecNames) {
+		return ""
+	}
+
+	return codecNames[codec]
+}
+
+// channelEntry is a channellist row including the -flags extension, which the
+// go-ts3 Channel type does not decode.
+type channelEntry struct {
+	ID            int    `ms:"cid"`
+	ParentID      int    `ms:"pid"`
+	ChannelOrder  int    `ms:"channel_order"`
+	ChannelName   string `ms:"channel_name"`
+	FlagDefault   bool   `ms:"channel_flag_default"`
+	FlagPermanent bool   `ms:"channel_flag_permanent"`
+	FlagSemiPerm  bool   `ms:"channel_flag_semi_permanent"`
+	IconID        int    `ms:"channel_icon_id"`
+	Topic         string `ms:"channel_topic"`
+	NeededTalk    int    `ms:"channel_needed_talk_power"`
+	Codec         int    `ms:"channel_codec"`
+	CodecQuality  int    `ms:"channel_codec_quality"`
+}
+
+// serverEntry is a serverinfo response including the bind addresses, which the
+// go-ts3 Server type does not decode.
+type serverEntry struct {
+	ts3.Server `ms:",squash"`
+	IP         string `ms:"virtualserver_ip"` // Comma-separated bind addresses
+
+	BandwidthIn  float64 `ms:"connection_bandwidth_received_last_minute_total"` // Bytes per second
+	BandwidthOut float64 `ms:"connection_bandwidth_sent_last_minute_total"`     // Bytes per second
 }
 
 // Service defines the TeamSpeak service interface.
 type Service interface {
-	Start(ctx context.Context) error
-	Stop() error
-	GetState(ctx context.Context) (*State, error)
+	Source
+	Files
+	Poker
 }
 
+const (
+	// reconnectBaseDelay is the wait before the first background reconnect
+	// attempt, after the immediate retry in GetState has failed.
+	reconnectBaseDelay = 2 * time.Second
+	// reconnectMaxDelay caps the exponential backoff between attempts.
+	reconnectMaxDelay = 2 * time.Minute
+)
+
 type service struct {
 	log    logrus.FieldLogger
 	cfg    Config
 	client *ts3.Client
+	files  *filetransfer.Client
 	mu     sync.Mutex
+	used   time.Time // When the connection last answered a command
+	banned time.Time // Until when the server has banned the bot from ServerQuery
+
+	running      bool // Start succeeded and Stop has not been called since
+	scheduler    scheduler.Service
+	done         chan struct{}
+	wg           sync.WaitGroup
+	closing      atomic.Bool
+	reconnecting atomic.Bool
 }
 
 // NewService creates a new TeamSpeak service.
 func NewService(log logrus.FieldLogger, cfg Config) Service {
-	return &service{
-		log: log.WithField("component", "teamspeak"),
-		cfg: cfg,
+	s := &service{
+		log:  log.WithField("component", "teamspeak"),
+		cfg:  cfg,
+		done: make(chan struct{}),
+		files: filetransfer.New(filetransfer.Config{
+			Host:     cfg.Host,
+			CacheDir: cfg.FileCacheDir,
+		}),
+	}
+
+	s.scheduler = scheduler.New(s.log)
+
+	if cfg.KeepaliveInterval > 0 {
+		s.scheduler.Add(s.keepaliveTask())
 	}
+
+	return s
 }
 
-// Start connects to the TeamSpeak server.
+// Start connects to the TeamSpeak server. Starting a running service does
+// nothing; a stopped one starts over.
 func (s *service) Start(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.running {
+		return nil
+	}
+
+	// Stop closed done and ended every goroutine reading it.
+	if s.closing.Load() {
+		s.done = make(chan struct{})
+		s.closing.Store(false)
+	}
+
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.QueryPort)
-	s.log.WithField("address", addr).Info("Connecting to TeamSpeak server")
+	s.log.WithFields(logrus.Fields{"address": addr, "ssh": s.cfg.SSH}).Info("Connecting to TeamSpeak server")
 
-	client, err := ts3.NewClient(addr)
+	client, err := s.dial()
+
+	ban := banError(err, time.Now())
 	if err != nil {
+		metrics.Error(metrics.ErrorTSConnect)
+	}
+
+	if err != nil && ban == nil {
 		return fmt.Errorf("failed to connect to TeamSpeak: %w", err)
 	}
 
-	if err := client.Login(s.cfg.Username, s.cfg.Password); err != nil {
-		client.Close()
-		return fmt.Errorf("failed to authenticate: %w", err)
+	// Keepalives run until Stop rather than for the lifetime of ctx. They
+	// start before anything is kept, so a failure leaves no connection or
+	// reconnect loop behind.
+	if err := s.scheduler.Start(context.Background()); err != nil {
+		if client != nil {
+			client.Close()
+		}
+
+		return fmt.Errorf("failed to start keepalive: %w", err)
 	}
 
-	if err := client.Use(s.cfg.ServerID); err != nil {
-		client.Close()
-		return fmt.Errorf("failed to select virtual server %d: %w", s.cfg.ServerID, err)
+	if ban != nil {
+		// Exiting would only have a supervisor restart the bot into the same
+		// ban; start instead and connect once it has expired.
+		s.noteBan(ban)
+		s.startReconnect()
+	} else {
+		s.client = client
+		s.used = time.Now()
+		s.log.Info("Connected to TeamSpeak server")
 	}
 
-	s.client = client
-	s.log.Info("Connected to TeamSpeak server")
+	s.running = true
 
 	return nil
 }
 
-// Stop disconnects from the TeamSpeak server.
+// noteBan records a ServerQuery ban and drops the connection, so nothing is
+// sent until it expires. Must be called with s.mu held.
+func (s *service) noteBan(ban *BannedError) {
+	if !ban.Until.After(s.banned) {
+		return
+	}
+
+	s.banned = ban.Until
+	s.log.WithField("until", ban.Until.Format(time.RFC3339)).Warn("Banned from ServerQuery, pausing until the ban expires")
+
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+}
+
+// Stop disconnects from the TeamSpeak server and ends any reconnect loop.
+// Stopping a stopped service does nothing.
 func (s *service) Stop() error {
+	// Under s.mu so GetState cannot start a loop after the wait below.
+	s.mu.Lock()
+	s.running = false
+	if s.closing.CompareAndSwap(false, true) {
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	if err := s.scheduler.Stop(); err != nil {
+		s.log.WithError(err).Warn("Failed to stop scheduler")
+	}
+
+	s.wg.Wait()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -84,6 +239,35 @@ func (s *service) Stop() error {
 	return nil
 }
 
+// dial opens, authenticates and selects the virtual server on a new query
+// connection.
+func (s *service) dial() (*ts3.Client, error) {
+	var options []func(*ts3.Client) error
+	if s.cfg.SSH {
+		options = append(options, ts3.SSH(s.sshConfig()))
+	}
+
+	client, err := ts3.NewClient(fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.QueryPort), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Over SSH the query user is authenticated by the SSH handshake.
+	if !s.cfg.SSH {
+		if err := client.Login(s.cfg.Username, s.cfg.Password); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Use(s.cfg.ServerID); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to select virtual server %d: %w", s.cfg.ServerID, err)
+	}
+
+	return client, nil
+}
+
 // reconnect closes any existing connection and establishes a new one.
 // Must be called with s.mu held.
 func (s *service) reconnect() error {
@@ -95,50 +279,192 @@ func (s *service) reconnect() error {
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.QueryPort)
 	s.log.WithField("address", addr).Info("Reconnecting to TeamSpeak server")
 
-	client, err := ts3.NewClient(addr)
+	client, err := s.dial()
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return err
 	}
 
-	if err := client.Login(s.cfg.Username, s.cfg.Password); err != nil {
-		client.Close()
-		return fmt.Errorf("failed to authenticate: %w", err)
+	s.client = client
+	s.used = time.Now()
+	metrics.Reconnect(metrics.ReconnectTeamSpeak)
+	s.log.Info("Reconnected to TeamSpeak server")
+
+	return nil
+}
+
+// startReconnect launches the backoff reconnect loop unless one is already
+// running or the service is stopping. Must be called with s.mu held.
+func (s *service) startReconnect() {
+	if s.closing.Load() || !s.reconnecting.CompareAndSwap(false, true) {
+		return
 	}
 
-	if err := client.Use(s.cfg.ServerID); err != nil {
-		client.Close()
-		return fmt.Errorf("failed to select virtual server: %w", err)
+	s.wg.Add(1)
+
+	go s.reconnectLoop()
+}
+
+// reconnectLoop retries the connection with exponential backoff and jitter
+// until it succeeds or the service is stopping, so a restarting TeamSpeak
+// server is not hammered with logins every update.
+func (s *service) reconnectLoop() {
+	defer s.wg.Done()
+	defer s.reconnecting.Store(false)
+	defer errreport.Recover()
+
+	s.log.Warn("TeamSpeak connection lost, reconnecting with backoff")
+
+	delay := reconnectBaseDelay
+	wait := jitter(delay)
+
+	s.mu.Lock()
+	if until := time.Until(s.banned); until > 0 {
+		wait = until + jitter(delay)
 	}
+	s.mu.Unlock()
 
-	s.client = client
-	s.log.Info("Reconnected to TeamSpeak server")
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(wait):
+		}
 
-	return nil
+		client, err := s.dial()
+		if ban := banError(err, time.Now()); ban != nil {
+			metrics.Error(metrics.ErrorTSConnect)
+
+			s.mu.Lock()
+			s.noteBan(ban)
+			s.mu.Unlock()
+
+			// Retrying during the ban only extends it on some servers.
+			wait = time.Until(ban.Until) + jitter(reconnectBaseDelay)
+
+			continue
+		}
+
+		if err != nil {
+			metrics.Error(metrics.ErrorTSConnect)
+
+			delay = min(delay*2, reconnectMaxDelay)
+			wait = jitter(delay)
+
+			s.cfg.LogSampler.Warn(s.log.WithError(err).WithFields(logrus.Fields{
+				"attempt":  attempt,
+				"retry_in": wait.Round(time.Second),
+			}), "TeamSpeak reconnect attempt failed")
+
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closing.Load() {
+			s.mu.Unlock()
+			client.Close()
+
+			return
+		}
+
+		s.client = client
+		s.used = time.Now()
+		s.mu.Unlock()
+
+		metrics.Reconnect(metrics.ReconnectTeamSpeak)
+		s.log.WithField("attempts", attempt).Info("Reconnected to TeamSpeak server")
+
+		return
+	}
+}
+
+// jitter spreads d by up to ±20% so several bots reconnecting to the same
+// server do not retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
 }
 
 // GetState fetches the current state of the TeamSpeak server.
-// If the query fails, it attempts to reconnect and retry once.
+// If the query fails, it reconnects and retries once; if that fails too, the
+// reconnect loop takes over and later calls fail fast until it succeeds.
 func (s *service) GetState(ctx context.Context) (*State, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if time.Now().Before(s.banned) {
+		s.startReconnect()
+		return nil, &BannedError{Until: s.banned}
+	}
+
+	if s.client == nil {
+		s.startReconnect()
+		return nil, fmt.Errorf("not connected to TeamSpeak, reconnecting")
+	}
+
 	state, err := s.queryState()
-	if err != nil {
-		s.log.WithError(err).Warn("Query failed, attempting reconnect")
+	if ban := banError(err, time.Now()); ban != nil {
+		metrics.Error(metrics.ErrorTSQuery)
+		s.noteBan(ban)
+		s.startReconnect()
+
+		return nil, ban
+	}
+
+	if err == nil {
+		s.used = time.Now()
+	} else {
+		metrics.Error(metrics.ErrorTSQuery)
+		s.cfg.LogSampler.Warn(s.log.WithError(err), "Query failed, attempting reconnect")
 
 		if reconnErr := s.reconnect(); reconnErr != nil {
+			metrics.Error(metrics.ErrorTSConnect)
+
+			if ban := banError(reconnErr, time.Now()); ban != nil {
+				s.noteBan(ban)
+				s.startReconnect()
+
+				return nil, ban
+			}
+
+			s.startReconnect()
+
 			return nil, fmt.Errorf("reconnect failed: %w", reconnErr)
 		}
 
 		state, err = s.queryState()
+		if ban := banError(err, time.Now()); ban != nil {
+			metrics.Error(metrics.ErrorTSQuery)
+			s.noteBan(ban)
+			s.startReconnect()
+
+			return nil, ban
+		}
+
 		if err != nil {
+			metrics.Error(metrics.ErrorTSQuery)
 			return nil, fmt.Errorf("query failed after reconnect: %w", err)
 		}
+
+		s.used = time.Now()
 	}
 
 	return state, nil
 }
 
+// cleanName strips invisible characters from a display string, logging what
+// was removed at debug level.
+func (s *service) cleanName(kind, name string) string {
+	cleaned, removed := sanitizeName(name)
+	if len(removed) > 0 {
+		s.log.WithFields(logrus.Fields{
+			"kind":    kind,
+			"name":    cleaned,
+			"removed": formatRunes(removed),
+		}).Debug("Stripped invisible characters")
+	}
+
+	return cleaned
+}
+
 // queryState performs the actual TeamSpeak queries.
 // Must be called with s.mu held.
 func (s *service) queryState() (*State, error) {
@@ -147,19 +473,19 @@ func (s *service) queryState() (*State, error) {
 	}
 
 	// Get server info
-	server, err := s.client.Server.Info()
-	if err != nil {
+	server := &serverEntry{}
+	if _, err := s.client.ExecCmd(ts3.NewCmd("serverinfo").WithResponse(&server)); err != nil {
 		return nil, fmt.Errorf("failed to get server info: %w", err)
 	}
 
-	// Get channels
-	channels, err := s.client.Server.ChannelList()
-	if err != nil {
+	// Get channels with their flags
+	var channels []*channelEntry
+	if _, err := s.client.ExecCmd(ts3.NewCmd("channellist").WithOptions("-flags", "-icon", "-topic", "-voice").WithResponse(&channels)); err != nil {
 		return nil, fmt.Errorf("failed to get channel list: %w", err)
 	}
 
 	// Get clients with extended info (voice, times, away status)
-	clients, err := s.client.Server.ClientList(ts3.ClientVoice, ts3.ClientTimes, ts3.ClientAway)
+	clients, err := s.client.Server.ClientList(ts3.ClientUID, ts3.ClientVoice, ts3.ClientTimes, ts3.ClientAway, ts3.ClientCountry, ts3.ClientGroups)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client list: %w", err)
 	}
@@ -170,11 +496,19 @@ func (s *service) queryState() (*State, error) {
 
 	for _, ch := range channels {
 		channel := Channel{
-			ID:       ch.ID,
-			Name:     ch.ChannelName,
-			ParentID: ch.ParentID,
-			Order:    ch.ChannelOrder,
-			Users:    make([]User, 0),
+			ID:              ch.ID,
+			Name:            s.cleanName("channel", ch.ChannelName),
+			ParentID:        ch.ParentID,
+			Order:           ch.ChannelOrder,
+			Users:           make([]User, 0),
+			IsDefault:       ch.FlagDefault,
+			IsPermanent:     ch.FlagPermanent,
+			IsSemiPermanent: ch.FlagSemiPerm,
+			IconID:          uint32(ch.IconID),
+			Topic:           s.cleanName("topic", ch.Topic),
+			NeededTalkPower: ch.NeededTalk,
+			Codec:           codecName(ch.Codec),
+			CodecQuality:    ch.CodecQuality,
 		}
 		channelMap[ch.ID] = &channel
 		stateChannels = append(stateChannels, channel)
@@ -190,12 +524,24 @@ func (s *service) queryState() (*State, error) {
 
 		user := User{
 			ID:          cl.ID,
-			Nickname:    cl.Nickname,
+			Nickname:    s.cleanName("nickname", cl.Nickname),
 			ChannelID:   cl.ChannelID,
 			Away:        cl.Away,
 			AwayMessage: cl.AwayMessage,
 		}
 
+		if cl.OnlineClientExt != nil && cl.UniqueIdentifier != nil {
+			user.UniqueID = *cl.UniqueIdentifier
+		}
+
+		if cl.OnlineClientExt != nil && cl.Country != nil {
+			user.Country = strings.ToUpper(*cl.Country)
+		}
+
+		if cl.OnlineClientExt != nil && cl.OnlineClientGroups != nil && cl.ServerGroups != nil {
+			user.ServerGroups = *cl.ServerGroups
+		}
+
 		// Populate voice status (if available)
 		if cl.OnlineClientVoice != nil {
 			if cl.InputMuted != nil {
@@ -207,11 +553,25 @@ func (s *service) queryState() (*State, error) {
 			if cl.IsRecording != nil {
 				user.IsRecording = *cl.IsRecording
 			}
+			if cl.TalkPower != nil {
+				user.TalkPower = *cl.TalkPower
+			}
+			if cl.IsTalker != nil {
+				user.IsTalker = *cl.IsTalker
+			}
+			if cl.IsPrioritySpeaker != nil {
+				user.PrioritySpeaker = *cl.IsPrioritySpeaker
+			}
 		}
 
 		// Populate time info (if available)
-		if cl.OnlineClientTimes != nil && cl.IdleTime != nil {
-			user.IdleTime = time.Duration(*cl.IdleTime) * time.Millisecond
+		if cl.OnlineClientTimes != nil {
+			if cl.IdleTime != nil {
+				user.IdleTime = time.Duration(*cl.IdleTime) * time.Millisecond
+			}
+			if cl.LastConnected != nil && *cl.LastConnected > 0 {
+				user.ConnectedAt = time.Unix(int64(*cl.LastConnected), 0)
+			}
 		}
 
 		if ch, ok := channelMap[cl.ChannelID]; ok {
@@ -228,12 +588,26 @@ func (s *service) queryState() (*State, error) {
 		}
 	}
 
+	name := server.Name
+	if s.cfg.Name != "" {
+		name = s.cfg.Name
+	}
+
 	state := &State{
-		ServerName: server.Name,
+		ServerName: name,
+		Label:      s.cfg.Label,
 		Uptime:     time.Duration(server.Uptime) * time.Second,
 		Channels:   stateChannels,
 		TotalUsers: totalUsers,
 		MaxClients: server.MaxClients,
+		FetchedAt:  time.Now(),
+		IconID:     uint32(server.IconID),
+		Address:    connectAddress(server.IP, s.cfg.Host, server.Port),
+		Network: &NetworkStats{
+			BandwidthIn:  server.BandwidthIn,
+			BandwidthOut: server.BandwidthOut,
+			PacketLoss:   server.TotalPacketLossTotal,
+		},
 	}
 
 	return state, nil
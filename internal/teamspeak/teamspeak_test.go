@@ -0,0 +1,33 @@
+package teamspeak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectAddress(t *testing.T) {
+	require.Equal(t, "ts.example.com", connectAddress("0.0.0.0,::", "ts.example.com", 9987))
+	require.Equal(t, "ts.example.com:9988", connectAddress("0.0.0.0", "ts.example.com", 9988))
+	require.Equal(t, "203.0.113.7:9988", connectAddress("0.0.0.0, 203.0.113.7", "127.0.0.1", 9988))
+	require.Equal(t, "[2001:db8::1]", connectAddress("2001:db8::1", "localhost", 9987))
+	require.Empty(t, connectAddress("0.0.0.0", "127.0.0.1", 9987))
+	require.Empty(t, connectAddress("", "teamspeak", 9987))
+	require.Empty(t, connectAddress("10.0.0.5", "192.168.1.2", 9987))
+}
+
+func TestJoinURI(t *testing.T) {
+	require.Equal(t, "ts3server://ts.example.com", JoinURI("ts.example.com", ""))
+	require.Equal(t, "ts3server://203.0.113.7?password=a+b%26c&port=9988", JoinURI("203.0.113.7:9988", "a b&c"))
+	require.Equal(t, "ts3server://[2001:db8::1]", JoinURI("[2001:db8::1]", ""))
+	require.Empty(t, JoinURI("", "secret"))
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		d := jitter(10 * time.Second)
+		require.GreaterOrEqual(t, d, 8*time.Second)
+		require.LessOrEqual(t, d, 12*time.Second)
+	}
+}
@@ -0,0 +1,230 @@
+package teamspeaktest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Query error trailers for Fail, as the server words them.
+const (
+	ErrFlooding = "error id=524 msg=client\\sis\\sflooding extra_msg=please\\swait\\s10\\sseconds"
+	ErrBanned   = "error id=3329 msg=connection\\sfailed,\\syou\\sare\\sbanned extra_msg=you\\smay\\sretry\\sin\\s600\\sseconds"
+	ErrNotFound = "error id=256 msg=command\\snot\\sfound"
+)
+
+const okTrailer = "error id=0 msg=ok"
+
+// QueryServer is an in-process ServerQuery server for tests. It answers each
+// command by its name (the first word) from canned responses, so the service
+// can be driven through reconnects and errors without a real server. login,
+// use and quit succeed unless told otherwise; other unknown commands fail as
+// on a real server.
+type QueryServer struct {
+	Host string // Always 127.0.0.1
+	Port int
+
+	ln net.Listener
+
+	mu          sync.Mutex
+	responses   map[string][]string // Reply lines by command name, trailer last
+	received    []string            // Commands received, in order
+	connections int                 // Connections accepted so far
+	conns       map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+// NewQueryServer starts a server on a free local port, closed when the test
+// ends.
+func NewQueryServer(t testing.TB) *QueryServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &QueryServer{
+		Host: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+		ln:   ln,
+		responses: map[string][]string{
+			"login": {okTrailer},
+			"use":   {okTrailer},
+			"quit":  {okTrailer},
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+
+	go s.accept()
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Handle answers command with the given data lines followed by a success
+// trailer.
+func (s *QueryServer) Handle(command string, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[command] = append(append([]string{}, lines...), okTrailer)
+}
+
+// Fail answers command with an error trailer such as ErrFlooding.
+func (s *QueryServer) Fail(command, trailer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[command] = []string{trailer}
+}
+
+// Replay registers the responses of a transcript: lines starting with "> "
+// are commands, and the "< " lines after each are its reply, trailer
+// included. Other lines are ignored, so transcripts can carry comments.
+//
+//	> serverinfo
+//	< virtualserver_name=Test virtualserver_maxclients=32
+//	< error id=0 msg=ok
+func (s *QueryServer) Replay(transcript string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	command := ""
+
+	for i, line := range strings.Split(transcript, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch {
+		case strings.HasPrefix(line, "> "):
+			command = commandName(line[2:])
+			s.responses[command] = nil
+		case strings.HasPrefix(line, "< "):
+			if command == "" {
+				return fmt.Errorf("line %d: reply before any command", i+1)
+			}
+
+			s.responses[command] = append(s.responses[command], line[2:])
+		}
+	}
+
+	for command, reply := range s.responses {
+		if len(reply) == 0 || !strings.HasPrefix(reply[len(reply)-1], "error ") {
+			return fmt.Errorf("reply to %s does not end in an error trailer", command)
+		}
+	}
+
+	return nil
+}
+
+// Received returns the commands received so far, in order.
+func (s *QueryServer) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.received...)
+}
+
+// Connections returns how many connections have been accepted.
+func (s *QueryServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connections
+}
+
+// Drop closes every open connection, like a server restart, while still
+// accepting new ones.
+func (s *QueryServer) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and closes every connection.
+func (s *QueryServer) Close() {
+	s.ln.Close()
+	s.Drop()
+	s.wg.Wait()
+}
+
+func (s *QueryServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.connections++
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+
+		go s.serve(conn)
+	}
+}
+
+// serve speaks the raw query protocol on one connection: the TS3 header and
+// banner, then one reply per command line.
+func (s *QueryServer) serve(conn net.Conn) {
+	defer s.wg.Done()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		conn.Close()
+	}()
+
+	if _, err := fmt.Fprint(conn, "TS3\n\rWelcome to the TeamSpeak 3 ServerQuery interface.\n\r"); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue // Keepalive
+		}
+
+		s.mu.Lock()
+		s.received = append(s.received, line)
+
+		reply, ok := s.responses[commandName(line)]
+		if !ok {
+			reply = []string{ErrNotFound}
+		}
+		s.mu.Unlock()
+
+		for _, l := range reply {
+			if _, err := fmt.Fprint(conn, l+"\n\r"); err != nil {
+				return
+			}
+		}
+
+		if commandName(line) == "quit" {
+			return
+		}
+	}
+}
+
+func commandName(line string) string {
+	name, _, _ := strings.Cut(line, " ")
+
+	return name
+}
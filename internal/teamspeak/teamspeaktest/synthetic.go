@@ -0,0 +1,68 @@
+// Package teamspeaktest provides helpers for TeamSpeak tests and benchmarks:
+// synthetic states, and a fake ServerQuery server replaying canned replies.
+package teamspeaktest
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/samcm/ts-discord-status/internal/teamspeak"
+)
+
+// SyntheticState builds a deterministic state with the given number of
+// channels and users. Users are spread unevenly across channels, roughly half
+// the channels stay empty, and statuses (muted, away, idle, recording) are
+// mixed in so renderers exercise every branch.
+func SyntheticState(channels, users int) *teamspeak.State {
+	rng := rand.New(rand.NewSource(int64(channels)*7919 + int64(users)))
+	now := time.Now()
+
+	state := &teamspeak.State{
+		ServerName: fmt.Sprintf("Synthetic %dc/%du", channels, users),
+		Uptime:     72 * time.Hour,
+		Channels:   make([]teamspeak.Channel, channels),
+		TotalUsers: users,
+		MaxClients: users + users/4 + 1,
+		FetchedAt:  now,
+	}
+
+	for i := range state.Channels {
+		state.Channels[i] = teamspeak.Channel{
+			ID:          i + 1,
+			Name:        fmt.Sprintf("Channel %03d", i+1),
+			Order:       i,
+			IsPermanent: i%5 != 4,
+		}
+	}
+
+	if channels == 0 {
+		return state
+	}
+
+	populated := channels/2 + 1
+	if populated > channels {
+		populated = channels
+	}
+
+	for i := 0; i < users; i++ {
+		// Skew towards the first channels like a real server's lobby.
+		idx := int(float64(populated) * rng.Float64() * rng.Float64())
+		ch := &state.Channels[idx]
+
+		ch.Users = append(ch.Users, teamspeak.User{
+			ID:          i + 1,
+			Nickname:    fmt.Sprintf("User-%04d", i+1),
+			ChannelID:   ch.ID,
+			InputMuted:  rng.Intn(4) == 0,
+			OutputMuted: rng.Intn(10) == 0,
+			Away:        rng.Intn(8) == 0,
+			AwayMessage: "brb",
+			IdleTime:    time.Duration(rng.Intn(120)) * time.Minute,
+			IsRecording: rng.Intn(50) == 0,
+			ConnectedAt: now.Add(-time.Duration(rng.Intn(600)) * time.Minute),
+		})
+	}
+
+	return state
+}
@@ -0,0 +1,46 @@
+package teamspeak
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ContentHash returns a short hash of what the state shows. Values that
+// change on every fetch without anything visible happening (fetch time,
+// uptime, idle times, traffic) and the version fields themselves are left out, so
+// two fetches of an unchanged server hash the same.
+func (s *State) ContentHash() string {
+	out := s.Clone()
+	stable(out)
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// stable clears the volatile fields of a cloned state.
+func stable(s *State) {
+	s.FetchedAt = time.Time{}
+	s.Uptime = 0
+	s.Network = nil
+	s.Version = 0
+	s.Hash = ""
+	s.BannedUntil = time.Time{}
+
+	for i := range s.Channels {
+		for j := range s.Channels[i].Users {
+			s.Channels[i].Users[j].IdleTime = 0
+		}
+	}
+
+	for _, sv := range s.Servers {
+		stable(sv)
+	}
+}
@@ -0,0 +1,32 @@
+package teamspeak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentHash(t *testing.T) {
+	state := &State{
+		ServerName: "Game Night",
+		Uptime:     time.Hour,
+		FetchedAt:  time.Now(),
+		Channels:   []Channel{{Name: "Lobby", Users: []User{{Nickname: "alice", IdleTime: time.Minute}}}},
+		TotalUsers: 1,
+	}
+
+	hash := state.ContentHash()
+	require.Len(t, hash, 16)
+
+	later := state.Clone()
+	later.Uptime = 2 * time.Hour
+	later.FetchedAt = later.FetchedAt.Add(time.Minute)
+	later.Channels[0].Users[0].IdleTime = 2 * time.Minute
+	later.Version, later.Hash = 3, hash
+	require.Equal(t, hash, later.ContentHash())
+
+	later.Channels[0].Users[0].Away = true
+	require.NotEqual(t, hash, later.ContentHash())
+	require.Equal(t, time.Minute, state.Channels[0].Users[0].IdleTime)
+}